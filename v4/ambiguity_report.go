@@ -0,0 +1,416 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ambiguityPathSearchLimit bounds the number of ATN states visited while
+// searching for the path of a single ambiguous alternative.
+const ambiguityPathSearchLimit = 100000
+
+// The kinds of AmbiguityPathStep.
+const (
+	AmbiguityStepEnter = "enter"
+	AmbiguityStepExit  = "exit"
+	AmbiguityStepMatch = "match"
+)
+
+// AmbiguityPathStep is a step of the path an ambiguous alternative takes
+// through the ATN: entering a rule, returning from one, or matching a
+// token.
+type AmbiguityPathStep struct {
+	// Kind is AmbiguityStepEnter, AmbiguityStepExit or AmbiguityStepMatch.
+	Kind string `json:"kind"`
+
+	// Rule is the rule entered or exited.
+	Rule string `json:"rule,omitempty"`
+
+	// Token is the display name of the token type matched, and Text the
+	// text of the token.
+	Token string `json:"token,omitempty"`
+	Text  string `json:"text,omitempty"`
+
+	// State is the number of the ATN state the step leads to.
+	State int `json:"state"`
+}
+
+func (s *AmbiguityPathStep) String() string {
+	switch s.Kind {
+	case AmbiguityStepMatch:
+		return s.Token + " " + strconv.Quote(s.Text)
+	default:
+		return s.Kind + " " + s.Rule
+	}
+}
+
+// AmbiguousAlt is one of the alternatives of an ambiguous decision that
+// match the ambiguous input.
+type AmbiguousAlt struct {
+	// Alt is the alternative number, starting at 1.
+	Alt int `json:"alt"`
+
+	// Path is a path through the ATN from the decision, through this
+	// alternative, that matches the ambiguous input, or nil if none was
+	// found. Semantic and precedence predicates are assumed to be true.
+	Path []*AmbiguityPathStep `json:"path"`
+}
+
+// AmbiguityReport describes an ambiguity: a decision where more than one
+// alternative matches the same input.
+type AmbiguityReport struct {
+	// Decision is the decision number, and Rule the name of the rule
+	// containing it.
+	Decision int    `json:"decision"`
+	Rule     string `json:"rule"`
+
+	// RuleStack is the rule invocation stack at the decision, innermost
+	// rule first.
+	RuleStack []string `json:"ruleStack"`
+
+	// Exact is true if the ambiguity is exact, i.e. the alternatives are
+	// known to be the only ones matching the input; see
+	// PredictionModeLLExactAmbigDetection.
+	Exact bool `json:"exact"`
+
+	// StartIndex and StopIndex are the token indexes of the first and last
+	// tokens of the ambiguous input, and Input is its text.
+	StartIndex int    `json:"startIndex"`
+	StopIndex  int    `json:"stopIndex"`
+	Input      string `json:"input"`
+
+	// StartLine, StartColumn, StopLine and StopColumn are the positions of
+	// the first and last tokens of the ambiguous input.
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	StopLine    int `json:"stopLine"`
+	StopColumn  int `json:"stopColumn"`
+
+	// Alts are the ambiguous alternatives.
+	Alts []*AmbiguousAlt `json:"alts"`
+}
+
+func (r *AmbiguityReport) String() string {
+	var b strings.Builder
+
+	kind := "ambiguity"
+	if r.Exact {
+		kind = "exact ambiguity"
+	}
+	fmt.Fprintf(&b, "%s in decision %d (%s) at %d:%d-%d:%d, input %s\n",
+		kind, r.Decision, r.Rule, r.StartLine, r.StartColumn, r.StopLine, r.StopColumn, strconv.Quote(r.Input))
+	fmt.Fprintf(&b, "  rule stack: %s\n", strings.Join(r.RuleStack, " < "))
+	for _, alt := range r.Alts {
+		fmt.Fprintf(&b, "  alt %d: ", alt.Alt)
+		if alt.Path == nil {
+			b.WriteString("(no path found)\n")
+			continue
+		}
+		for i, step := range alt.Path {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(step.String())
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// AmbiguityReporter is an ErrorListener that builds an AmbiguityReport for
+// every ambiguity reported by a parser, to help grammar authors find out
+// which alternatives of a decision collide and why.
+//
+// <p>Only full context prediction reports ambiguities, and only
+// PredictionModeLLExactAmbigDetection reports exact ones;
+// ReportAmbiguities sets up a parser for that.</p>
+type AmbiguityReporter struct {
+	*DefaultErrorListener
+
+	exactOnly bool
+	reports   []*AmbiguityReport
+}
+
+// NewAmbiguityReporter creates an AmbiguityReporter. If exactOnly is true,
+// it ignores the ambiguities that are not exact.
+func NewAmbiguityReporter(exactOnly bool) *AmbiguityReporter {
+	return &AmbiguityReporter{
+		DefaultErrorListener: NewDefaultErrorListener(),
+		exactOnly:            exactOnly,
+	}
+}
+
+// ReportAmbiguities switches parser to PredictionModeLLExactAmbigDetection
+// and adds an AmbiguityReporter for exact ambiguities to it, which it
+// returns. Exact ambiguity detection makes parsing slower; use it while
+// working on a grammar, not in production.
+func ReportAmbiguities(parser Parser) *AmbiguityReporter {
+	reporter := NewAmbiguityReporter(true)
+	parser.GetInterpreter().SetPredictionMode(PredictionModeLLExactAmbigDetection)
+	parser.AddErrorListener(reporter)
+
+	return reporter
+}
+
+// GetReports returns the reports of the ambiguities reported so far, in the
+// order they were reported.
+func (a *AmbiguityReporter) GetReports() []*AmbiguityReport {
+	return a.reports
+}
+
+// WriteText writes the reports as text, in the format of
+// AmbiguityReport.String.
+func (a *AmbiguityReporter) WriteText(w io.Writer) error {
+	for _, r := range a.reports {
+		if _, err := io.WriteString(w, r.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the reports as a JSON array.
+func (a *AmbiguityReporter) WriteJSON(w io.Writer) error {
+	reports := a.reports
+	if reports == nil {
+		reports = []*AmbiguityReport{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+func (a *AmbiguityReporter) ReportAmbiguity(recognizer Parser, dfa *DFA, startIndex, stopIndex int, exact bool, ambigAlts *BitSet, configs ATNConfigSet) {
+	if a.exactOnly && !exact {
+		return
+	}
+
+	a.reports = append(a.reports, newAmbiguityReport(recognizer, dfa, startIndex, stopIndex, exact, ambigAlts, configs))
+}
+
+func newAmbiguityReport(recognizer Parser, dfa *DFA, startIndex, stopIndex int, exact bool, ambigAlts *BitSet, configs ATNConfigSet) *AmbiguityReport {
+	input := recognizer.GetTokenStream()
+	ruleNames := recognizer.GetRuleNames()
+	ctx := recognizer.GetParserRuleContext()
+
+	r := &AmbiguityReport{
+		Decision:   dfa.decision,
+		Rule:       ambiguityRuleName(ruleNames, dfa.atnStartState.GetRuleIndex()),
+		RuleStack:  recognizer.GetRuleInvocationStack(ctx),
+		Exact:      exact,
+		StartIndex: startIndex,
+		StopIndex:  stopIndex,
+		Input:      input.GetTextFromInterval(NewInterval(startIndex, stopIndex)),
+	}
+	if start := input.Get(startIndex); start != nil {
+		r.StartLine, r.StartColumn = start.GetLine(), start.GetColumn()
+	}
+	if stop := input.Get(stopIndex); stop != nil {
+		r.StopLine, r.StopColumn = stop.GetLine(), stop.GetColumn()
+	}
+
+	if ambigAlts == nil {
+		ambigAlts = NewBitSet()
+		for _, c := range configs.GetItems() {
+			ambigAlts.add(c.GetAlt())
+		}
+	}
+
+	finder := newAmbiguityPathFinder(recognizer, startIndex, stopIndex)
+	stack := finder.outerStack(ctx)
+	for _, alt := range ambigAlts.values() {
+		r.Alts = append(r.Alts, &AmbiguousAlt{
+			Alt:  alt,
+			Path: finder.find(dfa.atnStartState, alt, stack),
+		})
+	}
+
+	return r
+}
+
+func ambiguityRuleName(ruleNames []string, ruleIndex int) string {
+	if ruleIndex < 0 || ruleIndex >= len(ruleNames) || ruleNames[ruleIndex] == "" {
+		return strconv.Itoa(ruleIndex)
+	}
+
+	return ruleNames[ruleIndex]
+}
+
+// ambiguityPathFinder searches the ATN for the paths of the alternatives
+// of an ambiguous decision that match the ambiguous input.
+type ambiguityPathFinder struct {
+	parser Parser
+	atn    *ATN
+	tokens []Token
+
+	visited map[string]bool
+	budget  int
+}
+
+func newAmbiguityPathFinder(parser Parser, startIndex, stopIndex int) *ambiguityPathFinder {
+	f := &ambiguityPathFinder{
+		parser: parser,
+		atn:    parser.GetInterpreter().ATN(),
+	}
+
+	input := parser.GetTokenStream()
+	channel := TokenDefaultChannel
+	if s, ok := input.(*CommonTokenStream); ok {
+		channel = s.channel
+	}
+	for i := startIndex; i <= stopIndex; i++ {
+		if t := input.Get(i); t != nil && t.GetChannel() == channel {
+			f.tokens = append(f.tokens, t)
+		}
+	}
+
+	return f
+}
+
+// outerStack returns the follow states of the rule invocations of ctx,
+// outermost first.
+func (f *ambiguityPathFinder) outerStack(ctx ParserRuleContext) []ATNState {
+	var stack []ATNState
+	for ctx != nil && ctx.GetInvokingState() >= 0 {
+		invoking := f.atn.states[ctx.GetInvokingState()]
+		if t, ok := invoking.GetTransitions()[0].(*RuleTransition); ok {
+			stack = append([]ATNState{t.followState}, stack...)
+		}
+
+		parent, _ := ctx.GetParent().(ParserRuleContext)
+		ctx = parent
+	}
+
+	return stack
+}
+
+// find returns a path from decision through alternative alt that matches
+// f.tokens, starting with the rule invocation stack stack.
+func (f *ambiguityPathFinder) find(decision ATNState, alt int, stack []ATNState) []*AmbiguityPathStep {
+	transitions := decision.GetTransitions()
+	if alt < 1 || alt > len(transitions) {
+		return nil
+	}
+
+	f.visited = make(map[string]bool)
+	f.budget = ambiguityPathSearchLimit
+	return f.walk(transitions[alt-1], 0, stack, []*AmbiguityPathStep{})
+}
+
+func (f *ambiguityPathFinder) visit(s ATNState, i int, stack []ATNState, path []*AmbiguityPathStep) []*AmbiguityPathStep {
+	if i == len(f.tokens) {
+		return path
+	}
+
+	f.budget--
+	if f.budget < 0 {
+		return nil
+	}
+
+	key := f.key(s, i, stack)
+	if f.visited[key] {
+		return nil
+	}
+	f.visited[key] = true
+
+	if _, ok := s.(*RuleStopState); ok {
+		if len(stack) == 0 {
+			return nil // only EOF follows the start rule
+		}
+
+		follow := stack[len(stack)-1]
+		step := &AmbiguityPathStep{
+			Kind:  AmbiguityStepExit,
+			Rule:  ambiguityRuleName(f.parser.GetRuleNames(), s.GetRuleIndex()),
+			State: follow.GetStateNumber(),
+		}
+		return f.visit(follow, i, stack[:len(stack)-1], appendAmbiguityStep(path, step))
+	}
+
+	for _, t := range s.GetTransitions() {
+		if result := f.walk(t, i, stack, path); result != nil {
+			return result
+		}
+	}
+
+	return nil
+}
+
+func (f *ambiguityPathFinder) walk(t Transition, i int, stack []ATNState, path []*AmbiguityPathStep) []*AmbiguityPathStep {
+	if i == len(f.tokens) {
+		return path
+	}
+
+	target := t.getTarget()
+
+	switch t := t.(type) {
+	case *RuleTransition:
+		step := &AmbiguityPathStep{
+			Kind:  AmbiguityStepEnter,
+			Rule:  ambiguityRuleName(f.parser.GetRuleNames(), target.GetRuleIndex()),
+			State: target.GetStateNumber(),
+		}
+		stack = append(stack[:len(stack):len(stack)], t.followState)
+		return f.visit(target, i, stack, appendAmbiguityStep(path, step))
+	}
+
+	if t.getIsEpsilon() {
+		return f.visit(target, i, stack, path)
+	}
+
+	token := f.tokens[i]
+	if !t.Matches(token.GetTokenType(), TokenMinUserTokenType, f.atn.maxTokenType) {
+		return nil
+	}
+
+	step := &AmbiguityPathStep{
+		Kind:  AmbiguityStepMatch,
+		Token: f.tokenName(token.GetTokenType()),
+		Text:  token.GetText(),
+		State: target.GetStateNumber(),
+	}
+	return f.visit(target, i+1, stack, appendAmbiguityStep(path, step))
+}
+
+func (f *ambiguityPathFinder) key(s ATNState, i int, stack []ATNState) string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(s.GetStateNumber()))
+	b.WriteString(":")
+	b.WriteString(strconv.Itoa(i))
+	for _, follow := range stack {
+		b.WriteString(",")
+		b.WriteString(strconv.Itoa(follow.GetStateNumber()))
+	}
+
+	return b.String()
+}
+
+func (f *ambiguityPathFinder) tokenName(ttype int) string {
+	if ttype == TokenEOF {
+		return "EOF"
+	}
+	if names := f.parser.GetSymbolicNames(); ttype < len(names) && names[ttype] != "" {
+		return names[ttype]
+	}
+	if names := f.parser.GetLiteralNames(); ttype < len(names) && names[ttype] != "" {
+		return names[ttype]
+	}
+
+	return strconv.Itoa(ttype)
+}
+
+// appendAmbiguityStep returns a copy of path with step appended, leaving
+// path unchanged for the other branches of the search.
+func appendAmbiguityStep(path []*AmbiguityPathStep, step *AmbiguityPathStep) []*AmbiguityPathStep {
+	return append(path[:len(path):len(path)], step)
+}
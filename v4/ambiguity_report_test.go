@@ -0,0 +1,137 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestAmbiguityReporter(t *testing.T) {
+	parser := newAmbParser(ambID, ambID)
+	reporter := ReportAmbiguities(parser)
+	parser.Parse(0)
+
+	var text bytes.Buffer
+	if err := reporter.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	expected := `exact ambiguity in decision 0 (x) at 1:0-1:3, input "ab"
+  rule stack: x < s
+  alt 1: enter y, ID "a", exit y, ID "b"
+  alt 2: ID "a", enter z, ID "b"
+`
+	if got := text.String(); got != expected {
+		t.Errorf("expected report\n%s\ngot\n%s", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := reporter.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var reports []*AmbiguityReport
+	if err := json.Unmarshal(buf.Bytes(), &reports); err != nil {
+		t.Fatalf("invalid JSON %s: %v", buf.String(), err)
+	}
+	if len(reports) != 1 || reports[0].String() != expected {
+		t.Errorf("expected JSON to round-trip to the report, got %s", buf.String())
+	}
+}
+
+func TestAmbiguityReporterNested(t *testing.T) {
+	parser := newCtxParser(ctxBang, ctxID)
+	reporter := NewAmbiguityReporter(false)
+	parser.AddErrorListener(reporter)
+	parser.GetInterpreter().SetPredictionMode(PredictionModeLLExactAmbigDetection)
+	parser.Parse(0)
+
+	reports := reporter.GetReports()
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 ambiguity, got %d", len(reports))
+	}
+
+	r := reports[0]
+	if r.Decision != ctxDecisionC || r.Rule != "c" || !r.Exact || r.StartIndex != 1 || r.StopIndex != 1 {
+		t.Errorf("unexpected report %+v", r)
+	}
+	if len(r.Alts) != 2 || r.Alts[0].Alt != 1 || r.Alts[1].Alt != 2 {
+		t.Fatalf("expected alts 1 and 2, got %+v", r.Alts)
+	}
+	for _, alt := range r.Alts {
+		if len(alt.Path) != 1 || alt.Path[0].Kind != AmbiguityStepMatch || alt.Path[0].Token != "ID" {
+			t.Errorf("alt %d: expected to match ID, got %v", alt.Alt, alt.Path)
+		}
+	}
+}
+
+func TestAmbiguityReporterExactOnly(t *testing.T) {
+	parser := newCtxParser(ctxBang, ctxID)
+	reporter := NewAmbiguityReporter(true)
+	parser.AddErrorListener(reporter)
+	parser.Parse(0)
+
+	// LL prediction stops at the first conflict, which is not exact
+	if n := len(reporter.GetReports()); n != 0 {
+		t.Errorf("expected no exact ambiguities, got %d", n)
+	}
+
+	var buf bytes.Buffer
+	if err := reporter.WriteJSON(&buf); err != nil || buf.String() != "[]\n" {
+		t.Errorf("expected an empty JSON array, got %q, %v", buf.String(), err)
+	}
+}
+
+func newAmbParser(types ...int) *ParserInterpreter {
+	var tokens []Token
+	column := 0
+	for i, ttype := range append(types, TokenEOF) {
+		token := NewCommonToken(nil, ttype, TokenDefaultChannel, -1, -1)
+		token.SetText(string(rune('a' + i)))
+		if ttype == TokenEOF {
+			token.SetText("<EOF>")
+		}
+		token.line = 1
+		token.column = column
+		column += 3
+		tokens = append(tokens, token)
+	}
+
+	stream := NewCommonTokenStream(NewListTokenSource(tokens, "Amb"), TokenDefaultChannel)
+	parser := NewParserInterpreter("Amb.g4", nil, ambSymbolicNames, ambRuleNames, ambParserATN, stream)
+	parser.RemoveErrorListeners()
+
+	return parser
+}
+
+// The fixture below is the serialized ATN of the following grammar, in
+// which the two alternatives of x both match two IDs:
+//
+//	grammar Amb;
+//	s : x EOF ;
+//	x : y ID | ID z ;
+//	y : ID ;
+//	z : ID ;
+
+const ambID = 1
+
+var ambSymbolicNames = []string{"", "ID"}
+
+var ambRuleNames = []string{"s", "x", "y", "z"}
+
+var ambSerializedParserATN = []uint16{
+	3, 24715, 42794, 33075, 47597, 16764, 15335, 30598, 22884, 3, 3, 28, 4, 2, 9, 2, 4, 3, 9, 3, 4, 4,
+	9, 4, 4, 5, 9, 5, 3, 2, 3, 2, 3, 2, 3, 2, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 5, 3, 23, 10, 3, 3, 4, 3, 4, 3, 5, 3, 5, 2,
+	2, 6, 2, 4, 6, 8, 2, 2, 2, 25, 2, 10, 3, 2, 2, 2, 4, 22, 3, 2, 2, 2,
+	6, 24, 3, 2, 2, 2, 8, 26, 3, 2, 2, 2, 10, 11, 5, 4, 3, 2, 11, 12, 3, 2,
+	2, 2, 12, 13, 7, 2, 2, 3, 13, 3, 3, 2, 2, 2, 14, 15, 5, 6, 4, 2, 15, 16,
+	3, 2, 2, 2, 16, 17, 7, 3, 2, 2, 17, 23, 3, 2, 2, 2, 18, 19, 7, 3, 2, 2,
+	19, 20, 3, 2, 2, 2, 20, 21, 5, 8, 5, 2, 21, 23, 3, 2, 2, 2, 22, 14, 3, 2,
+	2, 2, 22, 18, 3, 2, 2, 2, 23, 5, 3, 2, 2, 2, 24, 25, 7, 3, 2, 2, 25, 7,
+	3, 2, 2, 2, 26, 27, 7, 3, 2, 2, 27, 9, 3, 2, 2, 2, 3, 22,
+}
+
+var ambParserATN = NewATNDeserializer(nil).DeserializeFromUInt16(ambSerializedParserATN)
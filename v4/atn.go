@@ -105,6 +105,28 @@ func (a *ATN) getDecisionState(decision int) DecisionState {
 	return a.DecisionToState[decision]
 }
 
+// getRuleBlock returns the block holding the outer alternatives of rule
+// ruleIndex, or nil if the rule has a single outer alternative. The rule
+// block of a left-recursive rule is the block of its primary alternatives,
+// so getRuleBlock returns nil for it too; generated parsers enter such a
+// rule as outer alternative 1.
+func (a *ATN) getRuleBlock(ruleIndex int) BlockStartState {
+	start := a.ruleToStartState[ruleIndex]
+	if len(start.GetTransitions()) == 0 {
+		return nil
+	}
+
+	block, ok := start.GetTransitions()[0].getTarget().(BlockStartState)
+	if !ok || block.getEndState() == nil || len(block.getEndState().GetTransitions()) == 0 {
+		return nil
+	}
+	if _, ok := block.getEndState().GetTransitions()[0].getTarget().(*RuleStopState); !ok {
+		return nil
+	}
+
+	return block
+}
+
 // getExpectedTokens computes the set of input symbols which could follow ATN
 // state number stateNumber in the specified full parse context ctx and returns
 // the set of potentially valid input symbols which could follow the specified
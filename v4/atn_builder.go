@@ -0,0 +1,733 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ATNBuilder constructs a lexer or parser ATN from a description of its
+// rules, without going through the serialized form the ANTLR tool
+// generates. The states and transitions are laid out the way the tool lays
+// them out for the same grammar, so the simulators, the interpreters and
+// ATNSerializer can all use the result.
+//
+// <p>Each rule body is an ATNElement made with the element methods of the
+// builder, which follow the grammar notation:</p>
+//
+// <pre>
+// b := NewParserATNBuilder(3)
+// b.Rule("list", b.Seq(b.Atom(1), b.Star(b.Seq(b.Atom(2), b.Atom(1))), b.Atom(TokenEOF)))
+// atn := b.Build()
+// </pre>
+//
+// <p>builds the ATN of {@code list : A (',' A)* EOF ;} where A is token
+// type 1 and ',' is token type 2.</p>
+type ATNBuilder struct {
+	grammarType  int
+	maxTokenType int
+
+	rules       []*atnBuilderRule
+	ruleIndices map[string]int
+
+	// modeNames holds the names of the lexer modes. Lexer rules are added to
+	// the last mode.
+	modeNames []string
+
+	// atn, rule and lexerActions hold the ATN being built by Build, the
+	// index of the rule being built, and the lexer actions of the ATN.
+	atn          *ATN
+	rule         int
+	lexerActions []LexerAction
+}
+
+type atnBuilderRule struct {
+	name string
+	body ATNElement
+
+	precedence bool
+
+	// mode, tokenType and fragment describe lexer rules.
+	mode      int
+	tokenType int
+	fragment  bool
+}
+
+// NewParserATNBuilder returns a builder for a parser ATN matching token
+// types up to maxTokenType.
+func NewParserATNBuilder(maxTokenType int) *ATNBuilder {
+	return &ATNBuilder{
+		grammarType:  ATNTypeParser,
+		maxTokenType: maxTokenType,
+		ruleIndices:  make(map[string]int),
+	}
+}
+
+// NewLexerATNBuilder returns a builder for a lexer ATN producing token types
+// up to maxTokenType. Lexer rules are added to the default mode until Mode
+// is called.
+func NewLexerATNBuilder(maxTokenType int) *ATNBuilder {
+	return &ATNBuilder{
+		grammarType:  ATNTypeLexer,
+		maxTokenType: maxTokenType,
+		ruleIndices:  make(map[string]int),
+		modeNames:    []string{"DEFAULT_MODE"},
+	}
+}
+
+// Rule adds the parser rule name matching body, and returns its rule index.
+func (b *ATNBuilder) Rule(name string, body ATNElement) int {
+	b.checkGrammarType(ATNTypeParser, "parser rule "+name)
+
+	return b.addRule(&atnBuilderRule{name: name, body: body})
+}
+
+// PrecedenceRule adds the left-recursive parser rule name, and returns its
+// rule index. The body must be in the form the ANTLR tool rewrites
+// left-recursive rules to: the primary alternatives, followed by a greedy
+// loop over the binary and suffix alternatives. Each of those starts with a
+// PrecedencePredicate and calls the rule recursively with RulePrecedenceRef.
+// For
+//
+// <pre>
+// e : e '*' e | e '+' e | INT ;
+// </pre>
+//
+// <p>the body is made by</p>
+//
+// <pre>
+// mul := b.Seq(b.PrecedencePredicate(2), b.Atom(MUL), b.RulePrecedenceRef("e", 3))
+// add := b.Seq(b.PrecedencePredicate(1), b.Atom(ADD), b.RulePrecedenceRef("e", 2))
+// b.Seq(b.Atom(INT), b.Star(b.Alts(mul, add)))
+// </pre>
+func (b *ATNBuilder) PrecedenceRule(name string, body ATNElement) int {
+	b.checkGrammarType(ATNTypeParser, "precedence rule "+name)
+
+	return b.addRule(&atnBuilderRule{name: name, body: body, precedence: true})
+}
+
+// Mode starts the lexer mode name; the lexer rules added after it belong to
+// that mode. It returns the index of the mode, for use in mode actions.
+func (b *ATNBuilder) Mode(name string) int {
+	b.checkGrammarType(ATNTypeLexer, "mode "+name)
+
+	for _, n := range b.modeNames {
+		if n == name {
+			panic("mode " + name + " is already defined")
+		}
+	}
+	b.modeNames = append(b.modeNames, name)
+
+	return len(b.modeNames) - 1
+}
+
+// LexerRule adds the lexer rule name emitting tokenType for the input
+// matching body, and returns its rule index.
+func (b *ATNBuilder) LexerRule(name string, tokenType int, body ATNElement) int {
+	b.checkGrammarType(ATNTypeLexer, "lexer rule "+name)
+
+	return b.addRule(&atnBuilderRule{name: name, body: body, mode: len(b.modeNames) - 1, tokenType: tokenType})
+}
+
+// FragmentRule adds the lexer fragment rule name, which only matches body
+// when another lexer rule refers to it, and returns its rule index.
+func (b *ATNBuilder) FragmentRule(name string, body ATNElement) int {
+	b.checkGrammarType(ATNTypeLexer, "fragment rule "+name)
+
+	return b.addRule(&atnBuilderRule{name: name, body: body, mode: len(b.modeNames) - 1, fragment: true})
+}
+
+func (b *ATNBuilder) addRule(r *atnBuilderRule) int {
+	if r.name == "" {
+		panic("rule name is empty")
+	}
+	if _, ok := b.ruleIndices[r.name]; ok {
+		panic("rule " + r.name + " is already defined")
+	}
+	if r.body == nil {
+		panic("rule " + r.name + " has no body")
+	}
+
+	b.ruleIndices[r.name] = len(b.rules)
+	b.rules = append(b.rules, r)
+
+	return len(b.rules) - 1
+}
+
+func (b *ATNBuilder) checkGrammarType(grammarType int, what string) {
+	if b.grammarType != grammarType {
+		kind := "parser"
+		if b.grammarType == ATNTypeLexer {
+			kind = "lexer"
+		}
+		panic("cannot add " + what + " to a " + kind + " ATN")
+	}
+}
+
+// GetRuleNames returns the names of the rules, by rule index.
+func (b *ATNBuilder) GetRuleNames() []string {
+	names := make([]string, len(b.rules))
+	for i, r := range b.rules {
+		names[i] = r.name
+	}
+
+	return names
+}
+
+// GetModeNames returns the names of the lexer modes, by mode index, or nil
+// for a parser ATN.
+func (b *ATNBuilder) GetModeNames() []string {
+	if b.modeNames == nil {
+		return nil
+	}
+
+	return append([]string(nil), b.modeNames...)
+}
+
+// Build returns a new ATN for the rules added so far. The ATN is checked the
+// way ATNDeserializer checks deserialized ATNs when ATN verification is
+// enabled.
+//
+// @panics if a rule refers to an unknown rule, an element is used where the
+// ATN does not allow it, or the ATN does not verify.
+func (b *ATNBuilder) Build() *ATN {
+	if len(b.rules) == 0 {
+		panic("cannot build an ATN without rules")
+	}
+
+	b.atn = NewATN(b.grammarType, b.maxTokenType)
+	b.lexerActions = nil
+	defer func() {
+		b.atn = nil
+		b.lexerActions = nil
+	}()
+	atn := b.atn
+
+	// Build all start states, one per mode
+	for _, name := range b.modeNames {
+		start := NewTokensStartState()
+		b.addState(start, -1)
+		atn.modeToStartState = append(atn.modeToStartState, start)
+		atn.modeNameToStartState[name] = start
+		atn.defineDecisionState(start)
+	}
+
+	if b.grammarType == ATNTypeLexer {
+		atn.ruleToTokenType = make([]int, len(b.rules))
+	}
+	for i, r := range b.rules {
+		start := NewRuleStartState()
+		stop := NewRuleStopState()
+		b.addState(start, i)
+		b.addState(stop, i)
+		start.stopState = stop
+		start.isPrecedenceRule = r.precedence
+		atn.ruleToStartState = append(atn.ruleToStartState, start)
+		atn.ruleToStopState = append(atn.ruleToStopState, stop)
+
+		if b.grammarType == ATNTypeLexer && !r.fragment {
+			atn.ruleToTokenType[i] = r.tokenType
+		}
+	}
+
+	for i, r := range b.rules {
+		b.rule = i
+		h := r.body.build(b)
+		b.epsilon(atn.ruleToStartState[i], h.left)
+		b.epsilon(h.right, atn.ruleToStopState[i])
+	}
+	atn.lexerActions = b.lexerActions
+
+	// Link the mode start states to each token rule
+	for i, r := range b.rules {
+		if b.grammarType == ATNTypeLexer && !r.fragment {
+			b.epsilon(atn.modeToStartState[r.mode], atn.ruleToStartState[i])
+		}
+	}
+
+	b.addRuleFollowLinks()
+
+	d := NewATNDeserializer(&ATNDeserializationOptions{verifyATN: true})
+	d.markPrecedenceDecisions(atn)
+	b.checkPrecedenceRules()
+	d.verifyATN(atn)
+
+	return atn
+}
+
+func (b *ATNBuilder) addState(state ATNState, ruleIndex int) {
+	state.SetRuleIndex(ruleIndex)
+	b.atn.addState(state)
+}
+
+func (b *ATNBuilder) newBasicState() ATNState {
+	s := NewBasicState()
+	b.addState(s, b.rule)
+
+	return s
+}
+
+func (b *ATNBuilder) epsilon(a, c ATNState) {
+	a.AddTransition(NewEpsilonTransition(c, -1), -1)
+}
+
+// addRuleFollowLinks adds the transitions from the stop state of each rule
+// to the states following its invocations, the way ATNDeserializer derives
+// them.
+func (b *ATNBuilder) addRuleFollowLinks() {
+	atn := b.atn
+
+	for _, state := range atn.states {
+		for _, t := range state.GetTransitions() {
+			t, ok := t.(*RuleTransition)
+			if !ok {
+				continue
+			}
+
+			outermostPrecedenceReturn := -1
+			if atn.ruleToStartState[t.ruleIndex].isPrecedenceRule && t.precedence == 0 {
+				outermostPrecedenceReturn = t.ruleIndex
+			}
+
+			atn.ruleToStopState[t.ruleIndex].AddTransition(NewEpsilonTransition(t.followState, outermostPrecedenceReturn), -1)
+		}
+	}
+}
+
+// checkPrecedenceRules panics if the body of a precedence rule does not end
+// with the loop over its binary and suffix alternatives.
+func (b *ATNBuilder) checkPrecedenceRules() {
+	decisions := make(map[int]bool)
+	for _, state := range b.atn.states {
+		if s, ok := state.(*StarLoopEntryState); ok && s.precedenceRuleDecision {
+			decisions[s.GetRuleIndex()] = true
+		}
+	}
+
+	for i, r := range b.rules {
+		if r.precedence && !decisions[i] {
+			panic("precedence rule " + r.name + " does not end with a loop over its binary and suffix alternatives")
+		}
+	}
+}
+
+func (b *ATNBuilder) currentRuleName() string {
+	return b.rules[b.rule].name
+}
+
+// lexerActionIndex returns the index of action in the lexer actions of the
+// ATN, adding it if an equal action was not added yet.
+func (b *ATNBuilder) lexerActionIndex(action LexerAction) int {
+	for i, a := range b.lexerActions {
+		if a.equals(action) {
+			return i
+		}
+	}
+	b.lexerActions = append(b.lexerActions, action)
+
+	return len(b.lexerActions) - 1
+}
+
+// ATNElement is a part of a rule body, made by the element methods of an
+// ATNBuilder. Elements hold no states, so an element can be used several
+// times, and in several rules.
+type ATNElement interface {
+	build(b *ATNBuilder) atnHandle
+}
+
+// atnHandle is the entry and exit state of a built element.
+type atnHandle struct {
+	left, right ATNState
+}
+
+// atnTransitionElement is an element matching a single transition.
+type atnTransitionElement struct {
+	transition func(b *ATNBuilder, target ATNState) Transition
+}
+
+func (e *atnTransitionElement) build(b *ATNBuilder) atnHandle {
+	left := b.newBasicState()
+	right := b.newBasicState()
+	left.AddTransition(e.transition(b, right), -1)
+
+	return atnHandle{left, right}
+}
+
+func (b *ATNBuilder) transitionElement(transition func(b *ATNBuilder, target ATNState) Transition) ATNElement {
+	return &atnTransitionElement{transition: transition}
+}
+
+// Atom matches the token type label in a parser ATN, or the code point
+// label in a lexer ATN. TokenEOF matches the end of the input.
+func (b *ATNBuilder) Atom(label int) ATNElement {
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		return NewAtomTransition(target, label)
+	})
+}
+
+// Literal matches the code points of s in sequence, like a string literal
+// in a lexer rule.
+func (b *ATNBuilder) Literal(s string) ATNElement {
+	if s == "" {
+		panic("literal is empty")
+	}
+
+	var elements []ATNElement
+	for _, c := range s {
+		elements = append(elements, b.Atom(int(c)))
+	}
+
+	return b.Seq(elements...)
+}
+
+// Range matches the token types, or code points, start through stop.
+func (b *ATNBuilder) Range(start, stop int) ATNElement {
+	if start > stop {
+		panic("range " + strconv.Itoa(start) + ".." + strconv.Itoa(stop) + " is empty")
+	}
+
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		return NewRangeTransition(target, start, stop)
+	})
+}
+
+// Set matches the token types, or code points, in set.
+func (b *ATNBuilder) Set(set *IntervalSet) ATNElement {
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		return NewSetTransition(target, set)
+	})
+}
+
+// NotSet matches the token types, or code points, not in set.
+func (b *ATNBuilder) NotSet(set *IntervalSet) ATNElement {
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		return NewNotSetTransition(target, set)
+	})
+}
+
+// Wildcard matches any token type, or code point.
+func (b *ATNBuilder) Wildcard() ATNElement {
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		return NewWildcardTransition(target)
+	})
+}
+
+// Epsilon matches the empty input.
+func (b *ATNBuilder) Epsilon() ATNElement {
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		return NewEpsilonTransition(target, -1)
+	})
+}
+
+// RuleRef invokes the rule name, which may be added after the element is
+// made.
+func (b *ATNBuilder) RuleRef(name string) ATNElement {
+	return b.RulePrecedenceRef(name, 0)
+}
+
+// RulePrecedenceRef invokes the precedence rule name with precedence, like
+// {@code e[3]} in a rewritten left-recursive rule.
+func (b *ATNBuilder) RulePrecedenceRef(name string, precedence int) ATNElement {
+	return &atnRuleRefElement{name: name, precedence: precedence}
+}
+
+type atnRuleRefElement struct {
+	name       string
+	precedence int
+}
+
+func (e *atnRuleRefElement) build(b *ATNBuilder) atnHandle {
+	idx, ok := b.ruleIndices[e.name]
+	if !ok {
+		panic("rule " + b.currentRuleName() + " refers to unknown rule " + e.name)
+	}
+	if e.precedence != 0 && !b.rules[idx].precedence {
+		panic("rule " + b.currentRuleName() + " invokes rule " + e.name + " with a precedence, but it is not a precedence rule")
+	}
+
+	left := b.newBasicState()
+	right := b.newBasicState()
+	left.AddTransition(NewRuleTransition(b.atn.ruleToStartState[idx], idx, e.precedence, right), -1)
+
+	return atnHandle{left, right}
+}
+
+// Predicate is the semantic predicate predIndex of the rule, evaluated by
+// the Sempred method of the recognizer. A context-dependent predicate
+// refers to the rule context, like the generated code for {@code $x} does.
+func (b *ATNBuilder) Predicate(predIndex int, ctxDependent bool) ATNElement {
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		return NewPredicateTransition(target, b.rule, predIndex, ctxDependent)
+	})
+}
+
+// PrecedencePredicate is the predicate {@code precpred(_ctx, precedence)}
+// starting the binary and suffix alternatives of a precedence rule.
+func (b *ATNBuilder) PrecedencePredicate(precedence int) ATNElement {
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		if !b.rules[b.rule].precedence {
+			panic("rule " + b.currentRuleName() + " has a precedence predicate, but it is not a precedence rule")
+		}
+
+		return NewPrecedencePredicateTransition(target, precedence)
+	})
+}
+
+// Action is the action actionIndex of the rule. In a parser ATN it is
+// executed by the Action method of the recognizer; in a lexer ATN it is a
+// LexerCustomAction, executed when the token is emitted.
+func (b *ATNBuilder) Action(actionIndex int) ATNElement {
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		if b.grammarType == ATNTypeLexer {
+			return NewActionTransition(target, b.rule, b.lexerActionIndex(NewLexerCustomAction(b.rule, actionIndex)), false)
+		}
+
+		return NewActionTransition(target, b.rule, actionIndex, false)
+	})
+}
+
+// Command is the lexer command action, like {@code -> skip} or
+// {@code -> pushMode(M)}. Commands usually end a lexer rule.
+func (b *ATNBuilder) Command(action LexerAction) ATNElement {
+	if action == nil {
+		panic("lexer command is nil")
+	}
+
+	return b.transitionElement(func(b *ATNBuilder, target ATNState) Transition {
+		if b.grammarType != ATNTypeLexer {
+			panic("rule " + b.currentRuleName() + " has a lexer command, but the ATN is not a lexer ATN")
+		}
+
+		return NewActionTransition(target, b.rule, b.lexerActionIndex(action), false)
+	})
+}
+
+// Seq matches elements in sequence. An empty sequence matches the empty
+// input.
+func (b *ATNBuilder) Seq(elements ...ATNElement) ATNElement {
+	checkATNElements("sequence", elements)
+
+	return &atnSeqElement{elements: elements}
+}
+
+type atnSeqElement struct {
+	elements []ATNElement
+}
+
+func (e *atnSeqElement) build(b *ATNBuilder) atnHandle {
+	if len(e.elements) == 0 {
+		return b.Epsilon().build(b)
+	}
+
+	h := e.elements[0].build(b)
+	for _, el := range e.elements[1:] {
+		next := el.build(b)
+		b.epsilon(h.right, next.left)
+		h.right = next.right
+	}
+
+	return h
+}
+
+// Alts matches one of alternatives, the first alternative being alternative
+// 1 of the decision. Alts used as the block of Optional, Star or Plus makes
+// the alternatives of that block.
+func (b *ATNBuilder) Alts(alternatives ...ATNElement) ATNElement {
+	if len(alternatives) == 0 {
+		panic("block has no alternatives")
+	}
+	checkATNElements("block", alternatives)
+
+	return &atnAltsElement{alternatives: alternatives}
+}
+
+type atnAltsElement struct {
+	alternatives []ATNElement
+}
+
+func (e *atnAltsElement) build(b *ATNBuilder) atnHandle {
+	if len(e.alternatives) == 1 {
+		return e.alternatives[0].build(b)
+	}
+
+	alts := buildATNAlternatives(b, e)
+	start := NewBasicBlockStartState()
+	b.addState(start, b.rule)
+	b.atn.defineDecisionState(start)
+
+	return b.makeBlock(start, alts)
+}
+
+// buildATNAlternatives builds the alternatives of the block element.
+func buildATNAlternatives(b *ATNBuilder, block ATNElement) []atnHandle {
+	var alternatives []ATNElement
+	if a, ok := block.(*atnAltsElement); ok {
+		alternatives = a.alternatives
+	} else {
+		alternatives = []ATNElement{block}
+	}
+
+	alts := make([]atnHandle, len(alternatives))
+	for i, alt := range alternatives {
+		alts[i] = alt.build(b)
+	}
+
+	return alts
+}
+
+// makeBlock links start to each of alts, and each of alts to a new block end
+// state.
+func (b *ATNBuilder) makeBlock(start BlockStartState, alts []atnHandle) atnHandle {
+	end := NewBlockEndState()
+	b.addState(end, b.rule)
+	start.setEndState(end)
+	end.startState = start
+
+	for _, alt := range alts {
+		b.epsilon(start, alt.left)
+		b.epsilon(alt.right, end)
+	}
+
+	return atnHandle{start, end}
+}
+
+func checkATNElements(what string, elements []ATNElement) {
+	for i, e := range elements {
+		if e == nil {
+			panic(fmt.Sprintf("element %d of %s is nil", i, what))
+		}
+	}
+}
+
+const (
+	atnLoopOptional = iota
+	atnLoopStar
+	atnLoopPlus
+)
+
+// Optional matches block or the empty input, like {@code (...)?}.
+func (b *ATNBuilder) Optional(block ATNElement) ATNElement {
+	return newATNLoopElement(atnLoopOptional, block)
+}
+
+// Star matches block zero or more times, like {@code (...)*}.
+func (b *ATNBuilder) Star(block ATNElement) ATNElement {
+	return newATNLoopElement(atnLoopStar, block)
+}
+
+// Plus matches block one or more times, like {@code (...)+}.
+func (b *ATNBuilder) Plus(block ATNElement) ATNElement {
+	return newATNLoopElement(atnLoopPlus, block)
+}
+
+// NonGreedy returns the non-greedy form of the Optional, Star or Plus
+// element, like {@code (...)??}, {@code (...)*?} or {@code (...)+?}, which
+// prefers leaving the block to entering it.
+func (b *ATNBuilder) NonGreedy(element ATNElement) ATNElement {
+	e, ok := element.(*atnLoopElement)
+	if !ok {
+		panic(fmt.Sprintf("only Optional, Star and Plus elements can be non-greedy, not %T", element))
+	}
+
+	return &atnLoopElement{kind: e.kind, block: e.block, nonGreedy: true}
+}
+
+type atnLoopElement struct {
+	kind      int
+	block     ATNElement
+	nonGreedy bool
+}
+
+func newATNLoopElement(kind int, block ATNElement) *atnLoopElement {
+	if block == nil {
+		panic("block is nil")
+	}
+
+	return &atnLoopElement{kind: kind, block: block}
+}
+
+func (e *atnLoopElement) build(b *ATNBuilder) atnHandle {
+	alts := buildATNAlternatives(b, e.block)
+
+	switch e.kind {
+	case atnLoopOptional:
+		start := NewBasicBlockStartState()
+		b.addState(start, b.rule)
+		b.atn.defineDecisionState(start)
+		h := b.makeBlock(start, alts)
+
+		start.nonGreedy = e.nonGreedy
+		if e.nonGreedy {
+			// If not greedy, priority to the bypass; make it first
+			start.SetTransitions(append([]Transition{NewEpsilonTransition(h.right, -1)}, start.GetTransitions()...))
+		} else {
+			b.epsilon(start, h.right)
+		}
+
+		return h
+
+	case atnLoopStar:
+		blkStart := NewStarBlockStartState()
+		b.addState(blkStart, b.rule)
+		if len(alts) > 1 {
+			b.atn.defineDecisionState(blkStart)
+		}
+		blk := b.makeBlock(blkStart, alts)
+
+		entry := NewStarLoopEntryState()
+		b.addState(entry, b.rule)
+		entry.nonGreedy = e.nonGreedy
+		b.atn.defineDecisionState(entry)
+		end := NewLoopEndState()
+		b.addState(end, b.rule)
+		loop := NewStarLoopbackState()
+		b.addState(loop, b.rule)
+		entry.loopBackState = loop
+		end.loopBackState = loop
+
+		if e.nonGreedy {
+			// If not greedy, priority to the exit branch; make it first
+			b.epsilon(entry, end)
+			b.epsilon(entry, blkStart)
+		} else {
+			b.epsilon(entry, blkStart)
+			b.epsilon(entry, end)
+		}
+		b.epsilon(blk.right, loop)
+		b.epsilon(loop, entry)
+
+		return atnHandle{entry, end}
+
+	default:
+		blkStart := NewPlusBlockStartState()
+		b.addState(blkStart, b.rule)
+		if len(alts) > 1 {
+			b.atn.defineDecisionState(blkStart)
+		}
+		blk := b.makeBlock(blkStart, alts)
+
+		loop := NewPlusLoopbackState()
+		b.addState(loop, b.rule)
+		loop.nonGreedy = e.nonGreedy
+		b.atn.defineDecisionState(loop)
+		end := NewLoopEndState()
+		b.addState(end, b.rule)
+		blkStart.loopBackState = loop
+		end.loopBackState = loop
+
+		b.epsilon(blk.right, loop)
+		if e.nonGreedy {
+			// If not greedy, priority to the exit branch; make it first
+			b.epsilon(loop, end)
+			b.epsilon(loop, blkStart)
+		} else {
+			b.epsilon(loop, blkStart)
+			b.epsilon(loop, end)
+		}
+
+		return atnHandle{blkStart, end}
+	}
+}
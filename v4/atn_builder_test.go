@@ -0,0 +1,192 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// buildExprATNs builds the ATNs of the Expr grammar of the fixtures in
+// parse_tree_pattern_test.go.
+func buildExprATNs() (lexerATN, parserATN *ATN) {
+	l := NewLexerATNBuilder(9)
+	for i, lit := range []string{"=", ";", "*", "+", "(", ")"} {
+		l.LexerRule(exprLexerRuleNames[i], i+1, l.Literal(lit))
+	}
+	l.LexerRule("ID", 7, l.Plus(l.Range('a', 'z')))
+	l.LexerRule("INT", 8, l.Plus(l.Range('0', '9')))
+	ws := NewIntervalSet()
+	for _, c := range " \t\r\n" {
+		ws.addOne(int(c))
+	}
+	l.LexerRule("WS", 9, l.Seq(l.Plus(l.Set(ws)), l.Command(LexerSkipActionINSTANCE)))
+
+	p := NewParserATNBuilder(9)
+	p.Rule("prog", p.Seq(p.Plus(p.RuleRef("stat")), p.Atom(TokenEOF)))
+	p.Rule("stat", p.Alts(
+		p.Seq(p.Atom(7), p.Atom(1), p.RuleRef("expr"), p.Atom(2)),
+		p.Seq(p.RuleRef("expr"), p.Atom(2)),
+	))
+	p.PrecedenceRule("expr", p.Seq(
+		p.Alts(p.Atom(8), p.Atom(7), p.Seq(p.Atom(5), p.RuleRef("expr"), p.Atom(6))),
+		p.Star(p.Alts(
+			p.Seq(p.PrecedencePredicate(2), p.Atom(3), p.RulePrecedenceRef("expr", 3)),
+			p.Seq(p.PrecedencePredicate(1), p.Atom(4), p.RulePrecedenceRef("expr", 2)),
+		)),
+	))
+
+	return l.Build(), p.Build()
+}
+
+func TestATNBuilderExpr(t *testing.T) {
+	lexerATN, parserATN := buildExprATNs()
+
+	for _, input := range []string{"x = 1 + 2 * 3;", "(a + b) * c; y = 4;", "1 * 2 + 3 * 4;", "x = ;", "1 + ;;"} {
+		want, wantErrors := parseExprWith(exprLexerATN, exprParserATN, input)
+		got, gotErrors := parseExprWith(lexerATN, parserATN, input)
+		if got != want || gotErrors != wantErrors {
+			t.Errorf("%q: expected %s %v, got %s %v", input, want, wantErrors, got, gotErrors)
+		}
+	}
+
+	// The decision of the expr loop is a precedence decision.
+	precedenceDecisions := 0
+	for _, ds := range parserATN.DecisionToState {
+		if s, ok := ds.(*StarLoopEntryState); ok && s.precedenceRuleDecision {
+			precedenceDecisions++
+		}
+	}
+	if precedenceDecisions != 1 {
+		t.Errorf("expected 1 precedence decision, got %d", precedenceDecisions)
+	}
+
+	// A built ATN serializes, and reads back as the same ATN.
+	for _, atn := range []*ATN{lexerATN, parserATN} {
+		var want, got bytes.Buffer
+		options := NewATNDeserializationOptions(nil)
+		options.verifyATN = true
+		if err := NewATNGraph(atn, nil).WriteJSON(&want); err != nil {
+			t.Fatalf("writing JSON: %v", err)
+		}
+		if err := NewATNGraph(NewATNDeserializer(options).DeserializeFromUInt16(NewATNSerializer(atn).Serialize()), nil).WriteJSON(&got); err != nil {
+			t.Fatalf("writing JSON: %v", err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("expected ATN\n%s\ngot\n%s", want.String(), got.String())
+		}
+	}
+}
+
+// parseExprWith parses input with interpreters for the Expr grammar using
+// lexerATN and parserATN, and returns the parse tree and the syntax errors.
+func parseExprWith(lexerATN, parserATN *ATN, input string) (string, int) {
+	lexer := NewLexerInterpreter("Expr.g4", exprLiteralNames, exprSymbolicNames, exprLexerRuleNames, exprChannelNames, exprModeNames, lexerATN, NewInputStream(input))
+	lexer.RemoveErrorListeners()
+	parser := NewParserInterpreter("Expr.g4", exprLiteralNames, exprSymbolicNames, exprParserRuleNames, parserATN, NewCommonTokenStream(lexer, TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+
+	tree, syntaxErrors, _ := parseExprProg(parser)
+
+	return tree.ToStringTree(nil, parser), len(syntaxErrors)
+}
+
+func TestATNBuilderLexer(t *testing.T) {
+	b := NewLexerATNBuilder(4)
+	b.LexerRule("ID", 1, b.Seq(b.RuleRef("LETTER"), b.Star(b.Alts(b.RuleRef("LETTER"), b.Range('0', '9')))))
+	b.LexerRule("COMMENT", 2, b.Seq(b.Literal("/*"), b.NonGreedy(b.Star(b.Wildcard())), b.Literal("*/"), b.Command(NewLexerChannelAction(1))))
+	b.LexerRule("OPEN", 3, b.Seq(b.Literal("<"), b.Command(NewLexerPushModeAction(1))))
+	b.LexerRule("WS", 4, b.Seq(b.Literal(" "), b.Command(LexerSkipActionINSTANCE)))
+	b.FragmentRule("LETTER", b.Range('a', 'z'))
+	if mode := b.Mode("TAG"); mode != 1 {
+		t.Fatalf("expected mode 1, got %d", mode)
+	}
+	b.LexerRule("CLOSE", 3, b.Seq(b.Literal(">"), b.Command(LexerPopModeActionINSTANCE)))
+	gt := NewIntervalSet()
+	gt.addOne('>')
+	b.LexerRule("TEXT", 1, b.Plus(b.NotSet(gt)))
+	atn := b.Build()
+
+	if got := strings.Join(b.GetModeNames(), " "); got != "DEFAULT_MODE TAG" {
+		t.Errorf("unexpected mode names %s", got)
+	}
+	if len(atn.lexerActions) != 4 || atn.ruleToTokenType[4] != 0 {
+		t.Errorf("expected 4 lexer actions and no token type for LETTER, got %v and %v", atn.lexerActions, atn.ruleToTokenType)
+	}
+
+	lexer := NewLexerInterpreter("T.g4", nil, nil, b.GetRuleNames(), nil, b.GetModeNames(), atn, NewInputStream("a1 /* x */ b*/ <x y>"))
+	var got []string
+	for tok := lexer.NextToken(); tok.GetTokenType() != TokenEOF; tok = lexer.NextToken() {
+		got = append(got, tok.GetText()+":"+string(rune('0'+tok.GetTokenType()))+string(rune('0'+tok.GetChannel())))
+	}
+	if want := "a1:10 /* x */:21 b:10 <:30 x y:10 >:30"; strings.Join(got, " ") != want {
+		t.Errorf("expected tokens %s, got %s", want, strings.Join(got, " "))
+	}
+}
+
+func TestATNBuilderErrors(t *testing.T) {
+	for _, test := range []struct {
+		expected string
+		build    func()
+	}{
+		{"rule s refers to unknown rule t", func() {
+			b := NewParserATNBuilder(1)
+			b.Rule("s", b.RuleRef("t"))
+			b.Build()
+		}},
+		{"rule s is already defined", func() {
+			b := NewParserATNBuilder(1)
+			b.Rule("s", b.Atom(1))
+			b.Rule("s", b.Atom(1))
+		}},
+		{"cannot add lexer rule A to a parser ATN", func() {
+			b := NewParserATNBuilder(1)
+			b.LexerRule("A", 1, b.Atom('a'))
+		}},
+		{"rule s has a precedence predicate, but it is not a precedence rule", func() {
+			b := NewParserATNBuilder(1)
+			b.Rule("s", b.Seq(b.PrecedencePredicate(1), b.Atom(1)))
+			b.Build()
+		}},
+		{"precedence rule e does not end with a loop over its binary and suffix alternatives", func() {
+			b := NewParserATNBuilder(1)
+			b.PrecedenceRule("e", b.Atom(1))
+			b.Build()
+		}},
+		{"only Optional, Star and Plus elements can be non-greedy, not *antlr.atnSeqElement", func() {
+			b := NewParserATNBuilder(1)
+			b.NonGreedy(b.Seq(b.Atom(1)))
+		}},
+		{"cannot build an ATN without rules", func() {
+			NewLexerATNBuilder(1).Build()
+		}},
+	} {
+		expectPanic(t, test.expected, test.build)
+	}
+}
+
+func TestATNBuilderVerify(t *testing.T) {
+	options := NewATNDeserializationOptions(nil)
+	options.verifyATN = true
+
+	// The fixtures verify.
+	for _, serialized := range [][]uint16{serializerLexerATN, serializerParserATN, exprSerializedLexerATN, exprSerializedParserATN} {
+		NewATNDeserializer(options).DeserializeFromUInt16(serialized)
+	}
+
+	b := NewParserATNBuilder(1)
+	b.Rule("s", b.Star(b.Atom(1)))
+	atn := b.Build()
+
+	// A greedy loop entry must enter the loop first.
+	entry := atn.DecisionToState[0].(*StarLoopEntryState)
+	transitions := entry.GetTransitions()
+	entry.SetTransitions([]Transition{transitions[1], transitions[0]})
+	expectPanic(t, "IllegalState: ATN state "+strconv.Itoa(entry.GetStateNumber())+" exits the loop first but is greedy", func() {
+		NewATNDeserializer(options).verifyATN(atn)
+	})
+}
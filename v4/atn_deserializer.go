@@ -128,7 +128,9 @@ func (a *ATNDeserializer) reset(data []rune) {
 		} else if c > 1 {
 			temp[i] = c - 2
 		} else {
-		    temp[i] = c + 65533
+			// Values are shifted by 2 modulo 0x10000, so 0 and 1 are
+			// 0xFFFE and 0xFFFF
+			temp[i] = c + 65534
 		}
 	}
 
@@ -325,6 +327,10 @@ func (a *ATNDeserializer) readEdges(atn *ATN, sets []*IntervalSet) {
 	for i := 0; i < len(atn.states); i++ {
 		state := atn.states[i]
 
+		if state == nil {
+			continue
+		}
+
 		for j := 0; j < len(state.GetTransitions()); j++ {
 			var t, ok = state.GetTransitions()[j].(*RuleTransition)
 
@@ -349,18 +355,18 @@ func (a *ATNDeserializer) readEdges(atn *ATN, sets []*IntervalSet) {
 	for i := 0; i < len(atn.states); i++ {
 		state := atn.states[i]
 
-		if s2, ok := state.(*BaseBlockStartState); ok {
+		if s2, ok := state.(BlockStartState); ok {
 			// We need to know the end state to set its start state
-			if s2.endState == nil {
+			if s2.getEndState() == nil {
 				panic("IllegalState")
 			}
 
 			// Block end states can only be associated to a single block start state
-			if s2.endState.startState != nil {
+			if s2.getEndState().startState != nil {
 				panic("IllegalState")
 			}
 
-			s2.endState.startState = state
+			s2.getEndState().startState = state
 		}
 
 		if s2, ok := state.(*PlusLoopbackState); ok {
@@ -425,6 +431,8 @@ func (a *ATNDeserializer) readLexerActions(atn *ATN) {
 func (a *ATNDeserializer) generateRuleBypassTransitions(atn *ATN) {
 	count := len(atn.ruleToStartState)
 
+	atn.ruleToTokenType = make([]int, count)
+
 	for i := 0; i < count; i++ {
 		atn.ruleToTokenType[i] = atn.maxTokenType + i + 1
 	}
@@ -447,7 +455,7 @@ func (a *ATNDeserializer) generateRuleBypassTransition(atn *ATN, idx int) {
 
 	bypassStart.endState = bypassStop
 
-	atn.defineDecisionState(bypassStart.BaseDecisionState)
+	atn.defineDecisionState(bypassStart)
 
 	bypassStop.startState = bypassStart
 
@@ -496,11 +504,11 @@ func (a *ATNDeserializer) generateRuleBypassTransition(atn *ATN, idx int) {
 
 	// All transitions leaving the rule start state need to leave blockStart instead
 	ruleToStartState := atn.ruleToStartState[idx]
-	count := len(ruleToStartState.GetTransitions())
 
-	for count > 0 {
-		bypassStart.AddTransition(ruleToStartState.GetTransitions()[count-1], -1)
-		ruleToStartState.SetTransitions([]Transition{ruleToStartState.GetTransitions()[len(ruleToStartState.GetTransitions())-1]})
+	for len(ruleToStartState.GetTransitions()) > 0 {
+		transitions := ruleToStartState.GetTransitions()
+		bypassStart.AddTransition(transitions[len(transitions)-1], -1)
+		ruleToStartState.SetTransitions(transitions[:len(transitions)-1])
 	}
 
 	// Link the new states
@@ -577,63 +585,74 @@ func (a *ATNDeserializer) verifyATN(atn *ATN) {
 			continue
 		}
 
-		a.checkCondition(state.GetEpsilonOnlyTransitions() || len(state.GetTransitions()) <= 1, "")
+		a.checkStateCondition(state.GetEpsilonOnlyTransitions() || len(state.GetTransitions()) <= 1, state, "has mixed epsilon and non-epsilon transitions")
 
-		switch s2 := state.(type) {
-		case *PlusBlockStartState:
-			a.checkCondition(s2.loopBackState != nil, "")
+		if s2, ok := state.(*PlusBlockStartState); ok {
+			a.checkStateCondition(s2.loopBackState != nil, state, "has no loop back state")
+		}
 
-		case *StarLoopEntryState:
-			a.checkCondition(s2.loopBackState != nil, "")
-			a.checkCondition(len(s2.GetTransitions()) == 2, "")
+		if s2, ok := state.(*StarLoopEntryState); ok {
+			a.checkStateCondition(s2.loopBackState != nil, state, "has no loop back state")
+			a.checkStateCondition(len(s2.GetTransitions()) == 2, state, "does not have 2 transitions")
 
-			switch s2 := state.(type) {
+			switch s2.GetTransitions()[0].getTarget().(type) {
 			case *StarBlockStartState:
 				var _, ok2 = s2.GetTransitions()[1].getTarget().(*LoopEndState)
 
-				a.checkCondition(ok2, "")
-				a.checkCondition(!s2.nonGreedy, "")
+				a.checkStateCondition(ok2, state, "does not exit to a loop end state")
+				a.checkStateCondition(!s2.nonGreedy, state, "enters the loop first but is non-greedy")
 
 			case *LoopEndState:
-				var s3, ok2 = s2.GetTransitions()[1].getTarget().(*StarBlockStartState)
+				var _, ok2 = s2.GetTransitions()[1].getTarget().(*StarBlockStartState)
 
-				a.checkCondition(ok2, "")
-				a.checkCondition(s3.nonGreedy, "")
+				a.checkStateCondition(ok2, state, "does not enter a star block start state")
+				a.checkStateCondition(s2.nonGreedy, state, "exits the loop first but is greedy")
 
 			default:
-				panic("IllegalState")
+				a.checkStateCondition(false, state, "does not enter a star block start state")
 			}
+		}
 
-		case *StarLoopbackState:
-			a.checkCondition(len(state.GetTransitions()) == 1, "")
+		if _, ok := state.(*StarLoopbackState); ok {
+			a.checkStateCondition(len(state.GetTransitions()) == 1, state, "does not have 1 transition")
 
 			var _, ok2 = state.GetTransitions()[0].getTarget().(*StarLoopEntryState)
 
-			a.checkCondition(ok2, "")
-
-		case *LoopEndState:
-			a.checkCondition(s2.loopBackState != nil, "")
+			a.checkStateCondition(ok2, state, "does not loop back to a star loop entry state")
+		}
 
-		case *RuleStartState:
-			a.checkCondition(s2.stopState != nil, "")
+		if s2, ok := state.(*LoopEndState); ok {
+			a.checkStateCondition(s2.loopBackState != nil, state, "has no loop back state")
+		}
 
-		case *BaseBlockStartState:
-			a.checkCondition(s2.endState != nil, "")
+		if s2, ok := state.(*RuleStartState); ok {
+			a.checkStateCondition(s2.stopState != nil, state, "has no stop state")
+		}
 
-		case *BlockEndState:
-			a.checkCondition(s2.startState != nil, "")
+		if s2, ok := state.(BlockStartState); ok {
+			a.checkStateCondition(s2.getEndState() != nil, state, "has no end state")
+		}
 
-		case DecisionState:
-			a.checkCondition(len(s2.GetTransitions()) <= 1 || s2.getDecision() >= 0, "")
+		if s2, ok := state.(*BlockEndState); ok {
+			a.checkStateCondition(s2.startState != nil, state, "has no start state")
+		}
 
-		default:
-			var _, ok = s2.(*RuleStopState)
+		if s2, ok := state.(DecisionState); ok {
+			a.checkStateCondition(len(s2.GetTransitions()) <= 1 || s2.getDecision() >= 0, state, "has several transitions but no decision")
+		} else {
+			var _, ok = state.(*RuleStopState)
 
-			a.checkCondition(len(s2.GetTransitions()) <= 1 || ok, "")
+			a.checkStateCondition(len(state.GetTransitions()) <= 1 || ok, state, "has several transitions but is not a decision state")
 		}
 	}
 }
 
+func (a *ATNDeserializer) checkStateCondition(condition bool, state ATNState, message string) {
+	if !condition {
+		a.checkCondition(false, "IllegalState: ATN state "+strconv.Itoa(state.GetStateNumber())+" "+message)
+	}
+}
+
 func (a *ATNDeserializer) checkCondition(condition bool, message string) {
 	if !condition {
 		if message == "" {
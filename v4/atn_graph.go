@@ -0,0 +1,432 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ATNGraph describes the states, transitions, rules and decisions of an
+// ATN, with rule and token names resolved through a recognizer, for
+// inspecting how a grammar is recognized. WriteJSON writes it in a stable
+// JSON form, and WriteDOT and WriteRuleDOT as Graphviz graphs of the whole
+// grammar or of a single rule.
+type ATNGraph struct {
+	// GrammarType is "lexer" or "parser".
+	GrammarType  string `json:"grammarType"`
+	MaxTokenType int    `json:"maxTokenType"`
+
+	Rules     []*ATNGraphRule     `json:"rules"`
+	Modes     []*ATNGraphMode     `json:"modes,omitempty"`
+	Decisions []*ATNGraphDecision `json:"decisions"`
+
+	// States holds the states of the ATN in state number order.
+	States []*ATNGraphState `json:"states"`
+
+	// LexerActions describes the actions of a lexer ATN, by action index.
+	LexerActions []string `json:"lexerActions,omitempty"`
+}
+
+// ATNGraphRule describes a rule of an ATN.
+type ATNGraphRule struct {
+	Index      int    `json:"index"`
+	Name       string `json:"name"`
+	StartState int    `json:"startState"`
+	StopState  int    `json:"stopState"`
+
+	// PrecedenceRule is true for left-recursive rules.
+	PrecedenceRule bool `json:"precedenceRule,omitempty"`
+
+	// TokenType is the token type a lexer rule produces.
+	TokenType *int `json:"tokenType,omitempty"`
+}
+
+// ATNGraphMode describes a mode of a lexer ATN.
+type ATNGraphMode struct {
+	Index      int    `json:"index"`
+	Name       string `json:"name"`
+	StartState int    `json:"startState"`
+}
+
+// ATNGraphDecision describes a decision of an ATN.
+type ATNGraphDecision struct {
+	Decision int    `json:"decision"`
+	State    int    `json:"state"`
+	Rule     string `json:"rule"`
+}
+
+// ATNGraphState describes a state of an ATN.
+type ATNGraphState struct {
+	Number int `json:"number"`
+
+	// Type is the name of the state type, such as BASIC or RULE_START. See
+	// ATNStateSerializationNames.
+	Type string `json:"type"`
+
+	RuleIndex int    `json:"ruleIndex"`
+	Rule      string `json:"rule,omitempty"`
+
+	// Decision is the number of the decision the state is for, if any.
+	Decision  *int `json:"decision,omitempty"`
+	NonGreedy bool `json:"nonGreedy,omitempty"`
+
+	// PrecedenceDecision is true for the star loop entry state deciding
+	// whether a left-recursive rule continues.
+	PrecedenceDecision bool `json:"precedenceDecision,omitempty"`
+
+	// EndState is the end of a block start state, StartState the start of
+	// a block end state, and LoopBackState the loop back state of a loop.
+	EndState      *int `json:"endState,omitempty"`
+	StartState    *int `json:"startState,omitempty"`
+	LoopBackState *int `json:"loopBackState,omitempty"`
+
+	Transitions []*ATNGraphTransition `json:"transitions"`
+}
+
+// ATNGraphTransition describes a transition of an ATN.
+type ATNGraphTransition struct {
+	// Type is the name of the transition type, such as EPSILON or RULE.
+	// See TransitionserializationNames.
+	Type   string `json:"type"`
+	Target int    `json:"target"`
+
+	// Label is the display form of the transition: the symbols it
+	// matches, the rule it invokes, or its predicate or action.
+	Label string `json:"label"`
+
+	// Intervals holds the inclusive ranges of the symbols the transition
+	// matches, or for NOT_SET transitions does not match.
+	Intervals [][2]int `json:"intervals,omitempty"`
+
+	// Rule and RuleIndex are the rule invoked by a RULE transition, or the
+	// rule of a predicate or action.
+	Rule      string `json:"rule,omitempty"`
+	RuleIndex *int   `json:"ruleIndex,omitempty"`
+
+	FollowState               *int `json:"followState,omitempty"`
+	Precedence                *int `json:"precedence,omitempty"`
+	PredIndex                 *int `json:"predIndex,omitempty"`
+	ActionIndex               *int `json:"actionIndex,omitempty"`
+	CtxDependent              bool `json:"ctxDependent,omitempty"`
+	OutermostPrecedenceReturn *int `json:"outermostPrecedenceReturn,omitempty"`
+}
+
+// NewATNGraph describes atn. The rule, token and mode names come from
+// recognizer, the lexer or parser atn is for; without a recognizer, rules
+// and tokens are shown by number.
+func NewATNGraph(atn *ATN, recognizer Recognizer) *ATNGraph {
+	var ruleNames, literalNames, symbolicNames, modeNames []string
+	if recognizer != nil {
+		ruleNames = recognizer.GetRuleNames()
+		literalNames = recognizer.GetLiteralNames()
+		symbolicNames = recognizer.GetSymbolicNames()
+		if l, ok := recognizer.(interface{ GetModeNames() []string }); ok {
+			modeNames = l.GetModeNames()
+		}
+	}
+	isLexer := atn.grammarType == ATNTypeLexer
+	ruleName := func(ruleIndex int) string {
+		if ruleIndex >= 0 && ruleIndex < len(ruleNames) {
+			return ruleNames[ruleIndex]
+		}
+		return "rule" + strconv.Itoa(ruleIndex)
+	}
+
+	g := &ATNGraph{
+		GrammarType:  "parser",
+		MaxTokenType: atn.maxTokenType,
+		Rules:        make([]*ATNGraphRule, len(atn.ruleToStartState)),
+		Decisions:    make([]*ATNGraphDecision, len(atn.DecisionToState)),
+		States:       make([]*ATNGraphState, 0, len(atn.states)),
+	}
+	if isLexer {
+		g.GrammarType = "lexer"
+	}
+
+	for i, start := range atn.ruleToStartState {
+		rule := &ATNGraphRule{
+			Index:          i,
+			Name:           ruleName(i),
+			StartState:     start.GetStateNumber(),
+			StopState:      atn.ruleToStopState[i].GetStateNumber(),
+			PrecedenceRule: start.isPrecedenceRule,
+		}
+		if isLexer && i < len(atn.ruleToTokenType) {
+			rule.TokenType = graphInt(atn.ruleToTokenType[i])
+		}
+		g.Rules[i] = rule
+	}
+
+	for i, start := range atn.modeToStartState {
+		name := "mode" + strconv.Itoa(i)
+		if i < len(modeNames) {
+			name = modeNames[i]
+		}
+		g.Modes = append(g.Modes, &ATNGraphMode{Index: i, Name: name, StartState: start.GetStateNumber()})
+	}
+
+	for i, ds := range atn.DecisionToState {
+		g.Decisions[i] = &ATNGraphDecision{Decision: i, State: ds.GetStateNumber(), Rule: ruleName(ds.GetRuleIndex())}
+	}
+
+	for _, s := range atn.states {
+		if s == nil {
+			continue
+		}
+
+		state := &ATNGraphState{
+			Number:      s.GetStateNumber(),
+			Type:        graphName(ATNStateSerializationNames, s.GetStateType()),
+			RuleIndex:   s.GetRuleIndex(),
+			Transitions: make([]*ATNGraphTransition, 0, len(s.GetTransitions())),
+		}
+		if s.GetStateType() != ATNStateTokenStart {
+			state.Rule = ruleName(s.GetRuleIndex())
+		}
+		if ds, ok := s.(DecisionState); ok && ds.getDecision() >= 0 {
+			state.Decision = graphInt(ds.getDecision())
+			state.NonGreedy = ds.getNonGreedy()
+		}
+		switch s := s.(type) {
+		case *StarLoopEntryState:
+			state.PrecedenceDecision = s.precedenceRuleDecision
+			state.LoopBackState = graphStateNumber(s.loopBackState)
+		case *PlusBlockStartState:
+			state.LoopBackState = graphStateNumber(s.loopBackState)
+		case *LoopEndState:
+			state.LoopBackState = graphStateNumber(s.loopBackState)
+		case *BlockEndState:
+			state.StartState = graphStateNumber(s.startState)
+		}
+		if bs, ok := s.(BlockStartState); ok && bs.getEndState() != nil {
+			state.EndState = graphInt(bs.getEndState().GetStateNumber())
+		}
+
+		for _, t := range s.GetTransitions() {
+			state.Transitions = append(state.Transitions, newATNGraphTransition(t, ruleName, literalNames, symbolicNames, isLexer))
+		}
+
+		g.States = append(g.States, state)
+	}
+
+	for _, action := range atn.lexerActions {
+		g.LexerActions = append(g.LexerActions, lexerActionString(action))
+	}
+
+	return g
+}
+
+func newATNGraphTransition(t Transition, ruleName func(int) string, literalNames, symbolicNames []string, isLexer bool) *ATNGraphTransition {
+	gt := &ATNGraphTransition{
+		Type:   graphName(TransitionserializationNames, t.getSerializationType()),
+		Target: t.getTarget().GetStateNumber(),
+	}
+
+	switch t := t.(type) {
+	case *EpsilonTransition:
+		gt.Label = "ε"
+		if t.outermostPrecedenceReturn >= 0 {
+			gt.OutermostPrecedenceReturn = graphInt(t.outermostPrecedenceReturn)
+		}
+	case *RuleTransition:
+		gt.Label = ruleName(t.ruleIndex)
+		gt.Rule = gt.Label
+		gt.RuleIndex = graphInt(t.ruleIndex)
+		gt.FollowState = graphStateNumber(t.followState)
+		gt.Precedence = graphInt(t.precedence)
+	case *PredicateTransition:
+		gt.Label = t.String()
+		gt.Rule = ruleName(t.ruleIndex)
+		gt.RuleIndex = graphInt(t.ruleIndex)
+		gt.PredIndex = graphInt(t.predIndex)
+		gt.CtxDependent = t.isCtxDependent
+	case *PrecedencePredicateTransition:
+		gt.Label = t.String()
+		gt.Precedence = graphInt(t.precedence)
+	case *ActionTransition:
+		gt.Label = t.String()
+		gt.Rule = ruleName(t.ruleIndex)
+		gt.RuleIndex = graphInt(t.ruleIndex)
+		gt.ActionIndex = graphInt(t.actionIndex)
+		gt.CtxDependent = t.isCtxDependent
+	case *WildcardTransition:
+		gt.Label = "."
+	default:
+		if label := t.getLabel(); label != nil {
+			gt.Label = transitionSymbolsString(t, literalNames, symbolicNames, isLexer)
+			for _, v := range label.intervals {
+				gt.Intervals = append(gt.Intervals, [2]int{v.Start, v.Stop - 1})
+			}
+		}
+	}
+
+	return gt
+}
+
+// transitionSymbolsString returns the display form of the symbols a RANGE,
+// ATOM, SET or NOT_SET transition matches: characters for a lexer, and
+// otherwise token names, or token types without names.
+func transitionSymbolsString(t Transition, literalNames, symbolicNames []string, isLexer bool) string {
+	var s string
+	switch {
+	case isLexer:
+		s = t.getLabel().StringVerbose(nil, nil, true)
+	default:
+		s = t.getLabel().StringVerbose(literalNames, symbolicNames, false)
+	}
+	if t.getSerializationType() == TransitionNOTSET {
+		s = "~" + s
+	}
+
+	return s
+}
+
+func lexerActionString(action LexerAction) string {
+	switch action := action.(type) {
+	case fmt.Stringer:
+		return action.String()
+	case *LexerCustomAction:
+		return "custom(" + strconv.Itoa(action.ruleIndex) + "," + strconv.Itoa(action.actionIndex) + ")"
+	case *LexerIndexedCustomAction:
+		return lexerActionString(action.lexerAction) + "@" + strconv.Itoa(action.offset)
+	}
+
+	return "action" + strconv.Itoa(action.getActionType())
+}
+
+// WriteJSON writes g as JSON. The fields and their order only depend on
+// the ATN and the names of the recognizer.
+func (g *ATNGraph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the whole ATN as a Graphviz digraph, with the states of
+// each rule in a cluster labelled with the rule name. RULE transitions are
+// drawn as dashed edges, labelled with the rule invoked, to the state
+// following the invocation, rather than to the start of the rule, and the
+// transitions of rule stop states back to those states are left out.
+func (g *ATNGraph) WriteDOT(w io.Writer) error {
+	return g.writeDOT(w, "ATN", -1)
+}
+
+// WriteRuleDOT writes the states of the rule named rule as a Graphviz
+// digraph, as WriteDOT does for the whole ATN.
+func (g *ATNGraph) WriteRuleDOT(w io.Writer, rule string) error {
+	for _, r := range g.Rules {
+		if r.Name == rule {
+			return g.writeDOT(w, rule, r.Index)
+		}
+	}
+
+	return fmt.Errorf("no rule %s in the ATN", rule)
+}
+
+// writeDOT writes the states of rule ruleIndex, or of all rules if
+// ruleIndex is negative.
+func (g *ATNGraph) writeDOT(w io.Writer, name string, ruleIndex int) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", dotQuote(name))
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=circle, fontsize=11];")
+	fmt.Fprintln(bw, "\tedge [fontsize=11];")
+
+	byRule := make(map[int][]*ATNGraphState)
+	var included []*ATNGraphState
+	for _, s := range g.States {
+		if ruleIndex >= 0 && (s.RuleIndex != ruleIndex || s.Type == "TOKEN_START") {
+			continue
+		}
+		included = append(included, s)
+		byRule[s.RuleIndex] = append(byRule[s.RuleIndex], s)
+	}
+
+	if ruleIndex >= 0 {
+		for _, s := range included {
+			writeDOTState(bw, "\t", s)
+		}
+	} else {
+		for _, r := range g.Rules {
+			fmt.Fprintf(bw, "\tsubgraph cluster_%d {\n", r.Index)
+			fmt.Fprintf(bw, "\t\tlabel=%s;\n", dotQuote(r.Name))
+			for _, s := range byRule[r.Index] {
+				if s.Type != "TOKEN_START" {
+					writeDOTState(bw, "\t\t", s)
+				}
+			}
+			fmt.Fprintln(bw, "\t}")
+		}
+		for _, s := range included {
+			if s.Type == "TOKEN_START" || s.RuleIndex < 0 || s.RuleIndex >= len(g.Rules) {
+				writeDOTState(bw, "\t", s)
+			}
+		}
+	}
+
+	for _, s := range included {
+		if s.Type == "RULE_STOP" {
+			continue
+		}
+		for _, t := range s.Transitions {
+			if t.FollowState != nil {
+				fmt.Fprintf(bw, "\ts%d -> s%d [label=%s, style=dashed];\n", s.Number, *t.FollowState, dotQuote(t.Label))
+			} else {
+				fmt.Fprintf(bw, "\ts%d -> s%d [label=%s];\n", s.Number, t.Target, dotQuote(t.Label))
+			}
+		}
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func writeDOTState(w io.Writer, indent string, s *ATNGraphState) {
+	label := "s" + strconv.Itoa(s.Number)
+	if s.Type != "BASIC" {
+		label += "\n" + s.Type
+	}
+	if s.Decision != nil {
+		label += "\nd=" + strconv.Itoa(*s.Decision)
+	}
+
+	shape := ""
+	if s.Type == "RULE_STOP" {
+		shape = ", shape=doublecircle"
+	}
+
+	fmt.Fprintf(w, "%ss%d [label=%s%s];\n", indent, s.Number, dotQuote(label), shape)
+}
+
+// dotQuote quotes s as a DOT string, in which \n is a line break.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func graphName(names []string, i int) string {
+	if i >= 0 && i < len(names) {
+		return names[i]
+	}
+
+	return strconv.Itoa(i)
+}
+
+func graphInt(i int) *int {
+	return &i
+}
+
+func graphStateNumber(s ATNState) *int {
+	if s == nil {
+		return nil
+	}
+
+	return graphInt(s.GetStateNumber())
+}
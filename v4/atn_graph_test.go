@@ -0,0 +1,127 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestATNGraphParser(t *testing.T) {
+	parser := newQuietExprParser("")
+	g := NewATNGraph(exprParserATN, parser)
+
+	if g.GrammarType != "parser" || len(g.Rules) != 3 || g.Rules[ExprParserRULE_expr].Name != "expr" || !g.Rules[ExprParserRULE_expr].PrecedenceRule {
+		t.Errorf("unexpected rules %+v", g.Rules)
+	}
+	if len(g.Decisions) != len(exprParserATN.DecisionToState) {
+		t.Errorf("expected %d decisions, got %d", len(exprParserATN.DecisionToState), len(g.Decisions))
+	}
+
+	var ruleCall, precedence, token *ATNGraphTransition
+	for _, s := range g.States {
+		if s.Number != exprParserATN.states[s.Number].GetStateNumber() {
+			t.Fatalf("expected states in state number order, got %d", s.Number)
+		}
+		for _, tr := range s.Transitions {
+			switch {
+			case tr.Type == "RULE" && tr.Rule == "expr" && s.Rule == "stat":
+				ruleCall = tr
+			case tr.Type == "PRECEDENCE":
+				precedence = tr
+			case tr.Type == "ATOM" && tr.Label == "'='":
+				token = tr
+			}
+		}
+	}
+	if ruleCall == nil || ruleCall.FollowState == nil || ruleCall.Target != g.Rules[ExprParserRULE_expr].StartState {
+		t.Errorf("expected a call of expr from stat, got %+v", ruleCall)
+	}
+	if precedence == nil || precedence.Precedence == nil || !strings.HasSuffix(precedence.Label, ">= _p") {
+		t.Errorf("expected a precedence predicate, got %+v", precedence)
+	}
+	if token == nil || len(token.Intervals) != 1 || token.Intervals[0] != [2]int{1, 1} {
+		t.Errorf("expected a match of '=', got %+v", token)
+	}
+
+	// The JSON form is stable and reads back as the same graph.
+	var first, second bytes.Buffer
+	if err := g.WriteJSON(&first); err != nil {
+		t.Fatalf("writing JSON: %v", err)
+	}
+	if err := NewATNGraph(exprParserATN, parser).WriteJSON(&second); err != nil {
+		t.Fatalf("writing JSON: %v", err)
+	}
+	if first.String() != second.String() {
+		t.Error("expected the same JSON for the same ATN")
+	}
+	var read ATNGraph
+	if err := json.Unmarshal(first.Bytes(), &read); err != nil {
+		t.Fatalf("reading JSON: %v", err)
+	}
+	if len(read.States) != len(g.States) || read.Rules[ExprParserRULE_stat].Name != "stat" {
+		t.Errorf("unexpected graph read back %+v", read)
+	}
+}
+
+func TestATNGraphLexer(t *testing.T) {
+	lexer := newExprLexer(NewInputStream(""))
+	g := NewATNGraph(exprLexerATN, lexer)
+
+	if g.GrammarType != "lexer" || len(g.Modes) != 1 || g.Modes[0].Name != "DEFAULT_MODE" {
+		t.Errorf("unexpected modes %+v", g.Modes)
+	}
+	for i, r := range g.Rules {
+		if r.TokenType == nil || *r.TokenType != exprLexerATN.ruleToTokenType[i] {
+			t.Errorf("rule %s: expected token type %d, got %v", r.Name, exprLexerATN.ruleToTokenType[i], r.TokenType)
+		}
+	}
+
+	labels := make(map[string]bool)
+	for _, s := range g.States {
+		for _, tr := range s.Transitions {
+			labels[tr.Label] = true
+		}
+	}
+	for _, want := range []string{"'a'..'z'", "'='"} {
+		if !labels[want] {
+			t.Errorf("expected a transition labelled %s, got %v", want, labels)
+		}
+	}
+}
+
+func TestATNGraphDOT(t *testing.T) {
+	g := NewATNGraph(exprParserATN, newQuietExprParser(""))
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatalf("writing DOT: %v", err)
+	}
+	for _, want := range []string{"digraph \"ATN\" {\n", "subgraph cluster_0 {", "label=\"prog\";", "label=\"'='\"", "label=\"expr\", style=dashed", "shape=doublecircle"} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("expected DOT to contain %q, got\n%s", want, dot.String())
+		}
+	}
+
+	var rule bytes.Buffer
+	if err := g.WriteRuleDOT(&rule, "stat"); err != nil {
+		t.Fatalf("writing DOT: %v", err)
+	}
+	stop := g.Rules[ExprParserRULE_stat].StopState
+	if !strings.Contains(rule.String(), "digraph \"stat\" {") || strings.Contains(rule.String(), "cluster") ||
+		!strings.Contains(rule.String(), "\ts"+strconv.Itoa(stop)+" [label=") {
+		t.Errorf("unexpected DOT for rule stat\n%s", rule.String())
+	}
+	if strings.Contains(rule.String(), "\ts"+strconv.Itoa(g.Rules[ExprParserRULE_prog].StartState)+" [") {
+		t.Errorf("expected DOT for rule stat not to contain the states of prog\n%s", rule.String())
+	}
+
+	if err := g.WriteRuleDOT(&rule, "nosuchrule"); err == nil {
+		t.Error("expected an error for an unknown rule")
+	}
+}
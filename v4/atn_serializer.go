@@ -0,0 +1,374 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ATNSerializer writes an ATN in the serialized form the ANTLR tool
+// generates and ATNDeserializer reads, version SerializedVersion with UUID
+// SerializedUUID. Deserializing the result gives back an equivalent ATN, so
+// an ATN built or transformed in Go can be persisted as the serialized ATN
+// of a generated parser or lexer.
+//
+// <p>The transitions of rule stop states, and the links between block,
+// loop and rule start and end states that ATNDeserializer derives, are
+// not serialized.</p>
+type ATNSerializer struct {
+	atn *ATN
+
+	data []int
+
+	// bmpSets and smpSets hold the sets of SET and NOT_SET transitions
+	// within the Basic Multilingual Plane and beyond it, and setIndices
+	// maps the key of each set to its index.
+	bmpSets, smpSets []*IntervalSet
+	setIndices       map[string]int
+}
+
+func NewATNSerializer(atn *ATN) *ATNSerializer {
+	return &ATNSerializer{atn: atn}
+}
+
+// Serialize returns the serialized form of the ATN.
+//
+// @panics if the ATN refers to a removed state, or holds a value that does
+// not fit the serialized form.
+func (s *ATNSerializer) Serialize() []uint16 {
+	s.data = make([]int, 0)
+	s.bmpSets = make([]*IntervalSet, 0)
+	s.smpSets = make([]*IntervalSet, 0)
+	s.setIndices = make(map[string]int)
+
+	s.add(SerializedVersion)
+	s.addUUID(SerializedUUID)
+	s.add(s.atn.grammarType, s.atn.maxTokenType)
+
+	s.writeStates()
+	s.writeRules()
+	s.writeModes()
+
+	// Sets are numbered in the order they are written, BMP sets first.
+	for i, set := range s.bmpSets {
+		s.setIndices[intervalSetKey(set)] = i
+	}
+	for i, set := range s.smpSets {
+		s.setIndices[intervalSetKey(set)] = len(s.bmpSets) + i
+	}
+	s.writeSets(s.bmpSets, false)
+	s.writeSets(s.smpSets, true)
+	s.writeEdges()
+	s.writeDecisions()
+	s.writeLexerActions()
+
+	serialized := make([]uint16, len(s.data))
+	for i, v := range s.data {
+		if v < 0 || v > 0xFFFF {
+			panic("serialized ATN value " + strconv.Itoa(v) + " is out of range")
+		}
+		// Don't adjust the first value since that's the version number
+		if i == 0 {
+			serialized[i] = uint16(v)
+		} else {
+			serialized[i] = uint16((v + 2) & 0xFFFF)
+		}
+	}
+
+	return serialized
+}
+
+func (s *ATNSerializer) add(values ...int) {
+	s.data = append(s.data, values...)
+}
+
+// addOrMax adds v, or 0xFFFF, read back as -1, if v is -1.
+func (s *ATNSerializer) addOrMax(v int) {
+	if v == -1 {
+		v = 0xFFFF
+	}
+	s.add(v)
+}
+
+func (s *ATNSerializer) addBool(b bool) {
+	if b {
+		s.add(1)
+	} else {
+		s.add(0)
+	}
+}
+
+// addUUID adds uuid as 8 16-bit values, least significant first, as
+// ATNDeserializer.readUUID reads it.
+func (s *ATNSerializer) addUUID(uuid string) {
+	bb, err := hex.DecodeString(strings.Replace(uuid, "-", "", -1))
+	if err != nil || len(bb) != 16 {
+		panic("invalid UUID " + uuid)
+	}
+
+	for i := 7; i >= 0; i-- {
+		s.add(int(bb[2*i])<<8 | int(bb[2*i+1]))
+	}
+}
+
+func (s *ATNSerializer) writeStates() {
+	var nonGreedyStates, precedenceStates []int
+
+	s.add(len(s.atn.states))
+	for _, state := range s.atn.states {
+		if state == nil {
+			// might be optimized away
+			s.add(ATNStateInvalidType)
+			continue
+		}
+
+		if ds, ok := state.(DecisionState); ok && ds.getNonGreedy() {
+			nonGreedyStates = append(nonGreedyStates, state.GetStateNumber())
+		}
+		if rs, ok := state.(*RuleStartState); ok && rs.isPrecedenceRule {
+			precedenceStates = append(precedenceStates, state.GetStateNumber())
+		}
+
+		s.add(state.GetStateType())
+		s.addOrMax(state.GetRuleIndex())
+
+		if le, ok := state.(*LoopEndState); ok {
+			s.add(s.stateNumber(le.loopBackState))
+		} else if bs, ok := state.(BlockStartState); ok {
+			if bs.getEndState() == nil {
+				panic("block start state " + strconv.Itoa(state.GetStateNumber()) + " has no end state")
+			}
+			s.add(s.stateNumber(bs.getEndState()))
+		}
+
+		for _, t := range state.GetTransitions() {
+			switch t.getSerializationType() {
+			case TransitionSET, TransitionNOTSET:
+				s.addSet(t.getLabel())
+			}
+		}
+	}
+
+	s.add(len(nonGreedyStates))
+	s.add(nonGreedyStates...)
+
+	s.add(len(precedenceStates))
+	s.add(precedenceStates...)
+}
+
+func (s *ATNSerializer) writeRules() {
+	s.add(len(s.atn.ruleToStartState))
+	for r, start := range s.atn.ruleToStartState {
+		s.add(s.stateNumber(start))
+		if s.atn.grammarType == ATNTypeLexer {
+			s.addOrMax(s.atn.ruleToTokenType[r])
+		}
+	}
+}
+
+func (s *ATNSerializer) writeModes() {
+	s.add(len(s.atn.modeToStartState))
+	for _, start := range s.atn.modeToStartState {
+		s.add(s.stateNumber(start))
+	}
+}
+
+// addSet records set, unless an equal set was recorded already.
+func (s *ATNSerializer) addSet(set *IntervalSet) {
+	key := intervalSetKey(set)
+	if _, ok := s.setIndices[key]; ok {
+		return
+	}
+
+	s.setIndices[key] = -1
+	if len(set.intervals) > 0 && set.intervals[len(set.intervals)-1].Stop-1 > 0xFFFF {
+		s.smpSets = append(s.smpSets, set)
+	} else {
+		s.bmpSets = append(s.bmpSets, set)
+	}
+}
+
+func (s *ATNSerializer) writeSets(sets []*IntervalSet, smp bool) {
+	s.add(len(sets))
+	for _, set := range sets {
+		intervals := set.intervals
+
+		containsEOF := set.contains(TokenEOF)
+		if containsEOF && intervals[0].Stop-1 == TokenEOF {
+			s.add(len(intervals) - 1)
+		} else {
+			s.add(len(intervals))
+		}
+		s.addBool(containsEOF)
+
+		for _, v := range intervals {
+			if v.Start == TokenEOF {
+				if v.Stop-1 == TokenEOF {
+					continue
+				}
+				s.addCodePoint(0, smp)
+			} else {
+				s.addCodePoint(v.Start, smp)
+			}
+			s.addCodePoint(v.Stop-1, smp)
+		}
+	}
+}
+
+func (s *ATNSerializer) addCodePoint(c int, smp bool) {
+	if smp {
+		s.add(c&0xFFFF, (c>>16)&0xFFFF)
+	} else {
+		s.add(c)
+	}
+}
+
+func (s *ATNSerializer) writeEdges() {
+	nedges := 0
+	for _, state := range s.atn.states {
+		// Edges for rule stop states can be derived, so they are not
+		// serialized
+		if state != nil && state.GetStateType() != ATNStateRuleStop {
+			nedges += len(state.GetTransitions())
+		}
+	}
+
+	s.add(nedges)
+	for _, state := range s.atn.states {
+		if state == nil || state.GetStateType() == ATNStateRuleStop {
+			continue
+		}
+
+		for _, t := range state.GetTransitions() {
+			src := state.GetStateNumber()
+			trg := s.stateNumber(t.getTarget())
+			arg1, arg2, arg3 := 0, 0, 0
+
+			switch t := t.(type) {
+			case *RuleTransition:
+				trg = s.stateNumber(t.followState)
+				arg1 = s.stateNumber(t.getTarget())
+				arg2 = t.ruleIndex
+				arg3 = t.precedence
+
+			case *PrecedencePredicateTransition:
+				arg1 = t.precedence
+
+			case *PredicateTransition:
+				arg1 = t.ruleIndex
+				arg2 = t.predIndex
+				if t.isCtxDependent {
+					arg3 = 1
+				}
+
+			case *RangeTransition:
+				arg1 = t.start
+				arg2 = t.stop
+				if arg1 == TokenEOF {
+					arg1 = 0
+					arg3 = 1
+				}
+
+			case *AtomTransition:
+				arg1 = t.label
+				if arg1 == TokenEOF {
+					arg1 = 0
+					arg3 = 1
+				}
+
+			case *ActionTransition:
+				arg1 = t.ruleIndex
+				arg2 = t.actionIndex
+				if arg2 == -1 {
+					arg2 = 0xFFFF
+				}
+				if t.isCtxDependent {
+					arg3 = 1
+				}
+
+			case *SetTransition:
+				arg1 = s.setIndices[intervalSetKey(t.getLabel())]
+
+			case *NotSetTransition:
+				arg1 = s.setIndices[intervalSetKey(t.getLabel())]
+			}
+
+			s.add(src, trg, t.getSerializationType(), arg1, arg2, arg3)
+		}
+	}
+}
+
+func (s *ATNSerializer) writeDecisions() {
+	s.add(len(s.atn.DecisionToState))
+	for _, ds := range s.atn.DecisionToState {
+		s.add(s.stateNumber(ds))
+	}
+}
+
+func (s *ATNSerializer) writeLexerActions() {
+	if s.atn.grammarType != ATNTypeLexer {
+		return
+	}
+
+	s.add(len(s.atn.lexerActions))
+	for _, action := range s.atn.lexerActions {
+		s.add(action.getActionType())
+
+		switch action := action.(type) {
+		case *LexerChannelAction:
+			s.addOrMax(action.channel)
+			s.add(0)
+
+		case *LexerCustomAction:
+			s.addOrMax(action.ruleIndex)
+			s.addOrMax(action.actionIndex)
+
+		case *LexerModeAction:
+			s.addOrMax(action.mode)
+			s.add(0)
+
+		case *LexerPushModeAction:
+			s.addOrMax(action.mode)
+			s.add(0)
+
+		case *LexerTypeAction:
+			s.addOrMax(action.thetype)
+			s.add(0)
+
+		case *LexerMoreAction, *LexerPopModeAction, *LexerSkipAction:
+			s.add(0, 0)
+
+		default:
+			panic(fmt.Sprintf("cannot serialize lexer action %T", action))
+		}
+	}
+}
+
+// stateNumber returns the number of state, which must be a state of the
+// ATN.
+func (s *ATNSerializer) stateNumber(state ATNState) int {
+	n := state.GetStateNumber()
+	if n < 0 || n >= len(s.atn.states) || s.atn.states[n] != state {
+		panic("cannot serialize a reference to removed state " + strconv.Itoa(n))
+	}
+
+	return n
+}
+
+// intervalSetKey returns a key equal for sets holding the same values.
+func intervalSetKey(set *IntervalSet) string {
+	var b strings.Builder
+	for _, v := range set.intervals {
+		b.WriteString(strconv.Itoa(v.Start))
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(v.Stop))
+		b.WriteByte(' ')
+	}
+
+	return b.String()
+}
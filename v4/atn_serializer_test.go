@@ -0,0 +1,210 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestATNSerializerRoundTrip(t *testing.T) {
+	for name, serialized := range map[string][]uint16{
+		"lexer":       serializerLexerATN,
+		"parser":      serializerParserATN,
+		"expr lexer":  exprSerializedLexerATN,
+		"expr parser": exprSerializedParserATN,
+	} {
+		atn := NewATNDeserializer(nil).DeserializeFromUInt16(serialized)
+		reserialized := NewATNSerializer(atn).Serialize()
+		if len(reserialized) != len(serialized) {
+			t.Errorf("%s: expected %d values, got %d", name, len(serialized), len(reserialized))
+			continue
+		}
+		for i := range serialized {
+			if reserialized[i] != serialized[i] {
+				t.Errorf("%s: expected %d at %d, got %d", name, serialized[i], i, reserialized[i])
+				break
+			}
+		}
+
+		// The ATN read back is the same as the ATN serialized.
+		var want, got bytes.Buffer
+		if err := NewATNGraph(atn, nil).WriteJSON(&want); err != nil {
+			t.Fatalf("%s: writing JSON: %v", name, err)
+		}
+		if err := NewATNGraph(NewATNDeserializer(nil).DeserializeFromUInt16(reserialized), nil).WriteJSON(&got); err != nil {
+			t.Fatalf("%s: writing JSON: %v", name, err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("%s: expected ATN\n%s\ngot\n%s", name, want.String(), got.String())
+		}
+	}
+}
+
+func TestATNSerializerCoversAllTypes(t *testing.T) {
+	stateTypes := make(map[int]bool)
+	transitionTypes := make(map[int]bool)
+	actionTypes := make(map[int]bool)
+
+	for _, serialized := range [][]uint16{serializerLexerATN, serializerParserATN} {
+		atn := NewATNDeserializer(nil).DeserializeFromUInt16(serialized)
+		for _, s := range atn.states {
+			if s == nil {
+				stateTypes[ATNStateInvalidType] = true
+				continue
+			}
+			stateTypes[s.GetStateType()] = true
+			for _, tr := range s.GetTransitions() {
+				transitionTypes[tr.getSerializationType()] = true
+			}
+		}
+		for _, a := range atn.lexerActions {
+			actionTypes[a.getActionType()] = true
+		}
+	}
+
+	for i, name := range ATNStateSerializationNames {
+		if !stateTypes[i] {
+			t.Errorf("expected a state of type %s", name)
+		}
+	}
+	for i := TransitionEPSILON; i <= TransitionPRECEDENCE; i++ {
+		if !transitionTypes[i] {
+			t.Errorf("expected a transition of type %s", TransitionserializationNames[i])
+		}
+	}
+	for i := LexerActionTypeChannel; i <= LexerActionTypeType; i++ {
+		if !actionTypes[i] {
+			t.Errorf("expected a lexer action of type %d", i)
+		}
+	}
+}
+
+// serializerLexerATN is a lexer ATN with every kind of state, transition
+// and lexer action, built for
+//
+//	A : [a-z]+ -> skip ;
+//	B : '/*' .*? '*/' -> channel(1), more ;
+//	C : ([\u{1F600}-\u{1F64F}ab] | ~[x]) -> pushMode(1), type(5), mode(0) ;
+//	mode M;
+//	D : ('q' | 'r') {p}? E -> popMode, {a} ;
+//	fragment E : 'e' | EOF ;
+var serializerLexerATN = []uint16{
+	3, 24715, 42794, 33075, 47597, 16764, 15335, 30598, 22884, 2,
+	8, 73, 8, 1, 8, 1, 4, 2, 9, 2,
+	4, 3, 9, 3, 4, 4, 9, 4, 4, 5,
+	9, 5, 4, 6, 9, 6, 3, 2, 3, 2,
+	6, 2, 17, 10, 2, 13, 2, 14, 2, 18,
+	3, 2, 3, 2, 3, 3, 3, 3, 12, 3,
+	7, 3, 26, 10, 3, 11, 3, 14, 3, 27,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 4, 3, 4, 3, 4,
+	3, 4, 5, 4, 46, 10, 4, 3, 4, 3,
+	4, 3, 4, 3, 4, 3, 4, 3, 4, 3,
+	5, 3, 5, 3, 5, 3, 5, 3, 5, 3,
+	5, 5, 5, 60, 10, 5, 3, 5, 3, 5,
+	3, 5, 3, 5, 3, 5, 3, 5, 3, 6,
+	3, 6, 3, 6, 3, 6, 5, 6, 72, 10,
+	6, 4, 24, 25, 2, 7, 4, 3, 6, 4,
+	8, 5, 10, 6, 12, 2, 4, 2, 3, 3,
+	3, 2, 122, 122, 3, 4, 2, 99, 2, 100,
+	2, 62978, 3, 63057, 3, 75, 2, 4, 3, 2,
+	2, 2, 2, 6, 3, 2, 2, 2, 2, 8,
+	3, 2, 2, 2, 3, 10, 3, 2, 2, 2,
+	4, 16, 3, 2, 2, 2, 6, 29, 3, 2,
+	2, 2, 8, 45, 3, 2, 2, 2, 10, 59,
+	3, 2, 2, 2, 12, 71, 3, 2, 2, 2,
+	14, 15, 4, 99, 124, 2, 15, 17, 3, 2,
+	2, 2, 16, 14, 3, 2, 2, 2, 17, 18,
+	3, 2, 2, 2, 18, 16, 3, 2, 2, 2,
+	18, 19, 3, 2, 2, 2, 19, 20, 3, 2,
+	2, 2, 20, 21, 8, 2, 2, 2, 21, 5,
+	3, 2, 2, 2, 22, 23, 11, 2, 2, 2,
+	23, 26, 3, 2, 2, 2, 24, 28, 3, 2,
+	2, 2, 24, 25, 3, 2, 2, 2, 25, 22,
+	3, 2, 2, 2, 26, 27, 3, 2, 2, 2,
+	27, 24, 3, 2, 2, 2, 28, 33, 3, 2,
+	2, 2, 29, 30, 7, 49, 2, 2, 30, 31,
+	3, 2, 2, 2, 31, 32, 7, 44, 2, 2,
+	32, 24, 3, 2, 2, 2, 33, 34, 7, 44,
+	2, 2, 34, 35, 3, 2, 2, 2, 35, 36,
+	7, 49, 2, 2, 36, 37, 3, 2, 2, 2,
+	37, 38, 8, 3, 3, 2, 38, 39, 3, 2,
+	2, 2, 39, 40, 8, 3, 4, 2, 40, 7,
+	3, 2, 2, 2, 41, 42, 10, 2, 2, 2,
+	42, 46, 3, 2, 2, 2, 43, 44, 9, 3,
+	2, 2, 44, 46, 3, 2, 2, 2, 45, 43,
+	3, 2, 2, 2, 45, 41, 3, 2, 2, 2,
+	46, 47, 3, 2, 2, 2, 47, 48, 8, 4,
+	5, 2, 48, 49, 3, 2, 2, 2, 49, 50,
+	8, 4, 6, 2, 50, 51, 3, 2, 2, 2,
+	51, 52, 8, 4, 7, 2, 52, 9, 3, 2,
+	2, 2, 53, 54, 6, 5, 2, 3, 54, 61,
+	3, 2, 2, 2, 55, 56, 7, 115, 2, 2,
+	56, 60, 3, 2, 2, 2, 57, 58, 7, 116,
+	2, 2, 58, 60, 3, 2, 2, 2, 59, 55,
+	3, 2, 2, 2, 59, 57, 3, 2, 2, 2,
+	60, 53, 3, 2, 2, 2, 61, 62, 5, 12,
+	6, 2, 62, 63, 3, 2, 2, 2, 63, 64,
+	8, 5, 8, 2, 64, 65, 3, 2, 2, 2,
+	65, 66, 8, 5, 9, 2, 66, 11, 3, 2,
+	2, 2, 67, 68, 7, 103, 2, 2, 68, 72,
+	3, 2, 2, 2, 69, 70, 7, 2, 2, 3,
+	70, 72, 3, 2, 2, 2, 71, 67, 3, 2,
+	2, 2, 71, 69, 3, 2, 2, 2, 72, 13,
+	3, 2, 2, 2, 11, 2, 3, 16, 18, 24,
+	25, 45, 59, 71, 10, 8, 2, 2, 2, 3,
+	2, 5, 2, 2, 7, 3, 2, 9, 7, 2,
+	4, 2, 2, 6, 2, 2, 3, 5, 2,
+}
+
+// serializerParserATN is a parser ATN with every kind of parser state and
+// transition, and a removed state, built for
+//
+//	s : a? e EOF ;
+//	a : {p}? . | ~(T1 | T2) | {a} (T3 | EOF) | (EOF..T2) ;
+//	e : INT ( {3 >= _p}? '*' e[4] )* ;
+var serializerParserATN = []uint16{
+	3, 24715, 42794, 33075, 47597, 16764, 15335, 30598, 22884, 3,
+	8, 45, 4, 2, 9, 2, 4, 3, 9, 3,
+	4, 4, 9, 4, 2, 3, 2, 3, 2, 3,
+	2, 5, 2, 13, 10, 2, 3, 2, 3, 2,
+	3, 2, 3, 2, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 5, 3,
+	31, 10, 3, 3, 4, 3, 4, 3, 4, 3,
+	4, 3, 4, 3, 4, 3, 4, 3, 4, 12,
+	4, 7, 4, 42, 10, 4, 11, 4, 14, 4,
+	43, 2, 3, 6, 5, 2, 4, 6, 2, 4,
+	3, 2, 3, 4, 3, 3, 5, 5, 2, 46,
+	2, 12, 3, 2, 2, 2, 4, 30, 3, 2,
+	2, 2, 6, 32, 3, 2, 2, 2, 9, 13,
+	3, 2, 2, 2, 10, 11, 5, 4, 3, 2,
+	11, 13, 3, 2, 2, 2, 12, 10, 3, 2,
+	2, 2, 12, 9, 3, 2, 2, 2, 13, 14,
+	3, 2, 2, 2, 14, 15, 5, 6, 4, 2,
+	15, 16, 3, 2, 2, 2, 16, 17, 7, 2,
+	2, 3, 17, 3, 3, 2, 2, 2, 18, 19,
+	6, 3, 2, 2, 19, 20, 3, 2, 2, 2,
+	20, 21, 11, 2, 2, 2, 21, 31, 3, 2,
+	2, 2, 22, 23, 10, 2, 2, 2, 23, 31,
+	3, 2, 2, 2, 24, 25, 8, 3, 1, 2,
+	25, 26, 3, 2, 2, 2, 26, 27, 9, 3,
+	2, 2, 27, 31, 3, 2, 2, 2, 28, 29,
+	4, 2, 4, 3, 29, 31, 3, 2, 2, 2,
+	30, 18, 3, 2, 2, 2, 30, 22, 3, 2,
+	2, 2, 30, 24, 3, 2, 2, 2, 30, 28,
+	3, 2, 2, 2, 31, 5, 3, 2, 2, 2,
+	32, 33, 7, 7, 2, 2, 33, 40, 3, 2,
+	2, 2, 34, 35, 12, 5, 2, 2, 35, 36,
+	3, 2, 2, 2, 36, 37, 7, 6, 2, 2,
+	37, 38, 3, 2, 2, 2, 38, 39, 5, 6,
+	4, 6, 39, 42, 3, 2, 2, 2, 40, 41,
+	3, 2, 2, 2, 40, 44, 3, 2, 2, 2,
+	41, 34, 3, 2, 2, 2, 42, 43, 3, 2,
+	2, 2, 43, 40, 3, 2, 2, 2, 44, 7,
+	3, 2, 2, 2, 6, 12, 30, 40, 41,
+}
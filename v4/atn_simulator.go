@@ -10,6 +10,9 @@ type IATNSimulator interface {
 	SharedContextCache() *PredictionContextCache
 	ATN() *ATN
 	DecisionToDFA() []*DFA
+	ClearDFA()
+	SetCachePolicy(policy ATNCachePolicy)
+	CacheStats() ATNCacheStats
 }
 
 type BaseATNSimulator struct {
@@ -48,3 +51,76 @@ func (b *BaseATNSimulator) ATN() *ATN {
 func (b *BaseATNSimulator) DecisionToDFA() []*DFA {
 	return b.decisionToDFA
 }
+
+// ClearDFA removes all the states of the DFAs of the simulator, which are
+// shared with every other recognizer using the same DFAs, such as all the
+// instances of a generated parser or lexer. Predictions rebuild the states
+// from the ATN as needed. Use it to release the memory of DFA states built
+// for input that is no longer being parsed; the shared prediction context
+// cache is cleared separately, with SharedContextCache().Clear().
+func (b *BaseATNSimulator) ClearDFA() {
+	for _, dfa := range b.decisionToDFA {
+		dfa.Clear()
+	}
+}
+
+// ATNCachePolicy bounds the memory held by the DFAs and the prediction
+// context cache of an ATN simulator, which otherwise grow for as long as
+// new input is parsed. A limit of 0 means no limit.
+type ATNCachePolicy struct {
+	// MaxDFAStates is the maximum number of states of each DFA, i.e. of
+	// each decision for a parser, or of each mode for a lexer. A DFA that
+	// reaches it is cleared before its next state is added, and
+	// ReadDFASnapshot rejects a snapshot of a DFA with more states.
+	MaxDFAStates int
+
+	// MaxContextCacheSize is the maximum number of prediction contexts in
+	// the shared context cache. The cache is cleared when it reaches it.
+	MaxContextCacheSize int
+}
+
+// SetCachePolicy applies policy to the DFAs and the prediction context
+// cache of the simulator, which are shared with every other recognizer using
+// them.
+func (b *BaseATNSimulator) SetCachePolicy(policy ATNCachePolicy) {
+	for _, dfa := range b.decisionToDFA {
+		dfa.SetMaxStates(policy.MaxDFAStates)
+	}
+	if b.sharedContextCache != nil {
+		b.sharedContextCache.SetMaxSize(policy.MaxContextCacheSize)
+	}
+}
+
+// ATNCacheStats holds the sizes of the DFAs and the prediction context
+// cache of an ATN simulator, as returned by CacheStats.
+type ATNCacheStats struct {
+	// DFAStates is the number of states over all DFAs.
+	DFAStates int
+
+	// DFAClears is the number of times a DFA was cleared, over all DFAs.
+	DFAClears int64
+
+	// ContextCacheSize is the number of prediction contexts in the shared
+	// context cache.
+	ContextCacheSize int
+
+	// ContextCacheClears is the number of times the shared context cache was
+	// cleared.
+	ContextCacheClears int64
+}
+
+// CacheStats returns the current sizes of the DFAs and the prediction
+// context cache of the simulator.
+func (b *BaseATNSimulator) CacheStats() ATNCacheStats {
+	var stats ATNCacheStats
+	for _, dfa := range b.decisionToDFA {
+		stats.DFAStates += dfa.NumStates()
+		stats.DFAClears += dfa.Clears()
+	}
+	if b.sharedContextCache != nil {
+		stats.ContextCacheSize = b.sharedContextCache.Len()
+		stats.ContextCacheClears = b.sharedContextCache.Clears()
+	}
+
+	return stats
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"testing"
+)
+
+const cachePolicyInput = "a = 1 + 2 * (b + 3);\nc = a * (a + b * (4 + 5)) + 6;\nd = ((7));\n"
+
+func TestATNSimulatorClearDFA(t *testing.T) {
+	lexer, parser, tree := parseExprWithLexer(t, cachePolicyInput, false)
+
+	for _, sim := range []IATNSimulator{parser.GetInterpreter(), lexer.Interpreter} {
+		stats := sim.CacheStats()
+		if stats.DFAStates == 0 {
+			t.Fatalf("expected DFA states after parsing, got %+v", stats)
+		}
+
+		sim.ClearDFA()
+		if stats := sim.CacheStats(); stats.DFAStates != 0 || stats.DFAClears == 0 {
+			t.Errorf("expected cleared DFAs, got %+v", stats)
+		}
+	}
+
+	// The cleared DFAs are rebuilt for the same tree.
+	lexer.SetInputStream(NewInputStream(cachePolicyInput))
+	parser.SetInputStream(NewCommonTokenStream(lexer, TokenDefaultChannel))
+	rebuilt, syntaxErrors, err := parseExprProg(parser)
+	if err != nil || len(syntaxErrors) != 0 {
+		t.Fatalf("expected no errors, got %v, %v", err, syntaxErrors)
+	}
+	if got, want := rebuilt.ToStringTree(nil, parser), tree.ToStringTree(nil, parser); got != want {
+		t.Errorf("expected tree %s, got %s", want, got)
+	}
+	if stats := parser.GetInterpreter().CacheStats(); stats.DFAStates == 0 {
+		t.Errorf("expected DFA states to be rebuilt, got %+v", stats)
+	}
+}
+
+func TestATNSimulatorCachePolicy(t *testing.T) {
+	lexer := newExprLexer(NewInputStream(cachePolicyInput))
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(lexer, TokenDefaultChannel))
+
+	policy := ATNCachePolicy{MaxDFAStates: 2, MaxContextCacheSize: 1}
+	parser.GetInterpreter().SetCachePolicy(policy)
+	lexer.Interpreter.SetCachePolicy(policy)
+
+	tree, syntaxErrors, err := parseExprProg(parser)
+	if err != nil || len(syntaxErrors) != 0 {
+		t.Fatalf("expected no errors, got %v, %v", err, syntaxErrors)
+	}
+
+	_, unbounded, unboundedTree := parseExprWithLexer(t, cachePolicyInput, false)
+	if got, want := tree.ToStringTree(nil, parser), unboundedTree.ToStringTree(nil, unbounded); got != want {
+		t.Errorf("expected tree %s, got %s", want, got)
+	}
+
+	for _, dfa := range append(parser.GetInterpreter().DecisionToDFA(), lexer.Interpreter.DecisionToDFA()...) {
+		if n := dfa.NumStates(); n > policy.MaxDFAStates {
+			t.Errorf("DFA %d: expected at most %d states, got %d", dfa.decision, policy.MaxDFAStates, n)
+		}
+	}
+
+	stats := parser.GetInterpreter().CacheStats()
+	if stats.DFAClears == 0 {
+		t.Errorf("expected parser DFAs to be cleared, got %+v", stats)
+	}
+	if stats.ContextCacheSize > policy.MaxContextCacheSize {
+		t.Errorf("expected at most %d cached contexts, got %+v", policy.MaxContextCacheSize, stats)
+	}
+	if stats := lexer.Interpreter.CacheStats(); stats.DFAClears == 0 {
+		t.Errorf("expected lexer DFAs to be cleared, got %+v", stats)
+	}
+}
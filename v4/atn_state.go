@@ -25,6 +25,23 @@ const (
 	ATNStateInvalidStateNumber = -1
 )
 
+// ATNStateSerializationNames maps ATN state types to their names.
+var ATNStateSerializationNames = []string{
+	"INVALID",
+	"BASIC",
+	"RULE_START",
+	"BLOCK_START",
+	"PLUS_BLOCK_START",
+	"STAR_BLOCK_START",
+	"TOKEN_START",
+	"RULE_STOP",
+	"BLOCK_END",
+	"STAR_LOOP_BACK",
+	"STAR_LOOP_ENTRY",
+	"PLUS_LOOP_BACK",
+	"LOOP_END",
+}
+
 var ATNStateInitialNumTransitions = 4
 
 type ATNState interface {
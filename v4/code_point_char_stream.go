@@ -0,0 +1,325 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"io"
+	"io/ioutil"
+	"sort"
+	"unicode/utf8"
+)
+
+// codePointBlockSize is the number of code points between the byte offsets
+// a CodePointCharStream records to map between char indexes and byte
+// offsets.
+const codePointBlockSize = 64
+
+// CodePointCharStream is a CharStream over UTF-8 encoded input that keeps
+// track of where each code point came from in the input, so that char
+// indexes, such as Token.GetStart and Token.GetStop, can be converted to
+// byte offsets into the input and back.
+//
+// <p>Like InputStream it indexes by code point, but it stores the code
+// points compactly: in one byte each if every code point is below U+0100,
+// in two bytes each if every code point is in the Basic Multilingual
+// Plane, and in four bytes each otherwise.</p>
+//
+// <p>Invalid UTF-8 is decoded one byte at a time to U+FFFD, as by the
+// utf8 package, and each such byte counts as one char.</p>
+type CodePointCharStream struct {
+	// Name is the name of the source, returned by GetSourceName.
+	Name string
+
+	index int
+	size  int
+
+	// Exactly one of the following holds the code points, depending on
+	// the largest code point in the input.
+	data8  []uint8
+	data16 []uint16
+	data32 []rune
+
+	// byteLen is the length in bytes of the input.
+	byteLen int
+
+	// blockOffsets holds the byte offset of every codePointBlockSize-th
+	// char. It is nil if every char is a single byte, in which case char
+	// indexes and byte offsets are the same.
+	blockOffsets []int
+
+	// invalid holds, in order, the indexes of the chars decoded from
+	// invalid UTF-8, which are one byte long.
+	invalid []int
+}
+
+// NewCodePointCharStream creates a CodePointCharStream over the UTF-8
+// encoded data.
+func NewCodePointCharStream(data []byte) *CodePointCharStream {
+	return newCodePointCharStream(len(data), func(i int) (rune, int) {
+		return utf8.DecodeRune(data[i:])
+	})
+}
+
+// NewCodePointCharStreamFromString creates a CodePointCharStream over s.
+func NewCodePointCharStreamFromString(s string) *CodePointCharStream {
+	return newCodePointCharStream(len(s), func(i int) (rune, int) {
+		return utf8.DecodeRuneInString(s[i:])
+	})
+}
+
+// NewCodePointCharStreamFromReader creates a CodePointCharStream over the
+// UTF-8 encoded input read from r, up to EOF.
+func NewCodePointCharStreamFromReader(r io.Reader) (*CodePointCharStream, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCodePointCharStream(data), nil
+}
+
+// newCodePointCharStream decodes the byteLen bytes of input with decode,
+// which returns the code point at a byte offset and its width in bytes.
+func newCodePointCharStream(byteLen int, decode func(int) (rune, int)) *CodePointCharStream {
+	c := new(CodePointCharStream)
+	c.byteLen = byteLen
+
+	// first pass: count the code points and find the storage they need
+	maxCodePoint := rune(0)
+	for i := 0; i < byteLen; {
+		r, w := decode(i)
+		if r > maxCodePoint {
+			maxCodePoint = r
+		}
+		c.size++
+		i += w
+	}
+
+	switch {
+	case maxCodePoint <= 0xFF:
+		c.data8 = make([]uint8, c.size)
+	case maxCodePoint <= 0xFFFF:
+		c.data16 = make([]uint16, c.size)
+	default:
+		c.data32 = make([]rune, c.size)
+	}
+
+	if c.size != byteLen {
+		c.blockOffsets = make([]int, 0, c.size/codePointBlockSize+1)
+	}
+
+	// second pass: store the code points and record the byte offsets
+	n := 0
+	for i := 0; i < byteLen; n++ {
+		r, w := decode(i)
+		switch {
+		case c.data8 != nil:
+			c.data8[n] = uint8(r)
+		case c.data16 != nil:
+			c.data16[n] = uint16(r)
+		default:
+			c.data32[n] = r
+		}
+
+		if c.blockOffsets != nil {
+			if n%codePointBlockSize == 0 {
+				c.blockOffsets = append(c.blockOffsets, i)
+			}
+			if r == utf8.RuneError && w == 1 {
+				c.invalid = append(c.invalid, n)
+			}
+		}
+		i += w
+	}
+
+	return c
+}
+
+// at returns the code point at char index i.
+func (c *CodePointCharStream) at(i int) int {
+	switch {
+	case c.data8 != nil:
+		return int(c.data8[i])
+	case c.data16 != nil:
+		return int(c.data16[i])
+	default:
+		return int(c.data32[i])
+	}
+}
+
+// width returns the length in bytes of the UTF-8 encoding of the char at
+// index i in the input.
+func (c *CodePointCharStream) width(i int) int {
+	r := rune(c.at(i))
+	if r == utf8.RuneError {
+		if j := sort.SearchInts(c.invalid, i); j < len(c.invalid) && c.invalid[j] == i {
+			return 1
+		}
+	}
+
+	return utf8.RuneLen(r)
+}
+
+// ByteOffset returns the byte offset in the input of the char at index. An
+// index of Size() returns the length of the input, so the bytes of a token
+// t are ByteOffset(t.GetStart()) up to, but not including,
+// ByteOffset(t.GetStop()+1).
+//
+// @panics if index is not in 0..Size().
+func (c *CodePointCharStream) ByteOffset(index int) int {
+	if index < 0 || index > c.size {
+		panic("char index out of range")
+	}
+	if c.blockOffsets == nil {
+		return index
+	}
+	if index == c.size {
+		return c.byteLen
+	}
+
+	block := index / codePointBlockSize
+	offset := c.blockOffsets[block]
+	for i := block * codePointBlockSize; i < index; i++ {
+		offset += c.width(i)
+	}
+
+	return offset
+}
+
+// CharIndex returns the index of the char whose encoding in the input
+// contains the byte at byteOffset. A byteOffset equal to the length of the
+// input returns Size().
+//
+// @panics if byteOffset is not in 0..len(input).
+func (c *CodePointCharStream) CharIndex(byteOffset int) int {
+	if byteOffset < 0 || byteOffset > c.byteLen {
+		panic("byte offset out of range")
+	}
+	if c.blockOffsets == nil {
+		return byteOffset
+	}
+	if byteOffset == c.byteLen {
+		return c.size
+	}
+
+	// find the last block starting at or before byteOffset
+	block := sort.Search(len(c.blockOffsets), func(b int) bool {
+		return c.blockOffsets[b] > byteOffset
+	}) - 1
+
+	offset := c.blockOffsets[block]
+	i := block * codePointBlockSize
+	for {
+		offset += c.width(i)
+		if offset > byteOffset {
+			return i
+		}
+		i++
+	}
+}
+
+// ByteLength returns the length in bytes of the input.
+func (c *CodePointCharStream) ByteLength() int {
+	return c.byteLen
+}
+
+func (c *CodePointCharStream) Consume() {
+	if c.index >= c.size {
+		// assert c.LA(1) == TokenEOF
+		panic("cannot consume EOF")
+	}
+	c.index++
+}
+
+func (c *CodePointCharStream) LA(offset int) int {
+	if offset == 0 {
+		return 0 // nil
+	}
+	if offset < 0 {
+		offset++ // e.g., translate LA(-1) to use offset=0
+	}
+	pos := c.index + offset - 1
+
+	if pos < 0 || pos >= c.size { // invalid
+		return TokenEOF
+	}
+
+	return c.at(pos)
+}
+
+func (c *CodePointCharStream) Index() int {
+	return c.index
+}
+
+func (c *CodePointCharStream) Size() int {
+	return c.size
+}
+
+// mark/release do nothing we have entire buffer
+func (c *CodePointCharStream) Mark() int {
+	return -1
+}
+
+func (c *CodePointCharStream) Release(marker int) {
+}
+
+func (c *CodePointCharStream) Seek(index int) {
+	if index <= c.index {
+		c.index = index // just jump don't update stream state (line,...)
+		return
+	}
+	// seek forward
+	c.index = intMin(index, c.size)
+}
+
+func (c *CodePointCharStream) GetText(start int, stop int) string {
+	if stop >= c.size {
+		stop = c.size - 1
+	}
+	if start >= c.size || stop < start {
+		return ""
+	}
+
+	switch {
+	case c.data8 != nil:
+		runes := make([]rune, stop-start+1)
+		for i, b := range c.data8[start : stop+1] {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	case c.data16 != nil:
+		runes := make([]rune, stop-start+1)
+		for i, u := range c.data16[start : stop+1] {
+			runes[i] = rune(u)
+		}
+		return string(runes)
+	default:
+		return string(c.data32[start : stop+1])
+	}
+}
+
+func (c *CodePointCharStream) GetTextFromTokens(start, stop Token) string {
+	if start != nil && stop != nil {
+		return c.GetText(start.GetStart(), stop.GetStop())
+	}
+
+	return ""
+}
+
+func (c *CodePointCharStream) GetTextFromInterval(i *Interval) string {
+	return c.GetText(i.Start, i.Stop)
+}
+
+func (c *CodePointCharStream) GetSourceName() string {
+	if c.Name == "" {
+		return "<unknown>"
+	}
+
+	return c.Name
+}
+
+func (c *CodePointCharStream) String() string {
+	return c.GetText(0, c.size-1)
+}
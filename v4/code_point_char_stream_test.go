@@ -0,0 +1,104 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCodePointCharStreamStorage(t *testing.T) {
+	tests := []struct {
+		input string
+		bytes int
+	}{
+		{"", 1},
+		{"abc", 1},
+		{"café", 1},
+		{"λ x", 2},
+		{"\U0001F600!", 4},
+		{"a\xffb", 2}, // invalid UTF-8 decodes to U+FFFD
+	}
+
+	for _, test := range tests {
+		c := NewCodePointCharStreamFromString(test.input)
+		bytes := 4
+		if c.data8 != nil {
+			bytes = 1
+		} else if c.data16 != nil {
+			bytes = 2
+		}
+		if bytes != test.bytes {
+			t.Errorf("%q: expected %d byte storage, got %d", test.input, test.bytes, bytes)
+		}
+
+		expected := []rune(test.input)
+		if c.Size() != len(expected) {
+			t.Errorf("%q: expected size %d, got %d", test.input, len(expected), c.Size())
+		}
+		for i, r := range expected {
+			if got := c.LA(i + 1); got != int(r) {
+				t.Errorf("%q: expected LA(%d) %q, got %q", test.input, i+1, r, got)
+			}
+		}
+		if got := c.String(); got != string(expected) {
+			t.Errorf("%q: expected text %q, got %q", test.input, string(expected), got)
+		}
+	}
+}
+
+func TestCodePointCharStreamByteOffsets(t *testing.T) {
+	inputs := []string{
+		"",
+		"plain ascii",
+		strings.Repeat("abéλ\U0001F600�\xff\xe2\x82", 40),
+	}
+
+	for _, input := range inputs {
+		c := NewCodePointCharStream([]byte(input))
+		if c.ByteLength() != len(input) {
+			t.Errorf("expected byte length %d, got %d", len(input), c.ByteLength())
+		}
+
+		index := 0
+		for offset := 0; offset < len(input); {
+			_, w := utf8.DecodeRuneInString(input[offset:])
+			if got := c.ByteOffset(index); got != offset {
+				t.Fatalf("ByteOffset(%d): expected %d, got %d", index, offset, got)
+			}
+			for b := offset; b < offset+w; b++ {
+				if got := c.CharIndex(b); got != index {
+					t.Fatalf("CharIndex(%d): expected %d, got %d", b, index, got)
+				}
+			}
+			offset += w
+			index++
+		}
+
+		if got := c.ByteOffset(c.Size()); got != len(input) {
+			t.Errorf("ByteOffset(Size()): expected %d, got %d", len(input), got)
+		}
+		if got := c.CharIndex(len(input)); got != c.Size() {
+			t.Errorf("CharIndex(len): expected %d, got %d", c.Size(), got)
+		}
+	}
+}
+
+func TestCodePointCharStreamLexer(t *testing.T) {
+	input := "x = 1;\n  abc = (22);"
+	c, err := NewCodePointCharStreamFromReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lexer := newExprLexer(c)
+	for tok := lexer.NextToken(); tok.GetTokenType() != TokenEOF; tok = lexer.NextToken() {
+		start, stop := c.ByteOffset(tok.GetStart()), c.ByteOffset(tok.GetStop()+1)
+		if got := input[start:stop]; got != tok.GetText() {
+			t.Errorf("expected %q at %d..%d, got %q", tok.GetText(), start, stop, got)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strconv"
+)
+
+// DiagnosticSeverity is the severity of a Diagnostic. The values are those
+// of the Language Server Protocol.
+type DiagnosticSeverity int
+
+const (
+	DiagnosticSeverityError DiagnosticSeverity = iota + 1
+	DiagnosticSeverityWarning
+	DiagnosticSeverityInformation
+	DiagnosticSeverityHint
+)
+
+func (s DiagnosticSeverity) String() string {
+	switch s {
+	case DiagnosticSeverityError:
+		return "error"
+	case DiagnosticSeverityWarning:
+		return "warning"
+	case DiagnosticSeverityInformation:
+		return "information"
+	case DiagnosticSeverityHint:
+		return "hint"
+	default:
+		return "DiagnosticSeverity(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// Diagnostic describes a syntax error reported by a lexer or parser, with
+// everything the error listeners are told about it in structured form.
+type Diagnostic struct {
+	Severity DiagnosticSeverity
+	Kind     ErrorKind
+
+	// Source is the source name of the input, as returned by
+	// IntStream.GetSourceName.
+	Source string
+
+	// Msg is the message reported to the error listeners.
+	Msg string
+
+	// Start and Stop are the char indexes of the first and last char of the
+	// offending input, as for Token.GetStart and Token.GetStop. Stop is
+	// Start-1 when the offending input is empty, as it is at the end of the
+	// input.
+	Start int
+	Stop  int
+
+	// StartByte and StopByte are the UTF-8 byte offsets of the first and
+	// last byte of the offending input, or -1 if they are not known, as
+	// they are not for an UnbufferedCharStream. StopByte is StartByte-1
+	// when the offending input is empty.
+	StartByte int
+	StopByte  int
+
+	// Range is the line and column range of the offending input.
+	Range SourceRange
+
+	// OffendingToken is the offending token of a parser error, and nil for
+	// a lexer error.
+	OffendingToken Token
+
+	// Expected is the set of token types the parser expected at the error,
+	// or nil for a lexer error.
+	Expected *IntervalSet
+
+	// RuleStack is the rule invocation stack of a parser error, from the
+	// rule the error was found in up to the start rule, as returned by
+	// Parser.GetRuleInvocationStack. It is nil for a lexer error.
+	RuleStack []string
+
+	// Exception is the RecognitionException describing the error, or nil
+	// if the error was not raised by an exception.
+	Exception RecognitionException
+}
+
+func (d *Diagnostic) String() string {
+	source := d.Source
+	if source != "" {
+		source += ":"
+	}
+
+	return source + strconv.Itoa(d.Range.Start.Line) + ":" + strconv.Itoa(d.Range.Start.Column) + ": " + d.Severity.String() + ": " + d.Msg
+}
+
+// CollectingErrorListener is an ErrorListener recording each syntax error
+// reported to it as a Diagnostic. It can listen to a lexer and a parser at
+// the same time.
+//
+// <p>For example:</p>
+//
+//	diagnostics := antlr.NewCollectingErrorListener()
+//	lexer.RemoveErrorListeners()
+//	lexer.AddErrorListener(diagnostics)
+//	parser.RemoveErrorListeners()
+//	parser.AddErrorListener(diagnostics)
+//	parser.Prog()
+//	for _, d := range diagnostics.GetDiagnostics() {
+//		...
+//	}
+type CollectingErrorListener struct {
+	*DefaultErrorListener
+
+	diagnostics []*Diagnostic
+
+	// byteInput, byteIndex and byteOffset cache the UTF-8 byte offset of the
+	// last char index computed for an input that does not know its byte
+	// offsets, as errors are usually reported in input order.
+	byteInput  CharStream
+	byteIndex  int
+	byteOffset int
+}
+
+func NewCollectingErrorListener() *CollectingErrorListener {
+	return &CollectingErrorListener{DefaultErrorListener: NewDefaultErrorListener()}
+}
+
+func (c *CollectingErrorListener) SyntaxError(recognizer Recognizer, offendingSymbol interface{}, line, column int, msg string, e RecognitionException) {
+	d := &Diagnostic{
+		Severity:  DiagnosticSeverityError,
+		Kind:      SyntaxErrorKind(recognizer, e),
+		Msg:       msg,
+		Exception: e,
+	}
+
+	var input CharStream
+	var text string
+
+	if t, ok := offendingSymbol.(Token); ok {
+		d.OffendingToken = t
+		d.Start = t.GetStart()
+		d.Stop = t.GetStop()
+		if t.GetTokenType() == TokenEOF {
+			d.Stop = d.Start - 1
+		} else {
+			text = t.GetText()
+		}
+		input = t.GetInputStream()
+	} else if le, ok := e.(*LexerNoViableAltException); ok {
+		input = le.input.(CharStream)
+		d.Start = le.startIndex
+		// As for BaseLexer.notifyListeners, the offending input runs up to
+		// the char the lexer stopped at, which is none at the end of the
+		// input.
+		d.Stop = input.Index()
+		if input.LA(1) == TokenEOF {
+			d.Stop--
+		}
+		text = input.GetTextFromInterval(NewInterval(d.Start, d.Stop))
+	}
+
+	d.StartByte, d.StopByte = -1, -1
+	if input != nil {
+		d.Source = input.GetSourceName()
+
+		if start := c.getByteOffset(input, d.Start); start >= 0 {
+			// The text of a token may have been changed by the lexer
+			if d.Stop >= d.Start {
+				text = input.GetText(d.Start, d.Stop)
+			}
+			d.StartByte = start
+			d.StopByte = start + len(text) - 1
+		}
+	}
+
+	d.Range.Start = SourcePosition{Line: line, Column: column}
+	d.Range.End = d.Range.Start
+	for _, r := range text {
+		if r == '\n' {
+			d.Range.End.Line++
+			d.Range.End.Column = 0
+		} else {
+			d.Range.End.Column++
+		}
+	}
+
+	if parser, ok := recognizer.(Parser); ok {
+		if ee, ok := e.(interface{ getExpectedTokens() *IntervalSet }); ok {
+			d.Expected = ee.getExpectedTokens()
+		} else {
+			d.Expected = parser.GetExpectedTokens()
+		}
+		d.RuleStack = parser.GetRuleInvocationStack(nil)
+	}
+
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+// getByteOffset returns the UTF-8 byte offset of the char at index in
+// input, or -1 if input cannot return the text before index.
+func (c *CollectingErrorListener) getByteOffset(input CharStream, index int) int {
+	if _, ok := input.(*UnbufferedCharStream); ok || index < 0 {
+		return -1
+	}
+	if cs, ok := input.(*CodePointCharStream); ok {
+		return cs.ByteOffset(index)
+	}
+
+	if input != c.byteInput || index < c.byteIndex {
+		c.byteInput, c.byteIndex, c.byteOffset = input, 0, 0
+	}
+	if index > c.byteIndex {
+		c.byteOffset += len(input.GetText(c.byteIndex, index-1))
+		c.byteIndex = index
+	}
+
+	return c.byteOffset
+}
+
+// GetDiagnostics returns the diagnostics recorded so far, in the order the
+// errors were reported.
+func (c *CollectingErrorListener) GetDiagnostics() []*Diagnostic {
+	return c.diagnostics
+}
+
+// HasErrors returns whether an error was recorded.
+func (c *CollectingErrorListener) HasErrors() bool {
+	for _, d := range c.diagnostics {
+		if d.Severity == DiagnosticSeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reset discards the diagnostics recorded so far.
+func (c *CollectingErrorListener) Reset() {
+	c.diagnostics = nil
+	c.byteInput = nil
+}
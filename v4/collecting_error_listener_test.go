@@ -0,0 +1,150 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strings"
+	"testing"
+)
+
+// collectExprDiagnostics parses input with the Expr grammar and returns
+// the diagnostics of the lexer and parser.
+func collectExprDiagnostics(input string) []*Diagnostic {
+	return collectExprStreamDiagnostics(NewInputStream(input))
+}
+
+// collectExprStreamDiagnostics is collectExprDiagnostics for an input
+// read from a CharStream.
+func collectExprStreamDiagnostics(input CharStream) []*Diagnostic {
+	diagnostics := NewCollectingErrorListener()
+
+	lexer := newExprLexer(input)
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(diagnostics)
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(lexer, TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(diagnostics)
+	parser.Parse(ExprParserRULE_prog)
+
+	return diagnostics.GetDiagnostics()
+}
+
+func TestCollectingErrorListener(t *testing.T) {
+	diagnostics := collectExprDiagnostics("x = 1;\nyé = 2;\n(3;")
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diagnostics)
+	}
+
+	lexerError := diagnostics[0]
+	if lexerError.Kind != ErrorKindLexerNoViableAlt || lexerError.Severity != DiagnosticSeverityError || lexerError.OffendingToken != nil {
+		t.Errorf("unexpected lexer diagnostic %+v", lexerError)
+	}
+	if lexerError.Start != 8 || lexerError.Stop != 8 || lexerError.StartByte != 8 || lexerError.StopByte != 9 {
+		t.Errorf("expected chars 8-8 and bytes 8-9, got %d-%d and %d-%d", lexerError.Start, lexerError.Stop, lexerError.StartByte, lexerError.StopByte)
+	}
+	if want := (SourceRange{SourcePosition{2, 1}, SourcePosition{2, 2}}); lexerError.Range != want {
+		t.Errorf("expected range %v, got %v", want, lexerError.Range)
+	}
+	if lexerError.Expected != nil || lexerError.RuleStack != nil {
+		t.Errorf("expected no expected tokens and rule stack for a lexer error, got %v and %v", lexerError.Expected, lexerError.RuleStack)
+	}
+
+	parserError := diagnostics[1]
+	if parserError.Kind != ErrorKindMissingToken || parserError.Exception != nil || parserError.OffendingToken.GetText() != ";" {
+		t.Errorf("unexpected parser diagnostic %+v", parserError)
+	}
+	if parserError.Start != 17 || parserError.StartByte != 18 || parserError.StopByte != 18 {
+		t.Errorf("expected char 17 and byte 18, got %d and %d-%d", parserError.Start, parserError.StartByte, parserError.StopByte)
+	}
+	if got := parserError.Expected.String(); got != "6" {
+		t.Errorf("expected ')' to be expected, got %s", got)
+	}
+	if got := strings.Join(parserError.RuleStack, " "); got != "expr stat prog" {
+		t.Errorf("unexpected rule stack %s", got)
+	}
+	if got, want := parserError.String(), "Obtained from string:3:2: error: missing ')' at ';'"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCollectingErrorListenerCharStreams(t *testing.T) {
+	const input = "x = 1;\nyé = 2;\n#"
+
+	for _, test := range []struct {
+		name                string
+		input               CharStream
+		startByte, stopByte int
+	}{
+		{"code point", NewCodePointCharStreamFromString(input), 8, 9},
+		{"unbuffered", NewUnbufferedCharStream(strings.NewReader(input)), -1, -1},
+	} {
+		diagnostics := collectExprStreamDiagnostics(test.input)
+		if len(diagnostics) != 2 {
+			t.Fatalf("%s: expected 2 diagnostics, got %v", test.name, diagnostics)
+		}
+		for i, d := range diagnostics {
+			if d.Kind != ErrorKindLexerNoViableAlt {
+				t.Errorf("%s: expected lexer diagnostics, got %v", test.name, d)
+			}
+			if want := (SourcePosition{i + 2, 2 - i}); d.Range.End != want {
+				t.Errorf("%s: expected range to end at %v, got %v", test.name, want, d.Range)
+			}
+		}
+
+		d := diagnostics[0]
+		if d.Start != 8 || d.Stop != 8 || d.StartByte != test.startByte || d.StopByte != test.stopByte {
+			t.Errorf("%s: expected chars 8-8 and bytes %d-%d, got %d-%d and %d-%d", test.name, test.startByte, test.stopByte, d.Start, d.Stop, d.StartByte, d.StopByte)
+		}
+	}
+}
+
+func TestCollectingErrorListenerKinds(t *testing.T) {
+	for input, kind := range map[string]ErrorKind{
+		"x = ;":    ErrorKindMismatchedInput,
+		"x = = 1;": ErrorKindExtraneousInput,
+		"(1;":      ErrorKindMissingToken,
+		"x = #1;":  ErrorKindLexerNoViableAlt,
+	} {
+		diagnostics := collectExprDiagnostics(input)
+		if len(diagnostics) != 1 || diagnostics[0].Kind != kind {
+			t.Errorf("%q: expected a %s diagnostic, got %v", input, kind, diagnostics)
+		}
+	}
+
+	// At the end of the input the offending input is empty.
+	diagnostics := collectExprDiagnostics("1 +")
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diagnostics)
+	}
+	if d := diagnostics[0]; d.Start != 3 || d.Stop != 2 || d.StopByte != 2 || d.Range.End != d.Range.Start {
+		t.Errorf("expected empty offending input at 3, got %d-%d %v", d.Start, d.Stop, d.Range)
+	}
+}
+
+func TestCollectingErrorListenerNoViableAlt(t *testing.T) {
+	l := NewLexerATNBuilder(3)
+	l.LexerRule("A", 1, l.Literal("a"))
+	l.LexerRule("B", 2, l.Literal("b"))
+	l.LexerRule("C", 3, l.Literal("c"))
+	p := NewParserATNBuilder(3)
+	p.Rule("s", p.Alts(p.Seq(p.Atom(1), p.Atom(2)), p.Seq(p.Atom(1), p.Atom(3))))
+
+	diagnostics := NewCollectingErrorListener()
+	lexer := NewLexerInterpreter("T.g4", nil, nil, l.GetRuleNames(), nil, l.GetModeNames(), l.Build(), NewInputStream("aa"))
+	parser := NewParserInterpreter("T.g4", nil, nil, p.GetRuleNames(), p.Build(), NewCommonTokenStream(lexer, TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(diagnostics)
+	parser.Parse(0)
+
+	if got := diagnostics.GetDiagnostics(); len(got) != 1 || got[0].Kind != ErrorKindNoViableAlt || got[0].Expected.String() != "1" {
+		t.Errorf("expected a no viable alternative diagnostic, got %v", got)
+	}
+
+	diagnostics.Reset()
+	if diagnostics.HasErrors() || len(diagnostics.GetDiagnostics()) != 0 {
+		t.Error("expected no diagnostics after Reset")
+	}
+}
@@ -0,0 +1,637 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sync"
+)
+
+// GrammarCoverage records which parts of a grammar the parsers it is set on
+// exercised: the outer alternatives of each rule entered, through
+// BaseParser.EnterOuterAlt, the alternatives each decision predicted,
+// through ParserATNSimulator.AdaptivePredict, and the token references
+// matched, through BaseParser.Match and BaseParser.MatchWildcard.
+//
+// <p>Set a GrammarCoverage on each parser of a test corpus with
+// BaseParser.SetCoverage; parsers may share one, also concurrently. The
+// coverage of separate runs can be saved with WriteJSON, read back with
+// ReadGrammarCoverage and combined with Merge, and is reported by rule name
+// and alternative number with WriteLCOV and WriteHTML.</p>
+type GrammarCoverage struct {
+	mu sync.Mutex
+
+	grammar   string
+	rules     []*RuleCoverage
+	decisions []*DecisionCoverage
+
+	// tokens maps the ATN states of token references to their coverage in
+	// rules.
+	tokens map[int]*TokenCoverage
+}
+
+// RuleCoverage is the coverage of a rule.
+type RuleCoverage struct {
+	Rule string `json:"rule"`
+
+	// Alts holds the number of times each outer alternative of the rule was
+	// entered; Alts[0] is for alternative 1.
+	Alts []int64 `json:"alts"`
+
+	// Tokens is the coverage of the token references of the rule, in ATN
+	// state order.
+	Tokens []*TokenCoverage `json:"tokens"`
+}
+
+// DecisionCoverage is the coverage of a decision.
+type DecisionCoverage struct {
+	Decision int    `json:"decision"`
+	Rule     string `json:"rule"`
+
+	// State is the number of the ATN decision state.
+	State int `json:"state"`
+
+	// Alts holds the number of times each alternative of the decision was
+	// predicted; Alts[0] is for alternative 1.
+	Alts []int64 `json:"alts"`
+}
+
+// TokenCoverage is the coverage of a token reference, the transition
+// matching a token from an ATN state.
+type TokenCoverage struct {
+	// State is the number of the ATN state the token is matched from.
+	State int `json:"state"`
+
+	// Token is the display name of the tokens matched, such as ID, '=',
+	// {'+', '-'} or ~';'.
+	Token string `json:"token"`
+
+	Hits int64 `json:"hits"`
+}
+
+// CoverageSummary counts the parts of a grammar a GrammarCoverage records,
+// and how many of them were hit at least once.
+type CoverageSummary struct {
+	Rules, RulesHit                       int
+	Alts, AltsHit                         int
+	DecisionOutcomes, DecisionOutcomesHit int
+	Tokens, TokensHit                     int
+}
+
+// grammarCoverageJSON is the form WriteJSON writes a GrammarCoverage in.
+type grammarCoverageJSON struct {
+	Grammar   string              `json:"grammar"`
+	Rules     []*RuleCoverage     `json:"rules"`
+	Decisions []*DecisionCoverage `json:"decisions"`
+}
+
+// NewGrammarCoverage creates an empty GrammarCoverage for the grammar of
+// parser, with an entry for every rule, outer alternative, decision
+// alternative and token reference of its ATN.
+func NewGrammarCoverage(parser Parser) *GrammarCoverage {
+	atn := parser.GetInterpreter().ATN()
+	ruleNames := parser.GetRuleNames()
+	literalNames := parser.GetLiteralNames()
+	symbolicNames := parser.GetSymbolicNames()
+
+	c := &GrammarCoverage{
+		grammar:   parser.GetGrammarFileName(),
+		rules:     make([]*RuleCoverage, len(atn.ruleToStartState)),
+		decisions: make([]*DecisionCoverage, len(atn.DecisionToState)),
+	}
+
+	for i := range c.rules {
+		alts := 1
+		if block := atn.getRuleBlock(i); block != nil {
+			alts = len(block.GetTransitions())
+		}
+		c.rules[i] = &RuleCoverage{
+			Rule:   coverageRuleName(ruleNames, i),
+			Alts:   make([]int64, alts),
+			Tokens: make([]*TokenCoverage, 0),
+		}
+	}
+
+	for i, ds := range atn.DecisionToState {
+		c.decisions[i] = &DecisionCoverage{
+			Decision: i,
+			Rule:     coverageRuleName(ruleNames, ds.GetRuleIndex()),
+			State:    ds.GetStateNumber(),
+			Alts:     make([]int64, len(ds.GetTransitions())),
+		}
+	}
+
+	for _, s := range atn.states {
+		if s == nil || s.GetRuleIndex() < 0 || s.GetRuleIndex() >= len(c.rules) {
+			continue
+		}
+		for _, t := range s.GetTransitions() {
+			if token, ok := coverageTokenName(t, literalNames, symbolicNames); ok {
+				r := c.rules[s.GetRuleIndex()]
+				r.Tokens = append(r.Tokens, &TokenCoverage{State: s.GetStateNumber(), Token: token})
+				break
+			}
+		}
+	}
+	c.indexTokens()
+
+	return c
+}
+
+// ReadGrammarCoverage reads a GrammarCoverage written by WriteJSON.
+func ReadGrammarCoverage(r io.Reader) (*GrammarCoverage, error) {
+	var data grammarCoverageJSON
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	c := &GrammarCoverage{grammar: data.Grammar, rules: data.Rules, decisions: data.Decisions}
+	for i, r := range c.rules {
+		if r == nil {
+			return nil, fmt.Errorf("grammar coverage rule %d is null", i)
+		}
+		if r.Tokens == nil {
+			r.Tokens = make([]*TokenCoverage, 0)
+		}
+		for _, t := range r.Tokens {
+			if t == nil {
+				return nil, fmt.Errorf("grammar coverage of rule %s has a null token", r.Rule)
+			}
+		}
+	}
+	for i, d := range c.decisions {
+		if d == nil || d.Decision != i {
+			return nil, fmt.Errorf("grammar coverage decision %d is missing", i)
+		}
+	}
+	c.indexTokens()
+
+	return c, nil
+}
+
+func (c *GrammarCoverage) indexTokens() {
+	c.tokens = make(map[int]*TokenCoverage)
+	for _, r := range c.rules {
+		for _, t := range r.Tokens {
+			c.tokens[t.State] = t
+		}
+	}
+}
+
+// GetGrammarFileName returns the name of the grammar c is the coverage of.
+func (c *GrammarCoverage) GetGrammarFileName() string {
+	return c.grammar
+}
+
+// GetRules returns a copy of the coverage of each rule, by rule index.
+func (c *GrammarCoverage) GetRules() []*RuleCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.copyRules()
+}
+
+// GetDecisions returns a copy of the coverage of each decision, by decision
+// number.
+func (c *GrammarCoverage) GetDecisions() []*DecisionCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.copyDecisions()
+}
+
+// copyRules returns a copy of c.rules. The caller must hold c.mu.
+func (c *GrammarCoverage) copyRules() []*RuleCoverage {
+	rules := make([]*RuleCoverage, len(c.rules))
+	for i, r := range c.rules {
+		tokens := make([]*TokenCoverage, len(r.Tokens))
+		for j, t := range r.Tokens {
+			tc := *t
+			tokens[j] = &tc
+		}
+		rules[i] = &RuleCoverage{Rule: r.Rule, Alts: append([]int64(nil), r.Alts...), Tokens: tokens}
+	}
+
+	return rules
+}
+
+// copyDecisions returns a copy of c.decisions. The caller must hold c.mu.
+func (c *GrammarCoverage) copyDecisions() []*DecisionCoverage {
+	decisions := make([]*DecisionCoverage, len(c.decisions))
+	for i, d := range c.decisions {
+		dc := *d
+		dc.Alts = append([]int64(nil), d.Alts...)
+		decisions[i] = &dc
+	}
+
+	return decisions
+}
+
+// Summary counts the rules, outer alternatives, decision outcomes and token
+// references of the grammar, and how many of them were hit.
+func (c *GrammarCoverage) Summary() CoverageSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var s CoverageSummary
+	for _, r := range c.rules {
+		s.Rules++
+		if sumCoverage(r.Alts) > 0 {
+			s.RulesHit++
+		}
+		s.Alts += len(r.Alts)
+		s.AltsHit += countCovered(r.Alts)
+		for _, t := range r.Tokens {
+			s.Tokens++
+			if t.Hits > 0 {
+				s.TokensHit++
+			}
+		}
+	}
+	for _, d := range c.decisions {
+		s.DecisionOutcomes += len(d.Alts)
+		s.DecisionOutcomesHit += countCovered(d.Alts)
+	}
+
+	return s
+}
+
+// Merge adds the coverage recorded by other to c. Both must be the coverage
+// of the same grammar.
+func (c *GrammarCoverage) Merge(other *GrammarCoverage) error {
+	if c == other {
+		return fmt.Errorf("cannot merge grammar coverage into itself")
+	}
+
+	// Copy other first rather than holding both locks, so that merging two
+	// coverages into each other at the same time cannot deadlock.
+	other.mu.Lock()
+	rules, decisions := other.copyRules(), other.copyDecisions()
+	other.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkCompatible(other.grammar, rules, decisions); err != nil {
+		return err
+	}
+
+	for i, r := range rules {
+		into := c.rules[i]
+		into.Alts = addCoverage(into.Alts, r.Alts)
+		for _, t := range r.Tokens {
+			if ct, ok := c.tokens[t.State]; ok {
+				ct.Hits += t.Hits
+			} else {
+				into.Tokens = append(into.Tokens, t)
+				c.tokens[t.State] = t
+			}
+		}
+	}
+	for i, d := range decisions {
+		c.decisions[i].Alts = addCoverage(c.decisions[i].Alts, d.Alts)
+	}
+
+	return nil
+}
+
+// checkCompatible returns an error if the coverage of grammar by rules and
+// decisions cannot be merged into c.
+func (c *GrammarCoverage) checkCompatible(grammar string, rules []*RuleCoverage, decisions []*DecisionCoverage) error {
+	if c.grammar != grammar {
+		return fmt.Errorf("cannot merge coverage of grammar %q into coverage of grammar %q", grammar, c.grammar)
+	}
+	if len(c.rules) != len(rules) || len(c.decisions) != len(decisions) {
+		return fmt.Errorf("cannot merge coverage of %d rules and %d decisions into coverage of %d rules and %d decisions",
+			len(rules), len(decisions), len(c.rules), len(c.decisions))
+	}
+	for i, r := range rules {
+		if r.Rule != c.rules[i].Rule {
+			return fmt.Errorf("cannot merge coverage of rule %s into coverage of rule %s", r.Rule, c.rules[i].Rule)
+		}
+	}
+	for i, d := range decisions {
+		if d.State != c.decisions[i].State {
+			return fmt.Errorf("cannot merge coverage of decision %d at state %d into coverage of state %d", i, d.State, c.decisions[i].State)
+		}
+	}
+	for _, r := range rules {
+		for _, t := range r.Tokens {
+			if ct, ok := c.tokens[t.State]; ok && ct.Token != t.Token {
+				return fmt.Errorf("cannot merge coverage of token %s at state %d into coverage of token %s", t.Token, t.State, ct.Token)
+			}
+		}
+	}
+
+	return nil
+}
+
+// enterOuterAlt records that outer alternative alt of rule ruleIndex was
+// entered.
+func (c *GrammarCoverage) enterOuterAlt(ruleIndex, alt int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ruleIndex >= 0 && ruleIndex < len(c.rules) && alt > 0 {
+		c.rules[ruleIndex].Alts = hitCoverage(c.rules[ruleIndex].Alts, alt)
+	}
+}
+
+// predict records that decision predicted alt.
+func (c *GrammarCoverage) predict(decision, alt int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if decision >= 0 && decision < len(c.decisions) && alt > 0 {
+		c.decisions[decision].Alts = hitCoverage(c.decisions[decision].Alts, alt)
+	}
+}
+
+// match records that the token reference at ATN state stateNumber matched.
+func (c *GrammarCoverage) match(stateNumber int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t, ok := c.tokens[stateNumber]; ok {
+		t.Hits++
+	}
+}
+
+// WriteJSON writes c in a form ReadGrammarCoverage reads back.
+func (c *GrammarCoverage) WriteJSON(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&grammarCoverageJSON{Grammar: c.grammar, Rules: c.rules, Decisions: c.decisions})
+}
+
+// WriteLCOV writes c as an LCOV tracefile record for the grammar file, as
+// read by genhtml and most coverage services. As grammars are not covered
+// line by line, the record uses made-up line numbers: each rule is a
+// function starting on a line of its own, followed by a line for each of
+// its outer alternatives in order, then a line for each of its token
+// references. The decisions of a rule are branches on the rule's line,
+// with the decision number as block and the alternative number less one as
+// branch.
+func (c *GrammarCoverage) WriteLCOV(w io.Writer, testName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ruleLines := make([]int, len(c.rules))
+	ruleIndexes := make(map[string]int)
+	line := 1
+	for i, r := range c.rules {
+		ruleLines[i] = line
+		ruleIndexes[r.Rule] = i
+		line += 1 + len(r.Alts) + len(r.Tokens)
+	}
+
+	bw := bufio.NewWriter(w)
+	if testName != "" {
+		fmt.Fprintf(bw, "TN:%s\n", testName)
+	}
+	fmt.Fprintf(bw, "SF:%s\n", c.grammar)
+
+	rulesHit := 0
+	for i, r := range c.rules {
+		fmt.Fprintf(bw, "FN:%d,%s\n", ruleLines[i], r.Rule)
+	}
+	for _, r := range c.rules {
+		hits := sumCoverage(r.Alts)
+		if hits > 0 {
+			rulesHit++
+		}
+		fmt.Fprintf(bw, "FNDA:%d,%s\n", hits, r.Rule)
+	}
+	fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", len(c.rules), rulesHit)
+
+	branches, branchesHit := 0, 0
+	for _, d := range c.decisions {
+		ruleLine := 0
+		if i, ok := ruleIndexes[d.Rule]; ok {
+			ruleLine = ruleLines[i]
+		}
+		reached := sumCoverage(d.Alts) > 0
+		for alt, hits := range d.Alts {
+			branches++
+			if hits > 0 {
+				branchesHit++
+			}
+			if reached {
+				fmt.Fprintf(bw, "BRDA:%d,%d,%d,%d\n", ruleLine, d.Decision, alt, hits)
+			} else {
+				fmt.Fprintf(bw, "BRDA:%d,%d,%d,-\n", ruleLine, d.Decision, alt)
+			}
+		}
+	}
+	fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", branches, branchesHit)
+
+	lines, linesHit := 0, 0
+	da := func(line int, hits int64) {
+		lines++
+		if hits > 0 {
+			linesHit++
+		}
+		fmt.Fprintf(bw, "DA:%d,%d\n", line, hits)
+	}
+	for i, r := range c.rules {
+		line := ruleLines[i] + 1
+		for _, hits := range r.Alts {
+			da(line, hits)
+			line++
+		}
+		for _, t := range r.Tokens {
+			da(line, t.Hits)
+			line++
+		}
+	}
+	fmt.Fprintf(bw, "LF:%d\nLH:%d\n", lines, linesHit)
+	fmt.Fprintln(bw, "end_of_record")
+
+	return bw.Flush()
+}
+
+type coverageHTMLCount struct {
+	Name string
+	Hits int64
+}
+
+type coverageHTMLDecision struct {
+	Decision int
+	State    int
+	Alts     []coverageHTMLCount
+}
+
+type coverageHTMLRule struct {
+	Rule      string
+	Alts      []coverageHTMLCount
+	Decisions []coverageHTMLDecision
+	Tokens    []coverageHTMLCount
+}
+
+type coverageHTMLSummary struct {
+	Name       string
+	Hit, Total int
+}
+
+func (s coverageHTMLSummary) Percent() string {
+	if s.Total == 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.1f%%", 100*float64(s.Hit)/float64(s.Total))
+}
+
+var coverageHTMLTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage of {{.Grammar}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+td.hits { text-align: right; }
+tr.hit { background: #dfd; }
+tr.miss { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Coverage of {{.Grammar}}</h1>
+<table>
+<tr><th></th><th>Hit</th><th>Total</th><th>Coverage</th></tr>
+{{range .Summary}}<tr><td>{{.Name}}</td><td class="hits">{{.Hit}}</td><td class="hits">{{.Total}}</td><td class="hits">{{.Percent}}</td></tr>
+{{end}}</table>
+{{range .Rules}}<h2 id="rule-{{.Rule}}">{{.Rule}}</h2>
+<table>
+<tr><th>Alternative</th><th>Hits</th></tr>
+{{range .Alts}}<tr class="{{if .Hits}}hit{{else}}miss{{end}}"><td>{{.Name}}</td><td class="hits">{{.Hits}}</td></tr>
+{{end}}</table>
+{{range .Decisions}}<table>
+<tr><th>Decision {{.Decision}} (state {{.State}})</th><th>Hits</th></tr>
+{{range .Alts}}<tr class="{{if .Hits}}hit{{else}}miss{{end}}"><td>{{.Name}}</td><td class="hits">{{.Hits}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Tokens}}<table>
+<tr><th>Token</th><th>Hits</th></tr>
+{{range .Tokens}}<tr class="{{if .Hits}}hit{{else}}miss{{end}}"><td>{{.Name}}</td><td class="hits">{{.Hits}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes c as an HTML page with a section for each rule, listing
+// the hits of the rule's outer alternatives, of the alternatives of its
+// decisions and of its token references.
+func (c *GrammarCoverage) WriteHTML(w io.Writer) error {
+	summary := c.Summary()
+
+	c.mu.Lock()
+	rules := make([]*coverageHTMLRule, len(c.rules))
+	ruleIndexes := make(map[string]int)
+	for i, r := range c.rules {
+		rule := &coverageHTMLRule{Rule: r.Rule}
+		for alt, hits := range r.Alts {
+			rule.Alts = append(rule.Alts, coverageHTMLCount{Name: fmt.Sprintf("alt %d", alt+1), Hits: hits})
+		}
+		for _, t := range r.Tokens {
+			rule.Tokens = append(rule.Tokens, coverageHTMLCount{Name: fmt.Sprintf("%s (state %d)", t.Token, t.State), Hits: t.Hits})
+		}
+		rules[i] = rule
+		ruleIndexes[r.Rule] = i
+	}
+	for _, d := range c.decisions {
+		i, ok := ruleIndexes[d.Rule]
+		if !ok {
+			continue
+		}
+		decision := coverageHTMLDecision{Decision: d.Decision, State: d.State}
+		for alt, hits := range d.Alts {
+			decision.Alts = append(decision.Alts, coverageHTMLCount{Name: fmt.Sprintf("alt %d", alt+1), Hits: hits})
+		}
+		rules[i].Decisions = append(rules[i].Decisions, decision)
+	}
+	grammar := c.grammar
+	c.mu.Unlock()
+
+	return coverageHTMLTemplate.Execute(w, map[string]interface{}{
+		"Grammar": grammar,
+		"Summary": []coverageHTMLSummary{
+			{"Rules", summary.RulesHit, summary.Rules},
+			{"Alternatives", summary.AltsHit, summary.Alts},
+			{"Decision outcomes", summary.DecisionOutcomesHit, summary.DecisionOutcomes},
+			{"Token references", summary.TokensHit, summary.Tokens},
+		},
+		"Rules": rules,
+	})
+}
+
+func coverageRuleName(ruleNames []string, ruleIndex int) string {
+	if ruleIndex >= 0 && ruleIndex < len(ruleNames) {
+		return ruleNames[ruleIndex]
+	}
+
+	return fmt.Sprintf("rule%d", ruleIndex)
+}
+
+// coverageTokenName returns the display name of the tokens t matches, or
+// false if t does not match tokens.
+func coverageTokenName(t Transition, literalNames, symbolicNames []string) (string, bool) {
+	switch t.getSerializationType() {
+	case TransitionATOM, TransitionRANGE, TransitionSET, TransitionNOTSET:
+		return transitionSymbolsString(t, literalNames, symbolicNames, false), true
+	case TransitionWILDCARD:
+		return ".", true
+	}
+
+	return "", false
+}
+
+// hitCoverage adds a hit of alt to counts, growing counts if alt is beyond
+// the alternatives known so far.
+func hitCoverage(counts []int64, alt int) []int64 {
+	for len(counts) < alt {
+		counts = append(counts, 0)
+	}
+	counts[alt-1]++
+
+	return counts
+}
+
+func addCoverage(counts, other []int64) []int64 {
+	for len(counts) < len(other) {
+		counts = append(counts, 0)
+	}
+	for i, hits := range other {
+		counts[i] += hits
+	}
+
+	return counts
+}
+
+func sumCoverage(counts []int64) int64 {
+	var sum int64
+	for _, hits := range counts {
+		sum += hits
+	}
+
+	return sum
+}
+
+func countCovered(counts []int64) int {
+	n := 0
+	for _, hits := range counts {
+		if hits > 0 {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// parseExprCoverage parses each input at rule prog, recording its coverage
+// in coverage.
+func parseExprCoverage(t *testing.T, coverage *GrammarCoverage, inputs ...string) {
+	t.Helper()
+
+	for _, input := range inputs {
+		parser := newQuietExprParser(input)
+		parser.SetCoverage(coverage)
+		if _, syntaxErrors, err := parseExprProg(parser); err != nil || len(syntaxErrors) != 0 {
+			t.Fatalf("%q: expected no errors, got %v, %v", input, err, syntaxErrors)
+		}
+	}
+}
+
+func findTokenCoverage(rule *RuleCoverage, token string) *TokenCoverage {
+	for _, t := range rule.Tokens {
+		if t.Token == token {
+			return t
+		}
+	}
+
+	return nil
+}
+
+func TestGrammarCoverage(t *testing.T) {
+	coverage := NewGrammarCoverage(newQuietExprParser(""))
+	parseExprCoverage(t, coverage, "x = 1 + 2;", "y = x;")
+
+	rules := coverage.GetRules()
+	if got := rules[ExprParserRULE_prog].Alts; len(got) != 1 || got[0] != 2 {
+		t.Errorf("expected prog alts [2], got %v", got)
+	}
+	if got := rules[ExprParserRULE_stat].Alts; len(got) != 2 || got[0] != 2 || got[1] != 0 {
+		t.Errorf("expected stat alts [2 0], got %v", got)
+	}
+	if got := findTokenCoverage(rules[ExprParserRULE_stat], "'='"); got == nil || got.Hits != 2 {
+		t.Errorf("expected '=' in stat to be matched 2 times, got %+v", got)
+	}
+	if got := findTokenCoverage(rules[ExprParserRULE_expr], "'*'"); got == nil || got.Hits != 0 {
+		t.Errorf("expected '*' in expr not to be matched, got %+v", got)
+	}
+
+	// The decision of stat predicted alternative 1 every time.
+	found := false
+	for _, d := range coverage.GetDecisions() {
+		if d.Rule == "stat" && len(d.Alts) == 2 {
+			found = true
+			if d.Alts[0] != 2 || d.Alts[1] != 0 {
+				t.Errorf("expected stat decision alts [2 0], got %v", d.Alts)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected a two-way decision in stat")
+	}
+
+	summary := coverage.Summary()
+	if summary.Rules != 3 || summary.RulesHit != 3 || summary.Alts != 4 || summary.AltsHit != 3 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if summary.TokensHit == 0 || summary.TokensHit >= summary.Tokens {
+		t.Errorf("expected some tokens to be hit, got %+v", summary)
+	}
+}
+
+func TestGrammarCoverageMerge(t *testing.T) {
+	first := NewGrammarCoverage(newQuietExprParser(""))
+	parseExprCoverage(t, first, "x = 1 + 2;")
+	second := NewGrammarCoverage(newQuietExprParser(""))
+	parseExprCoverage(t, second, "2 * (3);")
+
+	var saved bytes.Buffer
+	if err := second.WriteJSON(&saved); err != nil {
+		t.Fatalf("writing coverage: %v", err)
+	}
+	read, err := ReadGrammarCoverage(&saved)
+	if err != nil {
+		t.Fatalf("reading coverage: %v", err)
+	}
+	if err := first.Merge(read); err != nil {
+		t.Fatalf("merging coverage: %v", err)
+	}
+
+	both := NewGrammarCoverage(newQuietExprParser(""))
+	parseExprCoverage(t, both, "x = 1 + 2;", "2 * (3);")
+	var got, want bytes.Buffer
+	if err := first.WriteJSON(&got); err != nil {
+		t.Fatalf("writing coverage: %v", err)
+	}
+	if err := both.WriteJSON(&want); err != nil {
+		t.Fatalf("writing coverage: %v", err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("expected merged coverage\n%s\ngot\n%s", want.String(), got.String())
+	}
+	if alts := first.GetRules()[ExprParserRULE_stat].Alts; alts[0] != 1 || alts[1] != 1 {
+		t.Errorf("expected both alternatives of stat to be hit once, got %v", alts)
+	}
+
+	other := NewGrammarCoverage(newCtxParser())
+	if err := first.Merge(other); err == nil {
+		t.Error("expected an error merging the coverage of another grammar")
+	}
+}
+
+func TestGrammarCoverageMergeConcurrently(t *testing.T) {
+	a := NewGrammarCoverage(newQuietExprParser(""))
+	parseExprCoverage(t, a, "x = 1 + 2;")
+	b := NewGrammarCoverage(newQuietExprParser(""))
+	parseExprCoverage(t, b, "2 * (3);")
+
+	// Merging two coverages into each other at the same time must not
+	// deadlock.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := a.Merge(b); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := b.Merge(a); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if hits := a.GetRules()[ExprParserRULE_stat].Alts; hits[0] == 0 || hits[1] == 0 {
+		t.Errorf("expected both alternatives of stat to be hit, got %v", hits)
+	}
+}
+
+func TestGrammarCoverageReports(t *testing.T) {
+	coverage := NewGrammarCoverage(newQuietExprParser(""))
+	parseExprCoverage(t, coverage, "x = 1 + 2;")
+
+	var lcov bytes.Buffer
+	if err := coverage.WriteLCOV(&lcov, "expr"); err != nil {
+		t.Fatalf("writing LCOV: %v", err)
+	}
+	for _, want := range []string{
+		"TN:expr\n", "SF:" + coverage.GetGrammarFileName() + "\n",
+		"FN:1,prog\n", "FNDA:1,prog\n", "FNDA:1,stat\n", "FNF:3\nFNH:3\n",
+		"BRH:", "LH:", "end_of_record\n",
+	} {
+		if !strings.Contains(lcov.String(), want) {
+			t.Errorf("expected LCOV to contain %q, got\n%s", want, lcov.String())
+		}
+	}
+	summary := coverage.Summary()
+	if !strings.Contains(lcov.String(), "\nBRF:"+strconv.Itoa(summary.DecisionOutcomes)+"\n") {
+		t.Errorf("expected %d branches in\n%s", summary.DecisionOutcomes, lcov.String())
+	}
+
+	var html bytes.Buffer
+	if err := coverage.WriteHTML(&html); err != nil {
+		t.Fatalf("writing HTML: %v", err)
+	}
+	for _, want := range []string{`<h2 id="rule-prog">prog</h2>`, `<h2 id="rule-stat">stat</h2>`, `<h2 id="rule-expr">expr</h2>`, `&#39;=&#39;`, `class="miss"`} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("expected HTML to contain %q, got\n%s", want, html.String())
+		}
+	}
+}
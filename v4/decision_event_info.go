@@ -0,0 +1,139 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+// DecisionEventInfo is the base of the events recorded by a
+// ProfilingATNSimulator while predicting a decision. It holds the input
+// the event occurred on and the state of prediction at that point.
+type DecisionEventInfo struct {
+	// Decision is the decision number.
+	Decision int
+
+	// Configs is the configuration set containing additional information
+	// relevant to the prediction state when the event occurred, or nil if
+	// no additional information is relevant or available.
+	Configs ATNConfigSet
+
+	// Input is the input token stream which is being parsed.
+	Input TokenStream
+
+	// StartIndex is the token index in the input when the decision was
+	// started.
+	StartIndex int
+
+	// StopIndex is the token index in the input at which the event
+	// occurred.
+	StopIndex int
+
+	// FullCtx is true if the event occurred during LL prediction, and
+	// false if it occurred during SLL prediction.
+	FullCtx bool
+}
+
+func NewDecisionEventInfo(decision int, configs ATNConfigSet, input TokenStream, startIndex, stopIndex int, fullCtx bool) *DecisionEventInfo {
+	return &DecisionEventInfo{
+		Decision:   decision,
+		Configs:    configs,
+		Input:      input,
+		StartIndex: startIndex,
+		StopIndex:  stopIndex,
+		FullCtx:    fullCtx,
+	}
+}
+
+// GetText returns the text of the input from StartIndex to StopIndex.
+func (d *DecisionEventInfo) GetText() string {
+	return d.Input.GetTextFromInterval(NewInterval(d.StartIndex, d.StopIndex))
+}
+
+// LookaheadEventInfo records the prediction of a decision that required
+// more lookahead than any previous prediction of that decision.
+type LookaheadEventInfo struct {
+	*DecisionEventInfo
+
+	// PredictedAlt is the alternative chosen by AdaptivePredict, not
+	// necessarily the outermost alt shown for a rule, as left-recursive
+	// rules have user-level alts that differ from the rewritten rule's
+	// alts.
+	PredictedAlt int
+}
+
+func NewLookaheadEventInfo(decision int, configs ATNConfigSet, predictedAlt int, input TokenStream, startIndex, stopIndex int, fullCtx bool) *LookaheadEventInfo {
+	return &LookaheadEventInfo{
+		DecisionEventInfo: NewDecisionEventInfo(decision, configs, input, startIndex, stopIndex, fullCtx),
+		PredictedAlt:      predictedAlt,
+	}
+}
+
+// AmbiguityInfo records an ambiguity: a decision where more than one
+// alternative matches the input, as reported by ReportAmbiguity.
+//
+// <p>Ambiguities are only exact in PredictionModeLLExactAmbigDetection;
+// otherwise the reported alternatives may be a superset of the truly
+// ambiguous alternatives.</p>
+type AmbiguityInfo struct {
+	*DecisionEventInfo
+
+	// AmbigAlts is the set of alternatives in the ambiguous decision, or
+	// nil if they are the alternatives of Configs.
+	AmbigAlts *BitSet
+}
+
+func NewAmbiguityInfo(decision int, configs ATNConfigSet, ambigAlts *BitSet, input TokenStream, startIndex, stopIndex int) *AmbiguityInfo {
+	return &AmbiguityInfo{
+		DecisionEventInfo: NewDecisionEventInfo(decision, configs, input, startIndex, stopIndex, configs.FullContext()),
+		AmbigAlts:         ambigAlts,
+	}
+}
+
+// ContextSensitivityInfo records a context sensitivity: a decision where
+// SLL prediction found a conflict that full context LL prediction resolved
+// to a different alternative than SLL would have chosen.
+type ContextSensitivityInfo struct {
+	*DecisionEventInfo
+}
+
+func NewContextSensitivityInfo(decision int, configs ATNConfigSet, input TokenStream, startIndex, stopIndex int) *ContextSensitivityInfo {
+	return &ContextSensitivityInfo{
+		DecisionEventInfo: NewDecisionEventInfo(decision, configs, input, startIndex, stopIndex, true),
+	}
+}
+
+// ErrorInfo records a syntax error found during prediction: no
+// alternative of the decision matches the input.
+type ErrorInfo struct {
+	*DecisionEventInfo
+}
+
+func NewErrorInfo(decision int, configs ATNConfigSet, input TokenStream, startIndex, stopIndex int, fullCtx bool) *ErrorInfo {
+	return &ErrorInfo{
+		DecisionEventInfo: NewDecisionEventInfo(decision, configs, input, startIndex, stopIndex, fullCtx),
+	}
+}
+
+// PredicateEvalInfo records the evaluation of a semantic predicate during
+// prediction.
+type PredicateEvalInfo struct {
+	*DecisionEventInfo
+
+	// Semctx is the semantic context which was evaluated.
+	Semctx SemanticContext
+
+	// PredictedAlt is the alternative number for the decision which is
+	// guarded by Semctx.
+	PredictedAlt int
+
+	// EvalResult is the result of evaluating Semctx.
+	EvalResult bool
+}
+
+func NewPredicateEvalInfo(decision int, input TokenStream, startIndex, stopIndex int, semctx SemanticContext, evalResult bool, predictedAlt int, fullCtx bool) *PredicateEvalInfo {
+	return &PredicateEvalInfo{
+		DecisionEventInfo: NewDecisionEventInfo(decision, nil, input, startIndex, stopIndex, fullCtx),
+		Semctx:            semctx,
+		PredictedAlt:      predictedAlt,
+		EvalResult:        evalResult,
+	}
+}
@@ -7,6 +7,7 @@ package antlr
 import (
 	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 type DFA struct {
@@ -20,20 +21,56 @@ type DFA struct {
 	states map[int]*DFAState
 	statesMu sync.RWMutex
 
-	s0 *DFAState
-	s0Mu sync.RWMutex
+	// s0 holds the *DFAState prediction starts from. Like the edges of DFA
+	// states, it is read without locking by concurrent predictions.
+	s0 atomic.Value
 
-	// precedenceDfa is the backing field for isPrecedenceDfa and setPrecedenceDfa.
-	// True if the DFA is for a precedence decision and false otherwise.
+	// precedenceDfa is true if the DFA is for a precedence decision and false
+	// otherwise. It is set once by NewDFA.
 	precedenceDfa bool
+
+	// maxStates is the maximum number of states d may hold before it is
+	// cleared, or 0 for no limit.
+	maxStates int
+
+	// clears is the number of times d was cleared.
+	clears int64
 }
 
+// NewDFA creates an empty DFA for the decision atnStartState. If
+// atnStartState is the decision for the closure block that determines
+// whether a precedence rule should continue or complete, the DFA is a
+// precedence DFA, and its initial state s0 is a DFAState whose edges store
+// the start states for individual precedence values.
 func NewDFA(atnStartState DecisionState, decision int) *DFA {
-	return &DFA{
+	d := &DFA{
 		atnStartState: atnStartState,
 		decision:      decision,
 		states:        make(map[int]*DFAState),
 	}
+	if s, ok := atnStartState.(*StarLoopEntryState); ok && s.precedenceRuleDecision {
+		d.precedenceDfa = true
+	}
+	d.resetS0()
+
+	return d
+}
+
+// resetS0 sets s0 to nil, or for a precedence DFA, to a new DFAState with
+// empty edges.
+func (d *DFA) resetS0() {
+	if !d.precedenceDfa {
+		d.setS0(nil)
+		return
+	}
+
+	precedenceState := NewDFAState(-1, NewBaseATNConfigSet(false))
+
+	// s0.edges is never nil for a precedence DFA
+	precedenceState.setEdges(make([]*DFAState, 0))
+	precedenceState.isAcceptState = false
+	precedenceState.requiresFullContext = false
+	d.setS0(precedenceState)
 }
 
 // getPrecedenceStartState gets the start state for the current precedence and
@@ -45,15 +82,7 @@ func (d *DFA) getPrecedenceStartState(precedence int) *DFAState {
 		panic("only precedence DFAs may contain a precedence start state")
 	}
 
-	d.s0Mu.RLock()
-	defer d.s0Mu.RUnlock()
-
-	// s0.edges is never nil for a precedence DFA
-	if precedence < 0 || precedence >= len(d.s0.edges) {
-		return nil
-	}
-
-	return d.s0.edges[precedence]
+	return d.getS0().getEdge(precedence)
 }
 
 // setPrecedenceStartState sets the start state for the current precedence. d
@@ -67,72 +96,84 @@ func (d *DFA) setPrecedenceStartState(precedence int, startState *DFAState) {
 		return
 	}
 
-	d.s0Mu.Lock()
-	defer d.s0Mu.Unlock()
-
-	// Synchronization on s0 here is ok. When the DFA is turned into a
-	// precedence DFA, s0 will be initialized once and not updated again. s0.edges
-	// is never nil for a precedence DFA.
-	if precedence >= len(d.s0.edges) {
-		d.s0.edges = append(d.s0.edges, make([]*DFAState, precedence+1-len(d.s0.edges))...)
-	}
-
-	d.s0.edges[precedence] = startState
+	d.getS0().setEdge(precedence, precedence+1, startState)
 }
 
-// setPrecedenceDfa sets whether d is a precedence DFA. If precedenceDfa differs
-// from the current DFA configuration, then d.states is cleared, the initial
-// state s0 is set to a new DFAState with an empty outgoing DFAState.edges to
-// store the start states for individual precedence values if precedenceDfa is
-// true or nil otherwise, and d.precedenceDfa is updated.
-func (d *DFA) setPrecedenceDfa(precedenceDfa bool) {
-	if d.precedenceDfa != precedenceDfa {
-		d.states = make(map[int]*DFAState)
-
-		if precedenceDfa {
-			precedenceState := NewDFAState(-1, NewBaseATNConfigSet(false))
+func (d *DFA) getS0() *DFAState {
+	s0, _ := d.s0.Load().(*DFAState)
+	return s0
+}
 
-			precedenceState.edges = make([]*DFAState, 0)
-			precedenceState.isAcceptState = false
-			precedenceState.requiresFullContext = false
-			d.s0 = precedenceState
-		} else {
-			d.s0 = nil
-		}
+func (d *DFA) setS0(s *DFAState) {
+	d.s0.Store(s)
+}
 
-		d.precedenceDfa = precedenceDfa
+// addStateIfAbsent adds state to d and returns it, or returns the equivalent
+// state d already holds. Looking the state up, making room for it, numbering
+// it and adding it happen under a single lock, so that concurrent predictions
+// that reach the same new state agree on one instance with a unique number.
+// The caller must have finished the configurations of state.
+func (d *DFA) addStateIfAbsent(state *DFAState) *DFAState {
+	hash := state.hash()
+	d.statesMu.Lock()
+	defer d.statesMu.Unlock()
+	if existing, ok := d.states[hash]; ok {
+		return existing
 	}
+	if d.maxStates > 0 && len(d.states) >= d.maxStates {
+		d.clear()
+	}
+	state.stateNumber = len(d.states)
+	d.states[hash] = state
+	return state
 }
 
-func (d *DFA) getS0() *DFAState {
-	d.s0Mu.RLock()
-	defer d.s0Mu.RUnlock()
-	return d.s0
+func (d *DFA) numStates() int {
+	d.statesMu.RLock()
+	defer d.statesMu.RUnlock()
+	return len(d.states)
 }
 
-func (d *DFA) setS0(s *DFAState) {
-	d.s0Mu.Lock()
-	defer d.s0Mu.Unlock()
-	d.s0 = s
+// NumStates returns the number of states in d.
+func (d *DFA) NumStates() int {
+	return d.numStates()
 }
 
-func (d *DFA) getState(hash int) (*DFAState, bool) {
+// Clears returns the number of times d was cleared, by Clear or because it
+// reached its maximum number of states.
+func (d *DFA) Clears() int64 {
 	d.statesMu.RLock()
 	defer d.statesMu.RUnlock()
-	s, ok := d.states[hash]
-	return s, ok
+	return d.clears
 }
 
-func (d *DFA) setState(hash int, state *DFAState) {
+// SetMaxStates limits the number of states d may hold to maxStates. Adding
+// a state to a DFA that holds maxStates states first clears it, so that its
+// memory use is bounded while the states used by recent input are rebuilt
+// from the ATN as needed. A maxStates of 0 or less means no limit, which is
+// the default.
+func (d *DFA) SetMaxStates(maxStates int) {
 	d.statesMu.Lock()
 	defer d.statesMu.Unlock()
-	d.states[hash] = state
+	d.maxStates = maxStates
 }
 
-func (d *DFA) numStates() int {
-	d.statesMu.RLock()
-	defer d.statesMu.RUnlock()
-	return len(d.states)
+// Clear removes all the states of d, after which predictions rebuild them
+// from the ATN.
+//
+// <p>Predictions running concurrently keep using the states they already
+// reached, which are no longer reachable from d.</p>
+func (d *DFA) Clear() {
+	d.statesMu.Lock()
+	defer d.statesMu.Unlock()
+	d.clear()
+}
+
+// clear removes all the states of d. The caller must hold statesMu.
+func (d *DFA) clear() {
+	d.states = make(map[int]*DFAState)
+	d.clears++
+	d.resetS0()
 }
 
 type dfaStateList []*DFAState
@@ -143,6 +184,9 @@ func (d dfaStateList) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
 
 // sortedStates returns the states in d sorted by their state number.
 func (d *DFA) sortedStates() []*DFAState {
+	d.statesMu.RLock()
+	defer d.statesMu.RUnlock()
+
 	vs := make([]*DFAState, 0, len(d.states))
 
 	for _, v := range d.states {
@@ -155,7 +199,7 @@ func (d *DFA) sortedStates() []*DFAState {
 }
 
 func (d *DFA) String(literalNames []string, symbolicNames []string) string {
-	if d.s0 == nil {
+	if d.getS0() == nil {
 		return ""
 	}
 
@@ -163,7 +207,7 @@ func (d *DFA) String(literalNames []string, symbolicNames []string) string {
 }
 
 func (d *DFA) ToLexerString() string {
-	if d.s0 == nil {
+	if d.getS0() == nil {
 		return ""
 	}
 
@@ -0,0 +1,265 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// These tests run many parsers in parallel over DFAs and prediction context
+// caches they all share, as generated parsers and lexers do. Run them with
+// the race detector, go test -race.
+
+const concurrentParsers = 32
+
+// sharedExprCaches holds the DFAs and prediction context caches shared by
+// the lexers and parsers created by newExprParser.
+type sharedExprCaches struct {
+	lexerDFA      []*DFA
+	lexerContexts *PredictionContextCache
+
+	parserDFA      []*DFA
+	parserContexts *PredictionContextCache
+}
+
+func newSharedExprCaches() *sharedExprCaches {
+	newDFAs := func(atn *ATN) []*DFA {
+		dfas := make([]*DFA, len(atn.DecisionToState))
+		for i, ds := range atn.DecisionToState {
+			dfas[i] = NewDFA(ds, i)
+		}
+		return dfas
+	}
+
+	return &sharedExprCaches{
+		lexerDFA:       newDFAs(exprLexerATN),
+		lexerContexts:  NewPredictionContextCache(),
+		parserDFA:      newDFAs(exprParserATN),
+		parserContexts: NewPredictionContextCache(),
+	}
+}
+
+func (c *sharedExprCaches) newExprParser(input string) *ParserInterpreter {
+	lexer := newExprLexer(NewInputStream(input))
+	lexer.Interpreter = NewLexerATNSimulator(lexer, exprLexerATN, c.lexerDFA, c.lexerContexts)
+	lexer.RemoveErrorListeners()
+
+	parser, _ := parseExpr("")
+	parser.Interpreter = NewParserATNSimulator(parser, exprParserATN, c.parserDFA, c.parserContexts)
+	parser.SetInputStream(NewCommonTokenStream(lexer, TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+
+	return parser
+}
+
+// concurrentExprInput returns the i-th of a family of inputs that differ
+// enough to keep adding DFA states and edges while they are parsed.
+func concurrentExprInput(i int) string {
+	var b strings.Builder
+	for j := 0; j < 1+i%5; j++ {
+		fmt.Fprintf(&b, "%s = ", strings.Repeat("v", j+1))
+		for k := 0; k < 1+(i+j)%4; k++ {
+			if k > 0 {
+				b.WriteString([]string{" + ", " * "}[(i+k)%2])
+			}
+			if (i+j+k)%3 == 0 {
+				fmt.Fprintf(&b, "(%s + %d)", strings.Repeat("x", k+1), i)
+			} else {
+				fmt.Fprintf(&b, "%d", i*k+j)
+			}
+		}
+		b.WriteString(";\n")
+	}
+
+	return b.String()
+}
+
+// parseConcurrently parses the inputs with a parser each, in parallel over
+// caches, and checks the trees match those of parsers with their own
+// caches.
+func parseConcurrently(t *testing.T, caches *sharedExprCaches, inputs []string) {
+	t.Helper()
+
+	want := make([]string, len(inputs))
+	for i, input := range inputs {
+		parser := newSharedExprCaches().newExprParser(input)
+		tree, syntaxErrors, err := parseExprProg(parser)
+		if err != nil || len(syntaxErrors) != 0 {
+			t.Fatalf("input %d: expected no errors, got %v, %v", i, err, syntaxErrors)
+		}
+		want[i] = tree.ToStringTree(nil, parser)
+	}
+
+	got := make([]string, len(inputs))
+	errs := make([]error, len(inputs))
+	var wg sync.WaitGroup
+	for i := range inputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			parser := caches.newExprParser(inputs[i])
+			tree, syntaxErrors, err := parseExprProg(parser)
+			if err == nil && len(syntaxErrors) != 0 {
+				err = syntaxErrors[0]
+			}
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			got[i] = tree.ToStringTree(nil, parser)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range inputs {
+		if errs[i] != nil {
+			t.Errorf("input %d: unexpected error %v", i, errs[i])
+		} else if got[i] != want[i] {
+			t.Errorf("input %d: expected tree %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestConcurrentParsersSharedDFA(t *testing.T) {
+	caches := newSharedExprCaches()
+
+	inputs := make([]string, concurrentParsers)
+	for i := range inputs {
+		inputs[i] = concurrentExprInput(i)
+	}
+
+	// once over cold DFAs, then again over the DFAs the first round built
+	parseConcurrently(t, caches, inputs)
+	parseConcurrently(t, caches, inputs)
+}
+
+func TestConcurrentParsersSharedDFAWithCachePolicy(t *testing.T) {
+	caches := newSharedExprCaches()
+	policy := ATNCachePolicy{MaxDFAStates: 3, MaxContextCacheSize: 2}
+	caches.newExprParser("").GetInterpreter().SetCachePolicy(policy)
+	for _, dfa := range caches.lexerDFA {
+		dfa.SetMaxStates(policy.MaxDFAStates)
+	}
+
+	inputs := make([]string, concurrentParsers)
+	for i := range inputs {
+		inputs[i] = concurrentExprInput(i)
+	}
+
+	parseConcurrently(t, caches, inputs)
+}
+
+func TestConcurrentParsersClearDFA(t *testing.T) {
+	caches := newSharedExprCaches()
+
+	inputs := make([]string, concurrentParsers)
+	for i := range inputs {
+		inputs[i] = concurrentExprInput(i)
+	}
+
+	done := make(chan struct{})
+	cleared := make(chan struct{})
+	go func() {
+		defer close(cleared)
+		sim := caches.newExprParser("").GetInterpreter()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				sim.ClearDFA()
+			}
+		}
+	}()
+
+	parseConcurrently(t, caches, inputs)
+	close(done)
+	<-cleared
+}
+
+func TestConcurrentParsersFullContext(t *testing.T) {
+	dfas := make([]*DFA, len(ctxParserATN.DecisionToState))
+	for i, ds := range ctxParserATN.DecisionToState {
+		dfas[i] = NewDFA(ds, i)
+	}
+	contexts := NewPredictionContextCache()
+
+	inputs := [][]int{
+		{ctxDollar, ctxINT, ctxID},
+		{ctxDollar, ctxID},
+		{ctxAt, ctxINT, ctxINT, ctxID},
+		{ctxAt, ctxINT, ctxID},
+		{ctxBang, ctxID},
+	}
+
+	want := make([]string, len(inputs))
+	for i, input := range inputs {
+		parser := newCtxParser(input...)
+		want[i] = parser.Parse(0).ToStringTree(nil, parser)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentParsers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := i % len(inputs)
+			parser := newCtxParser(inputs[input]...)
+			parser.Interpreter = NewParserATNSimulator(parser, ctxParserATN, dfas, contexts)
+			if got := parser.Parse(0).ToStringTree(nil, parser); got != want[input] {
+				t.Errorf("input %d: expected tree %s, got %s", input, want[input], got)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentAddDFAState(t *testing.T) {
+	const newStates = 16
+
+	caches := newSharedExprCaches()
+	sim := caches.newExprParser("").GetInterpreter()
+	dfa := caches.parserDFA[0]
+
+	// Each goroutine proposes its own, equal, instances of the same new
+	// states, all at once.
+	added := make([][]*DFAState, concurrentParsers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range added {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			for k := 0; k < newStates; k++ {
+				configs := NewBaseATNConfigSet(false)
+				configs.Add(NewBaseATNConfig6(exprParserATN.states[k], 1, BasePredictionContextEMPTY), nil)
+				added[i] = append(added[i], sim.addDFAState(dfa, NewDFAState(-1, configs)))
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for k := 0; k < newStates; k++ {
+		for i := range added {
+			if added[i][k] != added[0][k] {
+				t.Errorf("state %d: expected one instance, got %v and %v", k, added[0][k], added[i][k])
+			}
+		}
+	}
+
+	if n := dfa.NumStates(); n != newStates {
+		t.Fatalf("expected %d states, got %d", newStates, n)
+	}
+	for i, s := range dfa.sortedStates() {
+		if s.stateNumber != i {
+			t.Errorf("expected state number %d, got %d", i, s.stateNumber)
+		}
+	}
+}
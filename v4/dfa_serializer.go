@@ -34,7 +34,7 @@ func NewDFASerializer(dfa *DFA, literalNames, symbolicNames []string) *DFASerial
 }
 
 func (d *DFASerializer) String() string {
-	if d.dfa.s0 == nil {
+	if d.dfa.getS0() == nil {
 		return ""
 	}
 
@@ -42,11 +42,11 @@ func (d *DFASerializer) String() string {
 	states := d.dfa.sortedStates()
 
 	for _, s := range states {
-		if s.edges != nil {
-			n := len(s.edges)
+		if edges := s.getEdges(); edges != nil {
+			n := len(edges)
 
 			for j := 0; j < n; j++ {
-				t := s.edges[j]
+				t := edges[j]
 
 				if t != nil && t.stateNumber != 0x7FFFFFFF {
 					buf += d.GetStateString(s)
@@ -112,11 +112,11 @@ func NewLexerDFASerializer(dfa *DFA) *LexerDFASerializer {
 }
 
 func (l *LexerDFASerializer) getEdgeLabel(i int) string {
-	return "'" + string(i) + "'"
+	return "'" + string(rune(i)) + "'"
 }
 
 func (l *LexerDFASerializer) String() string {
-	if l.dfa.s0 == nil {
+	if l.dfa.getS0() == nil {
 		return ""
 	}
 
@@ -126,11 +126,11 @@ func (l *LexerDFASerializer) String() string {
 	for i := 0; i < len(states); i++ {
 		s := states[i]
 
-		if s.edges != nil {
-			n := len(s.edges)
+		if edges := s.getEdges(); edges != nil {
+			n := len(edges)
 
 			for j := 0; j < n; j++ {
-				t := s.edges[j]
+				t := edges[j]
 
 				if t != nil && t.stateNumber != 0x7FFFFFFF {
 					buf += l.GetStateString(s)
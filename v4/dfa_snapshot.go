@@ -0,0 +1,936 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+)
+
+// A DFA snapshot starts with dfaSnapshotMagic, followed by the format
+// version and the fingerprint of the ATN the DFAs were built from.
+const (
+	dfaSnapshotMagic   = "ANTLRDFA"
+	dfaSnapshotVersion = 1
+)
+
+// ErrDFASnapshotATNMismatch is returned by ReadDFASnapshot when the
+// snapshot was written for a different ATN, such as one generated from a
+// different version of the grammar.
+var ErrDFASnapshotATNMismatch = errors.New("DFA snapshot was written for a different ATN")
+
+// References to DFA states in a snapshot: nil, ATNSimulatorError, or the
+// index of a state of the same DFA plus dfaSnapshotFirstState.
+const (
+	dfaSnapshotNilState   = 0
+	dfaSnapshotErrorState = 1
+	dfaSnapshotFirstState = 2
+)
+
+// References to prediction contexts in a snapshot: nil,
+// BasePredictionContextEMPTY, or the index of a context in the snapshot's
+// context table plus dfaSnapshotFirstContext.
+const (
+	dfaSnapshotNilContext   = 0
+	dfaSnapshotEmptyContext = 1
+	dfaSnapshotFirstContext = 2
+)
+
+const (
+	dfaSnapshotSingletonContext = iota + 1
+	dfaSnapshotArrayContext
+)
+
+const (
+	dfaSnapshotSemanticContextNone = iota
+	dfaSnapshotPredicate
+	dfaSnapshotPrecedencePredicate
+	dfaSnapshotAND
+	dfaSnapshotOR
+)
+
+// Flags of an ATN configuration set in a snapshot.
+const (
+	dfaSnapshotReadOnly = 1 << iota
+	dfaSnapshotFullCtx
+	dfaSnapshotHasSemanticContext
+	dfaSnapshotDipsIntoOuterContext
+	dfaSnapshotOrdered
+)
+
+// Flags of an ATN configuration in a snapshot.
+const (
+	dfaSnapshotPrecedenceFilterSuppressed = 1 << iota
+	dfaSnapshotLexerConfig
+	dfaSnapshotPassedThroughNonGreedyDecision
+)
+
+// WriteDFASnapshot writes the DFAs in decisionToDFA, which were built from
+// atn, to w. Reading the snapshot back with ReadDFASnapshot when a process
+// starts gives its parsers or lexers the DFA states they would otherwise
+// have to compute from the ATN on their first inputs, which is the main
+// cost of the first parses.
+//
+// <p>For a parser, atn and decisionToDFA are those of its interpreter:</p>
+//
+//	sim := p.GetInterpreter()
+//	err := antlr.WriteDFASnapshot(w, sim.ATN(), sim.DecisionToDFA())
+//
+// <p>For a lexer, they are those of its Interpreter. The DFAs must not be in
+// use by a parser or lexer while they are written.</p>
+func WriteDFASnapshot(w io.Writer, atn *ATN, decisionToDFA []*DFA) error {
+	sw := &dfaSnapshotWriter{
+		w:        bufio.NewWriter(w),
+		atn:      atn,
+		contexts: make(map[PredictionContext]int),
+	}
+
+	dfas := make([][]*DFAState, len(decisionToDFA))
+	for i, dfa := range decisionToDFA {
+		dfas[i] = dfaSnapshotStates(dfa)
+		for _, s := range dfas[i] {
+			for _, c := range s.configs.GetItems() {
+				sw.addContext(c.GetContext())
+			}
+		}
+	}
+
+	sw.writeString(dfaSnapshotMagic)
+	sw.writeUvarint(dfaSnapshotVersion)
+	sw.writeUint64(atnFingerprint(atn))
+
+	sw.writeUvarint(uint64(len(sw.contextList)))
+	for _, ctx := range sw.contextList {
+		sw.writeContext(ctx)
+	}
+
+	sw.writeUvarint(uint64(len(decisionToDFA)))
+	for i, dfa := range decisionToDFA {
+		sw.writeDFA(dfa, dfas[i])
+	}
+
+	if sw.err != nil {
+		return sw.err
+	}
+
+	return sw.w.Flush()
+}
+
+// ReadDFASnapshot reads a snapshot written by WriteDFASnapshot from r into
+// decisionToDFA, replacing all of their states. It returns
+// ErrDFASnapshotATNMismatch if the snapshot was not written for atn, in
+// which case decisionToDFA is left unchanged, as it is for any other
+// error. A snapshot holding more states for a DFA than the maximum set by
+// DFA.SetMaxStates, as ATNCachePolicy.MaxDFAStates does, is an error.
+//
+// <p>Load a snapshot before any parser or lexer uses decisionToDFA. Since
+// generated parsers and lexers share their DFAs across instances, loading
+// a snapshot into the DFAs of one instance warms up all of them:</p>
+//
+//	sim := p.GetInterpreter()
+//	err := antlr.ReadDFASnapshot(r, sim.ATN(), sim.DecisionToDFA())
+func ReadDFASnapshot(r io.Reader, atn *ATN, decisionToDFA []*DFA) error {
+	sr := &dfaSnapshotReader{r: bufio.NewReader(r), atn: atn}
+
+	if magic := sr.readString(len(dfaSnapshotMagic)); sr.err == nil && magic != dfaSnapshotMagic {
+		return errors.New("not a DFA snapshot")
+	}
+	if version := sr.readUvarint(); sr.err == nil && version != dfaSnapshotVersion {
+		return fmt.Errorf("unsupported DFA snapshot version %d", version)
+	}
+	if fingerprint := sr.readUint64(); sr.err == nil && fingerprint != atnFingerprint(atn) {
+		return ErrDFASnapshotATNMismatch
+	}
+
+	n := sr.readUvarint()
+	for i := uint64(0); i < n && sr.err == nil; i++ {
+		sr.contexts = append(sr.contexts, sr.readContext())
+	}
+
+	if n := sr.readUvarint(); sr.err == nil && n != uint64(len(decisionToDFA)) {
+		return fmt.Errorf("DFA snapshot has %d DFAs, expected %d", n, len(decisionToDFA))
+	}
+
+	dfas := make([]*DFA, len(decisionToDFA))
+	for i, dfa := range decisionToDFA {
+		if sr.err != nil {
+			break
+		}
+		dfas[i] = sr.readDFA(dfa)
+	}
+
+	if sr.err != nil {
+		if sr.err == io.EOF {
+			sr.err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("malformed DFA snapshot: %v", sr.err)
+	}
+
+	for i, dfa := range decisionToDFA {
+		dfa.statesMu.RLock()
+		maxStates := dfa.maxStates
+		dfa.statesMu.RUnlock()
+		if n := len(dfas[i].states); maxStates > 0 && n > maxStates {
+			return fmt.Errorf("DFA snapshot has %d states for DFA %d, more than its maximum of %d", n, i, maxStates)
+		}
+	}
+
+	for i, dfa := range decisionToDFA {
+		dfa.statesMu.Lock()
+		dfa.states = dfas[i].states
+		dfa.statesMu.Unlock()
+
+		dfa.setS0(dfas[i].getS0())
+	}
+
+	return nil
+}
+
+// dfaSnapshotStates returns the states of dfa, ordered by state number,
+// followed by the states reachable from s0 that are not in dfa.states,
+// such as the start state of a precedence DFA.
+func dfaSnapshotStates(dfa *DFA) []*DFAState {
+	states := dfa.sortedStates()
+
+	seen := make(map[*DFAState]bool, len(states))
+	for _, s := range states {
+		seen[s] = true
+	}
+
+	work := []*DFAState{dfa.getS0()}
+	for len(work) > 0 {
+		s := work[len(work)-1]
+		work = work[:len(work)-1]
+		if s == nil || s == ATNSimulatorError || seen[s] {
+			continue
+		}
+
+		seen[s] = true
+		states = append(states, s)
+		work = append(work, s.getEdges()...)
+	}
+
+	return states
+}
+
+// atnFingerprint returns a hash of the structure of atn, which identifies
+// the grammar and version of the grammar it was generated from.
+func atnFingerprint(atn *ATN) uint64 {
+	h := fnv.New64a()
+	var buf [binary.MaxVarintLen64]byte
+	put := func(values ...int) {
+		for _, v := range values {
+			h.Write(buf[:binary.PutVarint(buf[:], int64(v))])
+		}
+	}
+
+	put(atn.grammarType, atn.maxTokenType, len(atn.states))
+	for _, s := range atn.states {
+		if s == nil {
+			put(ATNStateInvalidType)
+			continue
+		}
+
+		put(s.GetStateType(), s.GetRuleIndex(), len(s.GetTransitions()))
+		if rs, ok := s.(*RuleStartState); ok && rs.isPrecedenceRule {
+			put(1)
+		}
+		for _, t := range s.GetTransitions() {
+			put(t.getSerializationType(), t.getTarget().GetStateNumber())
+			switch t := t.(type) {
+			case *EpsilonTransition:
+				put(t.outermostPrecedenceReturn)
+			case *RuleTransition:
+				put(t.ruleIndex, t.precedence, t.followState.GetStateNumber())
+			case *PredicateTransition:
+				put(t.ruleIndex, t.predIndex, boolToInt(t.isCtxDependent))
+			case *ActionTransition:
+				put(t.ruleIndex, t.actionIndex, boolToInt(t.isCtxDependent))
+			case *PrecedencePredicateTransition:
+				put(t.precedence)
+			}
+			if label := t.getLabel(); label != nil {
+				put(len(label.intervals))
+				for _, i := range label.intervals {
+					put(i.Start, i.Stop)
+				}
+			}
+		}
+	}
+
+	put(len(atn.DecisionToState))
+	for _, s := range atn.DecisionToState {
+		put(s.GetStateNumber())
+	}
+	put(len(atn.ruleToStartState))
+	for _, s := range atn.ruleToStartState {
+		put(s.GetStateNumber())
+	}
+	put(len(atn.modeToStartState))
+	for _, s := range atn.modeToStartState {
+		put(s.GetStateNumber())
+	}
+	put(atn.ruleToTokenType...)
+	put(len(atn.lexerActions))
+	for _, a := range atn.lexerActions {
+		put(a.getActionType(), a.hash())
+	}
+
+	return h.Sum64()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+type dfaSnapshotWriter struct {
+	w   *bufio.Writer
+	atn *ATN
+	err error
+	buf [binary.MaxVarintLen64]byte
+
+	// contexts maps the prediction contexts of the snapshot to their index
+	// in contextList, in which every context follows its parents.
+	contexts    map[PredictionContext]int
+	contextList []PredictionContext
+}
+
+func (sw *dfaSnapshotWriter) fail(format string, args ...interface{}) {
+	if sw.err == nil {
+		sw.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (sw *dfaSnapshotWriter) write(p []byte) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(p)
+	}
+}
+
+func (sw *dfaSnapshotWriter) writeString(s string) {
+	sw.write([]byte(s))
+}
+
+func (sw *dfaSnapshotWriter) writeUint64(v uint64) {
+	binary.LittleEndian.PutUint64(sw.buf[:8], v)
+	sw.write(sw.buf[:8])
+}
+
+func (sw *dfaSnapshotWriter) writeUvarint(v uint64) {
+	sw.write(sw.buf[:binary.PutUvarint(sw.buf[:], v)])
+}
+
+func (sw *dfaSnapshotWriter) writeInt(v int) {
+	sw.write(sw.buf[:binary.PutVarint(sw.buf[:], int64(v))])
+}
+
+func (sw *dfaSnapshotWriter) writeBool(b bool) {
+	sw.writeUvarint(uint64(boolToInt(b)))
+}
+
+// addContext adds ctx and its parents to the context table.
+func (sw *dfaSnapshotWriter) addContext(ctx PredictionContext) {
+	if _, ok := ctx.(*EmptyPredictionContext); ok || ctx == nil {
+		return
+	}
+	if _, ok := sw.contexts[ctx]; ok {
+		return
+	}
+
+	for i := 0; i < ctx.length(); i++ {
+		sw.addContext(ctx.GetParent(i))
+	}
+
+	sw.contexts[ctx] = len(sw.contextList)
+	sw.contextList = append(sw.contextList, ctx)
+}
+
+func (sw *dfaSnapshotWriter) writeContextRef(ctx PredictionContext) {
+	if ctx == nil {
+		sw.writeUvarint(dfaSnapshotNilContext)
+	} else if _, ok := ctx.(*EmptyPredictionContext); ok {
+		sw.writeUvarint(dfaSnapshotEmptyContext)
+	} else {
+		sw.writeUvarint(uint64(sw.contexts[ctx] + dfaSnapshotFirstContext))
+	}
+}
+
+func (sw *dfaSnapshotWriter) writeContext(ctx PredictionContext) {
+	switch ctx := ctx.(type) {
+	case *BaseSingletonPredictionContext:
+		sw.writeUvarint(dfaSnapshotSingletonContext)
+		sw.writeContextRef(ctx.parentCtx)
+		sw.writeInt(ctx.returnState)
+	case *ArrayPredictionContext:
+		sw.writeUvarint(dfaSnapshotArrayContext)
+		sw.writeUvarint(uint64(len(ctx.returnStates)))
+		for i, returnState := range ctx.returnStates {
+			sw.writeContextRef(ctx.parents[i])
+			sw.writeInt(returnState)
+		}
+	default:
+		sw.fail("unsupported prediction context %T", ctx)
+	}
+}
+
+func (sw *dfaSnapshotWriter) writeSemanticContext(semctx SemanticContext) {
+	if semctx == SemanticContextNone {
+		sw.writeUvarint(dfaSnapshotSemanticContextNone)
+		return
+	}
+
+	switch semctx := semctx.(type) {
+	case *Predicate:
+		sw.writeUvarint(dfaSnapshotPredicate)
+		sw.writeInt(semctx.ruleIndex)
+		sw.writeInt(semctx.predIndex)
+		sw.writeBool(semctx.isCtxDependent)
+	case *PrecedencePredicate:
+		sw.writeUvarint(dfaSnapshotPrecedencePredicate)
+		sw.writeInt(semctx.precedence)
+	case *AND:
+		sw.writeUvarint(dfaSnapshotAND)
+		sw.writeSemanticContexts(semctx.opnds)
+	case *OR:
+		sw.writeUvarint(dfaSnapshotOR)
+		sw.writeSemanticContexts(semctx.opnds)
+	default:
+		sw.fail("unsupported semantic context %T", semctx)
+	}
+}
+
+func (sw *dfaSnapshotWriter) writeSemanticContexts(opnds []SemanticContext) {
+	sw.writeUvarint(uint64(len(opnds)))
+	for _, o := range opnds {
+		sw.writeSemanticContext(o)
+	}
+}
+
+// writeLexerAction writes a reference to action, which must be one of the
+// lexer actions of the ATN, possibly wrapped in a LexerIndexedCustomAction.
+func (sw *dfaSnapshotWriter) writeLexerAction(action LexerAction) {
+	offset := -1
+	if a, ok := action.(*LexerIndexedCustomAction); ok {
+		offset = a.offset
+		action = a.lexerAction
+	}
+
+	for i, a := range sw.atn.lexerActions {
+		if a == action || a.equals(action) {
+			sw.writeInt(offset)
+			sw.writeUvarint(uint64(i))
+			return
+		}
+	}
+
+	sw.fail("lexer action %T is not an action of the ATN", action)
+}
+
+func (sw *dfaSnapshotWriter) writeLexerActionExecutor(e *LexerActionExecutor) {
+	if e == nil {
+		sw.writeUvarint(0)
+		return
+	}
+
+	sw.writeUvarint(uint64(len(e.lexerActions) + 1))
+	for _, a := range e.lexerActions {
+		sw.writeLexerAction(a)
+	}
+}
+
+func (sw *dfaSnapshotWriter) writeConfigSet(configs ATNConfigSet) {
+	var b *BaseATNConfigSet
+	flags := 0
+	switch configs := configs.(type) {
+	case *BaseATNConfigSet:
+		b = configs
+	case *OrderedATNConfigSet:
+		b = configs.BaseATNConfigSet
+		flags |= dfaSnapshotOrdered
+	default:
+		sw.fail("unsupported ATN configuration set %T", configs)
+		return
+	}
+
+	if b.readOnly {
+		flags |= dfaSnapshotReadOnly
+	}
+	if b.fullCtx {
+		flags |= dfaSnapshotFullCtx
+	}
+	if b.hasSemanticContext {
+		flags |= dfaSnapshotHasSemanticContext
+	}
+	if b.dipsIntoOuterContext {
+		flags |= dfaSnapshotDipsIntoOuterContext
+	}
+	sw.writeUvarint(uint64(flags))
+	sw.writeInt(b.uniqueAlt)
+	sw.writeAlts(b.conflictingAlts)
+
+	sw.writeUvarint(uint64(len(b.configs)))
+	for _, c := range b.configs {
+		sw.writeConfig(c)
+	}
+}
+
+func (sw *dfaSnapshotWriter) writeAlts(alts *BitSet) {
+	if alts == nil {
+		sw.writeUvarint(0)
+		return
+	}
+
+	values := alts.values()
+	sw.writeUvarint(uint64(len(values) + 1))
+	for _, v := range values {
+		sw.writeInt(v)
+	}
+}
+
+func (sw *dfaSnapshotWriter) writeConfig(config ATNConfig) {
+	var b *BaseATNConfig
+	var lc *LexerATNConfig
+	switch config := config.(type) {
+	case *BaseATNConfig:
+		b = config
+	case *LexerATNConfig:
+		b = config.BaseATNConfig
+		lc = config
+	default:
+		sw.fail("unsupported ATN configuration %T", config)
+		return
+	}
+
+	flags := 0
+	if b.precedenceFilterSuppressed {
+		flags |= dfaSnapshotPrecedenceFilterSuppressed
+	}
+	if lc != nil {
+		flags |= dfaSnapshotLexerConfig
+		if lc.passedThroughNonGreedyDecision {
+			flags |= dfaSnapshotPassedThroughNonGreedyDecision
+		}
+	}
+	sw.writeUvarint(uint64(flags))
+	sw.writeUvarint(uint64(b.state.GetStateNumber()))
+	sw.writeInt(b.alt)
+	sw.writeContextRef(b.context)
+	sw.writeSemanticContext(b.semanticContext)
+	sw.writeInt(b.reachesIntoOuterContext)
+	if lc != nil {
+		sw.writeLexerActionExecutor(lc.lexerActionExecutor)
+	}
+}
+
+func (sw *dfaSnapshotWriter) writeDFA(dfa *DFA, states []*DFAState) {
+	keys := make(map[*DFAState]int, len(dfa.states))
+	for key, s := range dfa.states {
+		keys[s] = key
+	}
+
+	refs := make(map[*DFAState]int, len(states))
+	for i, s := range states {
+		refs[s] = i + dfaSnapshotFirstState
+	}
+	ref := func(s *DFAState) uint64 {
+		switch s {
+		case nil:
+			return dfaSnapshotNilState
+		case ATNSimulatorError:
+			return dfaSnapshotErrorState
+		default:
+			return uint64(refs[s])
+		}
+	}
+
+	sw.writeBool(dfa.precedenceDfa)
+	sw.writeUvarint(uint64(len(states)))
+	for _, s := range states {
+		key, inMap := keys[s]
+		sw.writeBool(inMap)
+		if inMap {
+			sw.writeInt(key)
+		}
+
+		sw.writeInt(s.stateNumber)
+		sw.writeBool(s.isAcceptState)
+		sw.writeInt(s.prediction)
+		sw.writeBool(s.requiresFullContext)
+		sw.writeLexerActionExecutor(s.lexerActionExecutor)
+		if s.predicates == nil {
+			sw.writeUvarint(0)
+		} else {
+			sw.writeUvarint(uint64(len(s.predicates) + 1))
+			for _, p := range s.predicates {
+				sw.writeInt(p.alt)
+				sw.writeSemanticContext(p.pred)
+			}
+		}
+		sw.writeConfigSet(s.configs)
+	}
+
+	// edges last, as they may point to any state
+	for _, s := range states {
+		edges := s.getEdges()
+		if edges == nil {
+			sw.writeUvarint(0)
+			continue
+		}
+
+		sw.writeUvarint(uint64(len(edges) + 1))
+		for _, t := range edges {
+			sw.writeUvarint(ref(t))
+		}
+	}
+	sw.writeUvarint(ref(dfa.getS0()))
+}
+
+type dfaSnapshotReader struct {
+	r   *bufio.Reader
+	atn *ATN
+	err error
+
+	contexts []PredictionContext
+}
+
+func (sr *dfaSnapshotReader) fail(format string, args ...interface{}) {
+	if sr.err == nil {
+		sr.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (sr *dfaSnapshotReader) readString(n int) string {
+	if sr.err != nil {
+		return ""
+	}
+
+	b := make([]byte, n)
+	_, sr.err = io.ReadFull(sr.r, b)
+	return string(b)
+}
+
+func (sr *dfaSnapshotReader) readUint64() uint64 {
+	b := sr.readString(8)
+	if sr.err != nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint64([]byte(b))
+}
+
+func (sr *dfaSnapshotReader) readUvarint() uint64 {
+	if sr.err != nil {
+		return 0
+	}
+
+	var v uint64
+	v, sr.err = binary.ReadUvarint(sr.r)
+	return v
+}
+
+func (sr *dfaSnapshotReader) readInt() int {
+	if sr.err != nil {
+		return 0
+	}
+
+	var v int64
+	v, sr.err = binary.ReadVarint(sr.r)
+	return int(v)
+}
+
+func (sr *dfaSnapshotReader) readBool() bool {
+	return sr.readUvarint() != 0
+}
+
+// readCount reads the count of a list of items, each of which takes at
+// least one byte, checking it is not larger than a snapshot could hold.
+func (sr *dfaSnapshotReader) readCount() int {
+	n := sr.readUvarint()
+	if n > 1<<31 {
+		sr.fail("invalid count %d", n)
+		return 0
+	}
+
+	return int(n)
+}
+
+func (sr *dfaSnapshotReader) readContextRef() PredictionContext {
+	ref := sr.readUvarint()
+	switch {
+	case ref == dfaSnapshotNilContext:
+		return nil
+	case ref == dfaSnapshotEmptyContext:
+		return BasePredictionContextEMPTY
+	case ref-dfaSnapshotFirstContext < uint64(len(sr.contexts)):
+		return sr.contexts[ref-dfaSnapshotFirstContext]
+	default:
+		sr.fail("invalid prediction context reference %d", ref)
+		return BasePredictionContextEMPTY
+	}
+}
+
+func (sr *dfaSnapshotReader) readContext() PredictionContext {
+	switch kind := sr.readUvarint(); kind {
+	case dfaSnapshotSingletonContext:
+		parent := sr.readContextRef()
+		return SingletonBasePredictionContextCreate(parent, sr.readInt())
+	case dfaSnapshotArrayContext:
+		n := sr.readCount()
+		var parents []PredictionContext
+		var returnStates []int
+		for i := 0; i < n && sr.err == nil; i++ {
+			parents = append(parents, sr.readContextRef())
+			returnStates = append(returnStates, sr.readInt())
+		}
+		if len(returnStates) == 0 {
+			sr.fail("empty array prediction context")
+			return BasePredictionContextEMPTY
+		}
+		return NewArrayPredictionContext(parents, returnStates)
+	default:
+		sr.fail("invalid prediction context kind %d", kind)
+		return BasePredictionContextEMPTY
+	}
+}
+
+func (sr *dfaSnapshotReader) readSemanticContext() SemanticContext {
+	switch kind := sr.readUvarint(); kind {
+	case dfaSnapshotSemanticContextNone:
+		return SemanticContextNone
+	case dfaSnapshotPredicate:
+		ruleIndex := sr.readInt()
+		predIndex := sr.readInt()
+		return NewPredicate(ruleIndex, predIndex, sr.readBool())
+	case dfaSnapshotPrecedencePredicate:
+		return NewPrecedencePredicate(sr.readInt())
+	case dfaSnapshotAND:
+		return &AND{opnds: sr.readSemanticContexts()}
+	case dfaSnapshotOR:
+		return &OR{opnds: sr.readSemanticContexts()}
+	default:
+		sr.fail("invalid semantic context kind %d", kind)
+		return SemanticContextNone
+	}
+}
+
+func (sr *dfaSnapshotReader) readSemanticContexts() []SemanticContext {
+	n := sr.readCount()
+	var opnds []SemanticContext
+	for i := 0; i < n && sr.err == nil; i++ {
+		opnds = append(opnds, sr.readSemanticContext())
+	}
+
+	return opnds
+}
+
+func (sr *dfaSnapshotReader) readLexerActionExecutor() *LexerActionExecutor {
+	n := sr.readCount()
+	if n == 0 {
+		return nil
+	}
+
+	var actions []LexerAction
+	for i := 1; i < n && sr.err == nil; i++ {
+		offset := sr.readInt()
+		index := sr.readUvarint()
+		if index >= uint64(len(sr.atn.lexerActions)) {
+			sr.fail("invalid lexer action index %d", index)
+			return nil
+		}
+
+		action := sr.atn.lexerActions[index]
+		if offset >= 0 {
+			action = NewLexerIndexedCustomAction(offset, action)
+		}
+		actions = append(actions, action)
+	}
+
+	return NewLexerActionExecutor(actions)
+}
+
+func (sr *dfaSnapshotReader) readConfigSet() ATNConfigSet {
+	flags := sr.readUvarint()
+	b := NewBaseATNConfigSet(flags&dfaSnapshotFullCtx != 0)
+	b.hasSemanticContext = flags&dfaSnapshotHasSemanticContext != 0
+	b.dipsIntoOuterContext = flags&dfaSnapshotDipsIntoOuterContext != 0
+	b.uniqueAlt = sr.readInt()
+	b.conflictingAlts = sr.readAlts()
+
+	n := sr.readCount()
+	for i := 0; i < n && sr.err == nil; i++ {
+		b.configs = append(b.configs, sr.readConfig())
+	}
+
+	var configs ATNConfigSet = b
+	if flags&dfaSnapshotOrdered != 0 {
+		b.configLookup = NewSet(nil, nil)
+		configs = &OrderedATNConfigSet{BaseATNConfigSet: b}
+	}
+	if flags&dfaSnapshotReadOnly != 0 {
+		b.SetReadOnly(true)
+	} else {
+		for _, c := range b.configs {
+			b.configLookup.add(c)
+		}
+	}
+
+	return configs
+}
+
+func (sr *dfaSnapshotReader) readAlts() *BitSet {
+	n := sr.readCount()
+	if n == 0 {
+		return nil
+	}
+
+	alts := NewBitSet()
+	for i := 1; i < n && sr.err == nil; i++ {
+		alts.add(sr.readInt())
+	}
+
+	return alts
+}
+
+func (sr *dfaSnapshotReader) readConfig() ATNConfig {
+	flags := sr.readUvarint()
+	stateNumber := sr.readUvarint()
+	if sr.err == nil && (stateNumber >= uint64(len(sr.atn.states)) || sr.atn.states[stateNumber] == nil) {
+		sr.fail("invalid ATN state %d", stateNumber)
+	}
+	if sr.err != nil {
+		return nil
+	}
+
+	b := &BaseATNConfig{
+		state:                      sr.atn.states[stateNumber],
+		alt:                        sr.readInt(),
+		context:                    sr.readContextRef(),
+		semanticContext:            sr.readSemanticContext(),
+		reachesIntoOuterContext:    sr.readInt(),
+		precedenceFilterSuppressed: flags&dfaSnapshotPrecedenceFilterSuppressed != 0,
+	}
+	if flags&dfaSnapshotLexerConfig == 0 {
+		return b
+	}
+
+	return &LexerATNConfig{
+		BaseATNConfig:                  b,
+		lexerActionExecutor:            sr.readLexerActionExecutor(),
+		passedThroughNonGreedyDecision: flags&dfaSnapshotPassedThroughNonGreedyDecision != 0,
+	}
+}
+
+// readDFA reads the states of dfa into a new DFA.
+func (sr *dfaSnapshotReader) readDFA(dfa *DFA) *DFA {
+	result := NewDFA(dfa.atnStartState, dfa.decision)
+	if precedenceDfa := sr.readBool(); sr.err == nil && precedenceDfa != result.precedenceDfa {
+		sr.fail("DFA %d is a precedence DFA in only one of the snapshot and the ATN", dfa.decision)
+	}
+
+	n := sr.readCount()
+	var states []*DFAState
+	for i := 0; i < n && sr.err == nil; i++ {
+		inMap := sr.readBool()
+		key := 0
+		if inMap {
+			key = sr.readInt()
+		}
+
+		s := &DFAState{
+			stateNumber:         sr.readInt(),
+			isAcceptState:       sr.readBool(),
+			prediction:          sr.readInt(),
+			requiresFullContext: sr.readBool(),
+			lexerActionExecutor: sr.readLexerActionExecutor(),
+		}
+		if m := sr.readCount(); m > 0 {
+			s.predicates = make([]*PredPrediction, 0)
+			for j := 1; j < m && sr.err == nil; j++ {
+				alt := sr.readInt()
+				s.predicates = append(s.predicates, NewPredPrediction(sr.readSemanticContext(), alt))
+			}
+		}
+		s.configs = sr.readConfigSet()
+
+		states = append(states, s)
+		if inMap {
+			result.states[key] = s
+		}
+	}
+
+	ref := func() *DFAState {
+		r := sr.readUvarint()
+		switch {
+		case r == dfaSnapshotNilState:
+			return nil
+		case r == dfaSnapshotErrorState:
+			return ATNSimulatorError
+		case r-dfaSnapshotFirstState < uint64(len(states)):
+			return states[r-dfaSnapshotFirstState]
+		default:
+			sr.fail("invalid DFA state reference %d", r)
+			return nil
+		}
+	}
+	maxEdges := sr.maxEdges(result)
+	for _, s := range states {
+		if sr.err != nil {
+			break
+		}
+		m := sr.readCount()
+		if m > maxEdges+1 {
+			sr.fail("DFA state %d has %d edges, expected at most %d", s.stateNumber, m-1, maxEdges)
+		}
+		if m > 0 && sr.err == nil {
+			edges := make([]*DFAState, 0)
+			for j := 1; j < m && sr.err == nil; j++ {
+				edges = append(edges, ref())
+			}
+			s.setEdges(edges)
+		}
+	}
+	result.setS0(ref())
+
+	if s0 := result.getS0(); result.precedenceDfa && sr.err == nil && (s0 == nil || s0.getEdges() == nil) {
+		sr.fail("precedence DFA %d has no precedence start states", dfa.decision)
+	}
+
+	return result
+}
+
+// maxEdges returns the most edges a state of dfa can have: one for each
+// token type and EOF in a parser DFA, and one for each char a lexer DFA
+// caches edges for. The start state of a precedence DFA has an edge for
+// each precedence instead, up to the highest precedence a rule is invoked
+// with.
+func (sr *dfaSnapshotReader) maxEdges(dfa *DFA) int {
+	n := sr.atn.maxTokenType + 2
+	if sr.atn.grammarType == ATNTypeLexer {
+		n = LexerATNSimulatorMaxDFAEdge - LexerATNSimulatorMinDFAEdge + 1
+	}
+
+	if dfa.precedenceDfa {
+		for _, s := range sr.atn.states {
+			if s == nil {
+				continue
+			}
+			for _, t := range s.GetTransitions() {
+				if rt, ok := t.(*RuleTransition); ok && rt.precedence+1 > n {
+					n = rt.precedence + 1
+				}
+			}
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const dfaSnapshotInput = "x = 1 + 2 * (y + 3);\nz = x * x + 1;\n"
+
+// parseExprWithLexer parses input at rule prog, returning the lexer and
+// parser so that their DFAs can be inspected.
+func parseExprWithLexer(t *testing.T, input string, profile bool) (*LexerInterpreter, *ParserInterpreter, ParserRuleContext) {
+	t.Helper()
+
+	lexer := newExprLexer(NewInputStream(input))
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(lexer, TokenDefaultChannel))
+	parser.SetProfile(profile)
+
+	tree, syntaxErrors, err := parseExprProg(parser)
+	if err != nil || len(syntaxErrors) != 0 {
+		t.Fatalf("expected no errors, got %v, %v", err, syntaxErrors)
+	}
+
+	return lexer, parser, tree
+}
+
+func TestDFASnapshot(t *testing.T) {
+	warmLexer, warmParser, warmTree := parseExprWithLexer(t, dfaSnapshotInput, false)
+
+	var parserSnapshot, lexerSnapshot bytes.Buffer
+	sim := warmParser.GetInterpreter()
+	if err := WriteDFASnapshot(&parserSnapshot, sim.ATN(), sim.DecisionToDFA()); err != nil {
+		t.Fatalf("writing parser snapshot: %v", err)
+	}
+	lexSim := warmLexer.Interpreter
+	if err := WriteDFASnapshot(&lexerSnapshot, lexSim.ATN(), lexSim.DecisionToDFA()); err != nil {
+		t.Fatalf("writing lexer snapshot: %v", err)
+	}
+
+	lexer := newExprLexer(NewInputStream(dfaSnapshotInput))
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(lexer, TokenDefaultChannel))
+	sim = parser.GetInterpreter()
+	if err := ReadDFASnapshot(&parserSnapshot, sim.ATN(), sim.DecisionToDFA()); err != nil {
+		t.Fatalf("reading parser snapshot: %v", err)
+	}
+	lexSim = lexer.Interpreter
+	if err := ReadDFASnapshot(&lexerSnapshot, lexSim.ATN(), lexSim.DecisionToDFA()); err != nil {
+		t.Fatalf("reading lexer snapshot: %v", err)
+	}
+
+	for i, dfa := range parser.GetInterpreter().DecisionToDFA() {
+		want := warmParser.GetInterpreter().DecisionToDFA()[i].String(exprLiteralNames, exprSymbolicNames)
+		if got := dfa.String(exprLiteralNames, exprSymbolicNames); got != want {
+			t.Errorf("parser DFA %d: expected\n%s\ngot\n%s", i, want, got)
+		}
+	}
+	for i, dfa := range lexer.Interpreter.DecisionToDFA() {
+		want := warmLexer.Interpreter.DecisionToDFA()[i].ToLexerString()
+		if got := dfa.ToLexerString(); got != want {
+			t.Errorf("lexer DFA %d: expected\n%s\ngot\n%s", i, want, got)
+		}
+	}
+
+	// The loaded DFAs predict the same input without going to the ATN.
+	parser.SetProfile(true)
+	tree, syntaxErrors, err := parseExprProg(parser)
+	if err != nil || len(syntaxErrors) != 0 {
+		t.Fatalf("expected no errors, got %v, %v", err, syntaxErrors)
+	}
+	if got, want := tree.ToStringTree(nil, parser), warmTree.ToStringTree(nil, warmParser); got != want {
+		t.Errorf("expected tree %s, got %s", want, got)
+	}
+	if n := parser.GetParseInfo().GetTotalATNLookaheadOps(); n != 0 {
+		t.Errorf("expected no ATN lookahead with a loaded snapshot, got %d", n)
+	}
+}
+
+func TestDFASnapshotErrors(t *testing.T) {
+	_, warmParser, _ := parseExprWithLexer(t, dfaSnapshotInput, false)
+
+	var snapshot bytes.Buffer
+	sim := warmParser.GetInterpreter()
+	if err := WriteDFASnapshot(&snapshot, sim.ATN(), sim.DecisionToDFA()); err != nil {
+		t.Fatalf("writing snapshot: %v", err)
+	}
+	data := snapshot.Bytes()
+
+	lexer := newExprLexer(NewInputStream(""))
+	lexSim := lexer.Interpreter
+	if err := ReadDFASnapshot(bytes.NewReader(data), lexSim.ATN(), lexSim.DecisionToDFA()); err != ErrDFASnapshotATNMismatch {
+		t.Errorf("expected ErrDFASnapshotATNMismatch, got %v", err)
+	}
+
+	parser, _ := parseExpr("")
+	sim = parser.GetInterpreter()
+	var s0s []*DFAState
+	for _, dfa := range sim.DecisionToDFA() {
+		s0s = append(s0s, dfa.getS0())
+	}
+	for _, n := range []int{0, 4, len(data) / 2, len(data) - 1} {
+		if err := ReadDFASnapshot(bytes.NewReader(data[:n]), sim.ATN(), sim.DecisionToDFA()); err == nil {
+			t.Errorf("expected an error reading %d of %d bytes", n, len(data))
+		}
+	}
+	if err := ReadDFASnapshot(bytes.NewReader([]byte("not a snapshot")), sim.ATN(), sim.DecisionToDFA()); err == nil {
+		t.Error("expected an error reading garbage")
+	}
+	for i, dfa := range sim.DecisionToDFA() {
+		if dfa.numStates() != 0 || dfa.getS0() != s0s[i] {
+			t.Errorf("expected DFA %d to be unchanged by failed reads", i)
+		}
+	}
+}
+
+func TestDFASnapshotCorrupt(t *testing.T) {
+	lexer := newExprLexer(NewInputStream(""))
+	sim := lexer.Interpreter
+	atn := sim.ATN()
+
+	// snapshotWithEdges returns a snapshot of a lexer DFA holding a single
+	// state with the given count of edges, none of which follow.
+	snapshotWithEdges := func(edges uint64) []byte {
+		var b bytes.Buffer
+		sw := &dfaSnapshotWriter{w: bufio.NewWriter(&b), atn: atn}
+		sw.writeString(dfaSnapshotMagic)
+		sw.writeUvarint(dfaSnapshotVersion)
+		sw.writeUint64(atnFingerprint(atn))
+		sw.writeUvarint(0) // prediction contexts
+		sw.writeUvarint(uint64(len(sim.DecisionToDFA())))
+
+		sw.writeBool(false) // precedence DFA
+		sw.writeUvarint(1)  // states
+		sw.writeBool(false) // in the states map
+		sw.writeInt(0)      // state number
+		sw.writeBool(false) // accept state
+		sw.writeInt(0)      // prediction
+		sw.writeBool(false) // requires full context
+		sw.writeUvarint(0)  // lexer action executor
+		sw.writeUvarint(0)  // predicates
+		sw.writeUvarint(0)  // config set flags
+		sw.writeInt(0)      // unique alt
+		sw.writeUvarint(0)  // conflicting alts
+		sw.writeUvarint(0)  // configs
+		sw.writeUvarint(edges + 1)
+		sw.w.Flush()
+
+		return b.Bytes()
+	}
+
+	for _, edges := range []uint64{1<<31 - 1, uint64(LexerATNSimulatorMaxDFAEdge - LexerATNSimulatorMinDFAEdge + 2), 3} {
+		err := ReadDFASnapshot(bytes.NewReader(snapshotWithEdges(edges)), atn, sim.DecisionToDFA())
+		if err == nil || !strings.HasPrefix(err.Error(), "malformed DFA snapshot") {
+			t.Errorf("%d edges: expected a malformed DFA snapshot, got %v", edges, err)
+		}
+	}
+	for i, dfa := range sim.DecisionToDFA() {
+		if dfa.numStates() != 0 || dfa.getS0() != nil {
+			t.Errorf("expected DFA %d to be unchanged by failed reads", i)
+		}
+	}
+}
+
+func TestDFASnapshotMaxStates(t *testing.T) {
+	_, warmParser, _ := parseExprWithLexer(t, dfaSnapshotInput, false)
+
+	var snapshot bytes.Buffer
+	sim := warmParser.GetInterpreter()
+	if err := WriteDFASnapshot(&snapshot, sim.ATN(), sim.DecisionToDFA()); err != nil {
+		t.Fatalf("writing snapshot: %v", err)
+	}
+	maxStates := 0
+	for _, dfa := range sim.DecisionToDFA() {
+		if n := dfa.NumStates(); n > maxStates {
+			maxStates = n
+		}
+	}
+
+	parser, _ := parseExpr("")
+	sim = parser.GetInterpreter()
+	sim.SetCachePolicy(ATNCachePolicy{MaxDFAStates: maxStates - 1})
+	if err := ReadDFASnapshot(bytes.NewReader(snapshot.Bytes()), sim.ATN(), sim.DecisionToDFA()); err == nil {
+		t.Errorf("expected an error reading a snapshot of more than %d states", maxStates-1)
+	}
+	for i, dfa := range sim.DecisionToDFA() {
+		if dfa.NumStates() != 0 {
+			t.Errorf("expected DFA %d to be unchanged by the failed read", i)
+		}
+	}
+
+	sim.SetCachePolicy(ATNCachePolicy{MaxDFAStates: maxStates})
+	if err := ReadDFASnapshot(bytes.NewReader(snapshot.Bytes()), sim.ATN(), sim.DecisionToDFA()); err != nil {
+		t.Errorf("reading a snapshot of at most %d states: %v", maxStates, err)
+	}
+}
@@ -6,6 +6,8 @@ package antlr
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 )
 
 // PredPrediction maps a predicate to a predicted alternative.
@@ -47,9 +49,12 @@ type DFAState struct {
 	stateNumber int
 	configs     ATNConfigSet
 
-	// edges elements point to the target of the symbol. Shift up by 1 so (-1)
-	// Token.EOF maps to the first element.
-	edges []*DFAState
+	// edges holds a []*DFAState whose elements point to the target of the
+	// symbol. Shift up by 1 so (-1) Token.EOF maps to the first element. The
+	// slice is never modified once stored, so that it can be read without
+	// locking while other goroutines add edges; see setEdge.
+	edges   atomic.Value
+	edgesMu sync.Mutex
 
 	isAcceptState bool
 
@@ -106,6 +111,54 @@ func (d *DFAState) GetAltSet() *Set {
 	return alts
 }
 
+// getEdges returns the edges of d, or nil if it has none. The returned slice
+// must not be modified.
+func (d *DFAState) getEdges() []*DFAState {
+	edges, _ := d.edges.Load().([]*DFAState)
+	return edges
+}
+
+// getEdge returns the target of edge i of d, or nil if it has none.
+func (d *DFAState) getEdge(i int) *DFAState {
+	edges := d.getEdges()
+	if i < 0 || i >= len(edges) {
+		return nil
+	}
+
+	return edges[i]
+}
+
+// setEdges replaces the edges of d with edges, which must not be modified
+// afterwards.
+func (d *DFAState) setEdges(edges []*DFAState) {
+	d.edgesMu.Lock()
+	defer d.edgesMu.Unlock()
+	d.edges.Store(edges)
+}
+
+// setEdge sets the target of edge i of d to target, making room for at least
+// n edges. Rather than modifying the edges in place, it stores a modified
+// copy, so that goroutines concurrently reading the edges of d with getEdge
+// see either the old or the new edges, and the target state fully
+// initialized.
+func (d *DFAState) setEdge(i, n int, target *DFAState) {
+	d.edgesMu.Lock()
+	defer d.edgesMu.Unlock()
+
+	old := d.getEdges()
+	if n < len(old) {
+		n = len(old)
+	}
+	if n <= i {
+		n = i + 1
+	}
+
+	edges := make([]*DFAState, n)
+	copy(edges, old)
+	edges[i] = target
+	d.edges.Store(edges)
+}
+
 func (d *DFAState) setPrediction(v int) {
 	d.prediction = v
 }
@@ -141,7 +194,7 @@ func (d *DFAState) String() string {
 		}
 	}
 
-	return fmt.Sprintf("%d:%s%s", fmt.Sprint(d.configs), s)
+	return fmt.Sprintf("%d:%s%s", d.stateNumber, fmt.Sprint(d.configs), s)
 }
 
 func (d *DFAState) hash() int {
@@ -0,0 +1,207 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SyntaxErrorFacts are the facts about a syntax error that
+// DefaultErrorStrategy formats the message of the error from.
+type SyntaxErrorFacts struct {
+	Kind ErrorKind
+
+	// Recognizer is the parser reporting the error. Its vocabulary and rule
+	// names name the tokens and rules of the grammar.
+	Recognizer Parser
+
+	// OffendingToken is the token the error was found at. For a missing
+	// token it is the token following the missing one.
+	OffendingToken Token
+
+	// Expected is the set of token types the parser expected, for
+	// ErrorKindMismatchedInput, ErrorKindMissingToken and
+	// ErrorKindExtraneousInput errors.
+	Expected *IntervalSet
+
+	// StartToken is the first token of the input the parser could not
+	// choose an alternative for, and Input is the text of that input up to
+	// and including the offending token, for ErrorKindNoViableAlt errors.
+	// Input is "<EOF>" at the end of the input, and "<unknown input>" if
+	// the parser has no token stream.
+	StartToken Token
+	Input      string
+
+	// RuleName is the rule the predicate of an ErrorKindFailedPredicate
+	// error belongs to, and Predicate the text of the predicate.
+	RuleName  string
+	Predicate string
+
+	// Repair is the repair of an ErrorKindRepairedInput error.
+	Repair *Repair
+
+	// Exception is the RecognitionException describing the error, or nil
+	// for ErrorKindMissingToken and ErrorKindExtraneousInput errors.
+	Exception RecognitionException
+}
+
+// ErrorMessageFormatter formats the messages of the syntax errors
+// DefaultErrorStrategy reports. Set one with
+// DefaultErrorStrategy.SetMessageFormatter to word the messages in another
+// language, or in terms the users of a grammar understand.
+type ErrorMessageFormatter interface {
+	FormatErrorMessage(facts *SyntaxErrorFacts) string
+}
+
+// DefaultErrorMessageFormatter is the ErrorMessageFormatter of
+// DefaultErrorStrategy. It formats messages in English, such as
+//
+// <pre>
+// mismatched input '}' expecting {';', '='}
+// </pre>
+//
+// <p>Token types are named by their literal or symbolic names, unless
+// SetTokenDescriptions gives a description for them. With descriptions,
+// sets of token types are written as a list, so the message above could
+// become</p>
+//
+// <pre>
+// mismatched input '}' expecting a semicolon or '='
+// </pre>
+type DefaultErrorMessageFormatter struct {
+	tokenDescriptions map[int]string
+}
+
+var _ ErrorMessageFormatter = &DefaultErrorMessageFormatter{}
+
+func NewDefaultErrorMessageFormatter() *DefaultErrorMessageFormatter {
+	return new(DefaultErrorMessageFormatter)
+}
+
+// SetTokenDescriptions sets the descriptions of token types used in place
+// of their names, such as "a semicolon" for ';'. A description for TokenEOF
+// describes the end of the input.
+func (f *DefaultErrorMessageFormatter) SetTokenDescriptions(descriptions map[int]string) {
+	f.tokenDescriptions = descriptions
+}
+
+func (f *DefaultErrorMessageFormatter) GetTokenDescriptions() map[int]string {
+	return f.tokenDescriptions
+}
+
+func (f *DefaultErrorMessageFormatter) FormatErrorMessage(facts *SyntaxErrorFacts) string {
+	switch facts.Kind {
+	case ErrorKindMismatchedInput:
+		return "mismatched input " + f.FormatToken(facts.OffendingToken) + " expecting " + f.FormatTokenSet(facts.Recognizer, facts.Expected)
+
+	case ErrorKindExtraneousInput:
+		return "extraneous input " + f.FormatToken(facts.OffendingToken) + " expecting " + f.FormatTokenSet(facts.Recognizer, facts.Expected)
+
+	case ErrorKindMissingToken:
+		return "missing " + f.FormatTokenSet(facts.Recognizer, facts.Expected) + " at " + f.FormatToken(facts.OffendingToken)
+
+	case ErrorKindNoViableAlt:
+		return "no viable alternative at input " + escapeWSAndQuote(facts.Input)
+
+	case ErrorKindFailedPredicate:
+		return "rule " + facts.RuleName + " " + facts.Exception.GetMessage()
+
+	case ErrorKindRepairedInput:
+		return "syntax error at " + f.FormatToken(facts.OffendingToken) + ", repaired by " + f.FormatRepair(facts.Recognizer, facts.Repair)
+
+	default:
+		if facts.Exception != nil {
+			return facts.Exception.GetMessage()
+		}
+
+		return "syntax error at " + f.FormatToken(facts.OffendingToken)
+	}
+}
+
+// FormatToken returns the text of t quoted, with whitespace escaped, or the
+// name of its token type in angle brackets if it has no text.
+func (f *DefaultErrorMessageFormatter) FormatToken(t Token) string {
+	return tokenErrorDisplay(t)
+}
+
+// FormatTokenSet returns the token types of set, using the vocabulary of
+// recognizer and the token descriptions.
+func (f *DefaultErrorMessageFormatter) FormatTokenSet(recognizer Parser, set *IntervalSet) string {
+	if set == nil {
+		return "{}"
+	}
+	if len(f.tokenDescriptions) == 0 || len(set.intervals) == 0 {
+		return set.StringVerbose(recognizer.GetLiteralNames(), recognizer.GetSymbolicNames(), false)
+	}
+
+	var names []string
+	for _, v := range set.intervals {
+		for t := v.Start; t < v.Stop; t++ {
+			if d, ok := f.tokenDescriptions[t]; ok {
+				names = append(names, d)
+			} else {
+				names = append(names, set.elementName(recognizer.GetLiteralNames(), recognizer.GetSymbolicNames(), t))
+			}
+		}
+	}
+
+	if len(names) == 1 {
+		return names[0]
+	}
+
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// FormatRepair returns the edits of repair, such as "inserting ')' and
+// deleting '='".
+func (f *DefaultErrorMessageFormatter) FormatRepair(recognizer Parser, repair *Repair) string {
+	edits := make([]string, len(repair.Edits))
+	for i, e := range repair.Edits {
+		switch e.Operation {
+		case RepairInsert:
+			edits[i] = "inserting " + f.formatTokenType(recognizer, e.TokenType)
+		case RepairDelete:
+			edits[i] = "deleting " + f.FormatToken(e.Token)
+		default:
+			edits[i] = "replacing " + f.FormatToken(e.Token) + " with " + f.formatTokenType(recognizer, e.TokenType)
+		}
+	}
+
+	if len(edits) == 1 {
+		return edits[0]
+	}
+
+	return strings.Join(edits[:len(edits)-1], ", ") + " and " + edits[len(edits)-1]
+}
+
+func (f *DefaultErrorMessageFormatter) formatTokenType(recognizer Parser, ttype int) string {
+	set := NewIntervalSet()
+	set.addOne(ttype)
+	return f.FormatTokenSet(recognizer, set)
+}
+
+// tokenErrorDisplay returns the text of t quoted, with whitespace escaped.
+func tokenErrorDisplay(t Token) string {
+	if t == nil {
+		return "<no token>"
+	}
+	s := t.GetText()
+	if s == "" {
+		if t.GetTokenType() == TokenEOF {
+			s = "<EOF>"
+		} else {
+			s = "<" + strconv.Itoa(t.GetTokenType()) + ">"
+		}
+	}
+	return escapeWSAndQuote(s)
+}
+
+func escapeWSAndQuote(s string) string {
+	s = strings.Replace(s, "\t", "\\t", -1)
+	s = strings.Replace(s, "\n", "\\n", -1)
+	s = strings.Replace(s, "\r", "\\r", -1)
+	return "'" + s + "'"
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"testing"
+)
+
+// exprErrorMessages parses input with the Expr grammar, with the messages
+// of the syntax errors formatted by formatter if it is not nil, and returns
+// the messages.
+func exprErrorMessages(input string, formatter ErrorMessageFormatter) []string {
+	diagnostics := NewCollectingErrorListener()
+
+	lexer := newExprLexer(NewInputStream(input))
+	lexer.RemoveErrorListeners()
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(lexer, TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(diagnostics)
+	if formatter != nil {
+		strategy := NewDefaultErrorStrategy()
+		strategy.SetMessageFormatter(formatter)
+		parser.SetErrorHandler(strategy)
+	}
+	parser.Parse(ExprParserRULE_prog)
+
+	var messages []string
+	for _, d := range diagnostics.GetDiagnostics() {
+		messages = append(messages, d.Msg)
+	}
+
+	return messages
+}
+
+func TestDefaultErrorMessageFormatter(t *testing.T) {
+	descriptions := NewDefaultErrorMessageFormatter()
+	descriptions.SetTokenDescriptions(map[int]string{
+		2: "a semicolon",
+		6: "a closing parenthesis",
+		7: "a name",
+		8: "a number",
+	})
+
+	for _, test := range []struct {
+		input, message, described string
+	}{
+		{"x = ;", "mismatched input ';' expecting {'(', ID, INT}", "mismatched input ';' expecting '(', a name or a number"},
+		{"x = = 1;", "extraneous input '=' expecting {'(', ID, INT}", "extraneous input '=' expecting '(', a name or a number"},
+		{"(1;", "missing ')' at ';'", "missing a closing parenthesis at ';'"},
+		{"x = 1", "missing ';' at '<EOF>'", "missing a semicolon at '<EOF>'"},
+	} {
+		if got := exprErrorMessages(test.input, nil); len(got) != 1 || got[0] != test.message {
+			t.Errorf("%q: expected %q, got %q", test.input, test.message, got)
+		}
+		if got := exprErrorMessages(test.input, descriptions); len(got) != 1 || got[0] != test.described {
+			t.Errorf("%q: expected %q, got %q", test.input, test.described, got)
+		}
+	}
+}
+
+// kindErrorMessageFormatter formats messages from the kind and the offending
+// token of the errors.
+type kindErrorMessageFormatter struct{}
+
+func (kindErrorMessageFormatter) FormatErrorMessage(facts *SyntaxErrorFacts) string {
+	return facts.Kind.String() + " at " + facts.OffendingToken.GetText()
+}
+
+func TestErrorMessageFormatter(t *testing.T) {
+	if got := exprErrorMessages("(1;", kindErrorMessageFormatter{}); len(got) != 1 || got[0] != "missing token at ;" {
+		t.Errorf("expected the custom message, got %q", got)
+	}
+
+	// Parse reports the exception the parser bails out with using the
+	// formatter of the error strategy.
+	strategy := NewBailErrorStrategy()
+	strategy.SetMessageFormatter(kindErrorMessageFormatter{})
+	parser := newQuietExprParser("x = ;")
+	parser.SetErrorHandler(strategy)
+	_, syntaxErrors, err := parseExprProg(parser)
+	if err == nil || len(syntaxErrors) != 1 || syntaxErrors[0].Msg != "no viable alternative at ;" {
+		t.Errorf("expected the custom message, got %v", syntaxErrors)
+	}
+}
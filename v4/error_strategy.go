@@ -7,8 +7,6 @@ package antlr
 import (
 	"fmt"
 	"reflect"
-	"strconv"
-	"strings"
 )
 
 type ErrorStrategy interface {
@@ -28,6 +26,12 @@ type DefaultErrorStrategy struct {
 	errorRecoveryMode bool
 	lastErrorIndex    int
 	lastErrorStates   *IntervalSet
+
+	// reportingErrorKind is the kind of the error being reported without a
+	// RecognitionException, for SyntaxErrorKind.
+	reportingErrorKind ErrorKind
+
+	messageFormatter ErrorMessageFormatter
 }
 
 var _ ErrorStrategy = &DefaultErrorStrategy{}
@@ -52,9 +56,24 @@ func NewDefaultErrorStrategy() *DefaultErrorStrategy {
 	//
 	d.lastErrorIndex = -1
 	d.lastErrorStates = nil
+	d.messageFormatter = NewDefaultErrorMessageFormatter()
 	return d
 }
 
+// SetMessageFormatter sets the ErrorMessageFormatter formatting the messages
+// of the errors d reports.
+func (d *DefaultErrorStrategy) SetMessageFormatter(formatter ErrorMessageFormatter) {
+	d.messageFormatter = formatter
+}
+
+func (d *DefaultErrorStrategy) GetMessageFormatter() ErrorMessageFormatter {
+	if d.messageFormatter == nil {
+		return NewDefaultErrorMessageFormatter()
+	}
+
+	return d.messageFormatter
+}
+
 // <p>The default implementation simply calls {@link //endErrorCondition} to
 // ensure that the handler is not in error recovery mode.</p>
 func (d *DefaultErrorStrategy) reset(recognizer Parser) {
@@ -260,8 +279,14 @@ func (d *DefaultErrorStrategy) ReportNoViableAlternative(recognizer Parser, e *N
 	} else {
 		input = "<unknown input>"
 	}
-	msg := "no viable alternative at input " + d.escapeWSAndQuote(input)
-	recognizer.NotifyErrorListeners(msg, e.offendingToken, e)
+	d.reportSyntaxError(&SyntaxErrorFacts{
+		Kind:           ErrorKindNoViableAlt,
+		Recognizer:     recognizer,
+		OffendingToken: e.offendingToken,
+		StartToken:     e.startToken,
+		Input:          input,
+		Exception:      e,
+	})
 }
 
 //
@@ -274,9 +299,13 @@ func (d *DefaultErrorStrategy) ReportNoViableAlternative(recognizer Parser, e *N
 // @param e the recognition exception
 //
 func (this *DefaultErrorStrategy) ReportInputMisMatch(recognizer Parser, e *InputMisMatchException) {
-	msg := "mismatched input " + this.GetTokenErrorDisplay(e.offendingToken) +
-		" expecting " + e.getExpectedTokens().StringVerbose(recognizer.GetLiteralNames(), recognizer.GetSymbolicNames(), false)
-	recognizer.NotifyErrorListeners(msg, e.offendingToken, e)
+	this.reportSyntaxError(&SyntaxErrorFacts{
+		Kind:           ErrorKindMismatchedInput,
+		Recognizer:     recognizer,
+		OffendingToken: e.offendingToken,
+		Expected:       e.getExpectedTokens(),
+		Exception:      e,
+	})
 }
 
 //
@@ -289,9 +318,14 @@ func (this *DefaultErrorStrategy) ReportInputMisMatch(recognizer Parser, e *Inpu
 // @param e the recognition exception
 //
 func (d *DefaultErrorStrategy) ReportFailedPredicate(recognizer Parser, e *FailedPredicateException) {
-	ruleName := recognizer.GetRuleNames()[recognizer.GetParserRuleContext().GetRuleIndex()]
-	msg := "rule " + ruleName + " " + e.message
-	recognizer.NotifyErrorListeners(msg, e.offendingToken, e)
+	d.reportSyntaxError(&SyntaxErrorFacts{
+		Kind:           ErrorKindFailedPredicate,
+		Recognizer:     recognizer,
+		OffendingToken: e.offendingToken,
+		RuleName:       recognizer.GetRuleNames()[recognizer.GetParserRuleContext().GetRuleIndex()],
+		Predicate:      e.predicate,
+		Exception:      e,
+	})
 }
 
 // This method is called to Report a syntax error which requires the removal
@@ -316,12 +350,12 @@ func (d *DefaultErrorStrategy) ReportUnwantedToken(recognizer Parser) {
 		return
 	}
 	d.beginErrorCondition(recognizer)
-	t := recognizer.GetCurrentToken()
-	tokenName := d.GetTokenErrorDisplay(t)
-	expecting := d.GetExpectedTokens(recognizer)
-	msg := "extraneous input " + tokenName + " expecting " +
-		expecting.StringVerbose(recognizer.GetLiteralNames(), recognizer.GetSymbolicNames(), false)
-	recognizer.NotifyErrorListeners(msg, t, nil)
+	d.reportSyntaxError(&SyntaxErrorFacts{
+		Kind:           ErrorKindExtraneousInput,
+		Recognizer:     recognizer,
+		OffendingToken: recognizer.GetCurrentToken(),
+		Expected:       d.GetExpectedTokens(recognizer),
+	})
 }
 
 // This method is called to Report a syntax error which requires the
@@ -345,11 +379,33 @@ func (d *DefaultErrorStrategy) ReportMissingToken(recognizer Parser) {
 		return
 	}
 	d.beginErrorCondition(recognizer)
-	t := recognizer.GetCurrentToken()
-	expecting := d.GetExpectedTokens(recognizer)
-	msg := "missing " + expecting.StringVerbose(recognizer.GetLiteralNames(), recognizer.GetSymbolicNames(), false) +
-		" at " + d.GetTokenErrorDisplay(t)
-	recognizer.NotifyErrorListeners(msg, t, nil)
+	d.reportSyntaxError(&SyntaxErrorFacts{
+		Kind:           ErrorKindMissingToken,
+		Recognizer:     recognizer,
+		OffendingToken: recognizer.GetCurrentToken(),
+		Expected:       d.GetExpectedTokens(recognizer),
+	})
+}
+
+// reportSyntaxError reports the error described by facts, with the message
+// formatted by the message formatter, to the error listeners of the
+// recognizer.
+func (d *DefaultErrorStrategy) reportSyntaxError(facts *SyntaxErrorFacts) {
+	msg := d.GetMessageFormatter().FormatErrorMessage(facts)
+
+	if facts.Exception == nil {
+		// Tell SyntaxErrorKind the kind of the error
+		d.reportingErrorKind = facts.Kind
+		defer func() {
+			d.reportingErrorKind = ErrorKindUnknown
+		}()
+	}
+
+	facts.Recognizer.NotifyErrorListeners(msg, facts.OffendingToken, facts.Exception)
+}
+
+func (d *DefaultErrorStrategy) getReportingErrorKind() ErrorKind {
+	return d.reportingErrorKind
 }
 
 // <p>The default implementation attempts to recover from the mismatched input
@@ -508,6 +564,9 @@ func (d *DefaultErrorStrategy) SingleTokenDeletion(recognizer Parser) Token {
 // If you change what tokens must be created by the lexer,
 // override d method to create the appropriate tokens.
 //
+// <p>The token is marked as missing (see {@link IsMissingToken}), and the
+// parser adds it to the parse tree as a {@link MissingNode}.</p>
+//
 func (d *DefaultErrorStrategy) GetMissingSymbol(recognizer Parser) Token {
 	currentSymbol := recognizer.GetCurrentToken()
 	expecting := d.GetExpectedTokens(recognizer)
@@ -531,8 +590,10 @@ func (d *DefaultErrorStrategy) GetMissingSymbol(recognizer Parser) Token {
 	}
 
 	tf := recognizer.GetTokenFactory()
+	t := tf.Create(current.GetSource(), expectedTokenType, tokenText, TokenDefaultChannel, -1, -1, current.GetLine(), current.GetColumn())
+	markMissingToken(t)
 
-	return tf.Create(current.GetSource(), expectedTokenType, tokenText, TokenDefaultChannel, -1, -1, current.GetLine(), current.GetColumn())
+	return t
 }
 
 func (d *DefaultErrorStrategy) GetExpectedTokens(recognizer Parser) *IntervalSet {
@@ -548,25 +609,7 @@ func (d *DefaultErrorStrategy) GetExpectedTokens(recognizer Parser) *IntervalSet
 // so that it creates a NewJava type.
 //
 func (d *DefaultErrorStrategy) GetTokenErrorDisplay(t Token) string {
-	if t == nil {
-		return "<no token>"
-	}
-	s := t.GetText()
-	if s == "" {
-		if t.GetTokenType() == TokenEOF {
-			s = "<EOF>"
-		} else {
-			s = "<" + strconv.Itoa(t.GetTokenType()) + ">"
-		}
-	}
-	return d.escapeWSAndQuote(s)
-}
-
-func (d *DefaultErrorStrategy) escapeWSAndQuote(s string) string {
-	s = strings.Replace(s, "\t", "\\t", -1)
-	s = strings.Replace(s, "\n", "\\n", -1)
-	s = strings.Replace(s, "\r", "\\r", -1)
-	return "'" + s + "'"
+	return tokenErrorDisplay(t)
 }
 
 // Compute the error recovery set for the current rule. During
@@ -731,16 +774,18 @@ func NewBailErrorStrategy() *BailErrorStrategy {
 
 // Instead of recovering from exception {@code e}, re-panic it wrapped
 // in a {@link ParseCancellationException} so it is not caught by the
-// rule func catches. Use {@link Exception//getCause()} to get the
-// original {@link RecognitionException}.
+// rule func catches. Use {@link ParseCancellationException//GetCause()} to
+// get the original {@link RecognitionException}.
 //
 func (b *BailErrorStrategy) Recover(recognizer Parser, e RecognitionException) {
 	context := recognizer.GetParserRuleContext()
 	for context != nil {
 		context.SetException(e)
-		context = context.GetParent().(ParserRuleContext)
+		context, _ = context.GetParent().(ParserRuleContext)
 	}
-	panic(NewParseCancellationException()) // TODO we don't emit e properly
+	pce := NewParseCancellationException()
+	pce.cause = e
+	panic(pce)
 }
 
 // Make sure we don't attempt to recover inline if the parser
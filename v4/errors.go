@@ -4,6 +4,11 @@
 
 package antlr
 
+import (
+	"context"
+	"strconv"
+)
+
 // The root of the ANTLR exception hierarchy. In general, ANTLR tracks just
 //  3 kinds of errors: prediction errors, failed predicate errors, and
 //  mismatched input errors. In each case, the parser knows where it is
@@ -231,7 +236,106 @@ func (f *FailedPredicateException) formatMessage(predicate, message string) stri
 	return "failed predicate: {" + predicate + "}?"
 }
 
+// ErrorKind identifies the kind of a syntax error reported to the error
+// listeners.
+type ErrorKind int
+
+const (
+	// ErrorKindUnknown is the kind of an error reported by an error
+	// strategy or recognizer that does not tell its kind.
+	ErrorKindUnknown ErrorKind = iota
+
+	// ErrorKindMissingToken is a token missing from the input, reported by
+	// DefaultErrorStrategy.ReportMissingToken.
+	ErrorKindMissingToken
+
+	// ErrorKindExtraneousInput is an extra token in the input, reported by
+	// DefaultErrorStrategy.ReportUnwantedToken.
+	ErrorKindExtraneousInput
+
+	// ErrorKindMismatchedInput is a token other than the expected ones,
+	// reported with an InputMisMatchException.
+	ErrorKindMismatchedInput
+
+	// ErrorKindNoViableAlt is input matching none of the alternatives of a
+	// decision, reported with a NoViableAltException.
+	ErrorKindNoViableAlt
+
+	// ErrorKindFailedPredicate is a semantic predicate failing while
+	// parsing, reported with a FailedPredicateException.
+	ErrorKindFailedPredicate
+
+	// ErrorKindLexerNoViableAlt is input matching no lexer rule, reported
+	// with a LexerNoViableAltException.
+	ErrorKindLexerNoViableAlt
+
+	// ErrorKindRepairedInput is input repaired by inserting, deleting or
+	// replacing tokens, reported by RepairErrorStrategy.ReportRepair.
+	ErrorKindRepairedInput
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindUnknown:
+		return "unknown"
+	case ErrorKindMissingToken:
+		return "missing token"
+	case ErrorKindExtraneousInput:
+		return "extraneous input"
+	case ErrorKindMismatchedInput:
+		return "mismatched input"
+	case ErrorKindNoViableAlt:
+		return "no viable alternative"
+	case ErrorKindFailedPredicate:
+		return "failed predicate"
+	case ErrorKindLexerNoViableAlt:
+		return "lexer no viable alternative"
+	case ErrorKindRepairedInput:
+		return "repaired input"
+	default:
+		return "ErrorKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// errorKindReporter is implemented by error strategies that report errors
+// without a RecognitionException, to tell the kind of the error being
+// reported.
+type errorKindReporter interface {
+	getReportingErrorKind() ErrorKind
+}
+
+// SyntaxErrorKind returns the kind of the syntax error recognizer reports
+// with e. It is meant to be called from ErrorListener.SyntaxError, as the
+// kind of an error reported without a RecognitionException is only known
+// while it is being reported.
+func SyntaxErrorKind(recognizer Recognizer, e RecognitionException) ErrorKind {
+	switch e.(type) {
+	case *InputMisMatchException:
+		return ErrorKindMismatchedInput
+	case *NoViableAltException:
+		return ErrorKindNoViableAlt
+	case *FailedPredicateException:
+		return ErrorKindFailedPredicate
+	case *LexerNoViableAltException:
+		return ErrorKindLexerNoViableAlt
+	case nil:
+		if parser, ok := recognizer.(Parser); ok {
+			if r, ok := parser.GetErrorHandler().(errorKindReporter); ok {
+				return r.getReportingErrorKind()
+			}
+		}
+	}
+
+	return ErrorKindUnknown
+}
+
+// ParseCancellationException is panicked to abort lexing or parsing, for
+// example by BailErrorStrategy on the first syntax error, or when the
+// context.Context set with SetContext on the lexer or parser is done. It
+// implements error.
 type ParseCancellationException struct {
+	cause RecognitionException
+	err   error
 }
 
 func NewParseCancellationException() *ParseCancellationException {
@@ -239,3 +343,42 @@ func NewParseCancellationException() *ParseCancellationException {
 	//	Error.captureStackTrace(this, ParseCancellationException)
 	return new(ParseCancellationException)
 }
+
+// GetCause returns the RecognitionException that caused the parse to be
+// cancelled, or nil if the parse was cancelled for another reason.
+func (p *ParseCancellationException) GetCause() RecognitionException {
+	return p.cause
+}
+
+// Unwrap returns the error of the context.Context that cancelled the parse,
+// context.Canceled or context.DeadlineExceeded, or nil if the parse was
+// cancelled for another reason.
+func (p *ParseCancellationException) Unwrap() error {
+	return p.err
+}
+
+func (p *ParseCancellationException) Error() string {
+	switch {
+	case p.err != nil:
+		return "parse cancelled: " + p.err.Error()
+	case p.cause != nil:
+		return "parse cancelled: " + p.cause.GetMessage()
+	default:
+		return "parse cancelled"
+	}
+}
+
+// checkContext panics with a ParseCancellationException if ctx is done.
+func checkContext(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		pce := NewParseCancellationException()
+		pce.err = ctx.Err()
+		panic(pce)
+	default:
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InterpreterData holds the grammar description the ANTLR tool writes to
+// an {@code .interp} file: the serialized ATN together with the vocabulary
+// and the rule, channel and mode names. It has everything needed to create
+// a LexerInterpreter or ParserInterpreter for the grammar.
+type InterpreterData struct {
+	ATN           *ATN
+	SerializedATN []uint16
+	LiteralNames  []string
+	SymbolicNames []string
+	RuleNames     []string
+
+	// ChannelNames and ModeNames are only present for lexer grammars.
+	ChannelNames []string
+	ModeNames    []string
+}
+
+// ReadInterpreterDataFile reads the {@code .interp} file fileName.
+func ReadInterpreterDataFile(fileName string) (*InterpreterData, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadInterpreterData(f)
+}
+
+// ReadInterpreterData reads interpreter data in the format of an
+// {@code .interp} file from r. The format is:
+//
+// <pre>
+// token literal names:
+// null
+// '='
+//
+// token symbolic names:
+// null
+// ID
+//
+// rule names:
+// prog
+//
+// channel names:
+// DEFAULT_TOKEN_CHANNEL
+// HIDDEN
+//
+// mode names:
+// DEFAULT_MODE
+//
+// atn:
+// [3, 24715, 42794, ...]
+// </pre>
+//
+// <p>Each section ends with an empty line. The channel and mode name
+// sections are only present for lexer grammars.</p>
+func ReadInterpreterData(r io.Reader) (*InterpreterData, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<26) // the ATN is on a single, possibly long, line
+	line := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		return strings.TrimRight(scanner.Text(), "\r"), true
+	}
+
+	section := func(header string) ([]string, error) {
+		if l, ok := line(); !ok || l != header {
+			return nil, errors.New("interpreter data: expected \"" + header + "\"")
+		}
+		return readInterpreterDataNames(line), nil
+	}
+
+	var err error
+	data := new(InterpreterData)
+	if data.LiteralNames, err = section("token literal names:"); err != nil {
+		return nil, err
+	}
+	if data.SymbolicNames, err = section("token symbolic names:"); err != nil {
+		return nil, err
+	}
+	if data.RuleNames, err = section("rule names:"); err != nil {
+		return nil, err
+	}
+
+	l, _ := line()
+	if l == "channel names:" {
+		// additional lexer data
+		data.ChannelNames = readInterpreterDataNames(line)
+		if data.ModeNames, err = section("mode names:"); err != nil {
+			return nil, err
+		}
+		l, _ = line()
+	}
+
+	if l != "atn:" {
+		return nil, errors.New("interpreter data: expected \"atn:\"")
+	}
+	l, _ = line()
+	if !strings.HasPrefix(l, "[") || !strings.HasSuffix(l, "]") {
+		return nil, errors.New("interpreter data: malformed serialized ATN")
+	}
+
+	elements := strings.Split(l[1:len(l)-1], ",")
+	data.SerializedATN = make([]uint16, len(elements))
+	for i, e := range elements {
+		v, err := strconv.ParseUint(strings.TrimSpace(e), 10, 16)
+		if err != nil {
+			return nil, errors.New("interpreter data: malformed serialized ATN: " + err.Error())
+		}
+		data.SerializedATN[i] = uint16(v)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := deserializeInterpreterDataATN(data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// readInterpreterDataNames reads names up to the next empty line. The tool
+// writes "null" for missing names.
+func readInterpreterDataNames(line func() (string, bool)) []string {
+	names := make([]string, 0)
+	for {
+		l, ok := line()
+		if !ok || l == "" {
+			return names
+		}
+		if l == "null" {
+			l = ""
+		}
+		names = append(names, l)
+	}
+}
+
+// deserializeInterpreterDataATN deserializes data.SerializedATN, reporting
+// an invalid ATN as an error rather than a panic.
+func deserializeInterpreterDataATN(data *InterpreterData) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("interpreter data: invalid serialized ATN: " + fmt.Sprint(r))
+		}
+	}()
+
+	data.ATN = NewATNDeserializer(nil).DeserializeFromUInt16(data.SerializedATN)
+	return nil
+}
+
+// NewLexerInterpreter creates a LexerInterpreter for the lexer grammar
+// described by d.
+func (d *InterpreterData) NewLexerInterpreter(grammarFileName string, input CharStream) *LexerInterpreter {
+	return NewLexerInterpreter(grammarFileName, d.LiteralNames, d.SymbolicNames, d.RuleNames, d.ChannelNames, d.ModeNames, d.ATN, input)
+}
+
+// NewParserInterpreter creates a ParserInterpreter for the parser grammar
+// described by d. The interpreter is given the serialized ATN, so that
+// tree patterns need not serialize the ATN again.
+func (d *InterpreterData) NewParserInterpreter(grammarFileName string, input TokenStream) *ParserInterpreter {
+	p := NewParserInterpreter(grammarFileName, d.LiteralNames, d.SymbolicNames, d.RuleNames, d.ATN, input)
+	p.SerializedATN = d.SerializedATN
+	return p
+}
@@ -224,7 +224,7 @@ func (i *IntervalSet) StringVerbose(literalNames []string, symbolicNames []strin
 }
 
 func (i *IntervalSet) toCharString() string {
-	names := make([]string, len(i.intervals))
+	names := make([]string, 0, len(i.intervals))
 
 	for j := 0; j < len(i.intervals); j++ {
 		v := i.intervals[j]
@@ -232,10 +232,10 @@ func (i *IntervalSet) toCharString() string {
 			if v.Start == TokenEOF {
 				names = append(names, "<EOF>")
 			} else {
-				names = append(names, ("'" + string(v.Start) + "'"))
+				names = append(names, ("'" + string(rune(v.Start)) + "'"))
 			}
 		} else {
-			names = append(names, "'"+string(v.Start)+"'..'"+string(v.Stop-1)+"'")
+			names = append(names, "'"+string(rune(v.Start))+"'..'"+string(rune(v.Stop-1))+"'")
 		}
 	}
 	if len(names) > 1 {
@@ -290,7 +290,10 @@ func (i *IntervalSet) elementName(literalNames []string, symbolicNames []string,
 		if a < len(literalNames) && literalNames[a] != "" {
 			return literalNames[a]
 		}
+		if a < len(symbolicNames) && symbolicNames[a] != "" {
+			return symbolicNames[a]
+		}
 
-		return symbolicNames[a]
+		return strconv.Itoa(a)
 	}
 }
@@ -5,6 +5,7 @@
 package antlr
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 )
@@ -20,6 +21,7 @@ type Lexer interface {
 	Recognizer
 
 	Emit() Token
+	SetInputStream(CharStream)
 
 	setChannel(int)
 	pushMode(int)
@@ -48,6 +50,9 @@ type BaseLexer struct {
 	modeStack              IntStack
 	mode                   int
 	text                   string
+
+	// cancelCtx is the context.Context that cancels lexing when done.
+	cancelCtx context.Context
 }
 
 func NewBaseLexer(input CharStream) *BaseLexer {
@@ -192,6 +197,8 @@ func (b *BaseLexer) NextToken() Token {
 	}()
 
 	for {
+		checkContext(b.cancelCtx)
+
 		if b.hitEOF {
 			b.EmitEOF()
 			return b.token
@@ -232,8 +239,18 @@ func (b *BaseLexer) NextToken() Token {
 		}
 		return b.token
 	}
+}
+
+// SetContext sets the context.Context that cancels lexing. Once ctx is
+// done, NextToken panics with a *ParseCancellationException wrapping
+// ctx.Err(). A nil ctx, the default, never cancels lexing.
+func (b *BaseLexer) SetContext(ctx context.Context) {
+	b.cancelCtx = ctx
+}
 
-	return nil
+// GetContext returns the context.Context set with SetContext, or nil.
+func (b *BaseLexer) GetContext() context.Context {
+	return b.cancelCtx
 }
 
 // Instruct the lexer to Skip creating a token for current lexer rule
@@ -278,7 +295,8 @@ func (b *BaseLexer) inputStream() CharStream {
 	return b.input
 }
 
-func (b *BaseLexer) setInputStream(input CharStream) {
+// SetInputStream resets the lexer and sets it to tokenize input.
+func (b *BaseLexer) SetInputStream(input CharStream) {
 	b.input = nil
 	b.tokenFactorySourcePair = &TokenSourceCharStreamPair{b, b.input}
 	b.reset()
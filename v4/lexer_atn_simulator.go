@@ -90,11 +90,12 @@ func (l *LexerATNSimulator) Match(input CharStream, mode int) int {
 
 	dfa := l.decisionToDFA[mode]
 
-	if dfa.s0 == nil {
+	s0 := dfa.getS0()
+	if s0 == nil {
 		return l.MatchATN(input)
 	}
 
-	return l.execATN(input, dfa.s0)
+	return l.execATN(input, s0)
 }
 
 func (l *LexerATNSimulator) reset() {
@@ -202,11 +203,11 @@ func (l *LexerATNSimulator) execATN(input CharStream, ds0 *DFAState) int {
 // {@code t}, or {@code nil} if the target state for l edge is not
 // already cached
 func (l *LexerATNSimulator) getExistingTargetState(s *DFAState, t int) *DFAState {
-	if s.edges == nil || t < LexerATNSimulatorMinDFAEdge || t > LexerATNSimulatorMaxDFAEdge {
+	if t < LexerATNSimulatorMinDFAEdge || t > LexerATNSimulatorMaxDFAEdge {
 		return nil
 	}
 
-	target := s.edges[t-LexerATNSimulatorMinDFAEdge]
+	target := s.getEdge(t - LexerATNSimulatorMinDFAEdge)
 	if LexerATNSimulatorDebug && target != nil {
 		fmt.Println("reuse state " + strconv.Itoa(s.stateNumber) + " edge to " + strconv.Itoa(target.stateNumber))
 	}
@@ -299,7 +300,7 @@ func (l *LexerATNSimulator) getReachableConfigSet(input CharStream, closure ATNC
 
 func (l *LexerATNSimulator) accept(input CharStream, lexerActionExecutor *LexerActionExecutor, startIndex, index, line, charPos int) {
 	if LexerATNSimulatorDebug {
-		fmt.Printf("ACTION %s\n", lexerActionExecutor)
+		fmt.Printf("ACTION %v\n", lexerActionExecutor)
 	}
 	// seek to after last char in token
 	input.Seek(index)
@@ -550,11 +551,8 @@ func (l *LexerATNSimulator) addDFAEdge(from *DFAState, tk int, to *DFAState, cfg
 	if LexerATNSimulatorDebug {
 		fmt.Println("EDGE " + from.String() + " -> " + to.String() + " upon " + strconv.Itoa(tk))
 	}
-	if from.edges == nil {
-		// make room for tokens 1..n and -1 masquerading as index 0
-		from.edges = make([]*DFAState, LexerATNSimulatorMaxDFAEdge-LexerATNSimulatorMinDFAEdge+1)
-	}
-	from.edges[tk-LexerATNSimulatorMinDFAEdge] = to // connect
+	// make room for tokens 1..n and -1 masquerading as index 0
+	from.setEdge(tk-LexerATNSimulatorMinDFAEdge, LexerATNSimulatorMaxDFAEdge-LexerATNSimulatorMinDFAEdge+1, to) // connect
 
 	return to
 }
@@ -582,18 +580,8 @@ func (l *LexerATNSimulator) addDFAState(configs ATNConfigSet) *DFAState {
 		proposed.lexerActionExecutor = firstConfigWithRuleStopState.(*LexerATNConfig).lexerActionExecutor
 		proposed.setPrediction(l.atn.ruleToTokenType[firstConfigWithRuleStopState.GetState().GetRuleIndex()])
 	}
-	hash := proposed.hash()
-	dfa := l.decisionToDFA[l.mode]
-	existing, ok := dfa.getState(hash)
-	if ok {
-		return existing
-	}
-	newState := proposed
-	newState.stateNumber = dfa.numStates()
 	configs.SetReadOnly(true)
-	newState.configs = configs
-	dfa.setState(hash, newState)
-	return newState
+	return l.decisionToDFA[l.mode].addStateIfAbsent(proposed)
 }
 
 func (l *LexerATNSimulator) getDFA(mode int) *DFA {
@@ -630,7 +618,7 @@ func (l *LexerATNSimulator) GetTokenName(tt int) string {
 		return "EOF"
 	}
 
-	return "'" + string(tt) + "'"
+	return "'" + string(rune(tt)) + "'"
 }
 
 func resetSimState(sim *SimState) {
@@ -0,0 +1,72 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+// LexerInterpreter is a lexer that drives a lexer ATN directly, without
+// generated code. Custom grammar actions are not executed and semantic
+// predicates always evaluate to true.
+type LexerInterpreter struct {
+	*BaseLexer
+
+	atn                *ATN
+	decisionToDFA      []*DFA
+	sharedContextCache *PredictionContextCache
+
+	channelNames []string
+	modeNames    []string
+}
+
+// NewLexerInterpreter creates a LexerInterpreter that tokenizes input using
+// atn. The names describe the vocabulary, rules, channels and modes of the
+// grammar the ATN was created from.
+//
+// @panics if atn is not a lexer ATN.
+func NewLexerInterpreter(grammarFileName string, literalNames, symbolicNames, ruleNames, channelNames, modeNames []string, atn *ATN, input CharStream) *LexerInterpreter {
+	if atn.grammarType != ATNTypeLexer {
+		panic("The ATN must be a lexer ATN.")
+	}
+
+	l := new(LexerInterpreter)
+
+	l.BaseLexer = NewBaseLexer(input)
+	l.Virt = l
+
+	l.GrammarFileName = grammarFileName
+	l.LiteralNames = literalNames
+	l.SymbolicNames = symbolicNames
+	l.RuleNames = ruleNames
+	l.channelNames = channelNames
+	l.modeNames = modeNames
+	l.atn = atn
+
+	l.decisionToDFA = make([]*DFA, len(atn.DecisionToState))
+	for i, ds := range atn.DecisionToState {
+		l.decisionToDFA[i] = NewDFA(ds, i)
+	}
+
+	l.sharedContextCache = NewPredictionContextCache()
+	l.Interpreter = NewLexerATNSimulator(l, atn, l.decisionToDFA, l.sharedContextCache)
+
+	return l
+}
+
+// GetATN returns the ATN the interpreter walks.
+func (l *LexerInterpreter) GetATN() *ATN {
+	return l.atn
+}
+
+// GetChannelNames returns the names of the grammar's token channels.
+func (l *LexerInterpreter) GetChannelNames() []string {
+	return l.channelNames
+}
+
+// GetModeNames returns the names of the grammar's lexer modes.
+func (l *LexerInterpreter) GetModeNames() []string {
+	return l.modeNames
+}
+
+// Action does nothing; the interpreter has no grammar actions to execute.
+func (l *LexerInterpreter) Action(localctx RuleContext, ruleIndex, actionIndex int) {
+}
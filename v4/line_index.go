@@ -0,0 +1,260 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"sort"
+)
+
+// SourcePosition is a position in a CharStream. Line is 1-based and Column
+// is 0-based, the same as Token.GetLine and Token.GetColumn.
+type SourcePosition struct {
+	Line   int
+	Column int
+}
+
+// SourceRange is a range of a CharStream, from Start up to, but not
+// including, End.
+type SourceRange struct {
+	Start SourcePosition
+	End   SourcePosition
+}
+
+// LineIndex maps char indexes of a CharStream to line and column positions
+// and back. It scans the input once, when it is created, to find the start
+// of every line; lines are terminated by '\n' as in the lexer.
+//
+// <p>By default a column is a count of chars since the start of the line,
+// as for Token.GetColumn. SetTabWidth makes tabs advance the column to the
+// next tab stop, and SetUTF16Columns counts columns in UTF-16 code units,
+// as the Language Server Protocol does by default.</p>
+//
+// <p>The input must support random access with GetText, so it cannot be an
+// UnbufferedCharStream.</p>
+type LineIndex struct {
+	input CharStream
+	size  int
+
+	// lineStarts holds the char index of the first char of every line.
+	lineStarts []int
+
+	tabWidth     int
+	utf16Columns bool
+}
+
+// NewLineIndex creates a LineIndex for input.
+func NewLineIndex(input CharStream) *LineIndex {
+	l := new(LineIndex)
+
+	l.input = input
+	l.size = input.Size()
+	l.tabWidth = 1
+	l.lineStarts = []int{0}
+
+	i := 0
+	for _, c := range input.GetText(0, l.size-1) {
+		i++
+		if c == '\n' {
+			l.lineStarts = append(l.lineStarts, i)
+		}
+	}
+
+	return l
+}
+
+// SetTabWidth sets the number of columns between tab stops. A tab advances
+// the column to the next tab stop. The default width is 1, which makes a
+// tab one column wide like any other char.
+//
+// @panics if width is less than 1.
+func (l *LineIndex) SetTabWidth(width int) {
+	if width < 1 {
+		panic("tab width must be at least 1")
+	}
+
+	l.tabWidth = width
+}
+
+// GetTabWidth returns the number of columns between tab stops.
+func (l *LineIndex) GetTabWidth() int {
+	return l.tabWidth
+}
+
+// SetUTF16Columns sets whether columns are counted in UTF-16 code units,
+// in which chars outside the Basic Multilingual Plane are two columns
+// wide, instead of in chars.
+func (l *LineIndex) SetUTF16Columns(utf16Columns bool) {
+	l.utf16Columns = utf16Columns
+}
+
+// GetUTF16Columns reports whether columns are counted in UTF-16 code units.
+func (l *LineIndex) GetUTF16Columns() bool {
+	return l.utf16Columns
+}
+
+// GetLineCount returns the number of lines in the input. Input ending with
+// '\n' has an empty last line.
+func (l *LineIndex) GetLineCount() int {
+	return len(l.lineStarts)
+}
+
+// GetLineStart returns the char index of the first char of line.
+//
+// @panics if line is not in 1..GetLineCount().
+func (l *LineIndex) GetLineStart(line int) int {
+	if line < 1 || line > len(l.lineStarts) {
+		panic("line out of range")
+	}
+
+	return l.lineStarts[line-1]
+}
+
+// lineEnd returns the char index of the '\n' ending the line with the given
+// 0-based index, or the size of the input for the last line.
+func (l *LineIndex) lineEnd(line int) int {
+	if line+1 < len(l.lineStarts) {
+		return l.lineStarts[line+1] - 1
+	}
+
+	return l.size
+}
+
+// advance returns the column following char c at column col.
+func (l *LineIndex) advance(col int, c rune) int {
+	switch {
+	case c == '\t' && l.tabWidth > 1:
+		return (col/l.tabWidth + 1) * l.tabWidth
+	case c >= 0x10000 && l.utf16Columns:
+		return col + 2
+	default:
+		return col + 1
+	}
+}
+
+// GetPosition returns the position of the char at index. An index of
+// Size() returns the position just past the last char.
+//
+// @panics if index is not in 0..Size().
+func (l *LineIndex) GetPosition(index int) SourcePosition {
+	if index < 0 || index > l.size {
+		panic("char index out of range")
+	}
+
+	// find the last line starting at or before index
+	line := sort.SearchInts(l.lineStarts, index+1) - 1
+	start := l.lineStarts[line]
+	if l.tabWidth == 1 && !l.utf16Columns {
+		return SourcePosition{line + 1, index - start}
+	}
+
+	col := 0
+	for _, c := range l.input.GetText(start, index-1) {
+		col = l.advance(col, c)
+	}
+
+	return SourcePosition{line + 1, col}
+}
+
+// GetIndex returns the index of the char at pos. A position inside a tab
+// or inside a char that is two UTF-16 code units wide returns the index of
+// that char. A column past the end of the line returns the index of the
+// '\n' ending it, and a line past the last line returns Size().
+func (l *LineIndex) GetIndex(pos SourcePosition) int {
+	if pos.Line < 1 {
+		return 0
+	}
+	if pos.Line > len(l.lineStarts) {
+		return l.size
+	}
+
+	line := pos.Line - 1
+	start := l.lineStarts[line]
+	end := l.lineEnd(line)
+	if pos.Column <= 0 {
+		return start
+	}
+	if l.tabWidth == 1 && !l.utf16Columns {
+		return intMin(start+pos.Column, end)
+	}
+
+	col := 0
+	i := start
+	for _, c := range l.input.GetText(start, end-1) {
+		col = l.advance(col, c)
+		if col > pos.Column {
+			return i
+		}
+		i++
+	}
+
+	return end
+}
+
+// GetRange returns the range of the chars start..stop, inclusive. If stop
+// is before start, the range is empty.
+func (l *LineIndex) GetRange(start, stop int) SourceRange {
+	startPos := l.GetPosition(start)
+	if stop < start {
+		return SourceRange{startPos, startPos}
+	}
+
+	return SourceRange{startPos, l.GetPosition(intMin(stop+1, l.size))}
+}
+
+// GetTokenRange returns the range of the chars of t. The range of EOF is
+// empty, as is that of a token conjured up by error recovery, such as the
+// symbol of a MissingNode, which is at the token following it.
+func (l *LineIndex) GetTokenRange(t Token) SourceRange {
+	return l.GetRange(l.tokenChars(t))
+}
+
+// GetContextRange returns the range of the chars from the start of ctx's
+// first token to the end of its last token. A context that matched no
+// tokens has an empty range at the start of the token following it, and a
+// context without a start token has the zero SourceRange.
+func (l *LineIndex) GetContextRange(ctx ParserRuleContext) SourceRange {
+	start, stop := ctx.GetStart(), ctx.GetStop()
+	if start == nil {
+		return SourceRange{}
+	}
+	startIndex, _ := l.tokenChars(start)
+	if stop == nil || stop.GetTokenIndex() < start.GetTokenIndex() {
+		return l.GetRange(startIndex, startIndex-1)
+	}
+	_, stopIndex := l.tokenChars(stop)
+
+	return l.GetRange(startIndex, stopIndex)
+}
+
+// GetIntervalRange returns the range of the chars of the tokens in
+// interval, such as one returned by ParseTree.GetSourceInterval, looking
+// the tokens up in tokens. An invalid or empty interval has the zero
+// SourceRange.
+func (l *LineIndex) GetIntervalRange(tokens TokenStream, interval *Interval) SourceRange {
+	if interval == nil || interval.Start < 0 || interval.Stop < interval.Start {
+		return SourceRange{}
+	}
+	start, _ := l.tokenChars(tokens.Get(interval.Start))
+	_, stop := l.tokenChars(tokens.Get(interval.Stop))
+
+	return l.GetRange(start, stop)
+}
+
+// tokenChars returns the char indexes of the first and last char of t. A
+// token conjured up by error recovery has no chars, and a start index of
+// -1; it gets an empty range at the char its line and column point to,
+// which error strategies set to those of the token following it.
+func (l *LineIndex) tokenChars(t Token) (start, stop int) {
+	if t.GetStart() >= 0 {
+		return t.GetStart(), t.GetStop()
+	}
+
+	index := l.size
+	if line := t.GetLine(); line >= 1 && line <= len(l.lineStarts) {
+		index = intMin(l.lineStarts[line-1]+intMax(t.GetColumn(), 0), l.lineEnd(line-1))
+	}
+
+	return index, index - 1
+}
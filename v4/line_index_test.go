@@ -0,0 +1,127 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"testing"
+)
+
+func TestLineIndexPosition(t *testing.T) {
+	l := NewLineIndex(NewInputStream("ab\n\tc\U0001F600d\n"))
+
+	if got := l.GetLineCount(); got != 3 {
+		t.Errorf("expected 3 lines, got %d", got)
+	}
+
+	tests := []struct {
+		index    int
+		tabWidth int
+		utf16    bool
+		line     int
+		column   int
+	}{
+		{0, 1, false, 1, 0},
+		{2, 1, false, 1, 2}, // '\n'
+		{3, 1, false, 2, 0}, // '\t'
+		{4, 1, false, 2, 1}, // 'c'
+		{6, 1, false, 2, 3}, // 'd'
+		{4, 4, false, 2, 4},
+		{6, 4, false, 2, 6},
+		{6, 1, true, 2, 4},
+		{6, 8, true, 2, 11},
+		{8, 1, false, 3, 0}, // end of input
+	}
+
+	for _, test := range tests {
+		l.SetTabWidth(test.tabWidth)
+		l.SetUTF16Columns(test.utf16)
+
+		pos := l.GetPosition(test.index)
+		if pos != (SourcePosition{test.line, test.column}) {
+			t.Errorf("%d (tab %d, utf16 %v): expected %d:%d, got %d:%d", test.index, test.tabWidth, test.utf16, test.line, test.column, pos.Line, pos.Column)
+		}
+		if got := l.GetIndex(pos); got != test.index {
+			t.Errorf("%d:%d (tab %d, utf16 %v): expected index %d, got %d", pos.Line, pos.Column, test.tabWidth, test.utf16, test.index, got)
+		}
+	}
+}
+
+func TestLineIndexIndex(t *testing.T) {
+	l := NewLineIndex(NewInputStream("ab\n\tc\U0001F600d"))
+	l.SetTabWidth(4)
+	l.SetUTF16Columns(true)
+
+	tests := []struct {
+		line   int
+		column int
+		index  int
+	}{
+		{0, 0, 0},
+		{1, 9, 2},  // past the end of the line
+		{2, 2, 3},  // inside the tab
+		{2, 6, 5},  // inside the surrogate pair
+		{2, 99, 7}, // past the end of the input
+		{3, 0, 7},
+	}
+
+	for _, test := range tests {
+		if got := l.GetIndex(SourcePosition{test.line, test.column}); got != test.index {
+			t.Errorf("%d:%d: expected index %d, got %d", test.line, test.column, test.index, got)
+		}
+	}
+}
+
+func TestLineIndexContextRange(t *testing.T) {
+	input := NewInputStream("x = 1;\n(a\n + b);")
+	lexer := newExprLexer(input)
+	tokens := NewCommonTokenStream(lexer, TokenDefaultChannel)
+	parser := NewParserInterpreter("Expr.g4", exprLiteralNames, exprSymbolicNames, exprParserRuleNames, exprParserATN, tokens)
+	tree := parser.Parse(ExprParserRULE_prog)
+
+	l := NewLineIndex(input)
+	stats := TreesfindAllRuleNodes(tree, ExprParserRULE_stat)
+
+	expected := SourceRange{SourcePosition{2, 0}, SourcePosition{3, 6}}
+	if got := l.GetContextRange(stats[1].(ParserRuleContext)); got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if got := l.GetIntervalRange(tokens, stats[1].GetSourceInterval()); got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	eof := tree.GetChild(tree.GetChildCount() - 1).(TerminalNode).GetSymbol()
+	expected = SourceRange{SourcePosition{3, 6}, SourcePosition{3, 6}}
+	if got := l.GetTokenRange(eof); got != expected {
+		t.Errorf("EOF: expected %v, got %v", expected, got)
+	}
+}
+
+func TestLineIndexMissingTokenRange(t *testing.T) {
+	parser := newQuietExprParser("x =\t\t(1 + 2;\ny = 3;")
+	tree := parser.Parse(ExprParserRULE_prog)
+	input := parser.GetTokenStream().GetTokenSource().GetInputStream()
+
+	missing := TreesFindAllTokenNodes(tree, 6)
+	if len(missing) != 1 {
+		t.Fatalf("expected a missing ')', got %v", missing)
+	}
+	token := missing[0].(MissingNode).GetSymbol()
+
+	// The missing ')' is where the ';' following it is, in columns counted
+	// with the tab width of the LineIndex.
+	l := NewLineIndex(input)
+	l.SetTabWidth(4)
+	expected := SourceRange{SourcePosition{1, 14}, SourcePosition{1, 14}}
+	if got := l.GetTokenRange(token); got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	// The expression enclosed by the missing ')' ends where it is.
+	expr := missing[0].GetParent().(ParserRuleContext)
+	expected = SourceRange{SourcePosition{1, 8}, SourcePosition{1, 14}}
+	if got := l.GetContextRange(expr); got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
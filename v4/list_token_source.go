@@ -0,0 +1,159 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import "strings"
+
+// ListTokenSource is a TokenSource that provides tokens from a slice of
+// tokens. If the final token in the slice is not an EOF token, an EOF token
+// is created after the last token, using the stop index and line of the last
+// token to compute its position.
+type ListTokenSource struct {
+	// tokens is the wrapped collection of tokens to return.
+	tokens []Token
+
+	// sourceName is the name of the input source. If empty, the name is
+	// derived from the input stream of the tokens, or "List".
+	sourceName string
+
+	// i is the index into tokens of the token to return by the next call to
+	// NextToken. The end of the input is indicated by i being greater than or
+	// equal to the length of tokens.
+	i int
+
+	// eofToken is used for the result of NextToken once the end of tokens
+	// has been reached.
+	eofToken Token
+
+	factory TokenFactory
+}
+
+// NewListTokenSource constructs a new ListTokenSource instance from the
+// specified collection of tokens. The sourceName is returned by
+// GetSourceName and may be empty.
+func NewListTokenSource(tokens []Token, sourceName string) *ListTokenSource {
+	if tokens == nil {
+		panic("tokens cannot be nil")
+	}
+
+	return &ListTokenSource{
+		tokens:     tokens,
+		sourceName: sourceName,
+		factory:    CommonTokenFactoryDEFAULT,
+	}
+}
+
+func (l *ListTokenSource) NextToken() Token {
+	if l.i >= len(l.tokens) {
+		if l.eofToken == nil {
+			start := -1
+			if len(l.tokens) > 0 {
+				previousStop := l.tokens[len(l.tokens)-1].GetStop()
+				if previousStop != -1 {
+					start = previousStop + 1
+				}
+			}
+
+			stop := start - 1
+			if stop < -1 {
+				stop = -1
+			}
+
+			l.eofToken = l.factory.Create(&TokenSourceCharStreamPair{l, l.GetInputStream()}, TokenEOF, "EOF", TokenDefaultChannel, start, stop, l.GetLine(), l.GetCharPositionInLine())
+		}
+
+		return l.eofToken
+	}
+
+	t := l.tokens[l.i]
+	if l.i == len(l.tokens)-1 && t.GetTokenType() == TokenEOF {
+		l.eofToken = t
+	}
+
+	l.i++
+
+	return t
+}
+
+// Skip does nothing; the tokens of a ListTokenSource have already been
+// created.
+func (l *ListTokenSource) Skip() {}
+
+// More does nothing; the tokens of a ListTokenSource have already been
+// created.
+func (l *ListTokenSource) More() {}
+
+func (l *ListTokenSource) GetLine() int {
+	if l.i < len(l.tokens) {
+		return l.tokens[l.i].GetLine()
+	} else if l.eofToken != nil {
+		return l.eofToken.GetLine()
+	} else if len(l.tokens) > 0 {
+		// have to calculate the result from the line/column of the previous
+		// token, along with the text of the token.
+		lastToken := l.tokens[len(l.tokens)-1]
+
+		return lastToken.GetLine() + strings.Count(lastToken.GetText(), "\n")
+	}
+
+	// only reach this if tokens is empty, meaning EOF occurs at the first
+	// position in the input
+	return 1
+}
+
+func (l *ListTokenSource) GetCharPositionInLine() int {
+	if l.i < len(l.tokens) {
+		return l.tokens[l.i].GetColumn()
+	} else if l.eofToken != nil {
+		return l.eofToken.GetColumn()
+	} else if len(l.tokens) > 0 {
+		// have to calculate the result from the line/column of the previous
+		// token, along with the text of the token.
+		lastToken := l.tokens[len(l.tokens)-1]
+		tokenText := lastToken.GetText()
+		if lastNewLine := strings.LastIndex(tokenText, "\n"); lastNewLine >= 0 {
+			return len(tokenText) - lastNewLine - 1
+		}
+
+		return lastToken.GetColumn() + lastToken.GetStop() - lastToken.GetStart() + 1
+	}
+
+	// only reach this if tokens is empty, meaning EOF occurs at the first
+	// position in the input
+	return 0
+}
+
+func (l *ListTokenSource) GetInputStream() CharStream {
+	if l.i < len(l.tokens) {
+		return l.tokens[l.i].GetInputStream()
+	} else if l.eofToken != nil {
+		return l.eofToken.GetInputStream()
+	} else if len(l.tokens) > 0 {
+		return l.tokens[len(l.tokens)-1].GetInputStream()
+	}
+
+	// no input stream information is available
+	return nil
+}
+
+func (l *ListTokenSource) GetSourceName() string {
+	if l.sourceName != "" {
+		return l.sourceName
+	}
+
+	if input := l.GetInputStream(); input != nil {
+		return input.GetSourceName()
+	}
+
+	return "List"
+}
+
+func (l *ListTokenSource) SetTokenFactory(factory TokenFactory) {
+	l.factory = factory
+}
+
+func (l *ListTokenSource) GetTokenFactory() TokenFactory {
+	return l.factory
+}
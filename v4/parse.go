@@ -0,0 +1,256 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strconv"
+)
+
+// SyntaxError is a syntax error reported by a lexer or parser to its error
+// listeners. It implements error.
+type SyntaxError struct {
+	// Recognizer is the lexer or parser that reported the error.
+	Recognizer Recognizer
+
+	// OffendingSymbol is the offending token for a parser error, and nil
+	// for a lexer error.
+	OffendingSymbol interface{}
+
+	// Line is the 1-based line of the error and Column the 0-based column,
+	// as for Token.GetLine and Token.GetColumn.
+	Line   int
+	Column int
+
+	// Msg is the message reported to the error listeners.
+	Msg string
+
+	// Exception is the RecognitionException describing the error, or nil
+	// if the error was not raised by an exception, such as an error
+	// reported by single-token deletion.
+	Exception RecognitionException
+}
+
+func (s *SyntaxError) Error() string {
+	return "line " + strconv.Itoa(s.Line) + ":" + strconv.Itoa(s.Column) + " " + s.Msg
+}
+
+// syntaxErrorCollector is an ErrorListener collecting the syntax errors
+// reported during Parse.
+type syntaxErrorCollector struct {
+	*DefaultErrorListener
+	errors []*SyntaxError
+}
+
+func (c *syntaxErrorCollector) SyntaxError(recognizer Recognizer, offendingSymbol interface{}, line, column int, msg string, e RecognitionException) {
+	c.errors = append(c.errors, &SyntaxError{
+		Recognizer:      recognizer,
+		OffendingSymbol: offendingSymbol,
+		Line:            line,
+		Column:          column,
+		Msg:             msg,
+		Exception:       e,
+	})
+}
+
+// reported reports whether e was reported to c.
+func (c *syntaxErrorCollector) reported(e RecognitionException) bool {
+	for _, s := range c.errors {
+		if s.Exception == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parse calls start, which invokes a start rule of parser, and returns the
+// resulting parse tree together with the syntax errors reported by parser
+// and by its lexer, if its token source is one. Recognition panics are
+// converted to errors; any other panic is passed on.
+//
+// <p>For example:</p>
+//
+//	tree, syntaxErrors, err := antlr.Parse(p, func() antlr.ParserRuleContext {
+//		return p.Prog()
+//	})
+//
+// <p>The returned error is:</p>
+//
+// <ul>
+// <li>nil if no syntax errors were reported;</li>
+// <li>the first SyntaxError if syntax errors were reported and the parser
+// recovered from them, in which case the tree is returned as well;</li>
+// <li>the first SyntaxError if the parse was aborted by a
+// RecognitionException, for example by BailErrorStrategy, in which case
+// the exception is reported as DefaultErrorStrategy would and the tree is
+// nil;</li>
+// <li>the *ParseCancellationException if the parse was cancelled through
+// the context.Context set with SetContext, in which case the tree is
+// nil.</li>
+// </ul>
+//
+// <p>The error listeners already added to parser, such as the
+// ConsoleErrorListener, are still notified of every syntax error.</p>
+func Parse(parser Parser, start func() ParserRuleContext) (tree ParserRuleContext, syntaxErrors []*SyntaxError, err error) {
+	collector := &syntaxErrorCollector{DefaultErrorListener: NewDefaultErrorListener()}
+
+	parser.AddErrorListener(collector)
+	defer parser.RemoveErrorListener(collector)
+
+	if tokens := parser.GetTokenStream(); tokens != nil {
+		if lexer, ok := tokens.GetTokenSource().(Recognizer); ok {
+			lexer.AddErrorListener(collector)
+			defer lexer.RemoveErrorListener(collector)
+		}
+	}
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			if len(collector.errors) > 0 {
+				err = collector.errors[0]
+			}
+			syntaxErrors = collector.errors
+			return
+		}
+
+		var re RecognitionException
+		switch e := r.(type) {
+		case *ParseCancellationException:
+			re = e.GetCause()
+			if re == nil {
+				tree, syntaxErrors, err = nil, collector.errors, e
+				return
+			}
+		case RecognitionException:
+			re = e
+		default:
+			panic(r)
+		}
+
+		// Rule functions report an exception before the error strategy
+		// bails out, but RecoverInline bails out without reporting it.
+		if !collector.reported(re) {
+			reporter := NewDefaultErrorStrategy()
+			if h, ok := parser.GetErrorHandler().(interface{ GetMessageFormatter() ErrorMessageFormatter }); ok {
+				reporter.SetMessageFormatter(h.GetMessageFormatter())
+			}
+			reporter.ReportError(parser, re)
+		}
+
+		tree, syntaxErrors, err = nil, collector.errors, collector.errors[0]
+	}()
+
+	return start(), nil, nil
+}
+
+// ParseStage identifies the stage of ParseTwoStage that produced the parse
+// tree.
+type ParseStage int
+
+const (
+	// ParseStageSLL is the first stage of ParseTwoStage: SLL prediction
+	// with a BailErrorStrategy.
+	ParseStageSLL ParseStage = iota + 1
+
+	// ParseStageLL is the second stage of ParseTwoStage: full LL
+	// prediction with the parser's own error strategy.
+	ParseStageLL
+)
+
+func (s ParseStage) String() string {
+	switch s {
+	case ParseStageSLL:
+		return "SLL"
+	case ParseStageLL:
+		return "LL"
+	default:
+		return "ParseStage(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// ParseTwoStage parses like Parse, using two-stage parsing, which is the
+// fastest way to parse input that is usually free of syntax errors.
+//
+// <p>The first stage parses with PredictionModeSLL and a
+// BailErrorStrategy, and without notifying the parser's error listeners.
+// SLL prediction is much faster than full LL prediction, and produces the
+// same parse tree whenever it succeeds. If the first stage finds a syntax
+// error, which may be either a real syntax error or a decision SLL cannot
+// make, the token stream is rewound, the parser is reset, and the second
+// stage parses again with PredictionModeLL and the parser's own error
+// strategy and error listeners. The returned stage tells which stage
+// produced the tree.</p>
+//
+// <p>The token stream must be able to seek back to the index it is at when
+// ParseTwoStage is called, as CommonTokenStream can. Errors the lexer
+// reported during the first stage are returned, and were reported to the
+// lexer's error listeners, only once, as the tokens are not lexed again.
+// Parse listeners, including a trace listener, see both stages; the
+// parser's prediction mode and error strategy are restored on return.</p>
+//
+// <p>For example:</p>
+//
+//	tree, stage, syntaxErrors, err := antlr.ParseTwoStage(p, func() antlr.ParserRuleContext {
+//		return p.Prog()
+//	})
+func ParseTwoStage(parser Parser, start func() ParserRuleContext) (tree ParserRuleContext, stage ParseStage, syntaxErrors []*SyntaxError, err error) {
+	interp := parser.GetInterpreter()
+	tokens := parser.GetTokenStream()
+	startIndex := intMax(tokens.Index(), 0) // -1 before the first token is fetched
+
+	predictionMode := interp.GetPredictionMode()
+	errHandler := parser.GetErrorHandler()
+	defer func() {
+		interp.SetPredictionMode(predictionMode)
+		parser.SetErrorHandler(errHandler)
+	}()
+
+	// stage 1: SLL prediction, bailing out on the first syntax error
+	interp.SetPredictionMode(PredictionModeSLL)
+	parser.SetErrorHandler(NewBailErrorStrategy())
+	tree, syntaxErrors, err = parseSilently(parser, start)
+	if tree != nil {
+		return tree, ParseStageSLL, syntaxErrors, err
+	}
+	if _, ok := err.(*ParseCancellationException); ok {
+		return nil, ParseStageSLL, syntaxErrors, err
+	}
+
+	var lexerErrors []*SyntaxError
+	for _, e := range syntaxErrors {
+		if _, ok := e.Recognizer.(Lexer); ok {
+			lexerErrors = append(lexerErrors, e)
+		}
+	}
+
+	// stage 2: full LL prediction with the parser's own error strategy
+	tokens.Seek(startIndex)
+	interp.SetPredictionMode(PredictionModeLL)
+	parser.SetErrorHandler(errHandler)
+	parser.SetTokenStream(tokens) // resets the parser
+	tree, syntaxErrors, err = Parse(parser, start)
+
+	syntaxErrors = append(lexerErrors, syntaxErrors...)
+	if _, ok := err.(*ParseCancellationException); !ok && len(syntaxErrors) > 0 {
+		err = syntaxErrors[0]
+	}
+
+	return tree, ParseStageLL, syntaxErrors, err
+}
+
+// parseSilently calls Parse with the error listeners of parser removed.
+func parseSilently(parser Parser, start func() ParserRuleContext) (ParserRuleContext, []*SyntaxError, error) {
+	listeners := parser.GetErrorListeners()
+	parser.RemoveErrorListeners()
+	defer func() {
+		parser.RemoveErrorListeners()
+		for _, l := range listeners {
+			parser.AddErrorListener(l)
+		}
+	}()
+
+	return Parse(parser, start)
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"time"
+)
+
+// DecisionInfo holds the profiling statistics of a single decision,
+// collected by a ProfilingATNSimulator over all the predictions of that
+// decision.
+//
+// <p>Prediction first runs SLL lookahead over the DFA cache, computing new
+// DFA states from the ATN only on cache misses. If SLL finds a conflict, it
+// falls back to full context LL lookahead, which always runs over the ATN.
+// Lookahead depths count tokens from the start of the decision, so a
+// decision taking k tokens of lookahead has a depth of k.</p>
+type DecisionInfo struct {
+	// Decision is the decision number, an index into ATN.DecisionToState.
+	Decision int
+
+	// Invocations is the number of times AdaptivePredict was called for
+	// this decision.
+	Invocations int64
+
+	// TimeInPrediction is the total time spent in AdaptivePredict for this
+	// decision. It includes the time spent evaluating semantic predicates
+	// and the overhead of profiling.
+	TimeInPrediction time.Duration
+
+	// SLLTotalLook is the sum of the SLL lookahead depths of all the
+	// predictions of this decision.
+	SLLTotalLook int64
+
+	// SLLMinLook is the minimum SLL lookahead depth of any prediction of
+	// this decision.
+	SLLMinLook int64
+
+	// SLLMaxLook is the maximum SLL lookahead depth of any prediction of
+	// this decision.
+	SLLMaxLook int64
+
+	// SLLMaxLookEvent is the prediction that required SLLMaxLook tokens of
+	// lookahead.
+	SLLMaxLookEvent *LookaheadEventInfo
+
+	// LLTotalLook is the sum of the LL lookahead depths of the predictions
+	// of this decision that fell back to full context prediction.
+	LLTotalLook int64
+
+	// LLMinLook is the minimum LL lookahead depth of any prediction of this
+	// decision that fell back to full context prediction.
+	LLMinLook int64
+
+	// LLMaxLook is the maximum LL lookahead depth of any prediction of this
+	// decision that fell back to full context prediction.
+	LLMaxLook int64
+
+	// LLMaxLookEvent is the prediction that required LLMaxLook tokens of
+	// lookahead.
+	LLMaxLookEvent *LookaheadEventInfo
+
+	// ContextSensitivities holds the context sensitivities found while
+	// predicting this decision.
+	ContextSensitivities []*ContextSensitivityInfo
+
+	// Errors holds the syntax errors found while predicting this decision.
+	Errors []*ErrorInfo
+
+	// Ambiguities holds the ambiguities found while predicting this
+	// decision.
+	Ambiguities []*AmbiguityInfo
+
+	// PredicateEvals holds the semantic predicates evaluated while
+	// predicting this decision. Precedence predicates are not included.
+	PredicateEvals []*PredicateEvalInfo
+
+	// SLLATNTransitions is the number of SLL lookahead operations that
+	// computed a new DFA state from the ATN, i.e. DFA cache misses.
+	SLLATNTransitions int64
+
+	// SLLDFATransitions is the number of SLL lookahead operations that
+	// followed an existing DFA edge, i.e. DFA cache hits.
+	SLLDFATransitions int64
+
+	// LLFallback is the number of predictions of this decision that fell
+	// back from SLL to full context LL prediction.
+	LLFallback int64
+
+	// LLATNTransitions is the number of LL lookahead operations, which all
+	// run over the ATN.
+	LLATNTransitions int64
+}
+
+// NewDecisionInfo creates an empty DecisionInfo for decision.
+func NewDecisionInfo(decision int) *DecisionInfo {
+	return &DecisionInfo{Decision: decision}
+}
+
+// SLLAvgLook returns the average SLL lookahead depth of the predictions of
+// this decision, or 0 if it was never predicted.
+func (d *DecisionInfo) SLLAvgLook() float64 {
+	if d.Invocations == 0 {
+		return 0
+	}
+
+	return float64(d.SLLTotalLook) / float64(d.Invocations)
+}
+
+// LLAvgLook returns the average LL lookahead depth of the predictions of
+// this decision that fell back to full context prediction, or 0 if none
+// did.
+func (d *DecisionInfo) LLAvgLook() float64 {
+	if d.LLFallback == 0 {
+		return 0
+	}
+
+	return float64(d.LLTotalLook) / float64(d.LLFallback)
+}
+
+// ParseInfo gives access to the profiling statistics collected by a
+// ProfilingATNSimulator, as returned by BaseParser.GetParseInfo.
+type ParseInfo struct {
+	atnSimulator *ProfilingATNSimulator
+}
+
+func NewParseInfo(atnSimulator *ProfilingATNSimulator) *ParseInfo {
+	return &ParseInfo{atnSimulator: atnSimulator}
+}
+
+// GetDecisionInfo returns the statistics of every decision, indexed by
+// decision number.
+func (p *ParseInfo) GetDecisionInfo() []*DecisionInfo {
+	return p.atnSimulator.GetDecisionInfo()
+}
+
+// GetLLDecisions returns the numbers of the decisions that fell back to
+// full context LL prediction at least once.
+func (p *ParseInfo) GetLLDecisions() []int {
+	var LL []int
+	for _, d := range p.atnSimulator.decisions {
+		if d.LLFallback > 0 {
+			LL = append(LL, d.Decision)
+		}
+	}
+
+	return LL
+}
+
+// GetTotalTimeInPrediction returns the total time spent in AdaptivePredict
+// over all decisions.
+func (p *ParseInfo) GetTotalTimeInPrediction() time.Duration {
+	var t time.Duration
+	for _, d := range p.atnSimulator.decisions {
+		t += d.TimeInPrediction
+	}
+
+	return t
+}
+
+// GetTotalSLLLookaheadOps returns the total number of SLL lookahead
+// operations over all decisions.
+func (p *ParseInfo) GetTotalSLLLookaheadOps() int64 {
+	var k int64
+	for _, d := range p.atnSimulator.decisions {
+		k += d.SLLTotalLook
+	}
+
+	return k
+}
+
+// GetTotalLLLookaheadOps returns the total number of LL lookahead
+// operations over all decisions.
+func (p *ParseInfo) GetTotalLLLookaheadOps() int64 {
+	var k int64
+	for _, d := range p.atnSimulator.decisions {
+		k += d.LLTotalLook
+	}
+
+	return k
+}
+
+// GetTotalSLLATNLookaheadOps returns the total number of SLL lookahead
+// operations that had to go to the ATN, over all decisions.
+func (p *ParseInfo) GetTotalSLLATNLookaheadOps() int64 {
+	var k int64
+	for _, d := range p.atnSimulator.decisions {
+		k += d.SLLATNTransitions
+	}
+
+	return k
+}
+
+// GetTotalLLATNLookaheadOps returns the total number of LL lookahead
+// operations that had to go to the ATN, over all decisions.
+func (p *ParseInfo) GetTotalLLATNLookaheadOps() int64 {
+	var k int64
+	for _, d := range p.atnSimulator.decisions {
+		k += d.LLATNTransitions
+	}
+
+	return k
+}
+
+// GetTotalATNLookaheadOps returns the total number of SLL and LL lookahead
+// operations that had to go to the ATN, over all decisions.
+func (p *ParseInfo) GetTotalATNLookaheadOps() int64 {
+	var k int64
+	for _, d := range p.atnSimulator.decisions {
+		k += d.SLLATNTransitions + d.LLATNTransitions
+	}
+
+	return k
+}
+
+// GetDFASize returns the total number of DFA states over all decisions.
+func (p *ParseInfo) GetDFASize() int {
+	n := 0
+	for decision := range p.atnSimulator.decisionToDFA {
+		n += p.GetDFASizeForDecision(decision)
+	}
+
+	return n
+}
+
+// GetDFASizeForDecision returns the number of DFA states of decision.
+func (p *ParseInfo) GetDFASizeForDecision(decision int) int {
+	return p.atnSimulator.decisionToDFA[decision].numStates()
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// newQuietExprParser returns a ParserInterpreter for the Expr grammar over
+// input, with no error listeners on the parser or its lexer.
+func newQuietExprParser(input string) *ParserInterpreter {
+	lexer := newExprLexer(NewInputStream(input))
+	lexer.RemoveErrorListeners()
+
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(lexer, TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+
+	return parser
+}
+
+func parseExprProg(parser *ParserInterpreter) (ParserRuleContext, []*SyntaxError, error) {
+	return Parse(parser, func() ParserRuleContext {
+		return parser.Parse(ExprParserRULE_prog)
+	})
+}
+
+func TestParse(t *testing.T) {
+	parser := newQuietExprParser("x = 1 + 2;")
+
+	tree, syntaxErrors, err := parseExprProg(parser)
+	if err != nil || len(syntaxErrors) != 0 {
+		t.Fatalf("expected no errors, got %v, %v", err, syntaxErrors)
+	}
+	if got, want := tree.ToStringTree(nil, parser), "(prog (stat x = (expr (expr 1) + (expr 2)) ;) <EOF>)"; got != want {
+		t.Errorf("expected tree %q, got %q", want, got)
+	}
+	if n := len(parser.listeners); n != 0 {
+		t.Errorf("expected the error listener to be removed, %d listeners left", n)
+	}
+}
+
+func TestParseRecoveredErrors(t *testing.T) {
+	parser := newQuietExprParser("x = (1 + 2; y = 3 # ;")
+
+	tree, syntaxErrors, err := parseExprProg(parser)
+	if tree == nil {
+		t.Fatal("expected a tree")
+	}
+	if len(syntaxErrors) != 2 {
+		t.Fatalf("expected 2 syntax errors, got %v", syntaxErrors)
+	}
+	if err != syntaxErrors[0] {
+		t.Errorf("expected the first syntax error, got %v", err)
+	}
+
+	if got, want := syntaxErrors[0].Error(), "line 1:10 missing ')' at ';'"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if _, ok := syntaxErrors[0].Recognizer.(Parser); !ok {
+		t.Error("expected the parser error to be reported by the parser")
+	}
+
+	if got, want := syntaxErrors[1].Error(), "line 1:18 token recognition error at: '#'"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if _, ok := syntaxErrors[1].Recognizer.(Lexer); !ok {
+		t.Error("expected the lexer error to be reported by the lexer")
+	}
+	if syntaxErrors[1].OffendingSymbol != nil {
+		t.Errorf("expected no offending symbol for a lexer error, got %v", syntaxErrors[1].OffendingSymbol)
+	}
+}
+
+func TestParseBail(t *testing.T) {
+	for _, input := range []string{"x = (1 + 2;", "x = 1 + ;"} {
+		parser := newQuietExprParser(input)
+		parser.SetErrorHandler(NewBailErrorStrategy())
+
+		tree, syntaxErrors, err := parseExprProg(parser)
+		if tree != nil {
+			t.Errorf("%q: expected no tree, got %v", input, tree.ToStringTree(nil, parser))
+		}
+		if len(syntaxErrors) != 1 {
+			t.Fatalf("%q: expected 1 syntax error, got %v", input, syntaxErrors)
+		}
+		if err != syntaxErrors[0] {
+			t.Errorf("%q: expected the syntax error, got %v", input, err)
+		}
+		if syntaxErrors[0].Exception == nil {
+			t.Errorf("%q: expected the exception aborting the parse", input)
+		}
+	}
+}
+
+func TestParseCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	parser := newQuietExprParser("x = 1;")
+	parser.SetContext(ctx)
+
+	tree, syntaxErrors, err := parseExprProg(parser)
+	if tree != nil || len(syntaxErrors) != 0 {
+		t.Errorf("expected no tree and no syntax errors, got %v, %v", tree, syntaxErrors)
+	}
+	if _, ok := err.(*ParseCancellationException); !ok {
+		t.Fatalf("expected a *ParseCancellationException, got %v", err)
+	}
+}
+
+func TestParseOtherPanic(t *testing.T) {
+	parser := newQuietExprParser("x = 1;")
+
+	expectPanic(t, "boom", func() {
+		Parse(parser, func() ParserRuleContext {
+			panic("boom")
+		})
+	})
+	if n := len(parser.listeners); n != 0 {
+		t.Errorf("expected the error listener to be removed, %d listeners left", n)
+	}
+}
+
+func TestParseTwoStage(t *testing.T) {
+	tests := []struct {
+		input  []int
+		stage  ParseStage
+		tree   string
+		errors int
+	}{
+		{[]int{ctxDollar, ctxINT, ctxID}, ParseStageSLL, "(s '$' (a (e INT) ID) <EOF>)", 0},
+		{[]int{ctxAt, ctxINT, ctxID}, ParseStageLL, "(s '@' (b e INT ID) <EOF>)", 0},
+		{[]int{ctxDollar, ctxID, ctxID}, ParseStageLL, "(s '$' (a e ID) ID <EOF>)", 1},
+	}
+
+	for _, test := range tests {
+		parser := newCtxParser(test.input...)
+		errHandler := parser.GetErrorHandler()
+
+		tree, stage, syntaxErrors, err := ParseTwoStage(parser, func() ParserRuleContext {
+			return parser.Parse(0)
+		})
+		if stage != test.stage {
+			t.Errorf("%v: expected stage %v, got %v", test.input, test.stage, stage)
+		}
+		if tree == nil {
+			t.Errorf("%v: expected a tree", test.input)
+		} else if got := tree.ToStringTree(nil, parser); got != test.tree {
+			t.Errorf("%v: expected tree %q, got %q", test.input, test.tree, got)
+		}
+		if len(syntaxErrors) != test.errors || (test.errors == 0) != (err == nil) {
+			t.Errorf("%v: expected %d syntax errors, got %v, %v", test.input, test.errors, syntaxErrors, err)
+		}
+
+		if mode := parser.GetInterpreter().GetPredictionMode(); mode != PredictionModeLL {
+			t.Errorf("%v: expected the prediction mode to be restored, got %d", test.input, mode)
+		}
+		if parser.GetErrorHandler() != errHandler {
+			t.Errorf("%v: expected the error strategy to be restored", test.input)
+		}
+	}
+}
+
+func TestParseTwoStageLexerErrors(t *testing.T) {
+	parser := newQuietExprParser("x = (1 # ;")
+	listener := &syntaxErrorCollector{DefaultErrorListener: NewDefaultErrorListener()}
+	parser.AddErrorListener(listener)
+
+	tree, stage, syntaxErrors, err := ParseTwoStage(parser, func() ParserRuleContext {
+		return parser.Parse(ExprParserRULE_prog)
+	})
+	if stage != ParseStageLL || tree == nil {
+		t.Fatalf("expected a tree from stage LL, got %v", stage)
+	}
+
+	var messages []string
+	for _, e := range syntaxErrors {
+		messages = append(messages, e.Error())
+	}
+	if got, want := fmt.Sprint(messages), "[line 1:7 token recognition error at: '#' line 1:9 missing ')' at ';']"; got != want {
+		t.Errorf("expected syntax errors %s, got %s", want, got)
+	}
+	if err != syntaxErrors[0] {
+		t.Errorf("expected the first syntax error, got %v", err)
+	}
+
+	// the parser's listeners only hear about the errors of stage LL
+	if len(listener.errors) != 1 || listener.errors[0].Msg != "missing ')' at ';'" {
+		t.Errorf("expected the listener to be notified of the stage LL error only, got %v", listener.errors)
+	}
+	if len(parser.listeners) != 1 {
+		t.Errorf("expected the error listeners to be restored, got %d", len(parser.listeners))
+	}
+}
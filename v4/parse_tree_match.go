@@ -0,0 +1,140 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import "fmt"
+
+// ParseTreeMatch represents the result of matching a ParseTree against a
+// tree pattern.
+type ParseTreeMatch struct {
+	// tree is the parse tree that was matched.
+	tree ParseTree
+
+	// pattern is the pattern that tree was matched against.
+	pattern *ParseTreePattern
+
+	// labels maps each label or tag name in the pattern to the parse tree
+	// nodes it matched.
+	labels map[string][]ParseTree
+
+	// mismatchedNode is the first node which failed to match the tree
+	// pattern, or nil if the match succeeded.
+	mismatchedNode ParseTree
+}
+
+// NewParseTreeMatch constructs a new instance of ParseTreeMatch from the
+// specified parse tree and pattern.
+//
+// @param tree The parse tree to match against the pattern.
+// @param pattern The parse tree pattern.
+// @param labels A mapping from label names to collections of ParseTree
+// objects located by the tree pattern matching process.
+// @param mismatchedNode The first node which failed to match the tree
+// pattern during the matching process.
+func NewParseTreeMatch(tree ParseTree, pattern *ParseTreePattern, labels map[string][]ParseTree, mismatchedNode ParseTree) *ParseTreeMatch {
+	if tree == nil {
+		panic("tree cannot be nil")
+	}
+
+	if pattern == nil {
+		panic("pattern cannot be nil")
+	}
+
+	if labels == nil {
+		panic("labels cannot be nil")
+	}
+
+	return &ParseTreeMatch{
+		tree:           tree,
+		pattern:        pattern,
+		labels:         labels,
+		mismatchedNode: mismatchedNode,
+	}
+}
+
+// Get gets the last node associated with a specific label.
+//
+// <p>For example, for pattern {@code <id:ID>}, {@code Get("id")} returns the
+// node matched for that {@code ID}. If more than one node matched the
+// specified label, only the last is returned. If there is no node
+// associated with the label, this returns nil.</p>
+//
+// <p>Pattern tags like {@code <ID>} and {@code <expr>} without labels are
+// considered to be labeled with {@code ID} and {@code expr}, respectively.</p>
+func (m *ParseTreeMatch) Get(label string) ParseTree {
+	parseTrees := m.labels[label]
+	if len(parseTrees) == 0 {
+		return nil
+	}
+
+	return parseTrees[len(parseTrees)-1] // return last if multiple
+}
+
+// GetAll returns all nodes matching a rule or token tag with the specified
+// label, in the order they were matched.
+//
+// <p>If the label is the name of a parser rule or token in the grammar, the
+// resulting list will contain both the parse trees matching rule or tags
+// explicitly labeled with the label and the complete set of parse trees
+// matching the labeled and unlabeled tags in the pattern for the parser
+// rule or token. For example, if label is {@code "foo"}, the result will
+// contain all of the following.</p>
+//
+// <ul>
+// <li>Parse tree nodes matching tags of the form {@code <foo:anyRuleName>}
+// and {@code <foo:AnyTokenName>}.</li>
+// <li>Parse tree nodes matching tags of the form
+// {@code <anyLabel:foo>}.</li>
+// <li>Parse tree nodes matching tags of the form {@code <foo>}.</li>
+// </ul>
+//
+// It returns an empty slice if no nodes matched the label.
+func (m *ParseTreeMatch) GetAll(label string) []ParseTree {
+	nodes := m.labels[label]
+	if nodes == nil {
+		return []ParseTree{}
+	}
+
+	return nodes
+}
+
+// GetLabels returns a mapping from label to matched nodes.
+//
+// <p>The map includes special entries corresponding to the names of rules
+// and tokens referenced in tags in the original pattern. For additional
+// information, see the description of GetAll.</p>
+func (m *ParseTreeMatch) GetLabels() map[string][]ParseTree {
+	return m.labels
+}
+
+// GetMismatchedNode gets the node at which we first detected a mismatch,
+// or nil if the match was successful.
+func (m *ParseTreeMatch) GetMismatchedNode() ParseTree {
+	return m.mismatchedNode
+}
+
+// Succeeded reports whether the match operation succeeded.
+func (m *ParseTreeMatch) Succeeded() bool {
+	return m.mismatchedNode == nil
+}
+
+// GetPattern gets the tree pattern we are matching against.
+func (m *ParseTreeMatch) GetPattern() *ParseTreePattern {
+	return m.pattern
+}
+
+// GetTree gets the parse tree we are trying to match to a pattern.
+func (m *ParseTreeMatch) GetTree() ParseTree {
+	return m.tree
+}
+
+func (m *ParseTreeMatch) String() string {
+	result := "failed"
+	if m.Succeeded() {
+		result = "succeeded"
+	}
+
+	return fmt.Sprintf("Match %s; found %d labels", result, len(m.labels))
+}
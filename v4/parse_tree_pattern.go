@@ -0,0 +1,100 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+// ParseTreePattern is a pattern like {@code <ID> = <expr>;} converted to a
+// ParseTree by ParseTreePatternMatcher.Compile.
+type ParseTreePattern struct {
+	// patternRuleIndex is the parser rule which serves as the outermost rule
+	// for the tree pattern.
+	patternRuleIndex int
+
+	// pattern is the tree pattern in concrete syntax form.
+	pattern string
+
+	// patternTree is the tree pattern as a ParseTree. The rule and token
+	// tags from the pattern are present in the parse tree as terminal nodes
+	// with a symbol of type RuleTagToken or TokenTagToken.
+	patternTree ParseTree
+
+	// matcher is the ParseTreePatternMatcher which created this tree
+	// pattern.
+	matcher *ParseTreePatternMatcher
+}
+
+// NewParseTreePattern constructs a new instance of the ParseTreePattern
+// struct.
+//
+// @param matcher The ParseTreePatternMatcher which created this tree
+// pattern.
+// @param pattern The tree pattern in concrete syntax form.
+// @param patternRuleIndex The parser rule which serves as the root of the
+// tree pattern.
+// @param patternTree The tree pattern in ParseTree form.
+func NewParseTreePattern(matcher *ParseTreePatternMatcher, pattern string, patternRuleIndex int, patternTree ParseTree) *ParseTreePattern {
+	return &ParseTreePattern{
+		matcher:          matcher,
+		patternRuleIndex: patternRuleIndex,
+		pattern:          pattern,
+		patternTree:      patternTree,
+	}
+}
+
+// Match matches a specific parse tree against this tree pattern. The
+// returned ParseTreeMatch describes the result of the match operation.
+// ParseTreeMatch.Succeeded is used to determine whether or not the match
+// was successful.
+func (p *ParseTreePattern) Match(tree ParseTree) *ParseTreeMatch {
+	return p.matcher.Match(tree, p)
+}
+
+// Matches determines whether or not a parse tree matches this tree
+// pattern.
+func (p *ParseTreePattern) Matches(tree ParseTree) bool {
+	return p.matcher.Match(tree, p).Succeeded()
+}
+
+// FindAll finds all nodes using XPath and then tries to match those
+// subtrees against this tree pattern. It returns the ParseTreeMatch of
+// every subtree that matched.
+//
+// @param tree The ParseTree to match against this pattern.
+// @param xpath An expression matching the nodes
+func (p *ParseTreePattern) FindAll(tree ParseTree, xpath string) []*ParseTreeMatch {
+	subtrees := XPathFindAll(tree, xpath, p.matcher.GetParser())
+	matches := make([]*ParseTreeMatch, 0)
+	for _, t := range subtrees {
+		match := p.Match(t)
+		if match.Succeeded() {
+			matches = append(matches, match)
+		}
+	}
+
+	return matches
+}
+
+// GetMatcher gets the ParseTreePatternMatcher which created this tree
+// pattern.
+func (p *ParseTreePattern) GetMatcher() *ParseTreePatternMatcher {
+	return p.matcher
+}
+
+// GetPattern gets the tree pattern in concrete syntax form.
+func (p *ParseTreePattern) GetPattern() string {
+	return p.pattern
+}
+
+// GetPatternRuleIndex gets the parser rule which serves as the outermost
+// rule for the tree pattern.
+func (p *ParseTreePattern) GetPatternRuleIndex() int {
+	return p.patternRuleIndex
+}
+
+// GetPatternTree gets the tree pattern as a ParseTree. The rule and token
+// tags from the pattern are present in the parse tree as terminal nodes
+// with a symbol of type RuleTagToken or TokenTagToken.
+func (p *ParseTreePattern) GetPatternTree() ParseTree {
+	return p.patternTree
+}
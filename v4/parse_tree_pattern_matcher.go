@@ -0,0 +1,528 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseTreePatternMatcher is a tree pattern matching mechanism for ANTLR
+// ParseTree instances.
+//
+// <p>Patterns are strings of source input text with special tags
+// representing token or rule references such as:</p>
+//
+// <p>{@code <ID> = <expr>;}</p>
+//
+// <p>Given a pattern start rule such as {@code statement}, this object
+// constructs a ParseTree with placeholders for the {@code ID} and
+// {@code expr} subtree. Then the Match routines can compare an actual
+// ParseTree from a parse with this pattern. Tag {@code <ID>} matches any
+// {@code ID} token and tag {@code <expr>} references the result of the
+// {@code expr} rule (generally an instance of {@code ExprContext}).</p>
+//
+// <p>Pattern {@code x = 0;} is a similar pattern that matches the same
+// pattern except that it requires the identifier to be {@code x} and the
+// expression to be {@code 0}.</p>
+//
+// <p>The Matches routines return {@code true} or {@code false} based upon a
+// match for the tree rooted at the parameter sent in. The Match routines
+// return a ParseTreeMatch object that contains the parse tree, the parse
+// tree pattern, and a map from tag name to matched nodes (more below). A
+// subtree that fails to match, returns with ParseTreeMatch.mismatchedNode
+// set to the first tree node that did not match.</p>
+//
+// <p>For efficiency, you can compile a tree pattern in string form to a
+// ParseTreePattern object. MatchPattern and MatchesPattern are easy to use
+// but not super efficient because they have to compile the pattern in
+// string form before using it.</p>
+//
+// <p>The lexer and parser that you pass into the ParseTreePatternMatcher
+// constructor are used to parse the pattern in string form. The lexer
+// converts the {@code <ID> = <expr>;} into a sequence of four tokens
+// (assuming lexer throws out whitespace or puts it on a hidden channel). Be
+// aware that the input stream is reset for the lexer (but not the parser; a
+// ParserInterpreter is created to parse the input.). Any user-defined fields
+// you have put into the lexer might get changed when this mechanism asks it
+// to scan the pattern string.</p>
+//
+// <p>Normally a parser does not accept token {@code <expr>} as a valid
+// {@code expr} but, from the parser passed in, we create a special version
+// of the underlying grammar representation (an ATN) that allows imaginary
+// tokens representing rules ({@code <expr>}) to match entire rules. We call
+// these bypass alternatives.</p>
+//
+// <p>Delimiters are {@code <} and {@code >}, with {@code \} as the escape
+// string by default, but you can set them to whatever you want using
+// SetDelimiters. You must escape both start and stop strings {@code \<}
+// and {@code \>}.</p>
+type ParseTreePatternMatcher struct {
+	// lexer is used to convert the text chunks of a pattern to tokens.
+	lexer Lexer
+
+	// parser supplies the rule and token names and the ATN with bypass
+	// alternatives used to parse patterns.
+	parser Parser
+
+	start  string
+	stop   string
+	escape string // e.g., \< and \> must escape BOTH!
+}
+
+// NewParseTreePatternMatcher constructs a ParseTreePatternMatcher from a
+// Lexer and Parser object. The lexer input stream is altered for tokenizing
+// the tree patterns. The parser is used as a convenient mechanism to get
+// the grammar name, plus token, rule names.
+func NewParseTreePatternMatcher(lexer Lexer, parser Parser) *ParseTreePatternMatcher {
+	return &ParseTreePatternMatcher{
+		lexer:  lexer,
+		parser: parser,
+		start:  "<",
+		stop:   ">",
+		escape: "\\",
+	}
+}
+
+// SetDelimiters sets the delimiters used for marking rule and token tags
+// within concrete syntax used by the tree pattern parser.
+//
+// @param start The start delimiter.
+// @param stop The stop delimiter.
+// @param escapeLeft The escape sequence to use for escaping a start or stop
+// delimiter.
+//
+// @panics if start or stop is empty.
+func (m *ParseTreePatternMatcher) SetDelimiters(start, stop, escapeLeft string) {
+	if start == "" {
+		panic("start cannot be empty")
+	}
+
+	if stop == "" {
+		panic("stop cannot be empty")
+	}
+
+	m.start = start
+	m.stop = stop
+	m.escape = escapeLeft
+}
+
+// GetLexer returns the lexer used to tokenize patterns.
+func (m *ParseTreePatternMatcher) GetLexer() Lexer {
+	return m.lexer
+}
+
+// GetParser returns the parser used to parse patterns.
+func (m *ParseTreePatternMatcher) GetParser() Parser {
+	return m.parser
+}
+
+// MatchesPattern reports whether pattern matches tree. The pattern is
+// compiled with patternRuleIndex as its start rule first.
+func (m *ParseTreePatternMatcher) MatchesPattern(tree ParseTree, pattern string, patternRuleIndex int) bool {
+	return m.Matches(tree, m.Compile(pattern, patternRuleIndex))
+}
+
+// Matches reports whether the compiled pattern matches tree. Use Match to
+// also learn which nodes the tags matched, or which node did not match.
+func (m *ParseTreePatternMatcher) Matches(tree ParseTree, pattern *ParseTreePattern) bool {
+	labels := make(map[string][]ParseTree)
+	mismatchedNode := m.matchImpl(tree, pattern.GetPatternTree(), labels)
+	return mismatchedNode == nil
+}
+
+// MatchPattern compares pattern matched as rule patternRuleIndex against
+// tree and returns a ParseTreeMatch object that contains the matched
+// elements, or the node at which the match failed.
+func (m *ParseTreePatternMatcher) MatchPattern(tree ParseTree, pattern string, patternRuleIndex int) *ParseTreeMatch {
+	return m.Match(tree, m.Compile(pattern, patternRuleIndex))
+}
+
+// Match compares the compiled pattern against tree and returns a
+// ParseTreeMatch object that contains the matched elements, or the node at
+// which the match failed. Pass in a compiled pattern instead of a string
+// representation of a tree pattern.
+func (m *ParseTreePatternMatcher) Match(tree ParseTree, pattern *ParseTreePattern) *ParseTreeMatch {
+	labels := make(map[string][]ParseTree)
+	mismatchedNode := m.matchImpl(tree, pattern.GetPatternTree(), labels)
+	return NewParseTreeMatch(tree, pattern, labels, mismatchedNode)
+}
+
+// Compile converts pattern to a ParseTreePattern whose tree is the result of
+// parsing the pattern starting at rule patternRuleIndex.
+//
+// @panics RecognitionException if the pattern cannot be parsed, and
+// *StartRuleDoesNotConsumeFullPattern if the rule does not consume the whole
+// pattern.
+func (m *ParseTreePatternMatcher) Compile(pattern string, patternRuleIndex int) *ParseTreePattern {
+	tokenList := m.Tokenize(pattern)
+	tokenSrc := NewListTokenSource(tokenList, "")
+	tokens := NewCommonTokenStream(tokenSrc, TokenDefaultChannel)
+
+	parserInterp := NewParserInterpreter(
+		m.parser.GetGrammarFileName(),
+		m.parser.GetLiteralNames(),
+		m.parser.GetSymbolicNames(),
+		m.parser.GetRuleNames(),
+		m.parser.GetATNWithBypassAlts(),
+		tokens)
+
+	parserInterp.SetErrorHandler(NewBailErrorStrategy())
+	tree := m.parsePattern(parserInterp, patternRuleIndex)
+
+	// Make sure tree pattern compilation checks for a complete parse
+	if tokens.LA(1) != TokenEOF {
+		panic(&StartRuleDoesNotConsumeFullPattern{})
+	}
+
+	return NewParseTreePattern(m, pattern, patternRuleIndex, tree)
+}
+
+func (m *ParseTreePatternMatcher) parsePattern(parserInterp *ParserInterpreter, patternRuleIndex int) ParseTree {
+	defer func() {
+		if r := recover(); r != nil {
+			switch e := r.(type) {
+			case *ParseCancellationException:
+				panic(e.GetCause())
+			case RecognitionException:
+				panic(e)
+			default:
+				panic(&CannotInvokeStartRule{cause: r})
+			}
+		}
+	}()
+
+	return parserInterp.Parse(patternRuleIndex)
+}
+
+// matchImpl recursively walks tree against patternTree, filling
+// labels. It returns the first node encountered in tree which does not
+// match a corresponding node in patternTree, or nil if the match was
+// successful. The specific node returned depends on the matching algorithm
+// used by the implementation, and may be overridden.
+func (m *ParseTreePatternMatcher) matchImpl(tree, patternTree ParseTree, labels map[string][]ParseTree) ParseTree {
+	if tree == nil {
+		panic("tree cannot be nil")
+	}
+
+	if patternTree == nil {
+		panic("patternTree cannot be nil")
+	}
+
+	// x and <ID>, x and y, or x and x; or could be mismatched types
+	t1, ok1 := tree.(TerminalNode)
+	t2, ok2 := patternTree.(TerminalNode)
+	if ok1 && ok2 {
+		// both are tokens and they have same type
+		if t1.GetSymbol().GetTokenType() != t2.GetSymbol().GetTokenType() {
+			return t1
+		}
+
+		if tokenTagToken, ok := t2.GetSymbol().(*TokenTagToken); ok { // x and <ID>
+			// track label->list-of-nodes for both token name and label (if any)
+			labels[tokenTagToken.GetTokenName()] = append(labels[tokenTagToken.GetTokenName()], tree)
+			if tokenTagToken.GetLabel() != "" {
+				labels[tokenTagToken.GetLabel()] = append(labels[tokenTagToken.GetLabel()], tree)
+			}
+		} else if t1.GetText() != t2.GetText() {
+			// x and y
+			return t1
+		}
+
+		// x and x
+		return nil
+	}
+
+	r1, ok1 := tree.(ParserRuleContext)
+	r2, ok2 := patternTree.(ParserRuleContext)
+	if ok1 && ok2 {
+		// (expr ...) and <expr>
+		if ruleTagToken := m.getRuleTagToken(r2); ruleTagToken != nil {
+			if r1.GetRuleIndex() != r2.GetRuleIndex() {
+				return r1
+			}
+
+			// track label->list-of-nodes for both rule name and label (if any)
+			labels[ruleTagToken.GetRuleName()] = append(labels[ruleTagToken.GetRuleName()], tree)
+			if ruleTagToken.GetLabel() != "" {
+				labels[ruleTagToken.GetLabel()] = append(labels[ruleTagToken.GetLabel()], tree)
+			}
+
+			return nil
+		}
+
+		// (expr ...) and (expr ...)
+		if r1.GetChildCount() != r2.GetChildCount() {
+			return r1
+		}
+
+		n := r1.GetChildCount()
+		for i := 0; i < n; i++ {
+			childMatch := m.matchImpl(r1.GetChild(i).(ParseTree), r2.GetChild(i).(ParseTree), labels)
+			if childMatch != nil {
+				return childMatch
+			}
+		}
+
+		return nil
+	}
+
+	// if nodes aren't both tokens or both rule nodes, can't match
+	return tree
+}
+
+// getRuleTagToken returns the RuleTagToken of t if t is a {@code (expr
+// <expr>)} subtree, and nil otherwise.
+func (m *ParseTreePatternMatcher) getRuleTagToken(t ParseTree) *RuleTagToken {
+	if r, ok := t.(RuleNode); ok {
+		if r.GetChildCount() == 1 {
+			if c, ok := r.GetChild(0).(TerminalNode); ok {
+				if ruleTagToken, ok := c.GetSymbol().(*RuleTagToken); ok {
+					return ruleTagToken
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Tokenize converts pattern to the list of tokens the pattern parser
+// consumes. Text chunks are run through the lexer; tags are replaced by
+// TokenTagToken and RuleTagToken instances.
+func (m *ParseTreePatternMatcher) Tokenize(pattern string) []Token {
+	// split pattern into chunks: sea (raw input) and islands (<ID>, <expr>)
+	chunks := m.Split(pattern)
+
+	// create token stream from text and tags
+	tokens := make([]Token, 0)
+	for _, chunk := range chunks {
+		switch c := chunk.(type) {
+		case *TagChunk:
+			// add special rule token or conjure up new token from name
+			first, _ := utf8.DecodeRuneInString(c.GetTag())
+			if unicode.IsUpper(first) {
+				ttype := m.parser.GetTokenType(c.GetTag())
+				if ttype == TokenInvalidType {
+					panic("Unknown token " + c.GetTag() + " in pattern: " + pattern)
+				}
+				tokens = append(tokens, NewTokenTagToken(c.GetTag(), ttype, c.GetLabel()))
+			} else if unicode.IsLower(first) {
+				ruleIndex := m.parser.GetRuleIndex(c.GetTag())
+				if ruleIndex == -1 {
+					panic("Unknown rule " + c.GetTag() + " in pattern: " + pattern)
+				}
+				ruleImaginaryTokenType := m.parser.GetATNWithBypassAlts().ruleToTokenType[ruleIndex]
+				tokens = append(tokens, NewRuleTagToken(c.GetTag(), ruleImaginaryTokenType, c.GetLabel()))
+			} else {
+				panic("invalid tag: " + c.GetTag() + " in pattern: " + pattern)
+			}
+
+		case *TextChunk:
+			m.lexer.SetInputStream(NewInputStream(c.GetText()))
+			t := m.lexer.NextToken()
+			for t.GetTokenType() != TokenEOF {
+				tokens = append(tokens, t)
+				t = m.lexer.NextToken()
+			}
+		}
+	}
+
+	return tokens
+}
+
+// Split splits {@code <ID> = <e:expr> ;} into 4 chunks for tokenizing by
+// Tokenize.
+func (m *ParseTreePatternMatcher) Split(pattern string) []Chunk {
+	p := 0
+	n := len(pattern)
+	chunks := make([]Chunk, 0)
+	// find all start and stop indexes first, then collect
+	starts := make([]int, 0)
+	stops := make([]int, 0)
+	for p < n {
+		if m.escape != "" && strings.HasPrefix(pattern[p:], m.escape+m.start) {
+			p += len(m.escape) + len(m.start)
+		} else if m.escape != "" && strings.HasPrefix(pattern[p:], m.escape+m.stop) {
+			p += len(m.escape) + len(m.stop)
+		} else if strings.HasPrefix(pattern[p:], m.start) {
+			starts = append(starts, p)
+			p += len(m.start)
+		} else if strings.HasPrefix(pattern[p:], m.stop) {
+			stops = append(stops, p)
+			p += len(m.stop)
+		} else {
+			p++
+		}
+	}
+
+	if len(starts) > len(stops) {
+		panic("unterminated tag in pattern: " + pattern)
+	}
+
+	if len(starts) < len(stops) {
+		panic("missing start tag in pattern: " + pattern)
+	}
+
+	ntags := len(starts)
+	for i := 0; i < ntags; i++ {
+		if starts[i] >= stops[i] {
+			panic("tag delimiters out of order in pattern: " + pattern)
+		}
+	}
+
+	// collect into chunks now
+	if ntags == 0 {
+		chunks = append(chunks, NewTextChunk(pattern))
+	}
+
+	if ntags > 0 && starts[0] > 0 { // copy text up to first tag into chunks
+		chunks = append(chunks, NewTextChunk(pattern[:starts[0]]))
+	}
+
+	for i := 0; i < ntags; i++ {
+		// copy inside of <tag>
+		tag := pattern[starts[i]+len(m.start) : stops[i]]
+		ruleOrToken := tag
+		label := ""
+		if colon := strings.Index(tag, ":"); colon >= 0 {
+			label = tag[:colon]
+			ruleOrToken = tag[colon+1:]
+		}
+		chunks = append(chunks, NewTagChunk(label, ruleOrToken))
+		if i+1 < ntags {
+			// copy from end of <tag> to start of next
+			chunks = append(chunks, NewTextChunk(pattern[stops[i]+len(m.stop):starts[i+1]]))
+		}
+	}
+
+	if ntags > 0 {
+		afterLastTag := stops[ntags-1] + len(m.stop)
+		if afterLastTag < n { // copy text from end of last tag to end
+			chunks = append(chunks, NewTextChunk(pattern[afterLastTag:]))
+		}
+	}
+
+	// strip out the escape sequences from text chunks but not tags
+	if m.escape != "" {
+		for i, c := range chunks {
+			if tc, ok := c.(*TextChunk); ok {
+				unescaped := strings.Replace(tc.GetText(), m.escape, "", -1)
+				if len(unescaped) < len(tc.GetText()) {
+					chunks[i] = NewTextChunk(unescaped)
+				}
+			}
+		}
+	}
+
+	return chunks
+}
+
+// CannotInvokeStartRule is panicked by ParseTreePatternMatcher.Compile when
+// parsing the pattern fails for a reason other than a RecognitionException.
+type CannotInvokeStartRule struct {
+	cause interface{}
+}
+
+// GetCause returns the value recovered while parsing the pattern.
+func (c *CannotInvokeStartRule) GetCause() interface{} {
+	return c.cause
+}
+
+func (c *CannotInvokeStartRule) Error() string {
+	return fmt.Sprintf("cannot invoke start rule: %v", c.cause)
+}
+
+// StartRuleDoesNotConsumeFullPattern is panicked by
+// ParseTreePatternMatcher.Compile when the pattern start rule does not
+// consume all of the tokens of the pattern.
+type StartRuleDoesNotConsumeFullPattern struct {
+}
+
+func (s *StartRuleDoesNotConsumeFullPattern) Error() string {
+	return "start rule does not consume full pattern"
+}
+
+// Chunk is a chunk of a tree pattern, either a TagChunk or a TextChunk.
+type Chunk interface {
+	String() string
+}
+
+// TagChunk represents a placeholder tag in a tree pattern. A tag can have
+// any of the following forms.
+//
+// <ul>
+// <li>{@code expr}: An unlabeled placeholder for a parser rule
+// {@code expr}.</li>
+// <li>{@code ID}: An unlabeled placeholder for a token of type
+// {@code ID}.</li>
+// <li>{@code e:expr}: A labeled placeholder for a parser rule
+// {@code expr}.</li>
+// <li>{@code id:ID}: A labeled placeholder for a token of type
+// {@code ID}.</li>
+// </ul>
+//
+// This struct does not perform any validation on the tag or label names
+// aside from ensuring that the tag is not empty.
+type TagChunk struct {
+	tag   string
+	label string
+}
+
+// NewTagChunk constructs a new instance of TagChunk with the specified
+// label and tag. An empty label means the tag is unlabeled.
+func NewTagChunk(label, tag string) *TagChunk {
+	if tag == "" {
+		panic("tag cannot be empty.")
+	}
+
+	return &TagChunk{tag: tag, label: label}
+}
+
+// GetTag gets the tag for this chunk.
+func (t *TagChunk) GetTag() string {
+	return t.tag
+}
+
+// GetLabel gets the label, if any, assigned to this chunk, or the empty
+// string if no label is assigned to the chunk.
+func (t *TagChunk) GetLabel() string {
+	return t.label
+}
+
+// String returns a text representation of the tag chunk. Labeled tags are
+// returned in the form {@code label:tag}, and unlabeled tags are returned
+// as just the tag name.
+func (t *TagChunk) String() string {
+	if t.label != "" {
+		return t.label + ":" + t.tag
+	}
+
+	return t.tag
+}
+
+// TextChunk represents a span of raw text (concrete syntax) between tags in
+// a tree pattern string.
+type TextChunk struct {
+	text string
+}
+
+// NewTextChunk constructs a new instance of TextChunk with the specified
+// text.
+func NewTextChunk(text string) *TextChunk {
+	return &TextChunk{text: text}
+}
+
+// GetText gets the raw text of this chunk.
+func (t *TextChunk) GetText() string {
+	return t.text
+}
+
+// String returns the result of GetText in single quotes.
+func (t *TextChunk) String() string {
+	return "'" + t.text + "'"
+}
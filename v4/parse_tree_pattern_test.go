@@ -0,0 +1,377 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTreePatternMatch(t *testing.T) {
+	matcher := NewParseTreePatternMatcher(newExprLexer(nil), newExprRecognizer())
+	pattern := NewParseTreePattern(matcher, "<ID> = <e:expr>;", ExprParserRULE_stat, buildExprTree("(stat <ID> = (expr <e:expr>) ;)"))
+	stat := buildExprTree("(stat x = (expr (expr 1) + (expr (expr 2) * (expr y))) ;)")
+
+	m := pattern.Match(stat)
+	if !m.Succeeded() {
+		t.Fatalf("expected match, mismatched node %v", m.GetMismatchedNode())
+	}
+	if got := m.Get("ID").GetText(); got != "x" {
+		t.Errorf("ID: expected x, got %s", got)
+	}
+	if got := m.Get("e").GetText(); got != "1+2*y" {
+		t.Errorf("e: expected 1+2*y, got %s", got)
+	}
+	if got := len(m.GetAll("expr")); got != 1 {
+		t.Errorf("expr: expected 1 node, got %d", got)
+	}
+	if got := len(m.GetAll("missing")); got != 0 {
+		t.Errorf("missing: expected no nodes, got %d", got)
+	}
+	if m.Get("missing") != nil {
+		t.Errorf("missing: expected nil")
+	}
+}
+
+func TestParseTreePatternMismatch(t *testing.T) {
+	matcher := NewParseTreePatternMatcher(newExprLexer(nil), newExprRecognizer())
+	pattern := NewParseTreePattern(matcher, "y = <expr>;", ExprParserRULE_stat, buildExprTree("(stat y = (expr <expr>) ;)"))
+
+	stat := buildExprTree("(stat x = (expr 1) ;)")
+	m := pattern.Match(stat)
+	if m.Succeeded() {
+		t.Fatalf("expected mismatch")
+	}
+	if got := m.GetMismatchedNode().GetText(); got != "x" {
+		t.Errorf("expected mismatch at x, got %s", got)
+	}
+	if pattern.Matches(stat) {
+		t.Errorf("expected Matches to be false")
+	}
+
+	// A statement of the other alternative has fewer children.
+	stat = buildExprTree("(stat (expr 1) ;)")
+	if got := pattern.Match(stat).GetMismatchedNode(); got != stat {
+		t.Errorf("expected mismatch at the statement, got %v", got)
+	}
+}
+
+func TestParseTreePatternTokenize(t *testing.T) {
+	m := NewParseTreePatternMatcher(newExprLexer(nil), newExprRecognizer())
+
+	tokens := m.Tokenize("<id:ID> = <INT>;")
+	if tag, ok := tokens[0].(*TokenTagToken); !ok || tag.GetTokenName() != "ID" || tag.GetLabel() != "id" {
+		t.Errorf("expected a token tag for ID labeled id, got %v", tokens[0])
+	}
+
+	// The pattern parser reads the tokens through a ListTokenSource, which
+	// ends them with EOF.
+	stream := NewCommonTokenStream(NewListTokenSource(tokens, ""), TokenDefaultChannel)
+	stream.Fill()
+	var types []int
+	for _, token := range stream.GetAllTokens() {
+		types = append(types, token.GetTokenType())
+	}
+	if len(types) != 5 || types[0] != 7 || types[1] != 1 || types[2] != 8 || types[3] != 2 || types[4] != TokenEOF {
+		t.Errorf("expected ID '=' INT ';' EOF, got %v", types)
+	}
+
+	// Rule tags get the token type of the bypass alternative of the rule.
+	tokens = m.Tokenize("<e:expr>")
+	if tag, ok := tokens[0].(*RuleTagToken); !ok || tag.GetTokenType() != newExprRecognizer().GetATNWithBypassAlts().ruleToTokenType[ExprParserRULE_expr] {
+		t.Errorf("expected a rule tag of the bypass token type of expr, got %v", tokens[0])
+	}
+
+	defer func() {
+		if r := recover(); r != "Unknown token FOO in pattern: <FOO>" {
+			t.Errorf("expected an unknown token panic, got %v", r)
+		}
+	}()
+	m.Tokenize("<FOO>")
+}
+
+func TestParseTreePatternCompile(t *testing.T) {
+	parser, tree := parseExpr("x = 1 + 2 * y; (a); z = 3;")
+
+	pattern := parser.CompileParseTreePattern("<ID> = <e:expr>;", ExprParserRULE_stat, nil)
+	if got := pattern.GetPatternTree().ToStringTree(nil, parser); got != "(stat <ID> = (expr <e:expr>) ;)" {
+		t.Errorf("expected the tags in the pattern tree, got %s", got)
+	}
+	matches := pattern.FindAll(tree, "//stat")
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(matches))
+	}
+	for i, e := range []string{"1+2*y", "3"} {
+		if got := matches[i].Get("e").GetText(); got != e {
+			t.Errorf("match %d: expected %s, got %s", i, e, got)
+		}
+	}
+
+	pattern = parser.CompileParseTreePattern("<expr> * <expr>", ExprParserRULE_expr, nil)
+	matches = pattern.FindAll(tree, "//expr")
+	if len(matches) != 1 || matches[0].GetTree().GetText() != "2*y" {
+		t.Errorf("expected a single match of 2*y, got %v", matches)
+	}
+
+	matcher := NewParseTreePatternMatcher(newExprLexer(nil), parser)
+	if stat := tree.GetChild(2).(ParseTree); !matcher.MatchesPattern(stat, "z = 3;", ExprParserRULE_stat) || matcher.MatchesPattern(stat, "z = 4;", ExprParserRULE_stat) {
+		t.Errorf("expected z = 3; to match only itself")
+	}
+}
+
+func TestParseTreePatternIncompleteParse(t *testing.T) {
+	parser, _ := parseExpr("")
+
+	defer func() {
+		if _, ok := recover().(*StartRuleDoesNotConsumeFullPattern); !ok {
+			t.Errorf("expected StartRuleDoesNotConsumeFullPattern")
+		}
+	}()
+	parser.CompileParseTreePattern("<ID> = <expr>; x", ExprParserRULE_stat, nil)
+}
+
+func TestParseTreePatternSplit(t *testing.T) {
+	m := NewParseTreePatternMatcher(newExprLexer(nil), newExprRecognizer())
+
+	chunks := m.Split("<ID> = <e:expr> ;")
+	expected := []string{"ID", "' = '", "e:expr", "' ;'"}
+	if len(chunks) != len(expected) {
+		t.Fatalf("expected %d chunks, got %v", len(expected), chunks)
+	}
+	for i, c := range chunks {
+		if c.String() != expected[i] {
+			t.Errorf("chunk %d: expected %s, got %s", i, expected[i], c)
+		}
+	}
+
+	chunks = m.Split("\\<x\\> <ID>")
+	if len(chunks) != 2 || chunks[0].(*TextChunk).GetText() != "<x> " {
+		t.Errorf("expected escaped delimiters to be text, got %v", chunks)
+	}
+}
+
+// The fixtures below are the serialized ATNs of the following grammar:
+//
+//	grammar Expr;
+//	prog : stat+ EOF ;
+//	stat : ID '=' expr ';' | expr ';' ;
+//	expr : expr '*' expr | expr '+' expr | INT | ID | '(' expr ')' ;
+//	ID   : [a-z]+ ;
+//	INT  : [0-9]+ ;
+//	WS   : [ \t\r\n]+ -> skip ;
+
+var exprSerializedLexerATN = []uint16{
+	3, 24715, 42794, 33075, 47597, 16764, 15335, 30598, 22884, 2, 11, 53, 8,
+	1, 4, 2, 9, 2, 4, 3, 9, 3, 4, 4, 9, 4, 4, 5, 9, 5, 4, 6, 9, 6, 4, 7, 9, 7,
+	4, 8, 9, 8, 4, 9, 9, 9, 4, 10, 9, 10, 3, 2, 3, 2, 3, 3, 3, 3, 3, 4, 3, 4,
+	3, 5, 3, 5, 3, 6, 3, 6, 3, 7, 3, 7, 3, 8, 3, 8, 6, 8, 36, 10, 8, 13, 8,
+	14, 8, 37, 3, 9, 3, 9, 6, 9, 42, 10, 9, 13, 9, 14, 9, 43, 3, 10, 3, 10, 6,
+	10, 48, 10, 10, 13, 10, 14, 10, 49, 3, 10, 3, 10, 2, 2, 11, 3, 3, 5, 4, 7,
+	5, 9, 6, 11, 7, 13, 8, 15, 9, 17, 10, 19, 11, 3, 2, 3, 5, 2, 11, 12, 15,
+	15, 34, 34, 2, 55, 2, 3, 3, 2, 2, 2, 2, 5, 3, 2, 2, 2, 2, 7, 3, 2, 2, 2,
+	2, 9, 3, 2, 2, 2, 2, 11, 3, 2, 2, 2, 2, 13, 3, 2, 2, 2, 2, 15, 3, 2, 2, 2,
+	2, 17, 3, 2, 2, 2, 2, 19, 3, 2, 2, 2, 3, 21, 3, 2, 2, 2, 5, 23, 3, 2, 2,
+	2, 7, 25, 3, 2, 2, 2, 9, 27, 3, 2, 2, 2, 11, 29, 3, 2, 2, 2, 13, 31, 3, 2,
+	2, 2, 15, 35, 3, 2, 2, 2, 17, 41, 3, 2, 2, 2, 19, 47, 3, 2, 2, 2, 21, 22,
+	7, 63, 2, 2, 22, 4, 3, 2, 2, 2, 23, 24, 7, 61, 2, 2, 24, 6, 3, 2, 2, 2,
+	25, 26, 7, 44, 2, 2, 26, 8, 3, 2, 2, 2, 27, 28, 7, 45, 2, 2, 28, 10, 3, 2,
+	2, 2, 29, 30, 7, 42, 2, 2, 30, 12, 3, 2, 2, 2, 31, 32, 7, 43, 2, 2, 32,
+	14, 3, 2, 2, 2, 33, 34, 4, 99, 124, 2, 34, 36, 3, 2, 2, 2, 35, 33, 3, 2,
+	2, 2, 36, 37, 3, 2, 2, 2, 37, 35, 3, 2, 2, 2, 37, 38, 3, 2, 2, 2, 38, 16,
+	3, 2, 2, 2, 39, 40, 4, 50, 59, 2, 40, 42, 3, 2, 2, 2, 41, 39, 3, 2, 2, 2,
+	42, 43, 3, 2, 2, 2, 43, 41, 3, 2, 2, 2, 43, 44, 3, 2, 2, 2, 44, 18, 3, 2,
+	2, 2, 45, 46, 9, 2, 2, 2, 46, 48, 3, 2, 2, 2, 47, 45, 3, 2, 2, 2, 48, 49,
+	3, 2, 2, 2, 49, 47, 3, 2, 2, 2, 49, 50, 3, 2, 2, 2, 50, 51, 3, 2, 2, 2,
+	51, 52, 8, 10, 2, 2, 52, 20, 3, 2, 2, 2, 9, 2, 35, 37, 41, 43, 47, 49, 3,
+	8, 2, 2,
+}
+var exprSerializedParserATN = []uint16{
+	3, 24715, 42794, 33075, 47597, 16764, 15335, 30598, 22884, 3, 11, 59, 4,
+	2, 9, 2, 4, 3, 9, 3, 4, 4, 9, 4, 3, 2, 3, 2, 6, 2, 11, 10, 2, 13, 2, 14,
+	2, 12, 3, 2, 3, 2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 5, 3, 29, 10, 3, 3, 4, 3, 4, 3, 4, 3, 4, 3, 4, 3, 4, 3,
+	4, 3, 4, 3, 4, 3, 4, 5, 4, 41, 10, 4, 3, 4, 3, 4, 3, 4, 3, 4, 3, 4, 3, 4,
+	3, 4, 3, 4, 3, 4, 3, 4, 3, 4, 3, 4, 12, 4, 7, 4, 56, 10, 4, 11, 4, 14, 4,
+	57, 2, 3, 6, 5, 2, 4, 6, 2, 2, 2, 62, 2, 10, 3, 2, 2, 2, 4, 28, 3, 2, 2,
+	2, 6, 40, 3, 2, 2, 2, 8, 9, 5, 4, 3, 2, 9, 11, 3, 2, 2, 2, 10, 8, 3, 2, 2,
+	2, 11, 12, 3, 2, 2, 2, 12, 10, 3, 2, 2, 2, 12, 13, 3, 2, 2, 2, 13, 14, 3,
+	2, 2, 2, 14, 15, 7, 2, 2, 3, 15, 3, 3, 2, 2, 2, 16, 17, 7, 9, 2, 2, 17,
+	18, 3, 2, 2, 2, 18, 19, 7, 3, 2, 2, 19, 20, 3, 2, 2, 2, 20, 21, 5, 6, 4,
+	2, 21, 22, 3, 2, 2, 2, 22, 23, 7, 4, 2, 2, 23, 29, 3, 2, 2, 2, 24, 25, 5,
+	6, 4, 2, 25, 26, 3, 2, 2, 2, 26, 27, 7, 4, 2, 2, 27, 29, 3, 2, 2, 2, 28,
+	16, 3, 2, 2, 2, 28, 24, 3, 2, 2, 2, 29, 5, 3, 2, 2, 2, 30, 31, 7, 10, 2,
+	2, 31, 41, 3, 2, 2, 2, 32, 33, 7, 9, 2, 2, 33, 41, 3, 2, 2, 2, 34, 35, 7,
+	7, 2, 2, 35, 36, 3, 2, 2, 2, 36, 37, 5, 6, 4, 2, 37, 38, 3, 2, 2, 2, 38,
+	39, 7, 8, 2, 2, 39, 41, 3, 2, 2, 2, 40, 30, 3, 2, 2, 2, 40, 32, 3, 2, 2,
+	2, 40, 34, 3, 2, 2, 2, 41, 54, 3, 2, 2, 2, 42, 43, 12, 4, 2, 2, 43, 44, 3,
+	2, 2, 2, 44, 45, 7, 5, 2, 2, 45, 46, 3, 2, 2, 2, 46, 47, 5, 6, 4, 5, 47,
+	56, 3, 2, 2, 2, 48, 49, 12, 3, 2, 2, 49, 50, 3, 2, 2, 2, 50, 51, 7, 6, 2,
+	2, 51, 52, 3, 2, 2, 2, 52, 53, 5, 6, 4, 4, 53, 56, 3, 2, 2, 2, 54, 55, 3,
+	2, 2, 2, 54, 58, 3, 2, 2, 2, 55, 42, 3, 2, 2, 2, 55, 48, 3, 2, 2, 2, 56,
+	57, 3, 2, 2, 2, 57, 54, 3, 2, 2, 2, 58, 7, 3, 2, 2, 2, 8, 10, 12, 28, 40,
+	54, 55,
+}
+
+var exprLexerATN = NewATNDeserializer(nil).DeserializeFromUInt16(exprSerializedLexerATN)
+var exprParserATN = NewATNDeserializer(nil).DeserializeFromUInt16(exprSerializedParserATN)
+
+var exprLiteralNames = []string{
+	"", "'='", "';'", "'*'", "'+'", "'('", "')'",
+}
+
+var exprSymbolicNames = []string{
+	"", "", "", "", "", "", "", "ID", "INT", "WS",
+}
+
+var exprLexerRuleNames = []string{
+	"T__0", "T__1", "T__2", "T__3", "T__4", "T__5", "ID", "INT", "WS",
+}
+
+var exprParserRuleNames = []string{
+	"prog", "stat", "expr",
+}
+
+// ExprParser rules.
+const (
+	ExprParserRULE_prog = 0
+	ExprParserRULE_stat = 1
+	ExprParserRULE_expr = 2
+)
+
+var exprChannelNames = []string{
+	"DEFAULT_TOKEN_CHANNEL", "HIDDEN",
+}
+
+var exprModeNames = []string{
+	"DEFAULT_MODE",
+}
+
+func newExprLexer(input CharStream) *LexerInterpreter {
+	return NewLexerInterpreter("Expr.g4", exprLiteralNames, exprSymbolicNames, exprLexerRuleNames, exprChannelNames, exprModeNames, exprLexerATN, input)
+}
+
+// parseExpr parses input starting at rule prog with a ParserInterpreter
+// for the Expr grammar.
+func parseExpr(input string) (*ParserInterpreter, ParserRuleContext) {
+	lexer := newExprLexer(NewInputStream(input))
+	stream := NewCommonTokenStream(lexer, TokenDefaultChannel)
+
+	parser := NewParserInterpreter("Expr.g4", exprLiteralNames, exprSymbolicNames, exprParserRuleNames, exprParserATN, stream)
+	parser.SerializedATN = exprSerializedParserATN
+
+	if input == "" {
+		return parser, nil
+	}
+
+	return parser, parser.Parse(ExprParserRULE_prog)
+}
+
+// newExprRecognizer returns a parser with the rule names, vocabulary and
+// serialized ATN of the Expr grammar.
+func newExprRecognizer() *BaseParser {
+	p := NewBaseParser(nil)
+	p.LiteralNames = exprLiteralNames
+	p.SymbolicNames = exprSymbolicNames
+	p.RuleNames = exprParserRuleNames
+	p.GrammarFileName = "Expr.g4"
+	p.SerializedATN = exprSerializedParserATN
+	p.Interpreter = NewParserATNSimulator(p, exprParserATN, nil, nil)
+
+	return p
+}
+
+// buildExprTree builds the Expr parse tree written in LISP form by s, such
+// as {@code (stat x = (expr 1) ;)}. Tokens that are parentheses are quoted,
+// as in {@code (expr '(' (expr 1) ')')}. The leaves {@code <ID>} and
+// {@code <id:ID>} are token tags, and {@code <expr>} and {@code <e:expr>}
+// rule tags, as in the trees of compiled tree patterns.
+func buildExprTree(s string) ParserRuleContext {
+	var fields []string
+	for i := 0; i < len(s); {
+		switch j := i + 1; s[i] {
+		case ' ':
+		case '(', ')':
+			fields = append(fields, s[i:j])
+		case '\'':
+			j = i + 1 + strings.IndexByte(s[i+1:], '\'') + 1
+			fields = append(fields, s[i:j])
+			i = j
+			continue
+		default:
+			for j < len(s) && !strings.ContainsRune(" ()", rune(s[j])) {
+				j++
+			}
+			fields = append(fields, s[i:j])
+			i = j
+			continue
+		}
+		i++
+	}
+
+	var build func(parent ParserRuleContext) ParserRuleContext
+	build = func(parent ParserRuleContext) ParserRuleContext {
+		ctx := NewBaseParserRuleContext(parent, -1)
+		for i, name := range exprParserRuleNames {
+			if name == fields[1] {
+				ctx.RuleIndex = i
+			}
+		}
+		fields = fields[2:]
+		for fields[0] != ")" {
+			if fields[0] == "(" {
+				ctx.AddChild(build(ctx))
+				continue
+			}
+			ctx.AddTokenNode(exprTreeToken(fields[0]))
+			fields = fields[1:]
+		}
+		fields = fields[1:]
+
+		return ctx
+	}
+
+	return build(nil)
+}
+
+// exprTreeToken returns the token or tag for a leaf of buildExprTree.
+func exprTreeToken(leaf string) Token {
+	if strings.HasPrefix(leaf, "<") && leaf != "<EOF>" {
+		name, label := leaf[1:len(leaf)-1], ""
+		if colon := strings.Index(name, ":"); colon >= 0 {
+			name, label = name[colon+1:], name[:colon]
+		}
+		for i, ruleName := range exprParserRuleNames {
+			if ruleName == name {
+				// the token type of the bypass alternative of the rule
+				return NewRuleTagToken(name, len(exprSymbolicNames)+i, label)
+			}
+		}
+		for i, symbolicName := range exprSymbolicNames {
+			if symbolicName == name {
+				return NewTokenTagToken(name, i, label)
+			}
+		}
+		panic("unknown tag " + leaf)
+	}
+
+	text := strings.Trim(leaf, "'")
+	ttype := 7 // ID
+	switch {
+	case leaf == "<EOF>":
+		ttype = TokenEOF
+	case text[0] >= '0' && text[0] <= '9':
+		ttype = 8 // INT
+	}
+	for i, literalName := range exprLiteralNames {
+		if literalName == "'"+text+"'" {
+			ttype = i
+		}
+	}
+
+	token := NewCommonToken(nil, ttype, TokenDefaultChannel, -1, -1)
+	token.SetText(text)
+
+	return token
+}
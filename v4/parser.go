@@ -5,8 +5,10 @@
 package antlr
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"sync"
 )
 
 type Parser interface {
@@ -15,6 +17,7 @@ type Parser interface {
 	GetInterpreter() *ParserATNSimulator
 
 	GetTokenStream() TokenStream
+	SetTokenStream(TokenStream)
 	GetTokenFactory() TokenFactory
 	GetParserRuleContext() ParserRuleContext
 	SetParserRuleContext(ParserRuleContext)
@@ -30,6 +33,9 @@ type Parser interface {
 	IsExpectedToken(int) bool
 	GetPrecedence() int
 	GetRuleInvocationStack(ParserRuleContext) []string
+	GetRuleIndex(string) int
+	GetATNWithBypassAlts() *ATN
+	GetContext() context.Context
 }
 
 type BaseParser struct {
@@ -38,6 +44,12 @@ type BaseParser struct {
 	Interpreter     *ParserATNSimulator
 	BuildParseTrees bool
 
+	// SerializedATN is the serialized form of the parser's ATN, used by
+	// features that need to deserialize the ATN again with different
+	// options, such as GetATNWithBypassAlts and tree patterns. If it is nil,
+	// they serialize the ATN with an ATNSerializer instead.
+	SerializedATN []uint16
+
 	input           TokenStream
 	errHandler      ErrorStrategy
 	precedenceStack IntStack
@@ -46,6 +58,12 @@ type BaseParser struct {
 	tracer         *TraceListener
 	parseListeners []ParseTreeListener
 	_SyntaxErrors  int
+
+	// cancelCtx is the context.Context that cancels the parse when done.
+	cancelCtx context.Context
+
+	// coverage records the grammar coverage of the parse, or is nil.
+	coverage *GrammarCoverage
 }
 
 // p.is all the parsing support code essentially most of it is error
@@ -86,13 +104,17 @@ func NewBaseParser(input TokenStream) *BaseParser {
 	return p
 }
 
-// p.field maps from the serialized ATN string to the deserialized {@link
-// ATN} with
+// p.field maps from the ATN of a parser, which every instance of a
+// generated parser shares, to the deserialized {@link ATN} with
 // bypass alternatives.
 //
 // @see ATNDeserializationOptions//isGenerateRuleBypassTransitions()
 //
-var bypassAltsAtnCache = make(map[string]int)
+var bypassAltsAtnCache = make(map[*ATN]*ATN)
+
+// bypassAltsAtnCacheMu guards bypassAltsAtnCache, which is shared by every
+// parser instance.
+var bypassAltsAtnCacheMu sync.RWMutex
 
 // reset the parser's state//
 func (p *BaseParser) reset() {
@@ -110,6 +132,22 @@ func (p *BaseParser) reset() {
 	}
 }
 
+// SetContext sets the context.Context that cancels the parse. Once ctx is
+// done, the parser panics with a *ParseCancellationException wrapping
+// ctx.Err() the next time it consumes a token, enters a rule or predicts
+// an alternative. A nil ctx, the default, never cancels the parse.
+//
+// <p>Adaptive prediction checks ctx for every token of lookahead, so even
+// a long SLL or LL prediction stops soon after ctx is done.</p>
+func (p *BaseParser) SetContext(ctx context.Context) {
+	p.cancelCtx = ctx
+}
+
+// GetContext returns the context.Context set with SetContext, or nil.
+func (p *BaseParser) GetContext() context.Context {
+	return p.cancelCtx
+}
+
 func (p *BaseParser) GetErrorHandler() ErrorStrategy {
 	return p.errHandler
 }
@@ -127,7 +165,7 @@ func (p *BaseParser) SetErrorHandler(e ErrorStrategy) {
 // strategy to attempt recovery. If {@link //getBuildParseTree} is
 // {@code true} and the token index of the symbol returned by
 // {@link ANTLRErrorStrategy//recoverInline} is -1, the symbol is added to
-// the parse tree by calling {@link ParserRuleContext//addErrorNode}.</p>
+// the parse tree by calling {@link ParserRuleContext//AddMissingNode}.</p>
 //
 // @param ttype the token type to Match
 // @return the Matched symbol
@@ -140,6 +178,9 @@ func (p *BaseParser) Match(ttype int) Token {
 	t := p.GetCurrentToken()
 
 	if t.GetTokenType() == ttype {
+		if p.coverage != nil {
+			p.coverage.match(p.GetState())
+		}
 		p.errHandler.ReportMatch(p)
 		p.Consume()
 	} else {
@@ -148,7 +189,7 @@ func (p *BaseParser) Match(ttype int) Token {
 			// we must have conjured up a Newtoken during single token
 			// insertion
 			// if it's not the current symbol
-			p.ctx.AddErrorNode(t)
+			p.ctx.AddMissingNode(t)
 		}
 	}
 
@@ -164,7 +205,7 @@ func (p *BaseParser) Match(ttype int) Token {
 // strategy to attempt recovery. If {@link //getBuildParseTree} is
 // {@code true} and the token index of the symbol returned by
 // {@link ANTLRErrorStrategy//recoverInline} is -1, the symbol is added to
-// the parse tree by calling {@link ParserRuleContext//addErrorNode}.</p>
+// the parse tree by calling {@link ParserRuleContext//AddMissingNode}.</p>
 //
 // @return the Matched symbol
 // @panics RecognitionException if the current input symbol did not Match
@@ -174,6 +215,9 @@ func (p *BaseParser) Match(ttype int) Token {
 func (p *BaseParser) MatchWildcard() Token {
 	t := p.GetCurrentToken()
 	if t.GetTokenType() > 0 {
+		if p.coverage != nil {
+			p.coverage.match(p.GetState())
+		}
 		p.errHandler.ReportMatch(p)
 		p.Consume()
 	} else {
@@ -182,7 +226,7 @@ func (p *BaseParser) MatchWildcard() Token {
 			// we must have conjured up a Newtoken during single token
 			// insertion
 			// if it's not the current symbol
-			p.ctx.AddErrorNode(t)
+			p.ctx.AddMissingNode(t)
 		}
 	}
 	return t
@@ -326,59 +370,71 @@ func (p *BaseParser) SetTokenFactory(factory TokenFactory) {
 }
 
 // The ATN with bypass alternatives is expensive to create so we create it
-// lazily.
+// lazily, once for all the parsers sharing an ATN.
 //
-// @panics UnsupportedOperationException if the current parser does not
-// implement the {@link //getSerializedATN()} method.
+// @panics if the current parser has no ATN.
 //
-func (p *BaseParser) GetATNWithBypassAlts() {
+func (p *BaseParser) GetATNWithBypassAlts() *ATN {
+	if p.Interpreter == nil {
+		panic("The current parser has no ATN to add bypass alternatives to.")
+	}
+
+	atn := p.GetATN()
 
-	// TODO
-	panic("Not implemented!")
+	bypassAltsAtnCacheMu.RLock()
+	result, ok := bypassAltsAtnCache[atn]
+	bypassAltsAtnCacheMu.RUnlock()
+	if ok {
+		return result
+	}
+
+	bypassAltsAtnCacheMu.Lock()
+	defer bypassAltsAtnCacheMu.Unlock()
+
+	// Another parser may have deserialized the ATN while we were waiting
+	// for the lock.
+	if result, ok = bypassAltsAtnCache[atn]; !ok {
+		serializedATN := p.SerializedATN
+		if serializedATN == nil {
+			serializedATN = NewATNSerializer(atn).Serialize()
+		}
+
+		deserializationOptions := NewATNDeserializationOptions(nil)
+		deserializationOptions.generateRuleBypassTransitions = true
+		result = NewATNDeserializer(deserializationOptions).DeserializeFromUInt16(serializedATN)
+		bypassAltsAtnCache[atn] = result
+	}
 
-	//	serializedAtn := p.getSerializedATN()
-	//	if (serializedAtn == nil) {
-	//		panic("The current parser does not support an ATN with bypass alternatives.")
-	//	}
-	//	result := p.bypassAltsAtnCache[serializedAtn]
-	//	if (result == nil) {
-	//		deserializationOptions := NewATNDeserializationOptions(nil)
-	//		deserializationOptions.generateRuleBypassTransitions = true
-	//		result = NewATNDeserializer(deserializationOptions).deserialize(serializedAtn)
-	//		p.bypassAltsAtnCache[serializedAtn] = result
-	//	}
-	//	return result
+	return result
 }
 
 // The preferred method of getting a tree pattern. For example, here's a
 // sample use:
 //
 // <pre>
-// ParseTree t = parser.expr()
-// ParseTreePattern p = parser.compileParseTreePattern("&ltID&gt+0",
-// MyParser.RULE_expr)
-// ParseTreeMatch m = p.Match(t)
-// String id = m.Get("ID")
+// t := parser.Expr()
+// p := parser.CompileParseTreePattern("&ltID&gt+0", MyParserRULE_expr, nil)
+// m := p.Match(t)
+// id := m.Get("ID")
 // </pre>
+//
+// If lexer is nil, the token source of the parser's token stream is used
+// when it is a {@link Lexer}.
+//
+func (p *BaseParser) CompileParseTreePattern(pattern string, patternRuleIndex int, lexer Lexer) *ParseTreePattern {
+	if lexer == nil {
+		if p.GetTokenStream() != nil {
+			if l, ok := p.GetTokenStream().GetTokenSource().(Lexer); ok {
+				lexer = l
+			}
+		}
+	}
+	if lexer == nil {
+		panic("Parser can't discover a lexer to use")
+	}
 
-func (p *BaseParser) compileParseTreePattern(pattern, patternRuleIndex, lexer Lexer) {
-
-	panic("NewParseTreePatternMatcher not implemented!")
-	//
-	//	if (lexer == nil) {
-	//		if (p.GetTokenStream() != nil) {
-	//			tokenSource := p.GetTokenStream().GetTokenSource()
-	//			if _, ok := tokenSource.(ILexer); ok {
-	//				lexer = tokenSource
-	//			}
-	//		}
-	//	}
-	//	if (lexer == nil) {
-	//		panic("Parser can't discover a lexer to use")
-	//	}
-
-	//	m := NewParseTreePatternMatcher(lexer, p)
-	//	return m.compile(pattern, patternRuleIndex)
+	m := NewParseTreePatternMatcher(lexer, p)
+	return m.Compile(pattern, patternRuleIndex)
 }
 
 func (p *BaseParser) GetInputStream() IntStream {
@@ -400,6 +456,13 @@ func (p *BaseParser) SetTokenStream(input TokenStream) {
 	p.input = input
 }
 
+// replaceTokenStream replaces the token stream in the middle of a parse,
+// without resetting the parser as SetTokenStream does. RepairErrorStrategy
+// serves the tokens it conjures up this way.
+func (p *BaseParser) replaceTokenStream(input TokenStream) {
+	p.input = input
+}
+
 // Match needs to return the current input symbol, which gets put
 // into the label for the associated token ref e.g., x=ID.
 //
@@ -419,13 +482,23 @@ func (p *BaseParser) NotifyErrorListeners(msg string, offendingToken Token, err
 }
 
 func (p *BaseParser) Consume() Token {
+	checkContext(p.cancelCtx)
+
 	o := p.GetCurrentToken()
 	if o.GetTokenType() != TokenEOF {
 		p.GetInputStream().Consume()
 	}
 	hasListener := p.parseListeners != nil && len(p.parseListeners) > 0
 	if p.BuildParseTrees || hasListener {
-		if p.errHandler.inErrorRecoveryMode(p) {
+		if IsMissingToken(o) {
+			// a token conjured up into the input by the error strategy
+			node := p.ctx.AddMissingNode(o)
+			if p.parseListeners != nil {
+				for _, l := range p.parseListeners {
+					visitMissingNode(l, node)
+				}
+			}
+		} else if p.errHandler.inErrorRecoveryMode(p) {
 			node := p.ctx.AddErrorNode(o)
 			if p.parseListeners != nil {
 				for _, l := range p.parseListeners {
@@ -455,6 +528,8 @@ func (p *BaseParser) addContextToParseTree() {
 }
 
 func (p *BaseParser) EnterRule(localctx ParserRuleContext, state, ruleIndex int) {
+	checkContext(p.cancelCtx)
+
 	p.SetState(state)
 	p.ctx = localctx
 	p.ctx.SetStart(p.input.LT(1))
@@ -482,6 +557,9 @@ func (p *BaseParser) ExitRule() {
 
 func (p *BaseParser) EnterOuterAlt(localctx ParserRuleContext, altNum int) {
 	localctx.SetAltNumber(altNum)
+	if p.coverage != nil {
+		p.coverage.enterOuterAlt(localctx.GetRuleIndex(), altNum)
+	}
 	// if we have Newlocalctx, make sure we replace existing ctx
 	// that is previous child of parse tree
 	if p.BuildParseTrees && p.ctx != localctx {
@@ -507,6 +585,8 @@ func (p *BaseParser) GetPrecedence() int {
 }
 
 func (p *BaseParser) EnterRecursionRule(localctx ParserRuleContext, state, ruleIndex, precedence int) {
+	checkContext(p.cancelCtx)
+
 	p.SetState(state)
 	p.precedenceStack.Push(precedence)
 	p.ctx = localctx
@@ -701,6 +781,47 @@ func (p *BaseParser) GetSourceName() string {
 	return p.GrammarFileName
 }
 
+// SetProfile turns profiling of the parser's predictions on or off. When
+// profiling is on, the parser's Interpreter is replaced by the
+// ParserATNSimulator of a ProfilingATNSimulator, and GetParseInfo returns
+// the statistics it collects. The prediction mode, DFA and prediction
+// context cache are kept either way.
+func (p *BaseParser) SetProfile(profile bool) {
+	interp := p.Interpreter
+	saveMode := interp.GetPredictionMode()
+	if profile {
+		if interp.profiler == nil {
+			p.Interpreter = NewProfilingATNSimulator(interp.parser).ParserATNSimulator
+		}
+	} else if interp.profiler != nil {
+		p.Interpreter = NewParserATNSimulator(interp.parser, interp.atn, interp.decisionToDFA, interp.sharedContextCache)
+	}
+	p.Interpreter.SetPredictionMode(saveMode)
+}
+
+// GetParseInfo returns the profiling statistics of the parser's
+// predictions, or nil if the parser is not profiling. See SetProfile.
+func (p *BaseParser) GetParseInfo() *ParseInfo {
+	if p.Interpreter != nil && p.Interpreter.profiler != nil {
+		return NewParseInfo(p.Interpreter.profiler)
+	}
+
+	return nil
+}
+
+// SetCoverage sets the GrammarCoverage recording the rules, alternatives,
+// decision outcomes and token references the parser exercises, or turns
+// recording off if coverage is nil. Parsers of the same grammar may share
+// one GrammarCoverage.
+func (p *BaseParser) SetCoverage(coverage *GrammarCoverage) {
+	p.coverage = coverage
+}
+
+// GetCoverage returns the GrammarCoverage set by SetCoverage, or nil.
+func (p *BaseParser) GetCoverage() *GrammarCoverage {
+	return p.coverage
+}
+
 // During a parse is sometimes useful to listen in on the rule entry and exit
 // events as well as token Matches. p.is for quick and dirty debugging.
 //
@@ -27,6 +27,10 @@ type ParserATNSimulator struct {
 	dfa            *DFA
 	mergeCache     *DoubleDict
 	outerContext   ParserRuleContext
+
+	// profiler collects statistics about every prediction if the simulator
+	// was created by NewProfilingATNSimulator.
+	profiler *ProfilingATNSimulator
 }
 
 func NewParserATNSimulator(parser Parser, atn *ATN, decisionToDFA []*DFA, sharedContextCache *PredictionContextCache) *ParserATNSimulator {
@@ -69,6 +73,37 @@ func (p *ParserATNSimulator) reset() {
 }
 
 func (p *ParserATNSimulator) AdaptivePredict(input TokenStream, decision int, outerContext ParserRuleContext) int {
+	var alt int
+	if p.profiler != nil {
+		alt = p.profiler.adaptivePredict(input, decision, outerContext)
+	} else {
+		alt = p.adaptivePredict(input, decision, outerContext)
+	}
+
+	if covered, ok := p.parser.(coveredParser); ok {
+		if coverage := covered.GetCoverage(); coverage != nil {
+			coverage.predict(decision, alt)
+		}
+	}
+
+	return alt
+}
+
+// coveredParser is implemented by parsers that may record their grammar
+// coverage, such as those embedding BaseParser.
+type coveredParser interface {
+	GetCoverage() *GrammarCoverage
+}
+
+// checkContext panics with a ParseCancellationException if the parser's
+// context.Context is done.
+func (p *ParserATNSimulator) checkContext() {
+	if p.parser != nil {
+		checkContext(p.parser.GetContext())
+	}
+}
+
+func (p *ParserATNSimulator) adaptivePredict(input TokenStream, decision int, outerContext ParserRuleContext) int {
 	if ParserATNSimulatorDebug || ParserATNSimulatorListATNDecisions {
 		fmt.Println("AdaptivePredict decision " + strconv.Itoa(decision) +
 			" exec LA(1)==" + p.getLookaheadName(input) +
@@ -76,6 +111,8 @@ func (p *ParserATNSimulator) AdaptivePredict(input TokenStream, decision int, ou
 			strconv.Itoa(input.LT(1).GetColumn()))
 	}
 
+	p.checkContext()
+
 	p.input = input
 	p.startIndex = input.Index()
 	p.outerContext = outerContext
@@ -101,7 +138,7 @@ func (p *ParserATNSimulator) AdaptivePredict(input TokenStream, decision int, ou
 		s0 = dfa.getPrecedenceStartState(p.parser.GetPrecedence())
 	} else {
 		// the start state for a "regular" DFA is just s0
-		s0 = dfa.s0
+		s0 = dfa.getS0()
 	}
 
 	if s0 == nil {
@@ -113,18 +150,6 @@ func (p *ParserATNSimulator) AdaptivePredict(input TokenStream, decision int, ou
 				" exec LA(1)==" + p.getLookaheadName(input) +
 				", outerContext=" + outerContext.String(p.parser.GetRuleNames(), nil))
 		}
-		// If p is not a precedence DFA, we check the ATN start state
-		// to determine if p ATN start state is the decision for the
-		// closure block that determines whether a precedence rule
-		// should continue or complete.
-
-		t2 := dfa.atnStartState
-		t, ok := t2.(*StarLoopEntryState)
-		if !dfa.precedenceDfa && ok {
-			if t.precedenceRuleDecision {
-				dfa.setPrecedenceDfa(true)
-			}
-		}
 		fullCtx := false
 		s0Closure := p.computeStartState(dfa.atnStartState, RuleContextEmpty, fullCtx)
 
@@ -140,7 +165,7 @@ func (p *ParserATNSimulator) AdaptivePredict(input TokenStream, decision int, ou
 			dfa.setPrecedenceStartState(p.parser.GetPrecedence(), s0)
 		} else {
 			s0 = p.addDFAState(dfa, NewDFAState(-1, s0Closure))
-			dfa.s0 = s0
+			dfa.setS0(s0)
 		}
 	}
 	alt := p.execATN(dfa, s0, input, index, outerContext)
@@ -196,6 +221,7 @@ func (p *ParserATNSimulator) execATN(dfa *DFA, s0 *DFAState, input TokenStream,
 	}
 	t := input.LA(1)
 	for { // for more work
+		p.checkContext()
 		D := p.getExistingTargetState(previousD, t)
 		if D == nil {
 			D = p.computeTargetState(dfa, previousD, t)
@@ -276,8 +302,6 @@ func (p *ParserATNSimulator) execATN(dfa *DFA, s0 *DFAState, input TokenStream,
 			t = input.LA(1)
 		}
 	}
-
-	panic("Should not have reached p state")
 }
 
 // Get an existing target state for an edge in the DFA. If the target state
@@ -291,12 +315,12 @@ func (p *ParserATNSimulator) execATN(dfa *DFA, s0 *DFAState, input TokenStream,
 // already cached
 
 func (p *ParserATNSimulator) getExistingTargetState(previousD *DFAState, t int) *DFAState {
-	edges := previousD.edges
-	if edges == nil {
-		return nil
+	D := previousD.getEdge(t + 1)
+	if p.profiler != nil {
+		p.profiler.existingTargetState(previousD, D)
 	}
 
-	return edges[t+1]
+	return D
 }
 
 // Compute a target state for an edge in the DFA, and attempt to add the
@@ -312,9 +336,15 @@ func (p *ParserATNSimulator) getExistingTargetState(previousD *DFAState, t int)
 
 func (p *ParserATNSimulator) computeTargetState(dfa *DFA, previousD *DFAState, t int) *DFAState {
 	reach := p.computeReachSet(previousD.configs, t, false)
+	if p.profiler != nil {
+		p.profiler.reachSet(previousD.configs, reach, false)
+	}
 
 	if reach == nil {
 		p.addDFAEdge(dfa, previousD, t, ATNSimulatorError)
+		if p.profiler != nil {
+			p.profiler.currentState = ATNSimulatorError
+		}
 		return ATNSimulatorError
 	}
 	// create Newtarget state we'll add to DFA after it's complete
@@ -353,6 +383,9 @@ func (p *ParserATNSimulator) computeTargetState(dfa *DFA, previousD *DFAState, t
 	}
 	// all adds to dfa are done after we've created full D state
 	D = p.addDFAEdge(dfa, previousD, t, D)
+	if p.profiler != nil {
+		p.profiler.currentState = D
+	}
 	return D
 }
 
@@ -391,7 +424,15 @@ func (p *ParserATNSimulator) execATNWithFullContext(dfa *DFA, D *DFAState, s0 AT
 	predictedAlt := -1
 
 	for { // for more work
+		p.checkContext()
+		if p.profiler != nil {
+			// the input position advanced during full context prediction
+			p.profiler.llStopIndex = input.Index()
+		}
 		reach = p.computeReachSet(previous, t, fullCtx)
+		if p.profiler != nil {
+			p.profiler.reachSet(previous, reach, fullCtx)
+		}
 		if reach == nil {
 			// if any configs in previous dipped into outer context, that
 			// means that input up to t actually finished entry rule
@@ -955,7 +996,8 @@ func (p *ParserATNSimulator) evalSemanticContext(predPredictions []*PredPredicti
 			continue
 		}
 
-		predicateEvaluationResult := pair.pred.evaluate(p.parser, outerContext)
+		fullCtx := false // in dfa
+		predicateEvaluationResult := p.evalPredicate(pair.pred, outerContext, pair.alt, fullCtx)
 		if ParserATNSimulatorDebug || ParserATNSimulatorDFADebug {
 			fmt.Println("eval pred " + pair.String() + "=" + fmt.Sprint(predicateEvaluationResult))
 		}
@@ -972,6 +1014,18 @@ func (p *ParserATNSimulator) evalSemanticContext(predPredictions []*PredPredicti
 	return predictions
 }
 
+// evalPredicate evaluates pred in the context of parserCallStack. alt is
+// the alternative pred guards, and fullCtx is true if pred is evaluated
+// during full context prediction.
+func (p *ParserATNSimulator) evalPredicate(pred SemanticContext, parserCallStack ParserRuleContext, alt int, fullCtx bool) bool {
+	result := pred.evaluate(p.parser, parserCallStack)
+	if p.profiler != nil {
+		p.profiler.predicateEvaluated(pred, result, alt, fullCtx)
+	}
+
+	return result
+}
+
 func (p *ParserATNSimulator) closure(config ATNConfig, configs ATNConfigSet, closureBusy *Set, collectPredicates, fullCtx, treatEOFAsEpsilon bool) {
 	initialDepth := 0
 	p.closureCheckingStopState(config, configs, closureBusy, collectPredicates,
@@ -1168,7 +1222,7 @@ func (p *ParserATNSimulator) precedenceTransition(config ATNConfig,
 			// later during conflict resolution.
 			currentPosition := p.input.Index()
 			p.input.Seek(p.startIndex)
-			predSucceeds := pt.getPredicate().evaluate(p.parser, p.outerContext)
+			predSucceeds := p.evalPredicate(pt.getPredicate(), p.outerContext, config.GetAlt(), fullCtx)
 			p.input.Seek(currentPosition)
 			if predSucceeds {
 				c = NewBaseATNConfig4(config, pt.getTarget()) // no pred context
@@ -1204,7 +1258,7 @@ func (p *ParserATNSimulator) predTransition(config ATNConfig, pt *PredicateTrans
 			// later during conflict resolution.
 			currentPosition := p.input.Index()
 			p.input.Seek(p.startIndex)
-			predSucceeds := pt.getPredicate().evaluate(p.parser, p.outerContext)
+			predSucceeds := p.evalPredicate(pt.getPredicate(), p.outerContext, config.GetAlt(), fullCtx)
 			p.input.Seek(currentPosition)
 			if predSucceeds {
 				c = NewBaseATNConfig4(config, pt.getTarget()) // no pred context
@@ -1385,10 +1439,7 @@ func (p *ParserATNSimulator) addDFAEdge(dfa *DFA, from *DFAState, t int, to *DFA
 	if from == nil || t < -1 || t > p.atn.maxTokenType {
 		return to
 	}
-	if from.edges == nil {
-		from.edges = make([]*DFAState, p.atn.maxTokenType+1+1)
-	}
-	from.edges[t+1] = to // connect
+	from.setEdge(t+1, p.atn.maxTokenType+1+1, to) // connect
 
 	if ParserATNSimulatorDebug {
 		var names []string
@@ -1420,24 +1471,21 @@ func (p *ParserATNSimulator) addDFAState(dfa *DFA, d *DFAState) *DFAState {
 	if d == ATNSimulatorError {
 		return d
 	}
-	hash := d.hash()
-	existing, ok := dfa.getState(hash)
-	if ok {
-		return existing
-	}
-	d.stateNumber = dfa.numStates()
 	if !d.configs.ReadOnly() {
 		d.configs.OptimizeConfigs(p.BaseATNSimulator)
 		d.configs.SetReadOnly(true)
 	}
-	dfa.setState(hash, d)
-	if ParserATNSimulatorDebug {
+	added := dfa.addStateIfAbsent(d)
+	if ParserATNSimulatorDebug && added == d {
 		fmt.Println("adding NewDFA state: " + d.String())
 	}
-	return d
+	return added
 }
 
 func (p *ParserATNSimulator) ReportAttemptingFullContext(dfa *DFA, conflictingAlts *BitSet, configs ATNConfigSet, startIndex, stopIndex int) {
+	if p.profiler != nil {
+		p.profiler.attemptingFullContext(conflictingAlts, configs)
+	}
 	if ParserATNSimulatorDebug || ParserATNSimulatorRetryDebug {
 		interval := NewInterval(startIndex, stopIndex+1)
 		fmt.Println("ReportAttemptingFullContext decision=" + strconv.Itoa(dfa.decision) + ":" + configs.String() +
@@ -1449,6 +1497,9 @@ func (p *ParserATNSimulator) ReportAttemptingFullContext(dfa *DFA, conflictingAl
 }
 
 func (p *ParserATNSimulator) ReportContextSensitivity(dfa *DFA, prediction int, configs ATNConfigSet, startIndex, stopIndex int) {
+	if p.profiler != nil {
+		p.profiler.contextSensitivity(prediction, configs, startIndex, stopIndex)
+	}
 	if ParserATNSimulatorDebug || ParserATNSimulatorRetryDebug {
 		interval := NewInterval(startIndex, stopIndex+1)
 		fmt.Println("ReportContextSensitivity decision=" + strconv.Itoa(dfa.decision) + ":" + configs.String() +
@@ -1462,6 +1513,9 @@ func (p *ParserATNSimulator) ReportContextSensitivity(dfa *DFA, prediction int,
 // If context sensitive parsing, we know it's ambiguity not conflict//
 func (p *ParserATNSimulator) ReportAmbiguity(dfa *DFA, D *DFAState, startIndex, stopIndex int,
 	exact bool, ambigAlts *BitSet, configs ATNConfigSet) {
+	if p.profiler != nil {
+		p.profiler.ambiguity(ambigAlts, configs, startIndex, stopIndex)
+	}
 	if ParserATNSimulatorDebug || ParserATNSimulatorRetryDebug {
 		interval := NewInterval(startIndex, stopIndex+1)
 		fmt.Println("ReportAmbiguity " + ambigAlts.String() + ":" + configs.String() +
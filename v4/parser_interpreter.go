@@ -0,0 +1,352 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"fmt"
+)
+
+// ParserInterpreter is a parser simulator that mimics what ANTLR's generated
+// parser code does. A ParserATNSimulator is used to make predictions via
+// AdaptivePredict but this struct moves a pointer through the ATN to simulate
+// parsing. ParserATNSimulator just makes us efficient rather than having to
+// backtrack, for example.
+//
+// This properly creates parse trees even for left recursive rules.
+//
+// We rely on the left recursive rule invocation and special predicate
+// transitions to make left recursive rules work.
+type ParserInterpreter struct {
+	*BaseParser
+
+	atn                *ATN
+	decisionToDFA      []*DFA
+	sharedContextCache *PredictionContextCache
+
+	// parentContextStack tracks the parent context and invoking state of
+	// each left recursive rule invocation, which is needed to unroll the
+	// recursion contexts when the rule completes.
+	parentContextStack []parentContextPair
+
+	// rootContext is the context of the start rule of the current parse.
+	rootContext InterpreterRuleContext
+
+	// The decision, input token index and alternative set by
+	// AddDecisionOverride. overrideDecision is -1 when no override is set.
+	overrideDecision           int
+	overrideDecisionInputIndex int
+	overrideDecisionAlt        int
+
+	// overrideDecisionReached records whether the override was applied
+	// during the current parse; it is only applied once.
+	overrideDecisionReached bool
+
+	// overrideDecisionRoot is the context in which the overridden decision
+	// was made.
+	overrideDecisionRoot InterpreterRuleContext
+}
+
+type parentContextPair struct {
+	ctx           ParserRuleContext
+	invokingState int
+}
+
+// NewParserInterpreter creates a ParserInterpreter that parses input using
+// atn. The names describe the vocabulary and rules of the grammar the ATN
+// was created from.
+//
+// @panics if atn is not a parser ATN.
+func NewParserInterpreter(grammarFileName string, literalNames, symbolicNames, ruleNames []string, atn *ATN, input TokenStream) *ParserInterpreter {
+	if atn.grammarType != ATNTypeParser {
+		panic("The ATN must be a parser ATN.")
+	}
+
+	p := new(ParserInterpreter)
+
+	p.BaseParser = NewBaseParser(input)
+
+	p.GrammarFileName = grammarFileName
+	p.LiteralNames = literalNames
+	p.SymbolicNames = symbolicNames
+	p.RuleNames = ruleNames
+	p.atn = atn
+	p.overrideDecision = -1
+	p.overrideDecisionInputIndex = -1
+	p.overrideDecisionAlt = -1
+
+	// init decision DFA
+	p.decisionToDFA = make([]*DFA, len(atn.DecisionToState))
+	for i, ds := range atn.DecisionToState {
+		p.decisionToDFA[i] = NewDFA(ds, i)
+	}
+
+	p.sharedContextCache = NewPredictionContextCache()
+
+	// get atn simulator that knows how to do predictions
+	p.Interpreter = NewParserATNSimulator(p, atn, p.decisionToDFA, p.sharedContextCache)
+
+	return p
+}
+
+// GetATN returns the ATN the interpreter walks.
+func (p *ParserInterpreter) GetATN() *ATN {
+	return p.atn
+}
+
+// GetRootContext returns the root of the parse tree built by the most recent
+// call to Parse.
+func (p *ParserInterpreter) GetRootContext() InterpreterRuleContext {
+	return p.rootContext
+}
+
+// Reset rewinds the input and clears the parser state so that Parse can be
+// called again. A decision override added with AddDecisionOverride is kept
+// and will be applied again.
+func (p *ParserInterpreter) Reset() {
+	p.reset()
+	p.parentContextStack = nil
+	p.rootContext = nil
+	p.overrideDecisionReached = false
+	p.overrideDecisionRoot = nil
+}
+
+// AddDecisionOverride overrides the interpreter's normal decision-making
+// process at a particular decision and input token index. Instead of
+// adaptive prediction, the interpreter chooses forcedAlt when it reaches
+// decision with the input positioned at tokenIndex. The override is applied
+// at most once per parse.
+//
+// <p>This is useful for exploring the alternative parse trees of an
+// ambiguous input: parse once to find the ambiguous decision, then parse
+// again once per alternative, forcing that alternative at the decision.</p>
+//
+// <p>Note that the override applies to the first time the decision is
+// reached at tokenIndex, even if the decision is reached again at the same
+// index later in the parse.</p>
+func (p *ParserInterpreter) AddDecisionOverride(decision, tokenIndex, forcedAlt int) {
+	p.overrideDecision = decision
+	p.overrideDecisionInputIndex = tokenIndex
+	p.overrideDecisionAlt = forcedAlt
+}
+
+// GetOverrideDecisionRoot returns the context in which the decision set by
+// AddDecisionOverride was overridden during the most recent parse, or nil if
+// the override was not reached.
+func (p *ParserInterpreter) GetOverrideDecisionRoot() InterpreterRuleContext {
+	return p.overrideDecisionRoot
+}
+
+// Action does nothing; the interpreter has no grammar actions to execute.
+func (p *ParserInterpreter) Action(localctx RuleContext, ruleIndex, actionIndex int) {
+}
+
+// Parse begins parsing at startRuleIndex and returns the resulting parse
+// tree.
+func (p *ParserInterpreter) Parse(startRuleIndex int) ParserRuleContext {
+	startRuleStartState := p.atn.ruleToStartState[startRuleIndex]
+
+	p.rootContext = p.createInterpreterRuleContext(nil, ATNStateInvalidStateNumber, startRuleIndex)
+	if startRuleStartState.isPrecedenceRule {
+		p.EnterRecursionRule(p.rootContext, startRuleStartState.GetStateNumber(), startRuleIndex, 0)
+	} else {
+		p.EnterRule(p.rootContext, startRuleStartState.GetStateNumber(), startRuleIndex)
+	}
+
+	for {
+		s := p.getATNState()
+		switch s.GetStateType() {
+		case ATNStateRuleStop:
+			// pop; return from rule
+			if p.ctx.IsEmpty() {
+				if startRuleStartState.isPrecedenceRule {
+					result := p.ctx
+					parentContext := p.popParentContext()
+					p.UnrollRecursionContexts(parentContext.ctx)
+					return result
+				}
+
+				p.ExitRule()
+				return p.rootContext
+			}
+
+			p.visitRuleStopState(s)
+
+		default:
+			p.visitStateRecovering(s)
+		}
+	}
+}
+
+// EnterRecursionRule records the current context before entering a left
+// recursive rule so that it can be restored when the rule completes.
+func (p *ParserInterpreter) EnterRecursionRule(localctx ParserRuleContext, state, ruleIndex, precedence int) {
+	p.parentContextStack = append(p.parentContextStack, parentContextPair{p.ctx, localctx.GetInvokingState()})
+	p.BaseParser.EnterRecursionRule(localctx, state, ruleIndex, precedence)
+}
+
+func (p *ParserInterpreter) popParentContext() parentContextPair {
+	n := len(p.parentContextStack) - 1
+	pair := p.parentContextStack[n]
+	p.parentContextStack = p.parentContextStack[:n]
+	return pair
+}
+
+func (p *ParserInterpreter) getATNState() ATNState {
+	return p.atn.states[p.GetState()]
+}
+
+// visitStateRecovering visits s the way the body of a generated rule
+// function would, reporting and recovering from recognition errors.
+func (p *ParserInterpreter) visitStateRecovering(s ATNState) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(RecognitionException); ok {
+				p.SetState(p.atn.ruleToStopState[s.GetRuleIndex()].GetStateNumber())
+				p.ctx.SetException(e)
+				p.GetErrorHandler().ReportError(p, e)
+				p.recover(e)
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	p.visitState(s)
+}
+
+func (p *ParserInterpreter) visitState(s ATNState) {
+	predictedAlt := 1
+	if ds, ok := s.(DecisionState); ok {
+		predictedAlt = p.visitDecisionState(ds)
+	}
+
+	// Enter the outer alternative of the rule where its alternatives
+	// start, as generated parsers do.
+	ruleStart := p.atn.ruleToStartState[s.GetRuleIndex()]
+	if s != ruleStart && ruleStart.GetTransitions()[0].getTarget() == s {
+		if p.atn.getRuleBlock(s.GetRuleIndex()) == s {
+			p.EnterOuterAlt(p.ctx, predictedAlt)
+		} else {
+			p.EnterOuterAlt(p.ctx, 1)
+		}
+	}
+
+	transition := s.GetTransitions()[predictedAlt-1]
+	switch transition.getSerializationType() {
+	case TransitionEPSILON:
+		if s2, ok := s.(*StarLoopEntryState); ok && s2.precedenceRuleDecision {
+			if _, ok := transition.getTarget().(*LoopEndState); !ok {
+				// We are at the start of a left recursive rule's (...)* loop
+				// and we're not taking the exit branch of loop.
+				parentContext := p.parentContextStack[len(p.parentContextStack)-1]
+				localctx := p.createInterpreterRuleContext(parentContext.ctx, parentContext.invokingState, p.ctx.GetRuleIndex())
+				p.PushNewRecursionContext(localctx, p.atn.ruleToStartState[s.GetRuleIndex()].GetStateNumber(), p.ctx.GetRuleIndex())
+			}
+		}
+
+	case TransitionATOM:
+		p.Match(transition.(*AtomTransition).label)
+
+	case TransitionRANGE, TransitionSET, TransitionNOTSET:
+		if !transition.Matches(p.input.LA(1), TokenMinUserTokenType, 65535) {
+			p.GetErrorHandler().RecoverInline(p)
+		}
+		p.MatchWildcard()
+
+	case TransitionWILDCARD:
+		p.MatchWildcard()
+
+	case TransitionRULE:
+		ruleStartState := transition.getTarget().(*RuleStartState)
+		ruleIndex := ruleStartState.GetRuleIndex()
+		newctx := p.createInterpreterRuleContext(p.ctx, s.GetStateNumber(), ruleIndex)
+		if ruleStartState.isPrecedenceRule {
+			p.EnterRecursionRule(newctx, ruleStartState.GetStateNumber(), ruleIndex, transition.(*RuleTransition).precedence)
+		} else {
+			p.EnterRule(newctx, transition.getTarget().GetStateNumber(), ruleIndex)
+		}
+
+	case TransitionPREDICATE:
+		predicateTransition := transition.(*PredicateTransition)
+		if !p.Sempred(p.ctx, predicateTransition.ruleIndex, predicateTransition.predIndex) {
+			panic(NewFailedPredicateException(p, "", ""))
+		}
+
+	case TransitionACTION:
+		actionTransition := transition.(*ActionTransition)
+		p.Action(p.ctx, actionTransition.ruleIndex, actionTransition.actionIndex)
+
+	case TransitionPRECEDENCE:
+		precedence := transition.(*PrecedencePredicateTransition).precedence
+		if !p.Precpred(p.ctx, precedence) {
+			panic(NewFailedPredicateException(p, fmt.Sprintf("precpred(_ctx, %d)", precedence), ""))
+		}
+
+	default:
+		panic("Unrecognized ATN transition type.")
+	}
+
+	p.SetState(transition.getTarget().GetStateNumber())
+}
+
+func (p *ParserInterpreter) visitDecisionState(s DecisionState) int {
+	if len(s.GetTransitions()) > 1 {
+		p.GetErrorHandler().Sync(p)
+		decision := s.getDecision()
+		if decision == p.overrideDecision && p.input.Index() == p.overrideDecisionInputIndex && !p.overrideDecisionReached {
+			p.overrideDecisionReached = true
+			p.overrideDecisionRoot, _ = p.ctx.(InterpreterRuleContext)
+			return p.overrideDecisionAlt
+		}
+
+		return p.Interpreter.AdaptivePredict(p.input, decision, p.ctx)
+	}
+
+	return 1
+}
+
+// createInterpreterRuleContext provides an opportunity for subclasses to
+// create their own context types.
+func (p *ParserInterpreter) createInterpreterRuleContext(parent ParserRuleContext, invokingStateNumber, ruleIndex int) InterpreterRuleContext {
+	return NewBaseInterpreterRuleContext(parent, invokingStateNumber, ruleIndex)
+}
+
+func (p *ParserInterpreter) visitRuleStopState(s ATNState) {
+	ruleStartState := p.atn.ruleToStartState[s.GetRuleIndex()]
+	if ruleStartState.isPrecedenceRule {
+		parentContext := p.popParentContext()
+		p.UnrollRecursionContexts(parentContext.ctx)
+		p.SetState(parentContext.invokingState)
+	} else {
+		p.ExitRule()
+	}
+
+	ruleTransition := p.atn.states[p.GetState()].GetTransitions()[0].(*RuleTransition)
+	p.SetState(ruleTransition.followState.GetStateNumber())
+}
+
+// recover rescues from a RecognitionException the same way generated rule
+// functions do. If no input was consumed by the error strategy, an error
+// node is added to the tree so that the tree reflects the error.
+func (p *ParserInterpreter) recover(e RecognitionException) {
+	i := p.input.Index()
+	p.GetErrorHandler().Recover(p, e)
+	if p.input.Index() == i {
+		// no input consumed, better add an error node
+		tok := e.GetOffendingToken()
+		expectedTokenType := TokenInvalidType
+		if ime, ok := e.(*InputMisMatchException); ok {
+			expectedTokens := ime.getExpectedTokens()
+			if expectedTokens != nil && len(expectedTokens.intervals) > 0 {
+				expectedTokenType = expectedTokens.first() // get any element
+			}
+		}
+
+		source := &TokenSourceCharStreamPair{tok.GetTokenSource(), tok.GetTokenSource().GetInputStream()}
+		errToken := p.GetTokenFactory().Create(source, expectedTokenType, tok.GetText(), TokenDefaultChannel, -1, -1, // invalid start/stop
+			tok.GetLine(), tok.GetColumn())
+		p.ctx.AddErrorNode(errToken)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParserInterpreterParse(t *testing.T) {
+	parser, tree := parseExpr("x = 1 + 2 * y; (a);")
+
+	expected := "(prog (stat x = (expr (expr 1) + (expr (expr 2) * (expr y))) ;) (stat (expr ( (expr a) )) ;) <EOF>)"
+	if got := TreesStringTree(tree, nil, parser); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	if _, ok := tree.(InterpreterRuleContext); !ok {
+		t.Errorf("expected an InterpreterRuleContext, got %T", tree)
+	}
+}
+
+func TestParserInterpreterSyntaxError(t *testing.T) {
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(newExprLexer(NewInputStream("x = ;")), TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+	tree := parser.Parse(ExprParserRULE_prog)
+
+	if got := parser._SyntaxErrors; got != 1 {
+		t.Errorf("expected 1 syntax error, got %d", got)
+	}
+	// no input is consumed recovering from the error in expr, so the
+	// offending token is added to expr as an error node
+	expected := "(prog (stat x = (expr ;) ;) <EOF>)"
+	if got := TreesStringTree(tree, nil, parser); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	expr := TreesfindAllRuleNodes(tree, ExprParserRULE_expr)[0]
+	if _, ok := expr.GetChild(0).(ErrorNode); !ok {
+		t.Errorf("expected an error node, got %T", expr.GetChild(0))
+	}
+}
+
+func TestParserInterpreterDecisionOverride(t *testing.T) {
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(newExprLexer(NewInputStream("1 + 2 * 3;")), TokenDefaultChannel))
+
+	// exit the (...)* loop of expr at '*' rather than let '*' bind tighter
+	parser.AddDecisionOverride(4, 3, 2)
+	tree := parser.Parse(ExprParserRULE_prog)
+
+	expected := "(prog (stat (expr (expr (expr 1) + (expr 2)) * (expr 3)) ;) <EOF>)"
+	if got := TreesStringTree(tree, nil, parser); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	if root := parser.GetOverrideDecisionRoot(); root == nil || root.GetRuleIndex() != ExprParserRULE_expr {
+		t.Errorf("expected the override root to be an expr, got %v", root)
+	}
+
+	// the override is applied again after a reset
+	parser.Reset()
+	if got := TreesStringTree(parser.Parse(ExprParserRULE_prog), nil, parser); got != expected {
+		t.Errorf("after reset: expected %s, got %s", expected, got)
+	}
+}
+
+func TestInterpreterData(t *testing.T) {
+	lexerData, err := ReadInterpreterData(strings.NewReader(exprInterpData(exprLexerRuleNames, exprChannelNames, exprModeNames, exprSerializedLexerATN)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parserData, err := ReadInterpreterData(strings.NewReader(exprInterpData(exprParserRuleNames, nil, nil, exprSerializedParserATN)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lexerData.ModeNames) != 1 || len(parserData.ModeNames) != 0 {
+		t.Errorf("expected modes only for the lexer, got %v and %v", lexerData.ModeNames, parserData.ModeNames)
+	}
+	if lexerData.SymbolicNames[7] != "ID" || lexerData.LiteralNames[0] != "" {
+		t.Errorf("unexpected vocabulary %v %v", lexerData.LiteralNames, lexerData.SymbolicNames)
+	}
+
+	lexer := lexerData.NewLexerInterpreter("Expr.g4", NewInputStream("x = (1);"))
+	parser := parserData.NewParserInterpreter("Expr.g4", NewCommonTokenStream(lexer, TokenDefaultChannel))
+	tree := parser.Parse(ExprParserRULE_prog)
+
+	expected := "(prog (stat x = (expr ( (expr 1) )) ;) <EOF>)"
+	if got := TreesStringTree(tree, nil, parser); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestInterpreterDataMalformed(t *testing.T) {
+	tests := []string{
+		"",
+		"token literal names:\nnull\n\nrule names:\n",
+		exprInterpData(exprParserRuleNames, nil, nil, nil),
+		exprInterpData(exprParserRuleNames, nil, nil, []uint16{3, 1, 2}),
+	}
+
+	for _, test := range tests {
+		if _, err := ReadInterpreterData(strings.NewReader(test)); err == nil {
+			t.Errorf("expected an error reading %q", test)
+		}
+	}
+}
+
+// exprInterpData formats the Expr grammar the way the tool writes .interp
+// files.
+func exprInterpData(ruleNames, channelNames, modeNames []string, atn []uint16) string {
+	var b strings.Builder
+	section := func(header string, names []string) {
+		b.WriteString(header + "\n")
+		for _, name := range names {
+			if name == "" {
+				name = "null"
+			}
+			b.WriteString(name + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	section("token literal names:", exprLiteralNames)
+	section("token symbolic names:", exprSymbolicNames)
+	section("rule names:", ruleNames)
+	if channelNames != nil {
+		section("channel names:", channelNames)
+		section("mode names:", modeNames)
+	}
+
+	values := make([]string, len(atn))
+	for i, v := range atn {
+		values[i] = fmt.Sprint(v)
+	}
+	b.WriteString("atn:\n[" + strings.Join(values, ", ") + "]\n")
+
+	return b.String()
+}
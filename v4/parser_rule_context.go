@@ -16,6 +16,7 @@ type ParserRuleContext interface {
 
 	AddTokenNode(token Token) *TerminalNodeImpl
 	AddErrorNode(badToken Token) *ErrorNodeImpl
+	AddMissingNode(missingToken Token) *MissingNodeImpl
 
 	EnterRule(listener ParseTreeListener)
 	ExitRule(listener ParseTreeListener)
@@ -73,14 +74,18 @@ func (prc *BaseParserRuleContext) VisitFunc(nv NodeVisitor, p ParserTreeVisitorH
 func (prc *BaseParserRuleContext) VisitChildrenFunc(nv NodeVisitor, h ParserTreeVisitorHandlers, args ...interface{}) {
 	for _, child := range prc.GetChildren() {
 		switch child := child.(type) {
-		case TerminalNode:
+		case MissingNode:
 			if nv != nil {
-				nv.VisitTerminal(child)
+				visitMissingNode(nv, child)
 			}
 		case ErrorNode:
 			if nv != nil {
 				nv.VisitErrorNode(child)
 			}
+		case TerminalNode:
+			if nv != nil {
+				nv.VisitTerminal(child)
+			}
 		case RuleNode:
 			child.VisitFunc(nv, h, args...)
 		}
@@ -169,6 +174,15 @@ func (prc *BaseParserRuleContext) AddErrorNode(badToken Token) *ErrorNodeImpl {
 	return node
 }
 
+// AddMissingNode adds a node for a token conjured up during error recovery,
+// marking the token as missing.
+func (prc *BaseParserRuleContext) AddMissingNode(missingToken Token) *MissingNodeImpl {
+	node := NewMissingNodeImpl(missingToken)
+	prc.addTerminalNodeChild(node)
+	node.parentCtx = prc
+	return node
+}
+
 func (prc *BaseParserRuleContext) GetChild(i int) Tree {
 	if prc.children != nil && len(prc.children) >= i {
 		return prc.children[i]
@@ -373,7 +387,7 @@ type BaseInterpreterRuleContext struct {
 	*BaseParserRuleContext
 }
 
-func NewBaseInterpreterRuleContext(parent BaseInterpreterRuleContext, invokingStateNumber, ruleIndex int) *BaseInterpreterRuleContext {
+func NewBaseInterpreterRuleContext(parent ParserRuleContext, invokingStateNumber, ruleIndex int) *BaseInterpreterRuleContext {
 
 	prc := new(BaseInterpreterRuleContext)
 
@@ -0,0 +1,139 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetATNWithBypassAltsConcurrent(t *testing.T) {
+	const n = 8
+
+	atns := make([]*ATN, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			atns[i] = newExprRecognizer().GetATNWithBypassAlts()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, atn := range atns {
+		if atn != atns[0] {
+			t.Fatalf("parser %d got a different ATN instance", i)
+		}
+	}
+
+	// Every rule gets a bypass token type past the grammar's own tokens.
+	if got := len(atns[0].ruleToTokenType); got != len(exprParserRuleNames) {
+		t.Fatalf("expected %d bypass token types, got %d", len(exprParserRuleNames), got)
+	}
+	if atns[0] == exprParserATN {
+		t.Fatalf("bypass ATN must not be the parser's own ATN")
+	}
+}
+
+func TestGetATNWithBypassAltsNoSerializedATN(t *testing.T) {
+	// A parser of its own ATN, as generated parsers have, which does not
+	// give its serialized form.
+	atn := NewATNDeserializer(nil).DeserializeFromUInt16(exprSerializedParserATN)
+	parser := NewParserInterpreter("Expr.g4", exprLiteralNames, exprSymbolicNames, exprParserRuleNames, atn, nil)
+	if parser.SerializedATN != nil {
+		t.Fatalf("expected no serialized ATN")
+	}
+
+	bypass := parser.GetATNWithBypassAlts()
+	if got := len(bypass.ruleToTokenType); got != len(exprParserRuleNames) {
+		t.Fatalf("expected %d bypass token types, got %d", len(exprParserRuleNames), got)
+	}
+	if again := parser.GetATNWithBypassAlts(); again != bypass {
+		t.Errorf("expected the bypass ATN to be cached")
+	}
+
+	// Tree patterns work with it.
+	tree := buildExprTree("(stat x = (expr 1) ;)")
+	pattern := parser.CompileParseTreePattern("<ID> = <expr>;", ExprParserRULE_stat, newExprLexer(NewInputStream("")))
+	if !pattern.Matches(tree) {
+		t.Errorf("expected %s to match %s", pattern.GetPattern(), tree.ToStringTree(nil, parser))
+	}
+}
+
+func TestGetATNWithBypassAltsNoATN(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic without an ATN")
+		}
+	}()
+
+	NewBaseParser(nil).GetATNWithBypassAlts()
+}
+
+// cancellingListener cancels the parse when it visits the given terminal.
+type cancellingListener struct {
+	*BaseParseTreeListener
+	cancel    context.CancelFunc
+	text      string
+	terminals int
+}
+
+func (l *cancellingListener) VisitTerminal(node TerminalNode) {
+	l.terminals++
+	if node.GetText() == l.text {
+		l.cancel()
+	}
+}
+
+func TestParserContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(newExprLexer(NewInputStream("x = 1; y = 2; z = 3;")), TokenDefaultChannel))
+	parser.SetContext(ctx)
+
+	listener := &cancellingListener{cancel: cancel, text: "y"}
+	parser.AddParseListener(listener)
+
+	defer func() {
+		pce, ok := recover().(*ParseCancellationException)
+		if !ok {
+			t.Fatalf("expected a *ParseCancellationException")
+		}
+		if pce.Unwrap() != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", pce.Unwrap())
+		}
+		if got := pce.Error(); got != "parse cancelled: context canceled" {
+			t.Errorf("unexpected message %q", got)
+		}
+		// the parse stopped right after y was consumed
+		if listener.terminals != 5 {
+			t.Errorf("expected 5 terminals before cancellation, got %d", listener.terminals)
+		}
+	}()
+
+	parser.Parse(ExprParserRULE_prog)
+	t.Fatalf("expected the parse to be cancelled")
+}
+
+func TestLexerContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	lexer := newExprLexer(NewInputStream("x = 1;"))
+	lexer.SetContext(ctx)
+
+	defer func() {
+		pce, ok := recover().(*ParseCancellationException)
+		if !ok || pce.Unwrap() != context.DeadlineExceeded {
+			t.Fatalf("expected a deadline *ParseCancellationException")
+		}
+	}()
+
+	lexer.NextToken()
+	t.Fatalf("expected lexing to be cancelled")
+}
@@ -6,6 +6,7 @@ package antlr
 
 import (
 	"strconv"
+	"sync"
 )
 
 // Represents {@code $} in local context prediction, which means wildcard.
@@ -68,7 +69,17 @@ func calculateEmptyHash() int {
 // can be used for both lexers and parsers.
 
 type PredictionContextCache struct {
+	// mu guards the fields below, as the cache is shared by the ATN
+	// simulators of all the recognizers using the same DFAs.
+	mu    sync.Mutex
 	cache map[PredictionContext]PredictionContext
+
+	// maxSize is the maximum number of contexts the cache may hold before it
+	// is cleared, or 0 for no limit.
+	maxSize int
+
+	// clears is the number of times the cache was cleared.
+	clears int64
 }
 
 func NewPredictionContextCache() *PredictionContextCache {
@@ -85,22 +96,67 @@ func (p *PredictionContextCache) add(ctx PredictionContext) PredictionContext {
 	if ctx == BasePredictionContextEMPTY {
 		return BasePredictionContextEMPTY
 	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	existing := p.cache[ctx]
 	if existing != nil {
 		return existing
 	}
+	if p.maxSize > 0 && len(p.cache) >= p.maxSize {
+		p.clear()
+	}
 	p.cache[ctx] = ctx
 	return ctx
 }
 
 func (p *PredictionContextCache) Get(ctx PredictionContext) PredictionContext {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.cache[ctx]
 }
 
 func (p *PredictionContextCache) length() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return len(p.cache)
 }
 
+// Len returns the number of contexts in the cache.
+func (p *PredictionContextCache) Len() int {
+	return p.length()
+}
+
+// Clears returns the number of times the cache was cleared, by Clear or
+// because it reached its maximum size.
+func (p *PredictionContextCache) Clears() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.clears
+}
+
+// SetMaxSize limits the number of contexts the cache may hold to maxSize.
+// Adding a context to a cache that holds maxSize contexts first clears it.
+// Contexts already in DFA states are not affected; they are only no longer
+// shared with the contexts of new DFA states. A maxSize of 0 or less means
+// no limit, which is the default.
+func (p *PredictionContextCache) SetMaxSize(maxSize int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxSize = maxSize
+}
+
+// Clear removes all the contexts from the cache.
+func (p *PredictionContextCache) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+}
+
+func (p *PredictionContextCache) clear() {
+	p.cache = make(map[PredictionContext]PredictionContext)
+	p.clears++
+}
+
 type SingletonPredictionContext interface {
 	PredictionContext
 }
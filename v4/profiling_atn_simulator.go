@@ -0,0 +1,233 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"time"
+)
+
+// ProfilingATNSimulator is a ParserATNSimulator that collects statistics
+// about every prediction it makes, per decision. Use it to find the
+// decisions of a grammar that make parsing slow: those that take a lot of
+// time or lookahead, keep missing the DFA cache, or fall back to full
+// context prediction.
+//
+// <p>The simplest way to profile a parser is BaseParser.SetProfile, after
+// which BaseParser.GetParseInfo returns the statistics. To install the
+// profiler directly, set the parser's Interpreter to the embedded
+// ParserATNSimulator:</p>
+//
+//	profiler := antlr.NewProfilingATNSimulator(p)
+//	p.Interpreter = profiler.ParserATNSimulator
+//
+// <p>Profiling makes prediction noticeably slower; do not leave it on in
+// production.</p>
+type ProfilingATNSimulator struct {
+	*ParserATNSimulator
+
+	decisions []*DecisionInfo
+
+	// sllStopIndex and llStopIndex are the token indexes SLL and LL
+	// lookahead reached for the current prediction, or -1 if it has not
+	// used that kind of lookahead.
+	sllStopIndex int
+	llStopIndex  int
+
+	currentDecision int
+	currentState    *DFAState
+
+	// conflictingAltResolvedBySLL is the alternative SLL prediction would
+	// have chosen when it reported a conflict and fell back to full
+	// context prediction. It is compared to the LL prediction to detect
+	// context sensitivities.
+	conflictingAltResolvedBySLL int
+}
+
+// NewProfilingATNSimulator creates a ProfilingATNSimulator for parser,
+// sharing the ATN, DFA and prediction context cache of the parser's
+// current interpreter.
+func NewProfilingATNSimulator(parser Parser) *ProfilingATNSimulator {
+	interp := parser.GetInterpreter()
+
+	p := new(ProfilingATNSimulator)
+	p.ParserATNSimulator = NewParserATNSimulator(parser, interp.atn, interp.decisionToDFA, interp.sharedContextCache)
+	p.ParserATNSimulator.profiler = p
+
+	numDecisions := len(p.atn.DecisionToState)
+	p.decisions = make([]*DecisionInfo, numDecisions)
+	for i := range p.decisions {
+		p.decisions[i] = NewDecisionInfo(i)
+	}
+	p.currentDecision = -1
+
+	return p
+}
+
+// GetDecisionInfo returns the statistics of every decision, indexed by
+// decision number.
+func (p *ProfilingATNSimulator) GetDecisionInfo() []*DecisionInfo {
+	return p.decisions
+}
+
+// GetCurrentState returns the DFA state the last lookahead operation
+// reached.
+func (p *ProfilingATNSimulator) GetCurrentState() *DFAState {
+	return p.currentState
+}
+
+func (p *ProfilingATNSimulator) adaptivePredict(input TokenStream, decision int, outerContext ParserRuleContext) int {
+	defer func() {
+		p.currentDecision = -1
+	}()
+
+	p.sllStopIndex = -1
+	p.llStopIndex = -1
+	p.currentDecision = decision
+
+	start := time.Now()
+	alt := p.ParserATNSimulator.adaptivePredict(input, decision, outerContext)
+	elapsed := time.Since(start)
+
+	d := p.decisions[decision]
+	d.TimeInPrediction += elapsed
+	d.Invocations++
+
+	SLLk := int64(p.sllStopIndex - p.startIndex + 1)
+	d.SLLTotalLook += SLLk
+	if d.SLLMinLook == 0 || SLLk < d.SLLMinLook {
+		d.SLLMinLook = SLLk
+	}
+	if SLLk > d.SLLMaxLook {
+		d.SLLMaxLook = SLLk
+		d.SLLMaxLookEvent = NewLookaheadEventInfo(decision, nil, alt, input, p.startIndex, p.sllStopIndex, false)
+	}
+
+	if p.llStopIndex >= 0 {
+		LLk := int64(p.llStopIndex - p.startIndex + 1)
+		d.LLTotalLook += LLk
+		if d.LLMinLook == 0 || LLk < d.LLMinLook {
+			d.LLMinLook = LLk
+		}
+		if LLk > d.LLMaxLook {
+			d.LLMaxLook = LLk
+			d.LLMaxLookEvent = NewLookaheadEventInfo(decision, nil, alt, input, p.startIndex, p.llStopIndex, true)
+		}
+	}
+
+	return alt
+}
+
+// existingTargetState is called after each time the input position
+// advances during SLL prediction, with the DFA state, if any, reached from
+// previousD over an existing DFA edge.
+func (p *ProfilingATNSimulator) existingTargetState(previousD, existingTargetState *DFAState) {
+	p.sllStopIndex = p.input.Index()
+
+	if existingTargetState != nil {
+		d := p.decisions[p.currentDecision]
+		d.SLLDFATransitions++ // count only if we transition over a DFA state
+		if existingTargetState == ATNSimulatorError {
+			d.Errors = append(d.Errors, NewErrorInfo(p.currentDecision, previousD.configs, p.input, p.startIndex, p.sllStopIndex, false))
+		}
+	}
+
+	p.currentState = existingTargetState
+}
+
+// reachSet is called after computing the set of configurations reach
+// reached from closure, which is nil if there is none.
+func (p *ProfilingATNSimulator) reachSet(closure, reach ATNConfigSet, fullCtx bool) {
+	d := p.decisions[p.currentDecision]
+	if fullCtx {
+		d.LLATNTransitions++ // count computation even if error
+		if reach == nil {
+			// no reach on current lookahead symbol. ERROR.
+			d.Errors = append(d.Errors, NewErrorInfo(p.currentDecision, closure, p.input, p.startIndex, p.llStopIndex, true))
+		}
+	} else {
+		d.SLLATNTransitions++
+		if reach == nil {
+			d.Errors = append(d.Errors, NewErrorInfo(p.currentDecision, closure, p.input, p.startIndex, p.sllStopIndex, false))
+		}
+	}
+}
+
+func (p *ProfilingATNSimulator) predicateEvaluated(pred SemanticContext, result bool, alt int, fullCtx bool) {
+	if _, ok := pred.(*PrecedencePredicate); ok || p.currentDecision < 0 {
+		return
+	}
+
+	stopIndex := p.sllStopIndex
+	if p.llStopIndex >= 0 {
+		stopIndex = p.llStopIndex
+	}
+
+	d := p.decisions[p.currentDecision]
+	d.PredicateEvals = append(d.PredicateEvals,
+		NewPredicateEvalInfo(p.currentDecision, p.input, p.startIndex, stopIndex, pred, result, alt, fullCtx))
+}
+
+func (p *ProfilingATNSimulator) attemptingFullContext(conflictingAlts *BitSet, configs ATNConfigSet) {
+	if p.currentDecision < 0 {
+		return // reported outside of AdaptivePredict
+	}
+
+	if conflictingAlts != nil {
+		p.conflictingAltResolvedBySLL = conflictingAlts.minValue()
+	} else {
+		p.conflictingAltResolvedBySLL = minConfigAlt(configs)
+	}
+
+	p.decisions[p.currentDecision].LLFallback++
+}
+
+func (p *ProfilingATNSimulator) contextSensitivity(prediction int, configs ATNConfigSet, startIndex, stopIndex int) {
+	if p.currentDecision < 0 {
+		return // reported outside of AdaptivePredict
+	}
+
+	if prediction != p.conflictingAltResolvedBySLL {
+		d := p.decisions[p.currentDecision]
+		d.ContextSensitivities = append(d.ContextSensitivities,
+			NewContextSensitivityInfo(p.currentDecision, configs, p.input, startIndex, stopIndex))
+	}
+}
+
+func (p *ProfilingATNSimulator) ambiguity(ambigAlts *BitSet, configs ATNConfigSet, startIndex, stopIndex int) {
+	if p.currentDecision < 0 {
+		return // reported outside of AdaptivePredict
+	}
+
+	var prediction int
+	if ambigAlts != nil {
+		prediction = ambigAlts.minValue()
+	} else {
+		prediction = minConfigAlt(configs)
+	}
+
+	d := p.decisions[p.currentDecision]
+	if configs.FullContext() && prediction != p.conflictingAltResolvedBySLL {
+		// Even though this is an ambiguity we are reporting, we can
+		// still detect some context sensitivities. Both SLL and LL
+		// are showing a conflict, hence an ambiguity, but if they resolve
+		// to different minimum alternatives we have also identified a
+		// context sensitivity.
+		d.ContextSensitivities = append(d.ContextSensitivities,
+			NewContextSensitivityInfo(p.currentDecision, configs, p.input, startIndex, stopIndex))
+	}
+	d.Ambiguities = append(d.Ambiguities,
+		NewAmbiguityInfo(p.currentDecision, configs, ambigAlts, p.input, startIndex, stopIndex))
+}
+
+// minConfigAlt returns the minimum alternative of the configurations in
+// configs.
+func minConfigAlt(configs ATNConfigSet) int {
+	alts := NewBitSet()
+	for _, c := range configs.GetItems() {
+		alts.add(c.GetAlt())
+	}
+
+	return alts.minValue()
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"testing"
+)
+
+func TestProfilingATNSimulator(t *testing.T) {
+	parser, _ := parseExpr("")
+	parser.SetInputStream(NewCommonTokenStream(newExprLexer(NewInputStream("x = 1 + 2; y = (3);")), TokenDefaultChannel))
+	parser.GetInterpreter().SetPredictionMode(PredictionModeSLL)
+	if parser.GetParseInfo() != nil {
+		t.Fatal("expected no parse info without profiling")
+	}
+
+	parser.SetProfile(true)
+	if mode := parser.GetInterpreter().GetPredictionMode(); mode != PredictionModeSLL {
+		t.Errorf("expected the prediction mode to be kept, got %d", mode)
+	}
+	parser.Parse(ExprParserRULE_prog)
+
+	info := parser.GetParseInfo()
+	if info == nil {
+		t.Fatal("expected parse info")
+	}
+	decisions := info.GetDecisionInfo()
+	if len(decisions) != len(exprParserATN.DecisionToState) {
+		t.Fatalf("expected %d decisions, got %d", len(exprParserATN.DecisionToState), len(decisions))
+	}
+
+	// stat : ID '=' expr ';' | expr ';' ; needs 2 tokens of lookahead
+	stat := decisions[2]
+	if stat.Invocations != 2 {
+		t.Errorf("expected 2 predictions of stat, got %d", stat.Invocations)
+	}
+	if stat.SLLMinLook != 2 || stat.SLLMaxLook != 2 || stat.SLLAvgLook() != 2 {
+		t.Errorf("expected a lookahead of 2 tokens, got min %d, max %d, avg %v", stat.SLLMinLook, stat.SLLMaxLook, stat.SLLAvgLook())
+	}
+	if got := stat.SLLMaxLookEvent.GetText(); got != "x=" {
+		t.Errorf("expected the max lookahead event at %q, got %q", "x=", got)
+	}
+	if stat.SLLATNTransitions != 2 || stat.SLLDFATransitions != 2 {
+		t.Errorf("expected 2 DFA cache misses and 2 hits, got %d and %d", stat.SLLATNTransitions, stat.SLLDFATransitions)
+	}
+	if len(info.GetLLDecisions()) != 0 || info.GetTotalLLLookaheadOps() != 0 {
+		t.Errorf("expected no LL decisions, got %v", info.GetLLDecisions())
+	}
+
+	var invocations int64
+	for _, d := range decisions {
+		invocations += d.Invocations
+	}
+	if invocations == 0 || info.GetTotalSLLLookaheadOps() < invocations {
+		t.Errorf("expected at least one token of lookahead per prediction, got %d for %d predictions", info.GetTotalSLLLookaheadOps(), invocations)
+	}
+	if info.GetTotalTimeInPrediction() <= 0 {
+		t.Error("expected time spent in prediction")
+	}
+	if info.GetDFASize() == 0 || info.GetDFASizeForDecision(2) == 0 {
+		t.Error("expected DFA states")
+	}
+
+	// a second parse of the same input only hits the DFA cache
+	misses := info.GetTotalSLLATNLookaheadOps()
+	parser.SetInputStream(NewCommonTokenStream(newExprLexer(NewInputStream("x = 1 + 2; y = (3);")), TokenDefaultChannel))
+	parser.Parse(ExprParserRULE_prog)
+	if got := info.GetTotalSLLATNLookaheadOps(); got != misses {
+		t.Errorf("expected no new DFA cache misses, got %d", got-misses)
+	}
+	if stat.Invocations != 4 || stat.SLLDFATransitions != 6 {
+		t.Errorf("expected 4 predictions of stat all hitting the DFA cache, got %d predictions and %d hits", stat.Invocations, stat.SLLDFATransitions)
+	}
+
+	parser.SetProfile(false)
+	if parser.GetParseInfo() != nil {
+		t.Error("expected no parse info after profiling is turned off")
+	}
+	if mode := parser.GetInterpreter().GetPredictionMode(); mode != PredictionModeSLL {
+		t.Errorf("expected the prediction mode to be kept, got %d", mode)
+	}
+}
+
+func TestProfilingATNSimulatorLL(t *testing.T) {
+	tests := []struct {
+		input                []int
+		decision             int
+		contextSensitivities int
+		ambiguities          int
+	}{
+		{[]int{ctxDollar, ctxINT, ctxID}, ctxDecisionE, 0, 0},
+		{[]int{ctxAt, ctxINT, ctxID}, ctxDecisionE, 1, 0},
+		{[]int{ctxBang, ctxID}, ctxDecisionC, 0, 1},
+	}
+
+	for _, test := range tests {
+		parser := newCtxParser(test.input...)
+		parser.SetProfile(true)
+		parser.Parse(0)
+
+		info := parser.GetParseInfo()
+		if got := info.GetLLDecisions(); len(got) != 1 || got[0] != test.decision {
+			t.Errorf("%v: expected LL decisions [%d], got %v", test.input, test.decision, got)
+			continue
+		}
+
+		d := info.GetDecisionInfo()[test.decision]
+		if d.LLFallback != 1 || d.LLATNTransitions == 0 || d.LLMaxLook == 0 {
+			t.Errorf("%v: expected an LL fallback, got %d fallbacks, %d ATN transitions and a lookahead of %d",
+				test.input, d.LLFallback, d.LLATNTransitions, d.LLMaxLook)
+		}
+		if len(d.ContextSensitivities) != test.contextSensitivities {
+			t.Errorf("%v: expected %d context sensitivities, got %d", test.input, test.contextSensitivities, len(d.ContextSensitivities))
+		}
+		if len(d.Ambiguities) != test.ambiguities {
+			t.Errorf("%v: expected %d ambiguities, got %d", test.input, test.ambiguities, len(d.Ambiguities))
+		}
+		if len(d.Errors) != 0 {
+			t.Errorf("%v: expected no errors, got %d", test.input, len(d.Errors))
+		}
+	}
+}
+
+// newCtxParser returns a ParserInterpreter for the Ctx grammar over tokens
+// of the given types, reporting no errors.
+func newCtxParser(types ...int) *ParserInterpreter {
+	var tokens []Token
+	for _, ttype := range append(types, TokenEOF) {
+		token := NewCommonToken(nil, ttype, TokenDefaultChannel, -1, -1)
+		if ttype == TokenEOF {
+			token.SetText("<EOF>")
+		} else {
+			token.SetText(ctxSymbolicNames[ttype])
+		}
+		tokens = append(tokens, token)
+	}
+
+	stream := NewCommonTokenStream(NewListTokenSource(tokens, "Ctx"), TokenDefaultChannel)
+	parser := NewParserInterpreter("Ctx.g4", nil, ctxSymbolicNames, ctxRuleNames, ctxParserATN, stream)
+	parser.RemoveErrorListeners()
+
+	return parser
+}
+
+// The fixture below is the serialized ATN of the following grammar, in
+// which decision e needs the rule invocation stack to choose between its
+// alternatives and decision c is ambiguous:
+//
+//	grammar Ctx;
+//	s : ('$' a | '@' b | '!' c) EOF ;
+//	a : e ID ;
+//	b : e INT ID ;
+//	e : INT | ;
+//	c : ID | ID ;
+
+const (
+	ctxDollar = 1
+	ctxAt     = 2
+	ctxBang   = 3
+	ctxINT    = 4
+	ctxID     = 5
+
+	ctxDecisionE = 1
+	ctxDecisionC = 2
+)
+
+var ctxSymbolicNames = []string{"", "'$'", "'@'", "'!'", "INT", "ID"}
+
+var ctxRuleNames = []string{"s", "a", "b", "e", "c"}
+
+var ctxSerializedParserATN = []uint16{
+	3, 24715, 42794, 33075, 47597, 16764, 15335, 30598, 22884, 3, 7, 49, 4, 2, 9, 2, 4, 3, 9, 3, 4, 4,
+	9, 4, 4, 5, 9, 5, 4, 6, 9, 6, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2,
+	3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 5, 2, 25, 10, 2, 3, 2, 3, 2, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 4, 3, 4, 3, 4, 3, 4, 3, 4, 3, 4, 3, 5, 3,
+	5, 3, 5, 5, 5, 42, 10, 5, 3, 6, 3, 6, 3, 6, 3, 6, 5, 6, 48, 10, 6, 2,
+	2, 7, 2, 4, 6, 8, 10, 2, 2, 2, 48, 2, 24, 3, 2, 2, 2, 4, 28, 3, 2, 2,
+	2, 6, 32, 3, 2, 2, 2, 8, 41, 3, 2, 2, 2, 10, 47, 3, 2, 2, 2, 12, 13, 7,
+	3, 2, 2, 13, 14, 3, 2, 2, 2, 14, 15, 5, 4, 3, 2, 15, 25, 3, 2, 2, 2, 16,
+	17, 7, 4, 2, 2, 17, 18, 3, 2, 2, 2, 18, 19, 5, 6, 4, 2, 19, 25, 3, 2, 2,
+	2, 20, 21, 7, 5, 2, 2, 21, 22, 3, 2, 2, 2, 22, 23, 5, 10, 6, 2, 23, 25, 3,
+	2, 2, 2, 24, 12, 3, 2, 2, 2, 24, 16, 3, 2, 2, 2, 24, 20, 3, 2, 2, 2, 25,
+	26, 3, 2, 2, 2, 26, 27, 7, 2, 2, 3, 27, 3, 3, 2, 2, 2, 28, 29, 5, 8, 5,
+	2, 29, 30, 3, 2, 2, 2, 30, 31, 7, 7, 2, 2, 31, 5, 3, 2, 2, 2, 32, 33, 5,
+	8, 5, 2, 33, 34, 3, 2, 2, 2, 34, 35, 7, 6, 2, 2, 35, 36, 3, 2, 2, 2, 36,
+	37, 7, 7, 2, 2, 37, 7, 3, 2, 2, 2, 38, 42, 3, 2, 2, 2, 39, 40, 7, 6, 2,
+	2, 40, 42, 3, 2, 2, 2, 41, 39, 3, 2, 2, 2, 41, 38, 3, 2, 2, 2, 42, 9, 3,
+	2, 2, 2, 43, 44, 7, 7, 2, 2, 44, 48, 3, 2, 2, 2, 45, 46, 7, 7, 2, 2, 46,
+	48, 3, 2, 2, 2, 47, 43, 3, 2, 2, 2, 47, 45, 3, 2, 2, 2, 48, 11, 3, 2, 2,
+	2, 5, 24, 41, 47,
+}
+
+var ctxParserATN = NewATNDeserializer(nil).DeserializeFromUInt16(ctxSerializedParserATN)
@@ -23,9 +23,14 @@ type Recognizer interface {
 	SetState(int)
 	Action(RuleContext, int, int)
 	AddErrorListener(ErrorListener)
+	RemoveErrorListener(ErrorListener)
+	GetErrorListeners() []ErrorListener
 	RemoveErrorListeners()
 	GetATN() *ATN
 	GetErrorListenerDispatch() ErrorListener
+	GetTokenType(string) int
+	GetRuleIndexMap() map[string]int
+	GetGrammarFileName() string
 }
 
 type BaseRecognizer struct {
@@ -36,6 +41,9 @@ type BaseRecognizer struct {
 	LiteralNames    []string
 	SymbolicNames   []string
 	GrammarFileName string
+
+	tokenTypeMap map[string]int
+	ruleIndexMap map[string]int
 }
 
 func NewBaseRecognizer() *BaseRecognizer {
@@ -45,9 +53,6 @@ func NewBaseRecognizer() *BaseRecognizer {
 	return rec
 }
 
-var tokenTypeMapCache = make(map[string]int)
-var ruleIndexMapCache = make(map[string]int)
-
 func (b *BaseRecognizer) checkVersion(toolVersion string) {
 	runtimeVersion := "4.7"
 	if runtimeVersion != toolVersion {
@@ -63,6 +68,23 @@ func (b *BaseRecognizer) AddErrorListener(listener ErrorListener) {
 	b.listeners = append(b.listeners, listener)
 }
 
+// RemoveErrorListener removes listener, if it was added, from the error
+// listeners of the recognizer.
+func (b *BaseRecognizer) RemoveErrorListener(listener ErrorListener) {
+	for i, l := range b.listeners {
+		if l == listener {
+			b.listeners = append(b.listeners[:i:i], b.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetErrorListeners returns the error listeners of the recognizer, in the
+// order they are notified.
+func (b *BaseRecognizer) GetErrorListeners() []ErrorListener {
+	return b.listeners
+}
+
 func (b *BaseRecognizer) RemoveErrorListeners() {
 	b.listeners = make([]ErrorListener, 0)
 }
@@ -83,6 +105,12 @@ func (b *BaseRecognizer) GetLiteralNames() []string {
 	return b.LiteralNames
 }
 
+// GetGrammarFileName returns the name of the grammar the recognizer was
+// generated from.
+func (b *BaseRecognizer) GetGrammarFileName() string {
+	return b.GrammarFileName
+}
+
 func (b *BaseRecognizer) GetState() int {
 	return b.state
 }
@@ -91,77 +119,63 @@ func (b *BaseRecognizer) SetState(v int) {
 	b.state = v
 }
 
-//func (b *Recognizer) GetTokenTypeMap() {
-//    var tokenNames = b.GetTokenNames()
-//    if (tokenNames==nil) {
-//        panic("The current recognizer does not provide a list of token names.")
-//    }
-//    var result = tokenTypeMapCache[tokenNames]
-//    if(result==nil) {
-//        result = tokenNames.reduce(function(o, k, i) { o[k] = i })
-//        result.EOF = TokenEOF
-//        tokenTypeMapCache[tokenNames] = result
-//    }
-//    return result
-//}
+// GetTokenTypeMap returns a map from token names to token types. Both the
+// literal names (such as {@code '='}) and the symbolic names (such as
+// {@code ID}) of the vocabulary are included, as well as {@code EOF}.
+//
+// <p>Used for XPath and tree pattern compilation.</p>
+//
+func (b *BaseRecognizer) GetTokenTypeMap() map[string]int {
+	if b.tokenTypeMap == nil {
+		if b.LiteralNames == nil && b.SymbolicNames == nil {
+			panic("The current recognizer does not provide a list of token names.")
+		}
+		result := make(map[string]int)
+		for i, name := range b.LiteralNames {
+			if name != "" {
+				result[name] = i
+			}
+		}
+		for i, name := range b.SymbolicNames {
+			if name != "" {
+				result[name] = i
+			}
+		}
+		result["EOF"] = TokenEOF
+		b.tokenTypeMap = result
+	}
+
+	return b.tokenTypeMap
+}
 
 // Get a map from rule names to rule indexes.
 //
 // <p>Used for XPath and tree pattern compilation.</p>
 //
 func (b *BaseRecognizer) GetRuleIndexMap() map[string]int {
+	if b.ruleIndexMap == nil {
+		if b.RuleNames == nil {
+			panic("The current recognizer does not provide a list of rule names.")
+		}
+		result := make(map[string]int, len(b.RuleNames))
+		for i, name := range b.RuleNames {
+			result[name] = i
+		}
+		b.ruleIndexMap = result
+	}
 
-	panic("Method not defined!")
-	//    var ruleNames = b.GetRuleNames()
-	//    if (ruleNames==nil) {
-	//        panic("The current recognizer does not provide a list of rule names.")
-	//    }
-	//
-	//    var result = ruleIndexMapCache[ruleNames]
-	//    if(result==nil) {
-	//        result = ruleNames.reduce(function(o, k, i) { o[k] = i })
-	//        ruleIndexMapCache[ruleNames] = result
-	//    }
-	//    return result
+	return b.ruleIndexMap
 }
 
+// GetTokenType returns the token type for the given token name, or
+// TokenInvalidType if the vocabulary has no such token.
 func (b *BaseRecognizer) GetTokenType(tokenName string) int {
-	panic("Method not defined!")
-	//    var ttype = b.GetTokenTypeMap()[tokenName]
-	//    if (ttype !=nil) {
-	//        return ttype
-	//    } else {
-	//        return TokenInvalidType
-	//    }
-}
+	if ttype, ok := b.GetTokenTypeMap()[tokenName]; ok {
+		return ttype
+	}
 
-//func (b *Recognizer) GetTokenTypeMap() map[string]int {
-//    Vocabulary vocabulary = getVocabulary()
-//
-//    Synchronized (tokenTypeMapCache) {
-//        Map<String, Integer> result = tokenTypeMapCache.Get(vocabulary)
-//        if (result == null) {
-//            result = new HashMap<String, Integer>()
-//            for (int i = 0; i < GetATN().maxTokenType; i++) {
-//                String literalName = vocabulary.getLiteralName(i)
-//                if (literalName != null) {
-//                    result.put(literalName, i)
-//                }
-//
-//                String symbolicName = vocabulary.GetSymbolicName(i)
-//                if (symbolicName != null) {
-//                    result.put(symbolicName, i)
-//                }
-//            }
-//
-//            result.put("EOF", Token.EOF)
-//            result = Collections.unmodifiableMap(result)
-//            tokenTypeMapCache.put(vocabulary, result)
-//        }
-//
-//        return result
-//    }
-//}
+	return TokenInvalidType
+}
 
 // What is the error header, normally line/character position information?//
 func (b *BaseRecognizer) GetErrorHeader(e RecognitionException) string {
@@ -0,0 +1,693 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"container/heap"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RepairOperation is the operation of a RepairEdit.
+type RepairOperation int
+
+const (
+	// RepairInsert inserts a conjured token before the next input token.
+	RepairInsert RepairOperation = iota
+
+	// RepairDelete deletes the next input token.
+	RepairDelete
+
+	// RepairReplace replaces the next input token with a conjured token.
+	RepairReplace
+)
+
+func (o RepairOperation) String() string {
+	switch o {
+	case RepairInsert:
+		return "insert"
+	case RepairDelete:
+		return "delete"
+	case RepairReplace:
+		return "replace"
+	default:
+		return "RepairOperation(" + strconv.Itoa(int(o)) + ")"
+	}
+}
+
+// RepairEdit is one edit of a Repair.
+type RepairEdit struct {
+	Operation RepairOperation
+
+	// TokenType is the token type inserted by a RepairInsert or
+	// RepairReplace edit.
+	TokenType int
+
+	// Token is the input token deleted by a RepairDelete edit or replaced
+	// by a RepairReplace edit, and nil for a RepairInsert edit.
+	Token Token
+
+	// Conjured is the token conjured up by a RepairInsert or RepairReplace
+	// edit, and nil for a RepairDelete edit. It has no start and stop
+	// index in the char stream.
+	Conjured Token
+}
+
+func (e *RepairEdit) String() string {
+	switch e.Operation {
+	case RepairInsert:
+		return "insert " + strconv.Itoa(e.TokenType)
+	case RepairDelete:
+		return "delete " + tokenErrorDisplay(e.Token)
+	default:
+		return "replace " + tokenErrorDisplay(e.Token) + " with " + strconv.Itoa(e.TokenType)
+	}
+}
+
+// Repair is a sequence of edits applied to the input at a syntax error by
+// RepairErrorStrategy. The edits are applied at the same position of the
+// input: the deleted and replaced tokens are the tokens following the
+// error in order, and the inserted and replacing tokens take their place
+// in order.
+type Repair struct {
+	// Token is the token the error was found at.
+	Token Token
+
+	Edits []*RepairEdit
+
+	// Cost is the sum of the costs of the edits.
+	Cost int
+}
+
+func (r *Repair) String() string {
+	edits := make([]string, len(r.Edits))
+	for i, e := range r.Edits {
+		edits[i] = e.String()
+	}
+
+	return strings.Join(edits, ", ")
+}
+
+// repairMaxSearchNodes bounds the number of candidate repairs
+// RepairErrorStrategy tries at an error.
+const repairMaxSearchNodes = 10000
+
+// RepairErrorStrategy is an ErrorStrategy recovering from syntax errors by
+// searching for the cheapest sequence of token insertions, deletions and
+// replacements that lets parsing continue, rather than only trying
+// single-token deletion and insertion as DefaultErrorStrategy does.
+//
+// <p>At a mismatched token, or at a decision the next token cannot be
+// predicted at, the strategy simulates the ATN from the current state and
+// rule invocation stack. It tries repairs in order of increasing cost,
+// up to MaxEdits edits and MaxCost, and applies the first one after which
+// the next Lookahead input tokens can be parsed, or the input can be parsed
+// to its end. Semantic predicates are assumed to hold during the
+// simulation. Deleted tokens are consumed by the parser and added to the
+// parse tree as error nodes, and conjured tokens are matched as missing
+// nodes. The conjured tokens are served to the parser ahead of its token
+// stream, which is left unchanged, so that GetAllText and rewriters only see
+// the input, and the input tokens keep their token indexes. The repair is
+// reported to the error listeners with ErrorKindRepairedInput, and recorded
+// in GetRepairs.</p>
+//
+// <p>If no repair is found, or the parser does not embed BaseParser, the
+// strategy recovers as DefaultErrorStrategy does.</p>
+//
+// <p>This gives better recovery in editors, where the input is often
+// incomplete while it is being typed, for example</p>
+//
+// <pre>
+// x = (1 + 2
+// y = 3;
+// </pre>
+//
+// <p>is repaired by inserting {@code ')'} and {@code ';'} after {@code 2}.</p>
+type RepairErrorStrategy struct {
+	*DefaultErrorStrategy
+
+	maxEdits  int
+	maxCost   int
+	lookahead int
+
+	insertCost  int
+	deleteCost  int
+	replaceCost int
+
+	repairs []*Repair
+
+	// conjured are the conjured tokens that the parser has not matched yet.
+	conjured map[Token]bool
+
+	// tokens serves the conjured tokens to the parser while it has some to
+	// match.
+	tokens *repairTokenStream
+}
+
+var _ ErrorStrategy = &RepairErrorStrategy{}
+
+// NewRepairErrorStrategy returns a RepairErrorStrategy trying up to 3
+// edits of cost 1 each, and requiring 3 tokens to be parsed after a
+// repair.
+func NewRepairErrorStrategy() *RepairErrorStrategy {
+	return &RepairErrorStrategy{
+		DefaultErrorStrategy: NewDefaultErrorStrategy(),
+		maxEdits:             3,
+		maxCost:              3,
+		lookahead:            3,
+		insertCost:           1,
+		deleteCost:           1,
+		replaceCost:          1,
+	}
+}
+
+func (r *RepairErrorStrategy) GetMaxEdits() int {
+	return r.maxEdits
+}
+
+// SetMaxEdits sets the maximum number of edits of a repair.
+func (r *RepairErrorStrategy) SetMaxEdits(maxEdits int) {
+	r.maxEdits = maxEdits
+}
+
+func (r *RepairErrorStrategy) GetMaxCost() int {
+	return r.maxCost
+}
+
+// SetMaxCost sets the maximum cost of a repair.
+func (r *RepairErrorStrategy) SetMaxCost(maxCost int) {
+	r.maxCost = maxCost
+}
+
+func (r *RepairErrorStrategy) GetLookahead() int {
+	return r.lookahead
+}
+
+// SetLookahead sets the number of input tokens that must be parsed after
+// a repair for the repair to be applied.
+func (r *RepairErrorStrategy) SetLookahead(lookahead int) {
+	r.lookahead = lookahead
+}
+
+// SetEditCosts sets the costs of inserting, deleting and replacing a
+// token.
+func (r *RepairErrorStrategy) SetEditCosts(insert, delete, replace int) {
+	r.insertCost = insert
+	r.deleteCost = delete
+	r.replaceCost = replace
+}
+
+// GetRepairs returns the repairs applied since the parser was reset, in the
+// order they were applied.
+func (r *RepairErrorStrategy) GetRepairs() []*Repair {
+	return r.repairs
+}
+
+func (r *RepairErrorStrategy) reset(recognizer Parser) {
+	if r.tokens != nil && recognizer.GetTokenStream() == r.tokens {
+		recognizer.(tokenStreamReplacer).replaceTokenStream(r.tokens.TokenStream)
+	}
+	r.tokens = nil
+	r.repairs = nil
+	r.conjured = nil
+	r.DefaultErrorStrategy.reset(recognizer)
+}
+
+// ReportMatch leaves error recovery mode, unless the matched token was
+// conjured up by a repair, so that the parser adds it to the parse tree as
+// an error node even if the token factory does not support marking it as
+// missing. Once the parser has consumed all the conjured tokens, it reads
+// its own token stream again.
+func (r *RepairErrorStrategy) ReportMatch(recognizer Parser) {
+	if t := recognizer.GetCurrentToken(); r.conjured[t] {
+		delete(r.conjured, t)
+		return
+	}
+
+	if r.tokens != nil && r.tokens.passed() {
+		if recognizer.GetTokenStream() == r.tokens {
+			recognizer.(tokenStreamReplacer).replaceTokenStream(r.tokens.TokenStream)
+		}
+		r.tokens = nil
+	}
+
+	r.DefaultErrorStrategy.ReportMatch(recognizer)
+}
+
+// RecoverInline repairs the input at the mismatched token and matches the
+// token the repair leaves in its place. Without a repair, it recovers as
+// DefaultErrorStrategy.RecoverInline does.
+func (r *RepairErrorStrategy) RecoverInline(recognizer Parser) Token {
+	repair := r.findRepair(recognizer)
+	if repair == nil {
+		return r.DefaultErrorStrategy.RecoverInline(recognizer)
+	}
+
+	r.applyRepair(recognizer, repair)
+	t := recognizer.GetCurrentToken()
+	r.ReportMatch(recognizer)
+	recognizer.Consume()
+
+	return t
+}
+
+// Sync repairs the input if the next token cannot follow the current state
+// in the current rule invocation stack. Without a repair, it synchronizes
+// as DefaultErrorStrategy.Sync does.
+func (r *RepairErrorStrategy) Sync(recognizer Parser) {
+	if r.inErrorRecoveryMode(recognizer) {
+		return
+	}
+
+	atn := recognizer.GetATN()
+	s := atn.states[recognizer.GetState()]
+	la := recognizer.GetTokenStream().LA(1)
+
+	// try the cheaper set within the rule first
+	nextTokens := atn.NextTokens(s, nil)
+	if nextTokens.contains(la) {
+		return
+	}
+	if nextTokens.contains(TokenEpsilon) && atn.NextTokens(s, recognizer.GetParserRuleContext()).contains(la) {
+		return
+	}
+
+	if repair := r.findRepair(recognizer); repair != nil {
+		r.applyRepair(recognizer, repair)
+		return
+	}
+
+	r.DefaultErrorStrategy.Sync(recognizer)
+}
+
+// ReportRepair reports repair, which is about to be applied at the current
+// token, to the error listeners of recognizer.
+func (r *RepairErrorStrategy) ReportRepair(recognizer Parser, repair *Repair) {
+	if r.inErrorRecoveryMode(recognizer) {
+		return // don't report spurious errors
+	}
+	r.beginErrorCondition(recognizer)
+	r.reportSyntaxError(&SyntaxErrorFacts{
+		Kind:           ErrorKindRepairedInput,
+		Recognizer:     recognizer,
+		OffendingToken: repair.Token,
+		Expected:       r.GetExpectedTokens(recognizer),
+		Repair:         repair,
+	})
+}
+
+// tokenStreamReplacer is implemented by parsers whose token stream can be
+// replaced in the middle of a parse, as BaseParser does.
+type tokenStreamReplacer interface {
+	replaceTokenStream(input TokenStream)
+}
+
+// findRepair returns the cheapest repair of the input at the current token,
+// or nil if there is none within the bounds of r.
+func (r *RepairErrorStrategy) findRepair(recognizer Parser) *Repair {
+	tokens := recognizer.GetTokenStream()
+	if _, ok := recognizer.(tokenStreamReplacer); !ok {
+		return nil
+	}
+
+	sim := newRepairSimulator(recognizer.GetATN())
+	start := sim.closure([]repairConfig{{
+		state: recognizer.GetATN().states[recognizer.GetState()],
+		stack: sim.contextStack(recognizer.GetParserRuleContext()),
+	}}, false)
+
+	queue := &repairQueue{}
+	heap.Push(queue, &repairNode{configs: start})
+	visited := make(map[string]bool)
+	for n := 0; queue.Len() > 0 && n < repairMaxSearchNodes; n++ {
+		node := heap.Pop(queue).(*repairNode)
+		key := node.configs.key + "@" + strconv.Itoa(node.offset)
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+
+		if len(node.edits) > 0 && r.parses(sim, tokens, node.configs, node.offset) {
+			return &Repair{Token: tokens.LT(1), Edits: node.edits, Cost: node.cost}
+		}
+		if len(node.edits) >= r.maxEdits {
+			continue
+		}
+
+		la := tokens.LA(node.offset + 1)
+		if la != TokenEOF {
+			queue.push(node, r.deleteCost, node.configs, node.offset+1, &RepairEdit{Operation: RepairDelete}, r.maxCost)
+		}
+		for _, v := range sim.expected(node.configs).intervals {
+			for ttype := v.Start; ttype < v.Stop; ttype++ {
+				if ttype < TokenMinUserTokenType {
+					continue
+				}
+				next := sim.advance(node.configs, ttype)
+				queue.push(node, r.insertCost, next, node.offset, &RepairEdit{Operation: RepairInsert, TokenType: ttype}, r.maxCost)
+				if la != TokenEOF && la != ttype {
+					queue.push(node, r.replaceCost, next, node.offset+1, &RepairEdit{Operation: RepairReplace, TokenType: ttype}, r.maxCost)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// parses returns whether the lookahead input tokens following the token at
+// offset from the current token can be parsed from configs.
+func (r *RepairErrorStrategy) parses(sim *repairSimulator, tokens TokenStream, configs *repairConfigSet, offset int) bool {
+	for i := 1; i <= r.lookahead; i++ {
+		if configs.terminal {
+			return true
+		}
+		ttype := tokens.LA(offset + i)
+		configs = sim.advance(configs, ttype)
+		if configs.empty() {
+			return false
+		}
+		if ttype == TokenEOF {
+			return true
+		}
+	}
+
+	return true
+}
+
+// applyRepair reports repair and applies it to the input at the current
+// token.
+func (r *RepairErrorStrategy) applyRepair(recognizer Parser, repair *Repair) {
+	tokens := recognizer.GetTokenStream()
+
+	k := 1
+	for _, e := range repair.Edits {
+		if e.Operation != RepairInsert {
+			e.Token = tokens.LT(k)
+			k++
+		}
+	}
+
+	// Conjured tokens are positioned at the token following the edits
+	current := tokens.LT(k)
+	if lookback := tokens.LT(k - 1); current.GetTokenType() == TokenEOF && lookback != nil {
+		current = lookback
+	}
+	var conjured []Token
+	for _, e := range repair.Edits {
+		if e.Operation != RepairDelete {
+			e.Conjured = r.conjureToken(recognizer, e.TokenType, current)
+			conjured = append(conjured, e.Conjured)
+		}
+	}
+
+	r.ReportRepair(recognizer, repair)
+	r.beginErrorCondition(recognizer)
+	r.repairs = append(r.repairs, repair)
+
+	for i := 1; i < k; i++ {
+		recognizer.Consume()
+	}
+
+	if len(conjured) > 0 {
+		if r.conjured == nil {
+			r.conjured = make(map[Token]bool)
+		}
+		for _, t := range conjured {
+			r.conjured[t] = true
+		}
+		if r.tokens == nil || recognizer.GetTokenStream() != r.tokens {
+			r.tokens = newRepairTokenStream(tokens)
+			recognizer.(tokenStreamReplacer).replaceTokenStream(r.tokens)
+		}
+		r.tokens.insert(conjured)
+	}
+}
+
+// conjureToken returns a token of type ttype to insert into the input at
+// current.
+func (r *RepairErrorStrategy) conjureToken(recognizer Parser, ttype int, current Token) Token {
+	tokenText := "<missing " + NewIntervalSet().elementName(recognizer.GetLiteralNames(), recognizer.GetSymbolicNames(), ttype) + ">"
+
+	t := recognizer.GetTokenFactory().Create(current.GetSource(), ttype, tokenText, TokenDefaultChannel, -1, -1, current.GetLine(), current.GetColumn())
+	markMissingToken(t)
+
+	return t
+}
+
+// repairNode is a candidate repair: the configurations the edits lead to,
+// and the number of input tokens they delete or replace.
+type repairNode struct {
+	configs *repairConfigSet
+	offset  int
+	edits   []*RepairEdit
+	cost    int
+
+	// seq orders nodes of the same cost and number of edits in the order
+	// they were found.
+	seq int
+}
+
+// repairQueue is a priority queue of repairNodes, cheapest first.
+type repairQueue struct {
+	nodes  []*repairNode
+	pushed int
+}
+
+func (q *repairQueue) Len() int { return len(q.nodes) }
+
+func (q *repairQueue) Less(i, j int) bool {
+	a, b := q.nodes[i], q.nodes[j]
+	if a.cost != b.cost {
+		return a.cost < b.cost
+	}
+	if len(a.edits) != len(b.edits) {
+		return len(a.edits) < len(b.edits)
+	}
+
+	return a.seq < b.seq
+}
+
+func (q *repairQueue) Swap(i, j int) { q.nodes[i], q.nodes[j] = q.nodes[j], q.nodes[i] }
+
+func (q *repairQueue) Push(x interface{}) {
+	n := x.(*repairNode)
+	n.seq = q.pushed
+	q.pushed++
+	q.nodes = append(q.nodes, n)
+}
+
+func (q *repairQueue) Pop() interface{} {
+	n := q.nodes[len(q.nodes)-1]
+	q.nodes = q.nodes[:len(q.nodes)-1]
+	return n
+}
+
+// push adds the repair extending node with edit, if it leads to
+// configurations and costs at most maxCost.
+func (q *repairQueue) push(node *repairNode, cost int, configs *repairConfigSet, offset int, edit *RepairEdit, maxCost int) {
+	if configs.empty() || node.cost+cost > maxCost {
+		return
+	}
+
+	edits := make([]*RepairEdit, len(node.edits), len(node.edits)+1)
+	copy(edits, node.edits)
+	heap.Push(q, &repairNode{
+		configs: configs,
+		offset:  offset,
+		edits:   append(edits, edit),
+		cost:    node.cost + cost,
+	})
+}
+
+// repairStack is a rule invocation stack of the simulation, as the follow
+// states of the rule transitions taken. Stacks are interned, so equal
+// stacks are the same pointer.
+type repairStack struct {
+	parent      *repairStack
+	followState ATNState
+	id, depth   int
+}
+
+type repairStackKey struct {
+	parent      *repairStack
+	followState ATNState
+}
+
+// repairConfig is a state of the simulation in a rule invocation stack.
+type repairConfig struct {
+	state ATNState
+	stack *repairStack
+}
+
+// repairConfigSet is the set of configurations the simulation is in, after
+// following epsilon transitions. Only configurations of states with
+// transitions matching tokens are kept.
+type repairConfigSet struct {
+	configs []repairConfig
+
+	// terminal is set if the simulation can return from the start rule, so
+	// that the parser stops regardless of the input that follows.
+	terminal bool
+
+	key string
+}
+
+func (s *repairConfigSet) empty() bool {
+	return len(s.configs) == 0 && !s.terminal
+}
+
+// repairSimulator simulates the ATN of a parser on token types, ignoring
+// semantic predicates.
+type repairSimulator struct {
+	atn    *ATN
+	stacks map[repairStackKey]*repairStack
+}
+
+func newRepairSimulator(atn *ATN) *repairSimulator {
+	return &repairSimulator{atn: atn, stacks: make(map[repairStackKey]*repairStack)}
+}
+
+func (s *repairSimulator) push(parent *repairStack, followState ATNState) *repairStack {
+	key := repairStackKey{parent, followState}
+	stack, ok := s.stacks[key]
+	if !ok {
+		stack = &repairStack{parent: parent, followState: followState, id: len(s.stacks) + 1, depth: 1}
+		if parent != nil {
+			stack.depth = parent.depth + 1
+		}
+		s.stacks[key] = stack
+	}
+
+	return stack
+}
+
+// contextStack returns the stack of the rule invocations of ctx.
+func (s *repairSimulator) contextStack(ctx ParserRuleContext) *repairStack {
+	var followStates []ATNState
+	for ctx != nil && ctx.GetInvokingState() >= 0 {
+		invokingState := s.atn.states[ctx.GetInvokingState()]
+		followStates = append(followStates, invokingState.GetTransitions()[0].(*RuleTransition).followState)
+		ctx, _ = ctx.GetParent().(ParserRuleContext)
+	}
+
+	var stack *repairStack
+	for i := len(followStates) - 1; i >= 0; i-- {
+		stack = s.push(stack, followStates[i])
+	}
+
+	return stack
+}
+
+// closure returns the configurations reachable from configs by epsilon
+// transitions.
+func (s *repairSimulator) closure(configs []repairConfig, terminal bool) *repairConfigSet {
+	set := &repairConfigSet{terminal: terminal}
+	seen := make(map[repairConfig]bool)
+
+	var visit func(c repairConfig)
+	visit = func(c repairConfig) {
+		if seen[c] {
+			return
+		}
+		seen[c] = true
+
+		if _, ok := c.state.(*RuleStopState); ok {
+			if c.stack == nil {
+				set.terminal = true
+			} else {
+				visit(repairConfig{c.stack.followState, c.stack.parent})
+			}
+			return
+		}
+
+		matches := false
+		for _, t := range c.state.GetTransitions() {
+			if rt, ok := t.(*RuleTransition); ok {
+				// Guard against left recursion, which has no end
+				if c.stack == nil || c.stack.depth < len(s.atn.states) {
+					visit(repairConfig{rt.getTarget(), s.push(c.stack, rt.followState)})
+				}
+			} else if t.getIsEpsilon() {
+				visit(repairConfig{t.getTarget(), c.stack})
+			} else {
+				matches = true
+			}
+		}
+		if matches {
+			set.configs = append(set.configs, c)
+		}
+	}
+
+	for _, c := range configs {
+		visit(c)
+	}
+
+	sort.Slice(set.configs, func(i, j int) bool {
+		a, b := set.configs[i], set.configs[j]
+		if a.state.GetStateNumber() != b.state.GetStateNumber() {
+			return a.state.GetStateNumber() < b.state.GetStateNumber()
+		}
+		return a.stack.getID() < b.stack.getID()
+	})
+	var key strings.Builder
+	if set.terminal {
+		key.WriteString("$")
+	}
+	for _, c := range set.configs {
+		key.WriteString(strconv.Itoa(c.state.GetStateNumber()))
+		key.WriteString(":")
+		key.WriteString(strconv.Itoa(c.stack.getID()))
+		key.WriteString(" ")
+	}
+	set.key = key.String()
+
+	return set
+}
+
+func (s *repairStack) getID() int {
+	if s == nil {
+		return 0
+	}
+
+	return s.id
+}
+
+// advance returns the configurations following configs on token type
+// ttype.
+func (s *repairSimulator) advance(configs *repairConfigSet, ttype int) *repairConfigSet {
+	var next []repairConfig
+	for _, c := range configs.configs {
+		for _, t := range c.state.GetTransitions() {
+			if !t.getIsEpsilon() && t.Matches(ttype, TokenMinUserTokenType, s.atn.maxTokenType) {
+				next = append(next, repairConfig{t.getTarget(), c.stack})
+			}
+		}
+	}
+
+	return s.closure(next, configs.terminal)
+}
+
+// expected returns the token types matched by configs.
+func (s *repairSimulator) expected(configs *repairConfigSet) *IntervalSet {
+	set := NewIntervalSet()
+	for _, c := range configs.configs {
+		for _, t := range c.state.GetTransitions() {
+			switch t.getSerializationType() {
+			case TransitionWILDCARD:
+				set.addRange(TokenMinUserTokenType, s.atn.maxTokenType)
+			case TransitionNOTSET:
+				set.addSet(t.getLabel().complement(TokenMinUserTokenType, s.atn.maxTokenType))
+			default:
+				if !t.getIsEpsilon() {
+					set.addSet(t.getLabel())
+				}
+			}
+		}
+	}
+
+	return set
+}
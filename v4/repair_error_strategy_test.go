@@ -0,0 +1,237 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strings"
+	"testing"
+)
+
+// repairExpr parses input with the Expr grammar using strategy, and
+// returns the parse tree, the texts of its error nodes and the messages of
+// the syntax errors.
+func repairExpr(t *testing.T, input string, strategy *RepairErrorStrategy) (string, []string, []string) {
+	parser := newQuietExprParser(input)
+	parser.SetErrorHandler(strategy)
+	tree, syntaxErrors, _ := parseExprProg(parser)
+	if tree == nil {
+		t.Fatalf("%q: no parse tree, errors %v", input, syntaxErrors)
+	}
+
+	var errorNodes []string
+	var walk func(tree Tree)
+	walk = func(tree Tree) {
+		if n, ok := tree.(ErrorNode); ok {
+			errorNodes = append(errorNodes, n.GetSymbol().GetText())
+		}
+		for _, child := range tree.GetChildren() {
+			walk(child)
+		}
+	}
+	walk(tree)
+
+	var messages []string
+	for _, e := range syntaxErrors {
+		messages = append(messages, e.Msg)
+	}
+
+	return tree.ToStringTree(nil, parser), errorNodes, messages
+}
+
+func TestRepairErrorStrategy(t *testing.T) {
+	for _, test := range []struct {
+		input, tree, errorNodes, message string
+	}{
+		{
+			"x = (1 + 2\ny = 3;",
+			"(prog (stat x = (expr ( (expr (expr 1) + (expr 2)) <missing ')'>) <missing ';'>) (stat y = (expr 3) ;) <EOF>)",
+			"<missing ')'> <missing ';'>",
+			"syntax error at 'y', repaired by inserting ')' and inserting ';'",
+		},
+		{
+			"x = (1 + 2",
+			"(prog (stat x = (expr ( (expr (expr 1) + (expr 2)) <missing ')'>) <missing ';'>) <EOF>)",
+			"<missing ')'> <missing ';'>",
+			"syntax error at '<EOF>', repaired by inserting ')' and inserting ';'",
+		},
+		{
+			"x = ;",
+			"(prog (stat x = (expr <missing ID>) ;) <EOF>)",
+			"<missing ID>",
+			"syntax error at ';', repaired by inserting ID",
+		},
+		{
+			"x = 1 +* 2;",
+			"(prog (stat x = (expr (expr 1) + (expr * 2)) ;) <EOF>)",
+			"*",
+			"syntax error at '*', repaired by deleting '*'",
+		},
+	} {
+		tree, errorNodes, messages := repairExpr(t, test.input, NewRepairErrorStrategy())
+		if tree != test.tree {
+			t.Errorf("%q: expected tree %s, got %s", test.input, test.tree, tree)
+		}
+		if got := strings.Join(errorNodes, " "); got != test.errorNodes {
+			t.Errorf("%q: expected error nodes %s, got %s", test.input, test.errorNodes, got)
+		}
+		if len(messages) != 1 || messages[0] != test.message {
+			t.Errorf("%q: expected %q, got %q", test.input, test.message, messages)
+		}
+	}
+}
+
+func TestRepairErrorStrategyRepairs(t *testing.T) {
+	strategy := NewRepairErrorStrategy()
+	diagnostics := NewCollectingErrorListener()
+	parser := newQuietExprParser("x = 1 2;\ny = (3;")
+	parser.AddErrorListener(diagnostics)
+	parser.SetErrorHandler(strategy)
+	parser.Parse(ExprParserRULE_prog)
+
+	repairs := strategy.GetRepairs()
+	if len(repairs) != 2 {
+		t.Fatalf("expected 2 repairs, got %v", repairs)
+	}
+	if got := repairs[0].String(); got != "delete '2'" || repairs[0].Cost != 1 {
+		t.Errorf("expected the deletion of '2', got %s", got)
+	}
+	if e := repairs[1].Edits[0]; e.Operation != RepairInsert || e.TokenType != 6 || e.Conjured.GetText() != "<missing ')'>" || e.Conjured.GetLine() != 2 {
+		t.Errorf("expected the insertion of ')', got %s", repairs[1])
+	}
+
+	if len(diagnostics.GetDiagnostics()) != 2 {
+		t.Errorf("expected 2 diagnostics, got %v", diagnostics.GetDiagnostics())
+	}
+	for _, d := range diagnostics.GetDiagnostics() {
+		if d.Kind != ErrorKindRepairedInput {
+			t.Errorf("expected a repaired input diagnostic, got %v", d.Kind)
+		}
+	}
+
+	parser.SetInputStream(NewCommonTokenStream(newExprLexer(NewInputStream("x = 1;")), TokenDefaultChannel))
+	if len(strategy.GetRepairs()) != 0 {
+		t.Error("expected no repairs after reset")
+	}
+}
+
+func TestRepairErrorStrategyBounds(t *testing.T) {
+	// The repair costs 2, so it is not found with a maximum cost of 1, and
+	// the parser recovers as with DefaultErrorStrategy.
+	strategy := NewRepairErrorStrategy()
+	strategy.SetMaxCost(1)
+	_, _, messages := repairExpr(t, "x = (1 + 2\ny = 3;", strategy)
+	if len(messages) != 1 || messages[0] != "mismatched input 'y' expecting ')'" {
+		t.Errorf("expected no repair, got %q", messages)
+	}
+
+	// Deleting costs more than inserting.
+	strategy = NewRepairErrorStrategy()
+	strategy.SetEditCosts(1, 3, 3)
+	_, _, messages = repairExpr(t, "x = 1 +* 2;", strategy)
+	if len(messages) != 1 || messages[0] != "syntax error at '*', repaired by inserting ID" {
+		t.Errorf("expected the insertion of ID, got %q", messages)
+	}
+}
+
+func TestRepairErrorStrategyTokenStream(t *testing.T) {
+	const input = "x = (1 + 2\ny = 3;"
+	tokens := NewCommonTokenStream(newExprLexer(NewInputStream(input)), TokenDefaultChannel)
+	tokens.Fill()
+	text := tokens.GetAllText()
+	inputTokens := append([]Token(nil), tokens.GetAllTokens()...)
+
+	parse := func() string {
+		parser := newQuietExprParser("")
+		parser.SetInputStream(tokens)
+		parser.SetErrorHandler(NewRepairErrorStrategy())
+		tree := parser.Parse(ExprParserRULE_prog)
+		if parser.GetTokenStream() != tokens {
+			t.Errorf("expected the parser to read its own token stream after the repair")
+		}
+		return tree.ToStringTree(nil, parser)
+	}
+	tree := parse()
+	if !strings.Contains(tree, "<missing ')'>) <missing ';'>") {
+		t.Fatalf("expected a repair, got %s", tree)
+	}
+
+	// The conjured tokens are not added to the token stream.
+	if got := tokens.GetAllText(); got != text {
+		t.Errorf("expected text %q, got %q", text, got)
+	}
+	if got := NewTokenStreamRewriter(tokens).GetTextDefault(); got != text {
+		t.Errorf("expected rewritten text %q, got %q", text, got)
+	}
+	if got := tokens.GetAllTokens(); len(got) != len(inputTokens) {
+		t.Fatalf("expected %d tokens, got %d", len(inputTokens), len(got))
+	}
+	for i, token := range tokens.GetAllTokens() {
+		if token != inputTokens[i] || token.GetTokenIndex() != i {
+			t.Errorf("expected token %d to be %v, got %v at index %d", i, inputTokens[i], token, token.GetTokenIndex())
+		}
+	}
+
+	// The stream can be parsed again.
+	tokens.Seek(0)
+	if again := parse(); again != tree {
+		t.Errorf("expected tree %s parsing again, got %s", tree, again)
+	}
+}
+
+func TestRepairTokenStream(t *testing.T) {
+	tokens := NewCommonTokenStream(newExprLexer(NewInputStream("x = 1;")), TokenDefaultChannel)
+	s := newRepairTokenStream(tokens)
+	s.Consume()
+	s.Consume()
+
+	factory := CommonTokenFactoryDEFAULT
+	plus := factory.Create(nil, 4, "+", TokenDefaultChannel, -1, -1, 1, 4)
+	two := factory.Create(nil, 8, "2", TokenDefaultChannel, -1, -1, 1, 4)
+	s.insert([]Token{plus, two})
+
+	lookahead := func() string {
+		var texts []string
+		for k := -1; k <= 4; k++ {
+			if k != 0 {
+				texts = append(texts, s.LT(k).GetText())
+			}
+		}
+		return strings.Join(texts, " ")
+	}
+	if got := lookahead(); got != "= + 2 1 ;" {
+		t.Errorf("expected lookahead = + 2 1 ;, got %s", got)
+	}
+	if s.Index() != 2 || s.Get(3) != two || s.Get(4).GetText() != "1" {
+		t.Errorf("expected the conjured tokens at indexes 2 and 3, got %d, %v, %v", s.Index(), s.Get(3), s.Get(4))
+	}
+
+	// The input tokens keep their token indexes, and looking back or taking
+	// the text of an interval skips the conjured tokens.
+	s.Consume()
+	if got := s.LT(1); got != two || s.Index() != 3 || s.LT(-1).GetText() != "=" {
+		t.Errorf("expected to be at the conjured 2, got %v at %d", got, s.Index())
+	}
+	s.Consume()
+	if got := s.LT(1); got.GetTokenIndex() != 2 || s.Index() != 4 || !s.passed() {
+		t.Errorf("expected to be at the input 1, got %v at %d", got, s.Index())
+	}
+	if got := s.GetTextFromInterval(NewInterval(1, 5)); got != "=1;" {
+		t.Errorf("expected text =1;, got %q", got)
+	}
+
+	// Seek moves in and out of the conjured tokens.
+	for _, index := range []int{0, 3, 5, 2} {
+		s.Seek(index)
+		if got := s.Index(); got != index || s.LT(1) != s.Get(index) {
+			t.Errorf("expected to seek to %d, got %d at %v", index, got, s.LT(1))
+		}
+	}
+	if got := lookahead(); got != "= + 2 1 ;" {
+		t.Errorf("expected lookahead = + 2 1 ; after seeking, got %s", got)
+	}
+	if got := tokens.GetAllText(); got != "x=1;" {
+		t.Errorf("expected the token stream to be unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+// repairTokenStream is a TokenStream serving the tokens conjured up by a
+// RepairErrorStrategy repair before the tokens of the token stream it
+// wraps, without adding them to that stream. The parser reads from it
+// while it has conjured tokens to match, so that the token stream of the
+// parser keeps only the input tokens, at their own token indexes, for
+// rewriters, formatters and later parses.
+//
+// <p>The conjured tokens sit before the token at index at of the wrapped
+// stream, and pos of them have been consumed. Indexes, as returned by Index
+// and taken by Seek, Get and GetTextFromInterval, count the conjured tokens
+// as if they were in the stream, so that prediction can rewind to any
+// position. Looking back with LT, and the text of tokens and rule contexts,
+// only see the input tokens, as for the tokens DefaultErrorStrategy
+// conjures up.</p>
+type repairTokenStream struct {
+	TokenStream
+
+	at       int
+	conjured []Token
+	pos      int
+}
+
+func newRepairTokenStream(tokens TokenStream) *repairTokenStream {
+	return &repairTokenStream{TokenStream: tokens, at: -1}
+}
+
+// insert inserts tokens before the current token, making the first of them
+// the current token.
+func (s *repairTokenStream) insert(tokens []Token) {
+	if s.TokenStream.Index() != s.at {
+		s.at, s.conjured, s.pos = s.TokenStream.Index(), nil, 0
+	}
+
+	conjured := make([]Token, 0, len(s.conjured)+len(tokens))
+	conjured = append(conjured, s.conjured[:s.pos]...)
+	conjured = append(conjured, tokens...)
+	s.conjured = append(conjured, s.conjured[s.pos:]...)
+}
+
+// passed returns whether all the conjured tokens have been consumed.
+func (s *repairTokenStream) passed() bool {
+	return s.pos == len(s.conjured) && s.TokenStream.Index() >= s.at
+}
+
+func (s *repairTokenStream) Consume() {
+	if s.TokenStream.Index() == s.at && s.pos < len(s.conjured) {
+		s.pos++
+		return
+	}
+
+	s.TokenStream.Consume()
+}
+
+func (s *repairTokenStream) LA(i int) int {
+	t := s.LT(i)
+	if t == nil {
+		return TokenInvalidType
+	}
+
+	return t.GetTokenType()
+}
+
+func (s *repairTokenStream) LT(k int) Token {
+	if k <= 0 || len(s.conjured) == 0 {
+		return s.TokenStream.LT(k)
+	}
+
+	index := s.TokenStream.Index()
+	if index > s.at {
+		return s.TokenStream.LT(k)
+	}
+
+	// Count the input tokens before the conjured tokens
+	before, pos := 0, s.pos
+	if index < s.at {
+		pos = 0
+		for before < k {
+			t := s.TokenStream.LT(before + 1)
+			if t.GetTokenIndex() >= s.at || t.GetTokenType() == TokenEOF {
+				break
+			}
+			before++
+		}
+		if before == k {
+			return s.TokenStream.LT(k)
+		}
+	}
+
+	if i := pos + k - before - 1; i < len(s.conjured) {
+		return s.conjured[i]
+	}
+
+	return s.TokenStream.LT(k - (len(s.conjured) - pos))
+}
+
+func (s *repairTokenStream) Index() int {
+	index := s.TokenStream.Index()
+	switch {
+	case index < s.at:
+		return index
+	case index == s.at:
+		return index + s.pos
+	default:
+		return index + len(s.conjured)
+	}
+}
+
+func (s *repairTokenStream) Seek(index int) {
+	switch {
+	case index <= s.at:
+		s.TokenStream.Seek(index)
+		s.pos = 0
+	case index < s.at+len(s.conjured):
+		s.TokenStream.Seek(s.at)
+		s.pos = index - s.at
+	default:
+		s.TokenStream.Seek(index - len(s.conjured))
+		s.pos = len(s.conjured)
+	}
+}
+
+func (s *repairTokenStream) Size() int {
+	return s.TokenStream.Size() + len(s.conjured)
+}
+
+func (s *repairTokenStream) Get(index int) Token {
+	switch {
+	case index < s.at || s.at < 0:
+		return s.TokenStream.Get(index)
+	case index < s.at+len(s.conjured):
+		return s.conjured[index-s.at]
+	default:
+		return s.TokenStream.Get(index - len(s.conjured))
+	}
+}
+
+// GetTextFromInterval returns the text of the input tokens in interval,
+// leaving out the conjured tokens.
+func (s *repairTokenStream) GetTextFromInterval(interval *Interval) string {
+	if interval == nil || s.at < 0 {
+		return s.TokenStream.GetTextFromInterval(interval)
+	}
+
+	start, stop := interval.Start, interval.Stop
+	if start >= s.at+len(s.conjured) {
+		start -= len(s.conjured)
+	} else if start > s.at {
+		start = s.at
+	}
+	if stop >= s.at+len(s.conjured) {
+		stop -= len(s.conjured)
+	} else if stop >= s.at {
+		stop = s.at - 1
+	}
+
+	return s.TokenStream.GetTextFromInterval(NewInterval(start, stop))
+}
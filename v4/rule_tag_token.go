@@ -0,0 +1,120 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import "strconv"
+
+// RuleTagToken is a Token object representing an entire subtree matched by
+// a parser rule; e.g., {@code <expr>}. These tokens are created for TagChunk
+// chunks where the tag corresponds to a parser rule.
+type RuleTagToken struct {
+	// ruleName is the name of the parser rule associated with this rule tag.
+	ruleName string
+
+	// bypassTokenType is the token type for the current token. This is the
+	// token type assigned to the bypass alternative for the rule during ATN
+	// deserialization.
+	bypassTokenType int
+
+	// label is the label associated with the rule tag, or the empty string
+	// if the rule tag is unlabeled.
+	label string
+
+	tokenIndex int
+}
+
+// NewRuleTagToken constructs a new RuleTagToken with the specified rule
+// name, bypass token type, and label. An empty label means the tag is
+// unlabeled.
+func NewRuleTagToken(ruleName string, bypassTokenType int, label string) *RuleTagToken {
+	if ruleName == "" {
+		panic("ruleName cannot be empty.")
+	}
+
+	return &RuleTagToken{
+		ruleName:        ruleName,
+		bypassTokenType: bypassTokenType,
+		label:           label,
+		tokenIndex:      -1,
+	}
+}
+
+// GetRuleName gets the name of the rule associated with this rule tag.
+func (r *RuleTagToken) GetRuleName() string {
+	return r.ruleName
+}
+
+// GetLabel gets the label associated with the rule tag, or the empty string
+// if this is an unlabeled rule tag.
+func (r *RuleTagToken) GetLabel() string {
+	return r.label
+}
+
+// GetChannel returns TokenDefaultChannel; rule tag tokens are always placed
+// on the default channel.
+func (r *RuleTagToken) GetChannel() int {
+	return TokenDefaultChannel
+}
+
+// GetText returns the text of the tag, {@code <label:ruleName>} for a
+// labeled tag and {@code <ruleName>} otherwise.
+func (r *RuleTagToken) GetText() string {
+	if r.label != "" {
+		return "<" + r.label + ":" + r.ruleName + ">"
+	}
+
+	return "<" + r.ruleName + ">"
+}
+
+// SetText panics; the text of a rule tag token is derived from its rule
+// name and label.
+func (r *RuleTagToken) SetText(s string) {
+	panic("RuleTagToken text cannot be changed.")
+}
+
+// GetTokenType returns the bypass token type assigned to the rule.
+func (r *RuleTagToken) GetTokenType() int {
+	return r.bypassTokenType
+}
+
+func (r *RuleTagToken) GetLine() int {
+	return 0
+}
+
+func (r *RuleTagToken) GetColumn() int {
+	return -1
+}
+
+func (r *RuleTagToken) GetTokenIndex() int {
+	return r.tokenIndex
+}
+
+func (r *RuleTagToken) SetTokenIndex(v int) {
+	r.tokenIndex = v
+}
+
+func (r *RuleTagToken) GetStart() int {
+	return -1
+}
+
+func (r *RuleTagToken) GetStop() int {
+	return -1
+}
+
+func (r *RuleTagToken) GetSource() *TokenSourceCharStreamPair {
+	return &TokenSourceCharStreamPair{}
+}
+
+func (r *RuleTagToken) GetTokenSource() TokenSource {
+	return nil
+}
+
+func (r *RuleTagToken) GetInputStream() CharStream {
+	return nil
+}
+
+func (r *RuleTagToken) String() string {
+	return r.ruleName + ":" + strconv.Itoa(r.bypassTokenType)
+}
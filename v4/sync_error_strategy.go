@@ -0,0 +1,291 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+// ErrorRecovery records the recovery of SyncErrorStrategy from a syntax
+// error.
+type ErrorRecovery struct {
+	// Token is the token the error was found at.
+	Token Token
+
+	// RuleIndex is the index of the rule the error was found in.
+	RuleIndex int
+
+	// Skipped is the number of tokens consumed without being matched to
+	// recover from the error, including the tokens consumed while
+	// recovering from further errors that were not reported because the
+	// parser was still recovering.
+	Skipped int
+}
+
+// SyncErrorStrategy is a DefaultErrorStrategy that also resynchronizes at
+// synchronization tokens declared per rule at runtime, and counts the
+// tokens skipped to recover from each error.
+//
+// <p>When resynchronizing, DefaultErrorStrategy consumes tokens until one
+// that can follow a rule in the rule invocation stack. In languages with
+// delimited blocks, this often skips whole blocks, as the only tokens that
+// can follow a statement are the ones that can start one or end the
+// block. With</p>
+//
+// <pre>
+// strategy := antlr.NewSyncErrorStrategy()
+// strategy.SetSyncTokens(MyParserRULE_statement, MyParserSEMI, MyParserRBRACE)
+// parser.SetErrorHandler(strategy)
+// </pre>
+//
+// <p>resynchronizing also stops at {@code ';'} and {@code '}'} within a
+// statement. If the token it stops at is a synchronization token of the
+// rule recovering from the error and cannot follow the rule, it is
+// consumed as the end of the erroneous input, so that a statement with an
+// error ends at its {@code ';'}.</p>
+type SyncErrorStrategy struct {
+	*DefaultErrorStrategy
+
+	syncTokens map[int]*IntervalSet
+	recoveries []*ErrorRecovery
+}
+
+var _ ErrorStrategy = &SyncErrorStrategy{}
+
+func NewSyncErrorStrategy() *SyncErrorStrategy {
+	return &SyncErrorStrategy{
+		DefaultErrorStrategy: NewDefaultErrorStrategy(),
+		syncTokens:           make(map[int]*IntervalSet),
+	}
+}
+
+// SetSyncTokens sets the synchronization tokens of the rule with index
+// ruleIndex, replacing the ones set before. Without token types, the rule
+// has no synchronization tokens.
+func (s *SyncErrorStrategy) SetSyncTokens(ruleIndex int, tokenTypes ...int) {
+	if len(tokenTypes) == 0 {
+		delete(s.syncTokens, ruleIndex)
+		return
+	}
+
+	set := NewIntervalSet()
+	for _, t := range tokenTypes {
+		set.addOne(t)
+	}
+	s.syncTokens[ruleIndex] = set
+}
+
+// GetSyncTokens returns the synchronization tokens of the rule with index
+// ruleIndex.
+func (s *SyncErrorStrategy) GetSyncTokens(ruleIndex int) *IntervalSet {
+	if set, ok := s.syncTokens[ruleIndex]; ok {
+		return set
+	}
+
+	return NewIntervalSet()
+}
+
+// GetRecoveries returns the recoveries from the errors reported since the
+// parser was reset, in the order the errors were reported.
+func (s *SyncErrorStrategy) GetRecoveries() []*ErrorRecovery {
+	return s.recoveries
+}
+
+// GetSkippedTokens returns the number of tokens skipped to recover from
+// errors since the parser was reset.
+func (s *SyncErrorStrategy) GetSkippedTokens() int {
+	skipped := 0
+	for _, r := range s.recoveries {
+		skipped += r.Skipped
+	}
+
+	return skipped
+}
+
+func (s *SyncErrorStrategy) reset(recognizer Parser) {
+	s.recoveries = nil
+	s.DefaultErrorStrategy.reset(recognizer)
+}
+
+func (s *SyncErrorStrategy) ReportError(recognizer Parser, e RecognitionException) {
+	newError := !s.inErrorRecoveryMode(recognizer)
+	t := recognizer.GetCurrentToken()
+	s.DefaultErrorStrategy.ReportError(recognizer, e)
+	s.record(recognizer, newError, t, 0)
+}
+
+// Recover resynchronizes as DefaultErrorStrategy.Recover does, also
+// stopping at the synchronization tokens of the rules in the rule
+// invocation stack.
+func (s *SyncErrorStrategy) Recover(recognizer Parser, e RecognitionException) {
+	if s.lastErrorIndex == recognizer.GetInputStream().Index() &&
+		s.lastErrorStates != nil && s.lastErrorStates.contains(recognizer.GetState()) {
+		// Another error at the same token index and state: consume a
+		// single token at least to prevent an infinite loop
+		s.record(recognizer, false, recognizer.Consume(), 1)
+	}
+	s.lastErrorIndex = recognizer.GetInputStream().Index()
+	if s.lastErrorStates == nil {
+		s.lastErrorStates = NewIntervalSet()
+	}
+	s.lastErrorStates.addOne(recognizer.GetState())
+	followSet := s.getErrorRecoverySet(recognizer)
+	s.consumeUntilSync(recognizer, followSet, s.GetSyncTokens(recognizer.GetParserRuleContext().GetRuleIndex()))
+}
+
+// RecoverInline recovers as DefaultErrorStrategy.RecoverInline does,
+// counting the token single-token deletion skips.
+func (s *SyncErrorStrategy) RecoverInline(recognizer Parser) Token {
+	newError := !s.inErrorRecoveryMode(recognizer)
+	t := recognizer.GetCurrentToken()
+
+	// SINGLE TOKEN DELETION
+	if matchedSymbol := s.SingleTokenDeletion(recognizer); matchedSymbol != nil {
+		s.record(recognizer, newError, t, 1)
+		recognizer.Consume()
+		return matchedSymbol
+	}
+	// SINGLE TOKEN INSERTION
+	if s.SingleTokenInsertion(recognizer) {
+		s.record(recognizer, newError, t, 0)
+		return s.GetMissingSymbol(recognizer)
+	}
+	// even that didn't work must panic the exception
+	panic(NewInputMisMatchException(recognizer))
+}
+
+// Sync synchronizes as DefaultErrorStrategy.Sync does, except in loops:
+// when the next token cannot start an iteration of a loop, nor follow it,
+// tokens are consumed until one that can, or a synchronization token of a
+// rule that can start an iteration or of a rule in the rule invocation
+// stack. A synchronization token of a rule that can start an iteration is
+// consumed as well, unless it can start an iteration or follow the loop.
+//
+// <p>For example, with {@code ';'} a synchronization token of
+// {@code statement}, the loop of</p>
+//
+// <pre>
+// block : '{' statement* '}' ;
+// </pre>
+//
+// <p>skips to the next statement after {@code ';'} on input such as
+// {@code { 1 2; x = 3; }}, where DefaultErrorStrategy would bail out of
+// {@code block}.</p>
+//
+// <p>Loops with synchronization tokens are also synchronized while the
+// parser is recovering from an error, so that the loop is not left when
+// the statement with the error is followed by more erroneous input. Without
+// synchronization tokens, loops recover as with DefaultErrorStrategy.</p>
+func (s *SyncErrorStrategy) Sync(recognizer Parser) {
+	recovering := s.inErrorRecoveryMode(recognizer)
+
+	state := recognizer.GetInterpreter().atn.states[recognizer.GetState()]
+	la := recognizer.GetTokenStream().LA(1)
+
+	nextTokens := recognizer.GetATN().NextTokens(state, nil)
+	if nextTokens.contains(TokenEpsilon) || nextTokens.contains(la) {
+		return
+	}
+
+	t := recognizer.GetCurrentToken()
+	switch state.GetStateType() {
+	case ATNStateBlockStart, ATNStateStarBlockStart, ATNStatePlusBlockStart, ATNStateStarLoopEntry:
+		// Report error and recover if possible
+		if !recovering && s.SingleTokenDeletion(recognizer) != nil {
+			s.record(recognizer, true, t, 1)
+			return
+		}
+		if state.GetStateType() != ATNStateStarLoopEntry || len(s.getEnteredSyncTokens(recognizer, state).intervals) == 0 {
+			if recovering {
+				return
+			}
+			panic(NewInputMisMatchException(recognizer))
+		}
+		fallthrough
+	case ATNStatePlusLoopBack, ATNStateStarLoopBack:
+		// While recovering, only loops with synchronization tokens are
+		// synchronized, so that they are not left
+		if recovering && len(s.getEnteredSyncTokens(recognizer, state).intervals) == 0 {
+			return
+		}
+		s.ReportUnwantedToken(recognizer)
+		s.record(recognizer, !recovering, t, 0)
+		expecting := NewIntervalSet()
+		expecting.addSet(recognizer.GetExpectedTokens())
+		whatFollowsLoopIterationOrRule := expecting.addSet(s.getErrorRecoverySet(recognizer))
+		s.consumeUntilSync(recognizer, whatFollowsLoopIterationOrRule, s.getEnteredSyncTokens(recognizer, state))
+	default:
+		// do nothing if we can't identify the exact kind of ATN state
+	}
+}
+
+// getEnteredSyncTokens returns the synchronization tokens of the rules that
+// can be entered from state before a token is matched.
+func (s *SyncErrorStrategy) getEnteredSyncTokens(recognizer Parser, state ATNState) *IntervalSet {
+	set := NewIntervalSet()
+	if len(s.syncTokens) == 0 {
+		return set
+	}
+
+	visited := make(map[ATNState]bool)
+	var visit func(state ATNState)
+	visit = func(state ATNState) {
+		if visited[state] {
+			return
+		}
+		visited[state] = true
+
+		if _, ok := state.(*RuleStopState); ok {
+			return
+		}
+		for _, t := range state.GetTransitions() {
+			if rt, ok := t.(*RuleTransition); ok {
+				set.addSet(s.GetSyncTokens(rt.ruleIndex))
+			}
+			if t.getIsEpsilon() {
+				visit(t.getTarget())
+			}
+		}
+	}
+	visit(state)
+
+	return set
+}
+
+// consumeUntilSync consumes tokens until one in set, in consumed, or a
+// synchronization token of a rule in the rule invocation stack. If that
+// token is in consumed and not in set, it is consumed as well.
+func (s *SyncErrorStrategy) consumeUntilSync(recognizer Parser, set, consumed *IntervalSet) {
+	stop := NewIntervalSet()
+	stop.addSet(set)
+	stop.addSet(consumed)
+	for ctx := recognizer.GetParserRuleContext(); ctx != nil; ctx, _ = ctx.GetParent().(ParserRuleContext) {
+		stop.addSet(s.GetSyncTokens(ctx.GetRuleIndex()))
+	}
+
+	t := recognizer.GetCurrentToken()
+	skipped := 0
+	ttype := recognizer.GetTokenStream().LA(1)
+	for ttype != TokenEOF && !stop.contains(ttype) {
+		recognizer.Consume()
+		skipped++
+		ttype = recognizer.GetTokenStream().LA(1)
+	}
+
+	if ttype != TokenEOF && !set.contains(ttype) && consumed.contains(ttype) {
+		recognizer.Consume()
+		skipped++
+	}
+
+	s.record(recognizer, false, t, skipped)
+}
+
+// record adds skipped tokens to the recovery from the current error, or
+// from a new error found at t.
+func (s *SyncErrorStrategy) record(recognizer Parser, newError bool, t Token, skipped int) {
+	if newError || len(s.recoveries) == 0 {
+		s.recoveries = append(s.recoveries, &ErrorRecovery{
+			Token:     t,
+			RuleIndex: recognizer.GetParserRuleContext().GetRuleIndex(),
+		})
+	}
+	s.recoveries[len(s.recoveries)-1].Skipped += skipped
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"testing"
+)
+
+// Token types and rule indexes of the Brace grammar.
+const (
+	braceLBRACE = iota + 1
+	braceRBRACE
+	braceSEMI
+	braceASSIGN
+	bracePLUS
+	braceID
+	braceINT
+	braceWS
+)
+
+const (
+	braceRULE_prog = iota
+	braceRULE_block
+	braceRULE_stat
+	braceRULE_expr
+)
+
+var (
+	braceLiteralNames  = []string{"", "'{'", "'}'", "';'", "'='", "'+'"}
+	braceSymbolicNames = []string{"", "LBRACE", "RBRACE", "SEMI", "ASSIGN", "PLUS", "ID", "INT", "WS"}
+)
+
+// newBraceParser returns a parser of input for the grammar
+//
+// <pre>
+// prog : block EOF ;
+// block : '{' stat* '}' ;
+// stat : block | ID '=' expr ';' ;
+// expr : INT ('+' INT)* ;
+// </pre>
+func newBraceParser(input string) *ParserInterpreter {
+	l := NewLexerATNBuilder(braceWS)
+	for t, lit := range braceLiteralNames[1:] {
+		l.LexerRule(braceSymbolicNames[t+1], t+1, l.Literal(lit[1:len(lit)-1]))
+	}
+	l.LexerRule("ID", braceID, l.Plus(l.Range('a', 'z')))
+	l.LexerRule("INT", braceINT, l.Plus(l.Range('0', '9')))
+	l.LexerRule("WS", braceWS, l.Seq(l.Plus(l.Alts(l.Literal(" "), l.Literal("\n"))), l.Command(LexerSkipActionINSTANCE)))
+
+	p := NewParserATNBuilder(braceWS)
+	p.Rule("prog", p.Seq(p.RuleRef("block"), p.Atom(TokenEOF)))
+	p.Rule("block", p.Seq(p.Atom(braceLBRACE), p.Star(p.RuleRef("stat")), p.Atom(braceRBRACE)))
+	p.Rule("stat", p.Alts(p.RuleRef("block"), p.Seq(p.Atom(braceID), p.Atom(braceASSIGN), p.RuleRef("expr"), p.Atom(braceSEMI))))
+	p.Rule("expr", p.Seq(p.Atom(braceINT), p.Star(p.Seq(p.Atom(bracePLUS), p.Atom(braceINT)))))
+
+	lexer := NewLexerInterpreter("Brace.g4", braceLiteralNames, braceSymbolicNames, l.GetRuleNames(), nil, l.GetModeNames(), l.Build(), NewInputStream(input))
+	lexer.RemoveErrorListeners()
+	parser := NewParserInterpreter("Brace.g4", braceLiteralNames, braceSymbolicNames, p.GetRuleNames(), p.Build(), NewCommonTokenStream(lexer, TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+
+	return parser
+}
+
+// parseBrace parses input with the Brace grammar using strategy, and
+// returns the parse tree and the messages of the syntax errors.
+func parseBrace(input string, strategy ErrorStrategy) (string, []string) {
+	parser := newBraceParser(input)
+	parser.SetErrorHandler(strategy)
+	tree, syntaxErrors, _ := Parse(parser, func() ParserRuleContext {
+		return parser.Parse(braceRULE_prog)
+	})
+
+	var messages []string
+	for _, e := range syntaxErrors {
+		messages = append(messages, e.Msg)
+	}
+
+	return tree.ToStringTree(nil, parser), messages
+}
+
+func TestSyncErrorStrategy(t *testing.T) {
+	for _, test := range []struct {
+		input string
+
+		// tree and skipped are the parse tree and the tokens skipped per
+		// error without and with ';' a synchronization token of stat.
+		tree, syncTree       string
+		skipped, syncSkipped []int
+	}{
+		{
+			// A statement starting with the wrong token
+			input:       "{ a = 1 ; 2 2 ; b = 3 ; }",
+			tree:        "(prog (block { (stat a = (expr 1) ;) 2 2 ; b = 3 ; }) <EOF>)",
+			skipped:     []int{8},
+			syncTree:    "(prog (block { (stat a = (expr 1) ;) 2 2 ; (stat b = (expr 3) ;) }) <EOF>)",
+			syncSkipped: []int{3},
+		},
+		{
+			// A statement with an error, followed by more erroneous input
+			input:       "{ a = 1 2 3 ; 4 5 ; b = 2 ; c = 3 4 ; }",
+			tree:        "(prog (block { (stat a = (expr 1) 2 3 ; 4 5 ;) (stat b = (expr 2) ;) (stat c = (expr 3) 4 ;) }) <EOF>)",
+			skipped:     []int{6, 1},
+			syncTree:    "(prog (block { (stat a = (expr 1) 2 3 ;) 4 5 ; (stat b = (expr 2) ;) (stat c = (expr 3) 4 ;) }) <EOF>)",
+			syncSkipped: []int{6, 1},
+		},
+	} {
+		for _, sync := range []bool{false, true} {
+			strategy := NewSyncErrorStrategy()
+			tree, skipped := test.tree, test.skipped
+			if sync {
+				strategy.SetSyncTokens(braceRULE_stat, braceSEMI)
+				tree, skipped = test.syncTree, test.syncSkipped
+			}
+
+			got, messages := parseBrace(test.input, strategy)
+			if got != tree {
+				t.Errorf("%q, sync %v: expected tree %s, got %s", test.input, sync, tree, got)
+			}
+
+			recoveries := strategy.GetRecoveries()
+			if len(recoveries) != len(skipped) || len(messages) != len(skipped) {
+				t.Errorf("%q, sync %v: expected %d errors, got %q", test.input, sync, len(skipped), messages)
+				continue
+			}
+			total := 0
+			for i, r := range recoveries {
+				if r.Skipped != skipped[i] {
+					t.Errorf("%q, sync %v: expected %d tokens skipped for error %d, got %d", test.input, sync, skipped[i], i, r.Skipped)
+				}
+				total += r.Skipped
+			}
+			if strategy.GetSkippedTokens() != total {
+				t.Errorf("%q, sync %v: expected %d tokens skipped, got %d", test.input, sync, total, strategy.GetSkippedTokens())
+			}
+		}
+	}
+}
+
+func TestSyncErrorStrategyRecoveries(t *testing.T) {
+	strategy := NewSyncErrorStrategy()
+	strategy.SetSyncTokens(braceRULE_stat, braceSEMI, braceRBRACE)
+	if got := strategy.GetSyncTokens(braceRULE_stat).String(); got != "2..3" {
+		t.Errorf("expected sync tokens 2..3, got %s", got)
+	}
+
+	parser := newBraceParser("{ a 1 ; b = 2 2 ; }")
+	parser.SetErrorHandler(strategy)
+	parser.Parse(braceRULE_prog)
+
+	recoveries := strategy.GetRecoveries()
+	if len(recoveries) != 2 {
+		t.Fatalf("expected 2 recoveries, got %v", recoveries)
+	}
+	if r := recoveries[0]; r.Token.GetText() != "1" || r.RuleIndex != braceRULE_stat || r.Skipped != 0 {
+		t.Errorf("expected the insertion of '=' in stat, got %+v", r)
+	}
+	if r := recoveries[1]; r.Token.GetText() != "2" || r.RuleIndex != braceRULE_stat || r.Skipped != 1 {
+		t.Errorf("expected the deletion of '2' in stat, got %+v", r)
+	}
+
+	parser.SetInputStream(newBraceParser("{ }").GetTokenStream())
+	if len(strategy.GetRecoveries()) != 0 || strategy.GetSkippedTokens() != 0 {
+		t.Error("expected no recoveries after reset")
+	}
+
+	strategy.SetSyncTokens(braceRULE_stat)
+	if len(strategy.GetSyncTokens(braceRULE_stat).intervals) != 0 {
+		t.Error("expected no sync tokens")
+	}
+}
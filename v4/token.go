@@ -48,6 +48,7 @@ type BaseToken struct {
 	column     int    // beginning of the line at which it occurs, 0..n-1
 	text       string // text of the token.
 	readOnly   bool
+	missing    bool // conjured up by error recovery rather than read from the input
 }
 
 const (
@@ -117,6 +118,32 @@ func (b *BaseToken) GetInputStream() CharStream {
 	return b.source.charStream
 }
 
+// IsMissing reports whether the token was conjured up by an error strategy
+// to stand for a token missing from the input.
+func (b *BaseToken) IsMissing() bool {
+	return b.missing
+}
+
+func (b *BaseToken) SetMissing(missing bool) {
+	b.missing = missing
+}
+
+// IsMissingToken reports whether t was conjured up by an error strategy to
+// stand for a token missing from the input. Its text, such as
+// {@code <missing ';'>}, is not part of the input.
+func IsMissingToken(t Token) bool {
+	m, ok := t.(interface{ IsMissing() bool })
+	return ok && m.IsMissing()
+}
+
+// markMissingToken marks t as conjured up by an error strategy, if t
+// supports it.
+func markMissingToken(t Token) {
+	if m, ok := t.(interface{ SetMissing(bool) }); ok {
+		m.SetMissing(true)
+	}
+}
+
 type CommonToken struct {
 	*BaseToken
 }
@@ -169,6 +196,7 @@ func (c *CommonToken) clone() *CommonToken {
 	t.line = c.GetLine()
 	t.column = c.GetColumn()
 	t.text = c.GetText()
+	t.missing = c.missing
 	return t
 }
 
@@ -0,0 +1,58 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import "strconv"
+
+// TokenTagToken is a Token object representing a token of a particular type;
+// e.g., {@code <ID>}. These tokens are created for TagChunk chunks where the
+// tag corresponds to a lexer rule or token type.
+type TokenTagToken struct {
+	*CommonToken
+
+	// tokenName is the name of the token associated with this token tag.
+	tokenName string
+
+	// label is the label associated with the token tag, or the empty string
+	// if the token tag is unlabeled.
+	label string
+}
+
+// NewTokenTagToken constructs a new TokenTagToken with the specified token
+// name, type, and label. An empty label means the tag is unlabeled.
+func NewTokenTagToken(tokenName string, tokenType int, label string) *TokenTagToken {
+	t := new(TokenTagToken)
+
+	t.CommonToken = NewCommonToken(&TokenSourceCharStreamPair{}, tokenType, TokenDefaultChannel, -1, -1)
+	t.tokenName = tokenName
+	t.label = label
+
+	return t
+}
+
+// GetTokenName gets the token name.
+func (t *TokenTagToken) GetTokenName() string {
+	return t.tokenName
+}
+
+// GetLabel gets the label associated with the token tag, or the empty
+// string if this is an unlabeled token tag.
+func (t *TokenTagToken) GetLabel() string {
+	return t.label
+}
+
+// GetText returns the text of the tag, {@code <label:tokenName>} for a
+// labeled tag and {@code <tokenName>} otherwise.
+func (t *TokenTagToken) GetText() string {
+	if t.label != "" {
+		return "<" + t.label + ":" + t.tokenName + ">"
+	}
+
+	return "<" + t.tokenName + ">"
+}
+
+func (t *TokenTagToken) String() string {
+	return t.tokenName + ":" + strconv.Itoa(t.tokenType)
+}
@@ -55,6 +55,15 @@ type ErrorNode interface {
 	errorNode()
 }
 
+// MissingNode is an ErrorNode for a token conjured up by error recovery to
+// stand for a token missing from the input. Its GetText is empty, so that
+// the text of the tree is the text of the input.
+type MissingNode interface {
+	ErrorNode
+
+	missingNode()
+}
+
 type ParserTreeVisitorHandlers interface {
 }
 
@@ -73,6 +82,13 @@ type TerminalVisitor interface {
 type ErrorNodeVisitor interface {
 	VisitErrorNode(node ErrorNode)
 }
+
+// MissingNodeVisitor is implemented by visitors and listeners that handle
+// missing nodes apart from other error nodes. Visitors and listeners that
+// do not implement it get missing nodes in VisitErrorNode.
+type MissingNodeVisitor interface {
+	VisitMissingNode(node MissingNode)
+}
 type AggregateResultVisitor interface {
 	AggregateResult(aggregate, nextResult interface{}) (result interface{})
 }
@@ -129,10 +145,12 @@ func (*BaseParseTreeVisitor) VisitChildren(node RuleNode, delegate ParseTreeVisi
 			continue
 		}
 		switch child := child.(type) {
-		case TerminalNode:
-			delegate.VisitTerminal(child)
+		case MissingNode:
+			visitMissingNode(delegate, child)
 		case ErrorNode:
 			delegate.VisitErrorNode(child)
+		case TerminalNode:
+			delegate.VisitTerminal(child)
 		case RuleNode:
 			if isRestCk && !rest.VisitRest(child, result) {
 				break
@@ -279,6 +297,46 @@ func (e *ErrorNodeImpl) Visit(v ParseTreeVisitor, args ...interface{}) interface
 	return nil
 }
 
+// Represents a token conjured up during error recovery, such as the token
+// single token insertion assumes is missing. Its symbol is marked as
+// missing (see {@link IsMissingToken}). String and ToStringTree return the
+// text of the symbol, such as {@code <missing ';'>}, but GetText returns
+// the empty string, as the token is not part of the input.
+
+type MissingNodeImpl struct {
+	*ErrorNodeImpl
+}
+
+var _ MissingNode = &MissingNodeImpl{}
+
+func NewMissingNodeImpl(token Token) *MissingNodeImpl {
+	markMissingToken(token)
+	mn := new(MissingNodeImpl)
+	mn.ErrorNodeImpl = NewErrorNodeImpl(token)
+	return mn
+}
+
+func (m *MissingNodeImpl) missingNode() {}
+
+func (m *MissingNodeImpl) GetText() string {
+	return ""
+}
+
+func (m *MissingNodeImpl) Visit(v ParseTreeVisitor, args ...interface{}) interface{} {
+	visitMissingNode(v, m)
+	return nil
+}
+
+// visitMissingNode calls VisitMissingNode of v if v is a
+// MissingNodeVisitor, and VisitErrorNode otherwise.
+func visitMissingNode(v ErrorNodeVisitor, node MissingNode) {
+	if mv, ok := v.(MissingNodeVisitor); ok {
+		mv.VisitMissingNode(node)
+	} else {
+		v.VisitErrorNode(node)
+	}
+}
+
 type ParseTreeWalker struct {
 }
 
@@ -288,6 +346,8 @@ func NewParseTreeWalker() *ParseTreeWalker {
 
 func (p *ParseTreeWalker) Walk(listener ParseTreeListener, t Tree) {
 	switch tt := t.(type) {
+	case MissingNode:
+		visitMissingNode(listener, tt)
 	case ErrorNode:
 		listener.VisitErrorNode(tt)
 	case TerminalNode:
@@ -0,0 +1,101 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strings"
+	"testing"
+)
+
+// nodeRecorder records the texts of the terminal, error and missing nodes
+// it visits, as a listener and as a visitor.
+type nodeRecorder struct {
+	BaseParseTreeListener
+	BaseParseTreeVisitor
+
+	terminals, errorNodes []string
+}
+
+func (r *nodeRecorder) VisitTerminal(node TerminalNode) {
+	r.terminals = append(r.terminals, node.GetText())
+}
+
+func (r *nodeRecorder) VisitErrorNode(node ErrorNode) {
+	r.errorNodes = append(r.errorNodes, node.GetText())
+}
+
+// missingNodeRecorder is a nodeRecorder that records missing nodes apart.
+type missingNodeRecorder struct {
+	nodeRecorder
+
+	missingNodes []string
+}
+
+func (r *missingNodeRecorder) VisitMissingNode(node MissingNode) {
+	r.missingNodes = append(r.missingNodes, node.GetSymbol().GetText())
+}
+
+func TestMissingNode(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		strategy ErrorStrategy
+		tree     string
+		text     string
+		missing  string
+	}{
+		{"(1;", NewDefaultErrorStrategy(), "(prog (stat (expr ( (expr 1) <missing ')'>) ;) <EOF>)", "(1;<EOF>", "<missing ')'>"},
+		{"x = (1 + 2", NewRepairErrorStrategy(), "(prog (stat x = (expr ( (expr (expr 1) + (expr 2)) <missing ')'>) <missing ';'>) <EOF>)", "x=(1+2<EOF>", "<missing ')'> <missing ';'>"},
+		{"x = 1 +* 2;", NewRepairErrorStrategy(), "(prog (stat x = (expr (expr 1) + (expr * 2)) ;) <EOF>)", "x=1+*2;<EOF>", ""},
+	} {
+		parser := newQuietExprParser(test.input)
+		parser.SetErrorHandler(test.strategy)
+		tree := parser.Parse(ExprParserRULE_prog)
+
+		if got := tree.ToStringTree(nil, parser); got != test.tree {
+			t.Errorf("%q: expected tree %s, got %s", test.input, test.tree, got)
+		}
+		if got := TreesStringTree(tree, nil, parser); got != test.tree {
+			t.Errorf("%q: expected string tree %s, got %s", test.input, test.tree, got)
+		}
+		if got := tree.GetText(); got != test.text {
+			t.Errorf("%q: expected text %q, got %q", test.input, test.text, got)
+		}
+
+		// Listeners and visitors without VisitMissingNode get missing nodes
+		// as error nodes, with no text.
+		listener := &nodeRecorder{}
+		ParseTreeWalkerDefault.Walk(listener, tree)
+		visitor := &nodeRecorder{}
+		tree.Visit(visitor)
+		for _, r := range []*nodeRecorder{listener, visitor} {
+			for _, text := range r.errorNodes {
+				if strings.HasPrefix(text, "<missing") {
+					t.Errorf("%q: expected missing nodes with no text, got %q", test.input, r.errorNodes)
+				}
+			}
+		}
+
+		missingListener := &missingNodeRecorder{}
+		ParseTreeWalkerDefault.Walk(missingListener, tree)
+		missingVisitor := &missingNodeRecorder{}
+		tree.Visit(missingVisitor)
+		for _, r := range []*missingNodeRecorder{missingListener, missingVisitor} {
+			if got := strings.Join(r.missingNodes, " "); got != test.missing {
+				t.Errorf("%q: expected missing nodes %s, got %s", test.input, test.missing, got)
+			}
+			if len(r.errorNodes)+len(r.missingNodes) != len(listener.errorNodes) {
+				t.Errorf("%q: expected missing nodes apart from error nodes %q, got %q", test.input, listener.errorNodes, r.errorNodes)
+			}
+		}
+
+		// The ')' nodes are missing nodes exactly if their tokens are missing.
+		for _, node := range TreesFindAllTokenNodes(tree, 6) {
+			_, missing := node.(MissingNode)
+			if token := node.(TerminalNode).GetSymbol(); IsMissingToken(token) != missing {
+				t.Errorf("%q: expected the token of %v to be marked missing %v", test.input, node, missing)
+			}
+		}
+	}
+}
@@ -103,11 +103,14 @@ func TreesfindAllRuleNodes(t ParseTree, ruleIndex int) []ParseTree {
 
 func TreesfindAllNodes(t ParseTree, index int, findTokens bool) []ParseTree {
 	nodes := make([]ParseTree, 0)
-	TreesFindAllNodes(t, index, findTokens, nodes)
+	TreesFindAllNodes(t, index, findTokens, &nodes)
 	return nodes
 }
 
-func TreesFindAllNodes(t ParseTree, index int, findTokens bool, nodes []ParseTree) {
+// TreesFindAllNodes appends to nodes every node at or below t that is a token
+// of type index (findTokens) or a context of rule index (!findTokens), in
+// pre-order.
+func TreesFindAllNodes(t ParseTree, index int, findTokens bool, nodes *[]ParseTree) {
 	// check this node (the root) first
 
 	t2, ok := t.(TerminalNode)
@@ -115,11 +118,11 @@ func TreesFindAllNodes(t ParseTree, index int, findTokens bool, nodes []ParseTre
 
 	if findTokens && ok {
 		if t2.GetSymbol().GetTokenType() == index {
-			nodes = append(nodes, t2)
+			*nodes = append(*nodes, t2)
 		}
 	} else if !findTokens && ok2 {
 		if t3.GetRuleIndex() == index {
-			nodes = append(nodes, t3)
+			*nodes = append(*nodes, t3)
 		}
 	}
 	// check children
@@ -0,0 +1,295 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// UnbufferedCharStream does not buffer all characters, unlike InputStream.
+// It only buffers characters from the oldest outstanding Mark up to the
+// current index, so the input may be arbitrarily large.
+//
+// <p>Text can only be retrieved for characters still in the buffer, which
+// means a lexer reading from an UnbufferedCharStream must copy token text
+// when the token is created. Use a token factory that copies text, such as
+// {@code NewCommonTokenFactory(true)}, with such a lexer.</p>
+type UnbufferedCharStream struct {
+	// Name is the name of the source, returned by GetSourceName.
+	Name string
+
+	input io.RuneReader
+
+	// data is a moving window buffer of the data being scanned. While there
+	// are outstanding markers, the buffer keeps growing; when all markers
+	// are released, the consumed part of the buffer is dropped.
+	data []rune
+
+	// n is the number of characters currently in data.
+	n int
+
+	// p is the index into data of the next character, data[p] is LA(1).
+	// If p == n, we are out of buffered characters.
+	p int
+
+	// numMarkers is the number of outstanding markers. Only when it is 0
+	// can data be released.
+	numMarkers int
+
+	// lastChar is LA(-1), the last character consumed.
+	lastChar int
+
+	// lastCharBufferStart is LA(-1) at the time of the first Mark, when
+	// the buffer restarts.
+	lastCharBufferStart int
+
+	// currentCharIndex is the absolute character index. It is the index of
+	// the character about to be read via LA(1). It goes from 0 to the
+	// number of characters in the entire stream, although the stream size
+	// is unknown before the end is reached.
+	currentCharIndex int
+}
+
+// NewUnbufferedCharStream creates an UnbufferedCharStream reading UTF-8
+// encoded text from input.
+func NewUnbufferedCharStream(input io.Reader) *UnbufferedCharStream {
+	return NewUnbufferedCharStreamSize(input, 256)
+}
+
+// NewUnbufferedCharStreamSize creates an UnbufferedCharStream reading UTF-8
+// encoded text from input, with an initial buffer of bufferSize characters.
+func NewUnbufferedCharStreamSize(input io.Reader, bufferSize int) *UnbufferedCharStream {
+	u := new(UnbufferedCharStream)
+
+	if rr, ok := input.(io.RuneReader); ok {
+		u.input = rr
+	} else {
+		u.input = bufio.NewReader(input)
+	}
+	u.data = make([]rune, intMax(bufferSize, 1))
+	u.lastChar = -1
+	u.fill(1) // prime
+
+	return u
+}
+
+func (u *UnbufferedCharStream) Consume() {
+	if u.LA(1) == TokenEOF {
+		panic("cannot consume EOF")
+	}
+
+	// buf always has at least data[p==0] in this method due to ctor
+	u.lastChar = int(u.data[u.p]) // track last char for LA(-1)
+
+	if u.p == u.n-1 && u.numMarkers == 0 {
+		u.n = 0
+		u.p = -1 // p++ will leave this at 0
+		u.lastCharBufferStart = u.lastChar
+	}
+
+	u.p++
+	u.currentCharIndex++
+	u.sync(1)
+}
+
+// sync makes sure we have want elements from the current position p.
+// The last valid p index is len(data)-1; p+want-1 is the data index
+// want-1 elements ahead of the current position.
+func (u *UnbufferedCharStream) sync(want int) {
+	need := (u.p + want - 1) - u.n + 1 // how many more elements we need?
+	if need > 0 {
+		u.fill(need)
+	}
+}
+
+// fill adds n characters to the buffer and returns the number of
+// characters actually added. The return value is less than n if and only
+// if the end of the input was reached; TokenEOF is then buffered as the
+// last element.
+//
+// @panics if reading the input fails.
+func (u *UnbufferedCharStream) fill(n int) int {
+	for i := 0; i < n; i++ {
+		if u.n > 0 && u.data[u.n-1] == TokenEOF {
+			return i
+		}
+
+		c, _, err := u.input.ReadRune()
+		if err == io.EOF {
+			u.add(TokenEOF)
+		} else if err != nil {
+			panic(err)
+		} else {
+			u.add(c)
+		}
+	}
+
+	return n
+}
+
+func (u *UnbufferedCharStream) add(c rune) {
+	if u.n >= len(u.data) {
+		data := make([]rune, 2*len(u.data))
+		copy(data, u.data)
+		u.data = data
+	}
+	u.data[u.n] = c
+	u.n++
+}
+
+func (u *UnbufferedCharStream) LA(i int) int {
+	if i == -1 {
+		return u.lastChar // special case
+	}
+	u.sync(i)
+	index := u.p + i - 1
+	if index < 0 {
+		panic("index out of range: LA(" + strconv.Itoa(i) + ")")
+	}
+	if index >= u.n {
+		return TokenEOF
+	}
+
+	return int(u.data[index])
+}
+
+// Mark returns a marker that can be passed to Release to release the
+// buffer. Seek is only possible to indexes between the oldest outstanding
+// marker and the current index. Markers must be released in the reverse
+// order they were returned.
+//
+// <p>The returned value is negative so that it cannot be confused with a
+// character index.</p>
+func (u *UnbufferedCharStream) Mark() int {
+	if u.numMarkers == 0 {
+		u.lastCharBufferStart = u.lastChar
+	}
+
+	mark := -u.numMarkers - 1
+	u.numMarkers++
+	return mark
+}
+
+// Release decrements the number of outstanding markers. When the last
+// marker is released, the characters before the current index are dropped
+// from the buffer.
+//
+// @panics if marker is not the most recently returned outstanding marker.
+func (u *UnbufferedCharStream) Release(marker int) {
+	expectedMark := -u.numMarkers
+	if marker != expectedMark {
+		panic("release() called with an invalid marker.")
+	}
+
+	u.numMarkers--
+	if u.numMarkers == 0 && u.p > 0 { // release buffer when we can, but don't do unnecessary work
+		// Copy data[p]..data[n-1] to data[0]..data[(n-1)-p], reset ptrs
+		copy(u.data, u.data[u.p:u.n])
+		u.n = u.n - u.p
+		u.p = 0
+		u.lastCharBufferStart = u.lastChar
+	}
+}
+
+func (u *UnbufferedCharStream) Index() int {
+	return u.currentCharIndex
+}
+
+// Seek moves the stream to index. Seeking forward reads ahead as needed;
+// seeking backward is only possible within the buffer, i.e. to indexes at
+// or after the oldest outstanding Mark.
+//
+// @panics if index is outside the buffer.
+func (u *UnbufferedCharStream) Seek(index int) {
+	if index == u.currentCharIndex {
+		return
+	}
+
+	if index > u.currentCharIndex {
+		u.sync(index - u.currentCharIndex)
+		index = intMin(index, u.getBufferStartIndex()+u.n-1)
+	}
+
+	// index == to bufferStartIndex should set p to 0
+	i := index - u.getBufferStartIndex()
+	if index < 0 {
+		panic("cannot seek to negative index " + strconv.Itoa(index))
+	} else if i < 0 || i >= u.n {
+		panic("seek to index outside buffer: " + strconv.Itoa(index) + " not in " +
+			strconv.Itoa(u.getBufferStartIndex()) + ".." + strconv.Itoa(u.getBufferStartIndex()+u.n-1))
+	}
+
+	u.p = i
+	u.currentCharIndex = index
+	if u.p == 0 {
+		u.lastChar = u.lastCharBufferStart
+	} else {
+		u.lastChar = int(u.data[u.p-1])
+	}
+}
+
+// Size returns the number of characters in the stream.
+//
+// @panics if the end of the input has not been reached yet, as the size
+// is not known before then.
+func (u *UnbufferedCharStream) Size() int {
+	if u.n > 0 && u.data[u.n-1] == TokenEOF {
+		return u.getBufferStartIndex() + u.n - 1
+	}
+
+	panic("Unbuffered stream cannot know its size")
+}
+
+func (u *UnbufferedCharStream) GetSourceName() string {
+	if u.Name == "" {
+		return "<unknown>"
+	}
+
+	return u.Name
+}
+
+// GetText returns the text of the characters start..stop, inclusive.
+//
+// @panics if the characters are no longer, or not yet, in the buffer.
+func (u *UnbufferedCharStream) GetText(start, stop int) string {
+	if start < 0 || stop < start-1 {
+		panic("invalid interval " + strconv.Itoa(start) + ".." + strconv.Itoa(stop))
+	}
+
+	bufferStartIndex := u.getBufferStartIndex()
+	end := bufferStartIndex + u.n
+	if u.n > 0 && u.data[u.n-1] == TokenEOF {
+		end-- // the EOF marker has no text
+		if stop >= end {
+			panic("the interval " + strconv.Itoa(start) + ".." + strconv.Itoa(stop) + " extends past the end of the stream")
+		}
+	}
+	if start < bufferStartIndex || stop >= end {
+		panic("interval " + strconv.Itoa(start) + ".." + strconv.Itoa(stop) + " outside buffer: " +
+			strconv.Itoa(bufferStartIndex) + ".." + strconv.Itoa(end-1))
+	}
+
+	// convert from absolute to local index
+	i := start - bufferStartIndex
+	return string(u.data[i : i+stop-start+1])
+}
+
+func (u *UnbufferedCharStream) GetTextFromTokens(start, stop Token) string {
+	if start != nil && stop != nil {
+		return u.GetText(start.GetStart(), stop.GetStop())
+	}
+
+	return ""
+}
+
+func (u *UnbufferedCharStream) GetTextFromInterval(i *Interval) string {
+	return u.GetText(i.Start, i.Stop)
+}
+
+func (u *UnbufferedCharStream) getBufferStartIndex() int {
+	return u.currentCharIndex - u.p
+}
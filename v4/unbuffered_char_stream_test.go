@@ -0,0 +1,120 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnbufferedCharStreamConsume(t *testing.T) {
+	input := NewUnbufferedCharStreamSize(strings.NewReader("xyé"), 1)
+
+	for i, c := range []int{'x', 'y', 'é'} {
+		if got := input.Index(); got != i {
+			t.Errorf("expected index %d, got %d", i, got)
+		}
+		if got := input.LA(1); got != c {
+			t.Errorf("expected LA(1) %q, got %q", c, got)
+		}
+		input.Consume()
+		if got := input.LA(-1); got != c {
+			t.Errorf("expected LA(-1) %q, got %q", c, got)
+		}
+	}
+
+	if got := input.LA(1); got != TokenEOF {
+		t.Errorf("expected EOF, got %d", got)
+	}
+	if got := input.Size(); got != 3 {
+		t.Errorf("expected size 3, got %d", got)
+	}
+	// nothing was marked, so the buffer only ever holds LA(1)
+	if got := len(input.data); got != 1 {
+		t.Errorf("expected buffer of 1, got %d", got)
+	}
+}
+
+func TestUnbufferedCharStreamMarkRelease(t *testing.T) {
+	input := NewUnbufferedCharStreamSize(strings.NewReader("abcdef"), 1)
+
+	input.Consume() // a
+	m1 := input.Mark()
+	input.Consume() // b
+	m2 := input.Mark()
+	input.Consume() // c
+	input.Consume() // d
+
+	if got := input.GetText(1, 3); got != "bcd" {
+		t.Errorf("expected bcd, got %s", got)
+	}
+
+	input.Seek(2)
+	if got := input.LA(1); got != 'c' {
+		t.Errorf("expected c after seek, got %q", got)
+	}
+	if got := input.LA(-1); got != 'b' {
+		t.Errorf("expected LA(-1) b after seek, got %q", got)
+	}
+	input.Seek(1)
+	if got := input.LA(-1); got != 'a' {
+		t.Errorf("expected LA(-1) a after seek to buffer start, got %q", got)
+	}
+	input.Seek(4)
+
+	input.Release(m2)
+	input.Release(m1)
+
+	if got := input.GetText(4, 4); got != "e" {
+		t.Errorf("expected e, got %s", got)
+	}
+	expectPanic(t, "interval 3..4 outside buffer: 4..4", func() { input.GetText(3, 4) })
+	expectPanic(t, "seek to index outside buffer: 1 not in 4..4", func() { input.Seek(1) })
+	expectPanic(t, "Unbuffered stream cannot know its size", func() { input.Size() })
+}
+
+func TestUnbufferedCharStreamReleaseOutOfOrder(t *testing.T) {
+	input := NewUnbufferedCharStream(strings.NewReader("ab"))
+
+	m1 := input.Mark()
+	input.Mark()
+	expectPanic(t, "release() called with an invalid marker.", func() { input.Release(m1) })
+}
+
+func TestUnbufferedCharStreamLexer(t *testing.T) {
+	text := strings.Repeat("x = 12 * (y + 3);\n", 1000)
+	lexer := newExprLexer(NewUnbufferedCharStreamSize(strings.NewReader(text), 4))
+	lexer.SetTokenFactory(NewCommonTokenFactory(true))
+
+	var b strings.Builder
+	n := 0
+	for tok := lexer.NextToken(); tok.GetTokenType() != TokenEOF; tok = lexer.NextToken() {
+		if tok.GetChannel() == TokenDefaultChannel {
+			b.WriteString(tok.GetText())
+			n++
+		}
+	}
+
+	if n != 10*1000 {
+		t.Errorf("expected %d tokens, got %d", 10*1000, n)
+	}
+	if expected := strings.Replace(strings.Replace(text, " ", "", -1), "\n", "", -1); b.String() != expected {
+		t.Errorf("token text does not match input")
+	}
+	// the buffer only grows to hold the longest token
+	if got := len(lexer.GetInputStream().(*UnbufferedCharStream).data); got > 4 {
+		t.Errorf("expected buffer to stay at 4, got %d", got)
+	}
+}
+
+func expectPanic(t *testing.T, expected string, f func()) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != expected {
+			t.Errorf("expected panic %q, got %v", expected, r)
+		}
+	}()
+	f()
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strconv"
+)
+
+// UnbufferedTokenStream is a TokenStream that does not buffer all tokens,
+// unlike CommonTokenStream. It only buffers tokens from the oldest
+// outstanding Mark up to the current index, so a parse over an
+// arbitrarily long token stream runs in memory bounded by the lookahead
+// the parser needs.
+//
+// <p>Tokens outside the buffer window can no longer be retrieved with Get,
+// and their text can no longer be retrieved with the GetText methods. The
+// token objects themselves, as referenced by a parse tree, remain valid.</p>
+type UnbufferedTokenStream struct {
+	tokenSource TokenSource
+
+	// tokens is a moving window buffer of the data being scanned. While
+	// there are outstanding markers, the buffer keeps growing; when all
+	// markers are released, the consumed part of the buffer is dropped.
+	tokens []Token
+
+	// n is the number of tokens currently in tokens.
+	n int
+
+	// p is the index into tokens of the current token, tokens[p] is LT(1).
+	// If p == n, we are out of buffered tokens.
+	p int
+
+	// numMarkers is the number of outstanding markers. Only when it is 0
+	// can tokens be released.
+	numMarkers int
+
+	// lastToken is LT(-1), the last token consumed.
+	lastToken Token
+
+	// lastTokenBufferStart is LT(-1) at the time of the first Mark, when
+	// the buffer restarts.
+	lastTokenBufferStart Token
+
+	// currentTokenIndex is the absolute token index. It is the index of the
+	// token about to be read via LT(1). It goes from 0 to the number of
+	// tokens in the entire stream, although the stream size is unknown
+	// before the end is reached.
+	//
+	// This value is used to set the token indexes if the stream provides
+	// tokens that implement SetTokenIndex.
+	currentTokenIndex int
+}
+
+// NewUnbufferedTokenStream creates an UnbufferedTokenStream reading tokens
+// from tokenSource.
+func NewUnbufferedTokenStream(tokenSource TokenSource) *UnbufferedTokenStream {
+	return NewUnbufferedTokenStreamSize(tokenSource, 256)
+}
+
+// NewUnbufferedTokenStreamSize creates an UnbufferedTokenStream reading
+// tokens from tokenSource, with an initial buffer of bufferSize tokens.
+func NewUnbufferedTokenStreamSize(tokenSource TokenSource, bufferSize int) *UnbufferedTokenStream {
+	u := new(UnbufferedTokenStream)
+
+	u.tokenSource = tokenSource
+	u.tokens = make([]Token, intMax(bufferSize, 1))
+	u.fill(1) // prime the pump
+
+	return u
+}
+
+// Get returns the token at absolute index i.
+//
+// @panics if the token is no longer, or not yet, in the buffer window.
+func (u *UnbufferedTokenStream) Get(i int) Token {
+	bufferStartIndex := u.getBufferStartIndex()
+	if i < bufferStartIndex || i >= bufferStartIndex+u.n {
+		panic("get(" + strconv.Itoa(i) + ") outside buffer: " +
+			strconv.Itoa(bufferStartIndex) + ".." + strconv.Itoa(bufferStartIndex+u.n-1))
+	}
+
+	return u.tokens[i-bufferStartIndex]
+}
+
+func (u *UnbufferedTokenStream) LT(i int) Token {
+	if i == -1 {
+		return u.lastToken
+	}
+
+	u.sync(i)
+	index := u.p + i - 1
+	if index < 0 {
+		panic("LT(" + strconv.Itoa(i) + ") gives negative index")
+	}
+
+	if index >= u.n {
+		// the last buffered token is EOF
+		return u.tokens[u.n-1]
+	}
+
+	return u.tokens[index]
+}
+
+func (u *UnbufferedTokenStream) LA(i int) int {
+	return u.LT(i).GetTokenType()
+}
+
+func (u *UnbufferedTokenStream) GetTokenSource() TokenSource {
+	return u.tokenSource
+}
+
+// SetTokenSource resets the stream to read tokens from tokenSource.
+func (u *UnbufferedTokenStream) SetTokenSource(tokenSource TokenSource) {
+	u.tokenSource = tokenSource
+	u.n = 0
+	u.p = 0
+	u.numMarkers = 0
+	u.lastToken = nil
+	u.lastTokenBufferStart = nil
+	u.currentTokenIndex = 0
+	u.fill(1)
+}
+
+func (u *UnbufferedTokenStream) Consume() {
+	if u.LA(1) == TokenEOF {
+		panic("cannot consume EOF")
+	}
+
+	// buf always has at least tokens[p==0] in this method due to ctor
+	u.lastToken = u.tokens[u.p] // track last token for LT(-1)
+
+	// if we're at last token and no markers, opportunity to flush buffer
+	if u.p == u.n-1 && u.numMarkers == 0 {
+		u.n = 0
+		u.p = -1 // p++ will leave this at 0
+		u.lastTokenBufferStart = u.lastToken
+	}
+
+	u.p++
+	u.currentTokenIndex++
+	u.sync(1)
+}
+
+// sync makes sure we have want elements from the current position p.
+// The last valid p index is len(tokens)-1; p+want-1 is the tokens index
+// want-1 elements ahead of the current position.
+func (u *UnbufferedTokenStream) sync(want int) {
+	need := (u.p + want - 1) - u.n + 1 // how many more elements we need?
+	if need > 0 {
+		u.fill(need)
+	}
+}
+
+// fill adds n elements to the buffer and returns the number of elements
+// actually added. The return value is less than n if and only if EOF was
+// reached before n tokens could be added.
+func (u *UnbufferedTokenStream) fill(n int) int {
+	for i := 0; i < n; i++ {
+		if u.n > 0 && u.tokens[u.n-1].GetTokenType() == TokenEOF {
+			return i
+		}
+
+		u.add(u.tokenSource.NextToken())
+	}
+
+	return n
+}
+
+func (u *UnbufferedTokenStream) add(t Token) {
+	if u.n >= len(u.tokens) {
+		tokens := make([]Token, 2*len(u.tokens))
+		copy(tokens, u.tokens)
+		u.tokens = tokens
+	}
+
+	t.SetTokenIndex(u.getBufferStartIndex() + u.n)
+	u.tokens[u.n] = t
+	u.n++
+}
+
+// Mark returns a marker that can be passed to Release to release the
+// buffer. Seek is only possible to indexes between the oldest outstanding
+// marker and the current index. Markers must be released in the reverse
+// order they were returned.
+//
+// <p>The returned value is negative so that it cannot be confused with a
+// token index.</p>
+func (u *UnbufferedTokenStream) Mark() int {
+	if u.numMarkers == 0 {
+		u.lastTokenBufferStart = u.lastToken
+	}
+
+	mark := -u.numMarkers - 1
+	u.numMarkers++
+	return mark
+}
+
+// Release decrements the number of outstanding markers. When the last
+// marker is released, the tokens before the current index are dropped from
+// the buffer.
+//
+// @panics if marker is not the most recently returned outstanding marker.
+func (u *UnbufferedTokenStream) Release(marker int) {
+	expectedMark := -u.numMarkers
+	if marker != expectedMark {
+		panic("release() called with an invalid marker.")
+	}
+
+	u.numMarkers--
+	if u.numMarkers == 0 { // can we release buffer?
+		if u.p > 0 {
+			// Copy tokens[p]..tokens[n-1] to tokens[0]..tokens[(n-1)-p], reset ptrs
+			copy(u.tokens, u.tokens[u.p:u.n])
+			for i := u.n - u.p; i < u.n; i++ {
+				u.tokens[i] = nil // let the dropped tokens be collected
+			}
+			u.n = u.n - u.p
+			u.p = 0
+		}
+
+		u.lastTokenBufferStart = u.lastToken
+	}
+}
+
+func (u *UnbufferedTokenStream) Index() int {
+	return u.currentTokenIndex
+}
+
+// Seek moves the stream to index. Seeking forward reads ahead as needed;
+// seeking backward is only possible within the buffer, i.e. to indexes at
+// or after the oldest outstanding Mark.
+//
+// @panics if index is outside the buffer.
+func (u *UnbufferedTokenStream) Seek(index int) {
+	if index == u.currentTokenIndex {
+		return
+	}
+
+	if index > u.currentTokenIndex {
+		u.sync(index - u.currentTokenIndex)
+		index = intMin(index, u.getBufferStartIndex()+u.n-1)
+	}
+
+	bufferStartIndex := u.getBufferStartIndex()
+	i := index - bufferStartIndex
+	if index < 0 {
+		panic("cannot seek to negative index " + strconv.Itoa(index))
+	} else if i < 0 || i >= u.n {
+		panic("seek to index outside buffer: " + strconv.Itoa(index) + " not in " +
+			strconv.Itoa(bufferStartIndex) + ".." + strconv.Itoa(bufferStartIndex+u.n-1))
+	}
+
+	u.p = i
+	u.currentTokenIndex = index
+	if u.p == 0 {
+		u.lastToken = u.lastTokenBufferStart
+	} else {
+		u.lastToken = u.tokens[u.p-1]
+	}
+}
+
+// Size returns the number of tokens in the stream.
+//
+// @panics if EOF has not been reached yet, as the size is not known before
+// then.
+func (u *UnbufferedTokenStream) Size() int {
+	if u.n > 0 && u.tokens[u.n-1].GetTokenType() == TokenEOF {
+		return u.getBufferStartIndex() + u.n
+	}
+
+	panic("Unbuffered stream cannot know its size")
+}
+
+func (u *UnbufferedTokenStream) GetSourceName() string {
+	return u.tokenSource.GetSourceName()
+}
+
+// GetAllText returns the empty string, as the text of tokens that have
+// left the buffer is not available.
+func (u *UnbufferedTokenStream) GetAllText() string {
+	return ""
+}
+
+func (u *UnbufferedTokenStream) GetTextFromRuleContext(ctx RuleContext) string {
+	return u.GetTextFromInterval(ctx.GetSourceInterval())
+}
+
+func (u *UnbufferedTokenStream) GetTextFromTokens(start, stop Token) string {
+	if start == nil || stop == nil {
+		return ""
+	}
+
+	return u.GetTextFromInterval(NewInterval(start.GetTokenIndex(), stop.GetTokenIndex()))
+}
+
+// GetTextFromInterval returns the text of the tokens in interval, up to
+// but not including EOF.
+//
+// @panics if the interval is not within the buffer window.
+func (u *UnbufferedTokenStream) GetTextFromInterval(interval *Interval) string {
+	if interval == nil {
+		return u.GetAllText()
+	}
+
+	start := interval.Start
+	stop := interval.Stop
+	if start < 0 || stop < 0 {
+		return ""
+	}
+
+	bufferStartIndex := u.getBufferStartIndex()
+	bufferStopIndex := bufferStartIndex + u.n - 1
+	if start < bufferStartIndex || stop > bufferStopIndex {
+		panic("interval " + strconv.Itoa(start) + ".." + strconv.Itoa(stop) + " not in token buffer window: " +
+			strconv.Itoa(bufferStartIndex) + ".." + strconv.Itoa(bufferStopIndex))
+	}
+
+	s := ""
+	for i := start - bufferStartIndex; i <= stop-bufferStartIndex; i++ {
+		t := u.tokens[i]
+		if t.GetTokenType() == TokenEOF {
+			break
+		}
+
+		s += t.GetText()
+	}
+
+	return s
+}
+
+func (u *UnbufferedTokenStream) getBufferStartIndex() int {
+	return u.currentTokenIndex - u.p
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnbufferedTokenStreamLookahead(t *testing.T) {
+	lexer := newExprLexer(NewInputStream("x = 302;"))
+	tokens := NewUnbufferedTokenStreamSize(lexer, 1)
+
+	if got := tokens.LT(1).GetText(); got != "x" {
+		t.Errorf("expected x, got %s", got)
+	}
+	if got := tokens.LT(3).GetText(); got != "302" {
+		t.Errorf("expected 302, got %s", got)
+	}
+
+	m := tokens.Mark()
+	tokens.Consume() // x
+	tokens.Consume() // =
+	if got := tokens.LT(-1).GetText(); got != "=" {
+		t.Errorf("expected LT(-1) =, got %s", got)
+	}
+	if got := tokens.GetTextFromInterval(NewInterval(0, 2)); got != "x=302" {
+		t.Errorf("expected x=302, got %s", got)
+	}
+
+	tokens.Seek(0)
+	if got := tokens.LT(1).GetText(); got != "x" {
+		t.Errorf("expected x after seek, got %s", got)
+	}
+	if tokens.LT(-1) != nil {
+		t.Errorf("expected no LT(-1) at the start, got %v", tokens.LT(-1))
+	}
+	tokens.Seek(2)
+	tokens.Release(m)
+
+	// releasing the marker drops x and = from the buffer
+	if got := tokens.Get(2).GetText(); got != "302" {
+		t.Errorf("expected 302, got %s", got)
+	}
+	if got := tokens.LT(-1).GetText(); got != "=" {
+		t.Errorf("expected LT(-1) =, got %s", got)
+	}
+	expectPanic(t, "get(1) outside buffer: 2..2", func() { tokens.Get(1) })
+	expectPanic(t, "interval 0..2 not in token buffer window: 2..2", func() { tokens.GetTextFromInterval(NewInterval(0, 2)) })
+	expectPanic(t, "seek to index outside buffer: 0 not in 2..2", func() { tokens.Seek(0) })
+	expectPanic(t, "Unbuffered stream cannot know its size", func() { tokens.Size() })
+
+	for tokens.LA(1) != TokenEOF {
+		tokens.Consume()
+	}
+	if got := tokens.Size(); got != 5 {
+		t.Errorf("expected 5 tokens, got %d", got)
+	}
+	expectPanic(t, "cannot consume EOF", func() { tokens.Consume() })
+}
+
+func TestUnbufferedTokenStreamParse(t *testing.T) {
+	text := strings.Repeat("x = 1 + 2 * (y + 3);\n", 500)
+	lexer := newExprLexer(NewUnbufferedCharStream(strings.NewReader(text)))
+	lexer.SetTokenFactory(NewCommonTokenFactory(true))
+	tokens := NewUnbufferedTokenStreamSize(lexer, 4)
+
+	parser := NewParserInterpreter("Expr.g4", exprLiteralNames, exprSymbolicNames, exprParserRuleNames, exprParserATN, tokens)
+	tree := parser.Parse(ExprParserRULE_prog)
+
+	if got := tree.GetChildCount(); got != 501 {
+		t.Errorf("expected 500 statements and EOF, got %d children", got)
+	}
+	if got := parser._SyntaxErrors; got != 0 {
+		t.Errorf("expected no syntax errors, got %d", got)
+	}
+	// the buffer only grows to hold the lookahead of a single decision
+	if got := len(tokens.tokens); got > 16 {
+		t.Errorf("expected a small token buffer, got %d", got)
+	}
+}
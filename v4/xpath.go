@@ -0,0 +1,475 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// XPath represents a path into a parse tree, used to select the subtrees
+// of interest.
+//
+// <p>Split path into words and separators {@code /} and {@code //} via
+// lexing, then walk the tree to find all nodes that match.</p>
+//
+// <p>Whitespace is not allowed.</p>
+//
+// <ul>
+// <li>{@code /prog}: Root node is a {@code prog}</li>
+// <li>{@code /prog/stat}: All {@code stat} children of the root</li>
+// <li>{@code //ID}: All {@code ID} tokens anywhere in the tree</li>
+// <li>{@code //expr/primary/ID}: Any {@code ID} child of a {@code primary}
+// child of an {@code expr}</li>
+// <li>{@code //body//ID}: Any {@code ID} anywhere below a {@code body}</li>
+// <li>{@code //'return'}: Any {@code 'return'} literal token</li>
+// <li>{@code //primary/*}: All children of {@code primary} nodes</li>
+// <li>{@code /prog/func/'def'}: Literal {@code 'def'} of a {@code func}</li>
+// <li>{@code //func/!ID}: All children of {@code func} that aren't
+// {@code ID} tokens</li>
+// </ul>
+//
+// <p>Anywhere ({@code //}) finds all descendants of the current node,
+// including the node itself, that match the next element. Root ({@code /})
+// finds matching children of the current node. Elements may be inverted
+// with {@code !}.</p>
+type XPath struct {
+	path     string
+	elements []XPathElement
+	recog    Recognizer
+}
+
+const (
+	XPathWildcard = "*" // word not operator/separator
+	XPathNot      = "!" // word for invert operator
+)
+
+// NewXPath splits path into elements, resolving rule and token names
+// against the rule names and vocabulary of recog.
+//
+// @panics if path is malformed or refers to an unknown rule or token.
+func NewXPath(recog Recognizer, path string) *XPath {
+	x := &XPath{path: path, recog: recog}
+	x.elements = x.split(path)
+	return x
+}
+
+// XPathFindAll returns all nodes of tree matched by xpath, in the order they
+// were found.
+func XPathFindAll(tree ParseTree, xpath string, recog Recognizer) []ParseTree {
+	return NewXPath(recog, xpath).Evaluate(tree)
+}
+
+// GetPath returns the path the XPath was created from.
+func (x *XPath) GetPath() string {
+	return x.path
+}
+
+// GetElements returns the elements of the path, in the order they are
+// evaluated.
+func (x *XPath) GetElements() []XPathElement {
+	return x.elements
+}
+
+// Evaluate returns a slice of all nodes matching the path in tree. The
+// first element of the path is matched against t itself.
+func (x *XPath) Evaluate(t ParseTree) []ParseTree {
+	dummyRoot := NewBaseParserRuleContext(nil, ATNStateInvalidStateNumber)
+	dummyRoot.children = []Tree{t} // don't set t's parent.
+
+	work := []ParseTree{dummyRoot}
+
+	for _, element := range x.elements {
+		next := make([]ParseTree, 0)
+		seen := make(map[ParseTree]bool)
+		for _, node := range work {
+			if node.GetChildCount() > 0 {
+				// only try to match next element if it has children
+				// e.g., //func/*/stat might have a token node for which
+				// we can't go looking for stat nodes.
+				for _, matching := range element.evaluate(node) {
+					// anywhere elements include the node itself, but the
+					// dummy root is not part of the tree.
+					if matching == ParseTree(dummyRoot) {
+						continue
+					}
+					if !seen[matching] {
+						seen[matching] = true
+						next = append(next, matching)
+					}
+				}
+			}
+		}
+		work = next
+	}
+
+	return work
+}
+
+// xpathToken is a word or separator of an XPath.
+type xpathToken struct {
+	ttype int
+	text  string
+	start int
+}
+
+const (
+	xpathTokenEOF = iota
+	xpathTokenRef
+	xpathRuleRef
+	xpathAnywhere
+	xpathRoot
+	xpathWildcard
+	xpathBang
+	xpathString
+)
+
+// tokenize splits path into words and separators.
+func (x *XPath) tokenize(path string) []xpathToken {
+	tokens := make([]xpathToken, 0)
+	i := 0
+	for i < len(path) {
+		start := i
+		r, size := utf8.DecodeRuneInString(path[i:])
+		switch {
+		case strings.HasPrefix(path[i:], "//"):
+			tokens = append(tokens, xpathToken{xpathAnywhere, "//", start})
+			i += 2
+
+		case r == '/':
+			tokens = append(tokens, xpathToken{xpathRoot, "/", start})
+			i++
+
+		case r == '*':
+			tokens = append(tokens, xpathToken{xpathWildcard, XPathWildcard, start})
+			i++
+
+		case r == '!':
+			tokens = append(tokens, xpathToken{xpathBang, XPathNot, start})
+			i++
+
+		case r == '\'':
+			end := strings.IndexRune(path[i+1:], '\'')
+			if end < 0 {
+				panic("Invalid tokens or characters at index " + strconv.Itoa(start) + " in path '" + path + "'")
+			}
+			i += end + 2
+			tokens = append(tokens, xpathToken{xpathString, path[start:i], start})
+
+		case xpathIsNameStartChar(r):
+			i += size
+			for i < len(path) {
+				r, size = utf8.DecodeRuneInString(path[i:])
+				if !xpathIsNameChar(r) {
+					break
+				}
+				i += size
+			}
+			word := path[start:i]
+			first, _ := utf8.DecodeRuneInString(word)
+			if unicode.IsUpper(first) {
+				tokens = append(tokens, xpathToken{xpathTokenRef, word, start})
+			} else {
+				tokens = append(tokens, xpathToken{xpathRuleRef, word, start})
+			}
+
+		default:
+			panic("Invalid tokens or characters at index " + strconv.Itoa(start) + " in path '" + path + "'")
+		}
+	}
+
+	return append(tokens, xpathToken{xpathTokenEOF, "<EOF>", len(path)})
+}
+
+func xpathIsNameStartChar(r rune) bool {
+	return unicode.IsLetter(r)
+}
+
+func xpathIsNameChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func (x *XPath) split(path string) []XPathElement {
+	tokens := x.tokenize(path)
+	elements := make([]XPathElement, 0)
+	n := len(tokens)
+	i := 0
+
+loop:
+	for i < n {
+		el := tokens[i]
+		switch el.ttype {
+		case xpathRoot, xpathAnywhere:
+			anywhere := el.ttype == xpathAnywhere
+			i++
+			next := tokens[i]
+			invert := next.ttype == xpathBang
+			if invert {
+				i++
+				next = tokens[i]
+			}
+			pathElement := x.getXPathElement(next, anywhere)
+			pathElement.setInvert(invert)
+			elements = append(elements, pathElement)
+			i++
+
+		case xpathTokenRef, xpathRuleRef, xpathWildcard:
+			elements = append(elements, x.getXPathElement(el, false))
+			i++
+
+		case xpathTokenEOF:
+			break loop
+
+		default:
+			panic("Unknown path element " + el.text)
+		}
+	}
+
+	return elements
+}
+
+// getXPathElement converts word, which is a token, rule or wildcard
+// reference, into the XPathElement that evaluates it. If anywhere is true
+// the element matches anywhere below the current node instead of among its
+// children.
+func (x *XPath) getXPathElement(wordToken xpathToken, anywhere bool) XPathElement {
+	if wordToken.ttype == xpathTokenEOF {
+		panic("Missing path element at end of path")
+	}
+
+	word := wordToken.text
+	switch wordToken.ttype {
+	case xpathWildcard:
+		if anywhere {
+			return NewXPathWildcardAnywhereElement()
+		}
+		return NewXPathWildcardElement()
+
+	case xpathTokenRef, xpathString:
+		ttype := x.recog.GetTokenType(word)
+		if ttype == TokenInvalidType {
+			panic(word + " at index " + strconv.Itoa(wordToken.start) + " isn't a valid token name")
+		}
+		if anywhere {
+			return NewXPathTokenAnywhereElement(word, ttype)
+		}
+		return NewXPathTokenElement(word, ttype)
+
+	case xpathRuleRef:
+		ruleIndex, ok := x.recog.GetRuleIndexMap()[word]
+		if !ok {
+			panic(word + " at index " + strconv.Itoa(wordToken.start) + " isn't a valid rule name")
+		}
+		if anywhere {
+			return NewXPathRuleAnywhereElement(word, ruleIndex)
+		}
+		return NewXPathRuleElement(word, ruleIndex)
+
+	default:
+		panic("Unknown path element " + word)
+	}
+}
+
+// XPathElement is a single step of an XPath.
+type XPathElement interface {
+	// evaluate returns the nodes matched by this element, given the node
+	// matched by the previous element.
+	evaluate(t ParseTree) []ParseTree
+	setInvert(bool)
+	String() string
+}
+
+type BaseXPathElement struct {
+	nodeName string
+	invert   bool
+}
+
+func NewBaseXPathElement(nodeName string) *BaseXPathElement {
+	return &BaseXPathElement{nodeName: nodeName}
+}
+
+func (b *BaseXPathElement) setInvert(invert bool) {
+	b.invert = invert
+}
+
+func (b *BaseXPathElement) string(kind string) string {
+	inv := ""
+	if b.invert {
+		inv = XPathNot
+	}
+
+	return kind + "[" + inv + b.nodeName + "]"
+}
+
+// XPathRuleElement matches children of the current node that are contexts
+// of a particular rule, or, when inverted, contexts of any other rule.
+type XPathRuleElement struct {
+	*BaseXPathElement
+	ruleIndex int
+}
+
+func NewXPathRuleElement(ruleName string, ruleIndex int) *XPathRuleElement {
+	return &XPathRuleElement{BaseXPathElement: NewBaseXPathElement(ruleName), ruleIndex: ruleIndex}
+}
+
+func (e *XPathRuleElement) evaluate(t ParseTree) []ParseTree {
+	// return all children of t that match nodeName
+	nodes := make([]ParseTree, 0)
+	for _, c := range TreesGetChildren(t) {
+		if ctx, ok := c.(ParserRuleContext); ok {
+			if (ctx.GetRuleIndex() == e.ruleIndex) != e.invert {
+				nodes = append(nodes, ctx)
+			}
+		}
+	}
+
+	return nodes
+}
+
+func (e *XPathRuleElement) String() string {
+	return e.string("XPathRuleElement")
+}
+
+// XPathRuleAnywhereElement matches the contexts of a particular rule at or
+// below the current node, or, when inverted, contexts of any other rule.
+type XPathRuleAnywhereElement struct {
+	*BaseXPathElement
+	ruleIndex int
+}
+
+func NewXPathRuleAnywhereElement(ruleName string, ruleIndex int) *XPathRuleAnywhereElement {
+	return &XPathRuleAnywhereElement{BaseXPathElement: NewBaseXPathElement(ruleName), ruleIndex: ruleIndex}
+}
+
+func (e *XPathRuleAnywhereElement) evaluate(t ParseTree) []ParseTree {
+	if !e.invert {
+		return TreesfindAllRuleNodes(t, e.ruleIndex)
+	}
+
+	nodes := make([]ParseTree, 0)
+	for _, d := range TreesDescendants(t) {
+		if ctx, ok := d.(ParserRuleContext); ok {
+			if ctx.GetRuleIndex() != e.ruleIndex {
+				nodes = append(nodes, ctx)
+			}
+		}
+	}
+
+	return nodes
+}
+
+func (e *XPathRuleAnywhereElement) String() string {
+	return e.string("XPathRuleAnywhereElement")
+}
+
+// XPathTokenElement matches children of the current node that are tokens
+// of a particular type, or, when inverted, tokens of any other type.
+type XPathTokenElement struct {
+	*BaseXPathElement
+	tokenType int
+}
+
+func NewXPathTokenElement(tokenName string, tokenType int) *XPathTokenElement {
+	return &XPathTokenElement{BaseXPathElement: NewBaseXPathElement(tokenName), tokenType: tokenType}
+}
+
+func (e *XPathTokenElement) evaluate(t ParseTree) []ParseTree {
+	// return all children of t that match nodeName
+	nodes := make([]ParseTree, 0)
+	for _, c := range TreesGetChildren(t) {
+		if tnode, ok := c.(TerminalNode); ok {
+			if (tnode.GetSymbol().GetTokenType() == e.tokenType) != e.invert {
+				nodes = append(nodes, tnode)
+			}
+		}
+	}
+
+	return nodes
+}
+
+func (e *XPathTokenElement) String() string {
+	return e.string("XPathTokenElement")
+}
+
+// XPathTokenAnywhereElement matches tokens of a particular type at or below
+// the current node, or, when inverted, tokens of any other type.
+type XPathTokenAnywhereElement struct {
+	*BaseXPathElement
+	tokenType int
+}
+
+func NewXPathTokenAnywhereElement(tokenName string, tokenType int) *XPathTokenAnywhereElement {
+	return &XPathTokenAnywhereElement{BaseXPathElement: NewBaseXPathElement(tokenName), tokenType: tokenType}
+}
+
+func (e *XPathTokenAnywhereElement) evaluate(t ParseTree) []ParseTree {
+	if !e.invert {
+		return TreesFindAllTokenNodes(t, e.tokenType)
+	}
+
+	nodes := make([]ParseTree, 0)
+	for _, d := range TreesDescendants(t) {
+		if tnode, ok := d.(TerminalNode); ok {
+			if tnode.GetSymbol().GetTokenType() != e.tokenType {
+				nodes = append(nodes, tnode)
+			}
+		}
+	}
+
+	return nodes
+}
+
+func (e *XPathTokenAnywhereElement) String() string {
+	return e.string("XPathTokenAnywhereElement")
+}
+
+// XPathWildcardElement matches every child of the current node.
+type XPathWildcardElement struct {
+	*BaseXPathElement
+}
+
+func NewXPathWildcardElement() *XPathWildcardElement {
+	return &XPathWildcardElement{BaseXPathElement: NewBaseXPathElement(XPathWildcard)}
+}
+
+func (e *XPathWildcardElement) evaluate(t ParseTree) []ParseTree {
+	kids := make([]ParseTree, 0)
+	if e.invert {
+		return kids // !* is weird but valid (empty)
+	}
+	for _, c := range TreesGetChildren(t) {
+		kids = append(kids, c.(ParseTree))
+	}
+
+	return kids
+}
+
+func (e *XPathWildcardElement) String() string {
+	return e.string("XPathWildcardElement")
+}
+
+// XPathWildcardAnywhereElement matches the current node and every node
+// below it.
+type XPathWildcardAnywhereElement struct {
+	*BaseXPathElement
+}
+
+func NewXPathWildcardAnywhereElement() *XPathWildcardAnywhereElement {
+	return &XPathWildcardAnywhereElement{BaseXPathElement: NewBaseXPathElement(XPathWildcard)}
+}
+
+func (e *XPathWildcardAnywhereElement) evaluate(t ParseTree) []ParseTree {
+	if e.invert {
+		return make([]ParseTree, 0) // !* is weird but valid (empty)
+	}
+
+	return TreesDescendants(t)
+}
+
+func (e *XPathWildcardAnywhereElement) String() string {
+	return e.string("XPathWildcardAnywhereElement")
+}
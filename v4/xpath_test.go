@@ -0,0 +1,107 @@
+// Copyright (c) 2012-2017 The ANTLR Project. All rights reserved.
+// Use of this file is governed by the BSD 3-clause license that
+// can be found in the LICENSE.txt file in the project root.
+
+package antlr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestXPathEvaluate(t *testing.T) {
+	parser := newExprRecognizer()
+	// x = 1 + 2 * y; (a);
+	tree := buildExprTree("(prog (stat x = (expr (expr 1) + (expr (expr 2) * (expr y))) ;) (stat (expr '(' (expr a) ')') ;) <EOF>)")
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/prog", "prog"},
+		{"/stat", ""},
+		{"//ID", "x y a"},
+		{"/prog/*/ID", "x"},
+		{"//stat/!ID", "= ; ;"},
+		{"//expr/'+'", "+"},
+		{"//expr//ID", "y a"},
+		{"//!expr", "prog stat stat"},
+		{"/prog/stat/!*", ""},
+		{"//stat/expr/'('", "("},
+		{"/prog/EOF", "<EOF>"},
+		{"//*/prog", ""},
+		{"//*/stat", "stat stat"},
+	}
+
+	for _, test := range tests {
+		nodes := XPathFindAll(tree, test.path, parser)
+		texts := make([]string, len(nodes))
+		for i, n := range nodes {
+			texts[i] = TreesGetNodeText(n, nil, parser)
+		}
+		if got := strings.Join(texts, " "); got != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.path, test.expected, got)
+		}
+	}
+}
+
+func TestXPathInvalidPath(t *testing.T) {
+	parser := newExprRecognizer()
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"//FOO", "FOO at index 2 isn't a valid token name"},
+		{"/prog/foo", "foo at index 6 isn't a valid rule name"},
+		{"//stat/", "Missing path element at end of path"},
+		{"//'x", "Invalid tokens or characters at index 2 in path '//'x'"},
+		{"/prog stat", "Invalid tokens or characters at index 5 in path '/prog stat'"},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r != test.expected {
+					t.Errorf("%s: expected panic %q, got %v", test.path, test.expected, r)
+				}
+			}()
+			NewXPath(parser, test.path)
+		}()
+	}
+}
+
+func TestTreesFindAllNodes(t *testing.T) {
+	// x = 1 + 2 * y; (a);
+	tree := buildExprTree("(prog (stat x = (expr (expr 1) + (expr (expr 2) * (expr y))) ;) (stat (expr '(' (expr a) ')') ;) <EOF>)")
+
+	if got := len(TreesFindAllTokenNodes(tree, 7)); got != 3 {
+		t.Errorf("expected 3 ID nodes, got %d", got)
+	}
+	if got := len(TreesfindAllRuleNodes(tree, ExprParserRULE_expr)); got != 7 {
+		t.Errorf("expected 7 expr nodes, got %d", got)
+	}
+}
+
+func TestParseTreePatternFindAll(t *testing.T) {
+	matcher := NewParseTreePatternMatcher(newExprLexer(nil), newExprRecognizer())
+	// x = 1 + 2 * y; (a); z = 3;
+	tree := buildExprTree("(prog (stat x = (expr (expr 1) + (expr (expr 2) * (expr y))) ;) (stat (expr '(' (expr a) ')') ;) (stat z = (expr 3) ;) <EOF>)")
+
+	pattern := NewParseTreePattern(matcher, "<ID> = <expr>;", ExprParserRULE_stat, buildExprTree("(stat <ID> = (expr <expr>) ;)"))
+	matches := pattern.FindAll(tree, "//stat")
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(matches))
+	}
+	for i, id := range []string{"x", "z"} {
+		if got := matches[i].Get("ID").GetText(); got != id {
+			t.Errorf("match %d: expected %s, got %s", i, id, got)
+		}
+	}
+
+	pattern = NewParseTreePattern(matcher, "<expr> * <expr>", ExprParserRULE_expr, buildExprTree("(expr (expr <expr>) * (expr <expr>))"))
+	matches = pattern.FindAll(tree, "//expr")
+	if len(matches) != 1 || matches[0].GetTree().GetText() != "2*y" {
+		t.Errorf("expected a single match of 2*y, got %v", matches)
+	}
+}